@@ -0,0 +1,82 @@
+// Package barcode validates the EAN and UPC barcodes printed on parts. They are all GTINs of 8, 12, 13 or 14 digits
+// whose last digit is a check digit over the others.
+package barcode
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	ErrLength     = errors.New("a barcode has 8, 12, 13 or 14 digits")
+	ErrCharacter  = errors.New("a barcode contains only digits")
+	ErrCheckDigit = errors.New("barcode check digit does not match")
+)
+
+// lengths are the lengths of EAN-8, UPC-A, EAN-13 and GTIN-14 barcodes
+var lengths = []int{8, 12, 13, 14}
+
+// Normalize removes the spaces and dashes people type into a barcode
+func Normalize(code string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(code))
+}
+
+// CheckDigit returns the check digit for the digits preceding it. Counting from the right, the digits are weighted 3
+// and 1 alternately, so the same number gives the same check digit whatever leading zeros it is written with.
+func CheckDigit(digits string) (byte, error) {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] < '0' || digits[i] > '9' {
+			return 0, ErrCharacter
+		}
+		value := int(digits[i] - '0')
+		if (len(digits)-i)%2 == 1 {
+			value *= 3
+		}
+		sum += value
+	}
+	return byte('0' + (10-sum%10)%10), nil
+}
+
+// Validate normalizes the barcode and checks its length and check digit
+func Validate(code string) (string, error) {
+	code = Normalize(code)
+	if !validLength(len(code)) {
+		return "", ErrLength
+	}
+	checkDigit, err := CheckDigit(code[:len(code)-1])
+	if err != nil {
+		return "", err
+	}
+	if code[len(code)-1] != checkDigit {
+		return "", ErrCheckDigit
+	}
+	return code, nil
+}
+
+// Equivalents returns the valid barcode written in every length that holds the same GTIN. A scanner reads the UPC-A
+// 036000291452 as the EAN-13 0036000291452, so lookups and duplicate checks have to match both.
+func Equivalents(code string) []string {
+	significant := strings.TrimLeft(code, "0")
+	equivalents := []string{}
+	for _, length := range lengths {
+		if length >= len(significant) {
+			equivalents = append(equivalents, strings.Repeat("0", length-len(significant))+significant)
+		}
+	}
+	return equivalents
+}
+
+func validLength(length int) bool {
+	for _, valid := range lengths {
+		if length == valid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	EnvironmentDevelopment = "development"
+	EnvironmentTest        = "test"
+	EnvironmentProduction  = "production"
+)
+
+// Config holds every setting the application reads at startup
+type Config struct {
+	Environment string         `yaml:"environment"`
+	Server      ServerConfig   `yaml:"server"`
+	Database    DatabaseConfig `yaml:"database"`
+	Auth        AuthConfig     `yaml:"auth"`
+	Payments    PaymentsConfig `yaml:"payments"`
+}
+
+type ServerConfig struct {
+	Address string `yaml:"address"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies whose X-Forwarded-For header is believed. The
+	// client address of requests from anywhere else is the address they came from, none are trusted by default.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+}
+
+type DatabaseConfig struct {
+	URI            string        `yaml:"uri"`
+	Name           string        `yaml:"name"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+type AuthConfig struct {
+	JWTSecret            string        `yaml:"jwt_secret"`
+	SessionSecret        string        `yaml:"session_secret"`
+	AccessTokenLifetime  time.Duration `yaml:"access_token_lifetime"`
+	RefreshTokenLifetime time.Duration `yaml:"refresh_token_lifetime"`
+}
+
+type PaymentsConfig struct {
+	// Gateway names the provider card payments are captured through, card payments are turned off without one
+	Gateway string `yaml:"gateway"`
+}
+
+// paymentGateways are the gateways PAYMENT_GATEWAY can name
+var paymentGateways = []string{"", "fake"}
+
+// minSecretLength is the shortest JWT or session secret accepted in production
+const minSecretLength = 32
+
+// Defaults returns the settings used for the given environment before any file or variable is applied
+func Defaults(environment string) Config {
+	cfg := Config{
+		Environment: environment,
+		Server: ServerConfig{
+			Address: "0.0.0.0:9090",
+		},
+		Database: DatabaseConfig{
+			Name:           "poosible_db",
+			ConnectTimeout: 10 * time.Second,
+		},
+		Auth: AuthConfig{
+			AccessTokenLifetime:  24 * time.Hour,
+			RefreshTokenLifetime: 30 * 24 * time.Hour,
+		},
+	}
+
+	switch environment {
+	case EnvironmentDevelopment:
+		cfg.Auth.JWTSecret = "development-jwt-secret"
+		cfg.Auth.SessionSecret = "development-session-secret"
+		cfg.Payments.Gateway = "fake"
+	case EnvironmentTest:
+		cfg.Database.Name = "poosible_test"
+		cfg.Auth.JWTSecret = "test-jwt-secret"
+		cfg.Auth.SessionSecret = "test-session-secret"
+		cfg.Payments.Gateway = "fake"
+	}
+
+	return cfg
+}
+
+// Load reads the configuration once from the optional .env file, the optional YAML file and the environment, in increasing order of precedence
+func Load() (*Config, error) {
+	//Values already present in the environment win over the .env file
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	environment := os.Getenv("APP_ENV")
+	if environment == "" {
+		environment = EnvironmentDevelopment
+	}
+	cfg := Defaults(environment)
+
+	path, required := os.Getenv("CONFIG_FILE"), true
+	if path == "" {
+		path, required = "config.yaml", false
+	}
+	if err := cfg.loadFile(path, required); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// loadFile overlays the YAML file at path, which may be missing unless required
+func (cfg *Config) loadFile(path string, required bool) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	if err := yaml.Unmarshal(content, cfg); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overlays the settings given as environment variables
+func (cfg *Config) loadEnv() error {
+	values := map[string]*string{
+		"APP_ENV":         &cfg.Environment,
+		"SERVER_ADDRESS":  &cfg.Server.Address,
+		"MONGOURI":        &cfg.Database.URI,
+		"MONGO_DATABASE":  &cfg.Database.Name,
+		"JWT_SECRET":      &cfg.Auth.JWTSecret,
+		"SESSION_SECRET":  &cfg.Auth.SessionSecret,
+		"PAYMENT_GATEWAY": &cfg.Payments.Gateway,
+	}
+	for key, target := range values {
+		if value, ok := os.LookupEnv(key); ok {
+			*target = value
+		}
+	}
+
+	if value, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.Server.TrustedProxies = nil
+		for _, proxy := range strings.Split(value, ",") {
+			if proxy = strings.TrimSpace(proxy); proxy != "" {
+				cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, proxy)
+			}
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"MONGO_CONNECT_TIMEOUT":  &cfg.Database.ConnectTimeout,
+		"ACCESS_TOKEN_LIFETIME":  &cfg.Auth.AccessTokenLifetime,
+		"REFRESH_TOKEN_LIFETIME": &cfg.Auth.RefreshTokenLifetime,
+	}
+	for key, target := range durations {
+		value, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		*target = duration
+	}
+	return nil
+}
+
+// Validate reports every missing or unsafe setting at once
+func (cfg *Config) Validate() error {
+	var problems []string
+
+	switch cfg.Environment {
+	case EnvironmentDevelopment, EnvironmentTest, EnvironmentProduction:
+	default:
+		problems = append(problems, fmt.Sprintf("APP_ENV must be one of %s, %s or %s", EnvironmentDevelopment, EnvironmentTest, EnvironmentProduction))
+	}
+
+	if cfg.Server.Address == "" {
+		problems = append(problems, "SERVER_ADDRESS is required")
+	}
+	for _, proxy := range cfg.Server.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			problems = append(problems, fmt.Sprintf("TRUSTED_PROXIES %q is not an IP address or CIDR range", proxy))
+		}
+	}
+	if cfg.Database.URI == "" {
+		problems = append(problems, "MONGOURI is required")
+	}
+	if cfg.Database.Name == "" {
+		problems = append(problems, "MONGO_DATABASE is required")
+	}
+	if cfg.Database.ConnectTimeout <= 0 {
+		problems = append(problems, "MONGO_CONNECT_TIMEOUT must be positive")
+	}
+
+	if cfg.Auth.JWTSecret == "" {
+		problems = append(problems, "JWT_SECRET is required")
+	}
+	if cfg.Auth.SessionSecret == "" {
+		problems = append(problems, "SESSION_SECRET is required")
+	}
+	if cfg.Environment == EnvironmentProduction {
+		if len(cfg.Auth.JWTSecret) < minSecretLength {
+			problems = append(problems, fmt.Sprintf("JWT_SECRET must be at least %d characters in production", minSecretLength))
+		}
+		if len(cfg.Auth.SessionSecret) < minSecretLength {
+			problems = append(problems, fmt.Sprintf("SESSION_SECRET must be at least %d characters in production", minSecretLength))
+		}
+	}
+	if cfg.Auth.AccessTokenLifetime <= 0 {
+		problems = append(problems, "ACCESS_TOKEN_LIFETIME must be positive")
+	}
+	if cfg.Auth.RefreshTokenLifetime < cfg.Auth.AccessTokenLifetime {
+		problems = append(problems, "REFRESH_TOKEN_LIFETIME must not be shorter than ACCESS_TOKEN_LIFETIME")
+	}
+
+	knownGateway := false
+	for _, gateway := range paymentGateways {
+		knownGateway = knownGateway || cfg.Payments.Gateway == gateway
+	}
+	if !knownGateway {
+		problems = append(problems, fmt.Sprintf("PAYMENT_GATEWAY %q is not a known payment gateway", cfg.Payments.Gateway))
+	}
+	if cfg.Environment == EnvironmentProduction && cfg.Payments.Gateway == "fake" {
+		problems = append(problems, "PAYMENT_GATEWAY must not be fake in production")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
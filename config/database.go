@@ -5,16 +5,16 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
-	"time"
 )
 
-func ConnectDatabase() *mongo.Client {
-	client, err := mongo.NewClient(options.Client().ApplyURI(Env("MONGOURI")))
+func ConnectDatabase(cfg DatabaseConfig) *mongo.Database {
+	client, err := mongo.NewClient(options.Client().ApplyURI(cfg.URI))
 	if err != nil {
 		log.Fatalln(err)
 	}
 
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
 	err = client.Connect(ctx)
 	if err != nil {
 		log.Fatalln(err)
@@ -27,11 +27,9 @@ func ConnectDatabase() *mongo.Client {
 	}
 
 	log.Println("Connected to MongoDB!")
-	return client
+	return client.Database(cfg.Name)
 }
 
-var Database *mongo.Client = ConnectDatabase()
-
-func GetCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	return client.Database("poosible_db").Collection(collectionName)
+func GetCollection(database *mongo.Database, collectionName string) *mongo.Collection {
+	return database.Collection(collectionName)
 }
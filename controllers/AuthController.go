@@ -2,53 +2,91 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
+	"poosible-backend/config"
 	"poosible-backend/models"
+	"poosible-backend/repositories"
 	"poosible-backend/responses"
+	"poosible-backend/utils"
+	"poosible-backend/validators"
 	"strings"
 	"time"
 )
 
-// generateToken generates a JWT token and stores it in the session
-func generateToken(user models.User, c *gin.Context) error {
+// generateToken generates a JWT token and a refresh token of the given family and stores them in the session
+func generateToken(ctx context.Context, cfg config.AuthConfig, refreshTokens repositories.RefreshTokenRepository, user models.User, familyID string, c *gin.Context) (*models.RefreshToken, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
 	claims["authorized"] = true
 	claims["user_id"] = user.ID.Hex()
-	expirationTime := time.Now().Add(time.Hour * 24) // Token will be valid for 24 hours
+	expirationTime := time.Now().Add(cfg.AccessTokenLifetime)
 	claims["exp"] = expirationTime.Unix()
-	refreshUUID, err := uuid.NewRandom()
+	tokenString, err := token.SignedString([]byte(cfg.JWTSecret))
 	if err != nil {
-		return err
+		return nil, err
 	}
-	refreshUUIDString := refreshUUID.String()
-	claims["refresh_uuid"] = refreshUUIDString
-	tokenString, err := token.SignedString([]byte("secret_key"))
+
+	refreshTokenString, refreshToken, err := issueRefreshToken(ctx, refreshTokens, user.ID, familyID, cfg.RefreshTokenLifetime)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	authSession := sessions.Default(c)
 	authSession.Set("authenticated", true)
 	authSession.Set("access_token", tokenString)
 	authSession.Set("access_token_expiry", expirationTime)
-	authSession.Set("refresh_token", refreshUUIDString)
+	authSession.Set("refresh_token", refreshTokenString)
+	authSession.Set("refresh_token_expiry", refreshToken.ExpiresAt)
 
 	// Save it before we write to the response/return from the handler.
 	err = authSession.Save()
 	if err != nil {
-		return err
+		return nil, err
+	}
+	return refreshToken, nil
+}
+
+// issueRefreshToken creates a new refresh token in the given family and persists its hash
+func issueRefreshToken(ctx context.Context, refreshTokens repositories.RefreshTokenRepository, userID primitive.ObjectID, familyID string, lifetime time.Duration) (string, *models.RefreshToken, error) {
+	refreshUUID, err := uuid.NewRandom()
+	if err != nil {
+		return "", nil, err
+	}
+	refreshTokenString := refreshUUID.String()
+
+	refreshToken := models.RefreshToken{
+		TokenHash: utils.HashRefreshToken(refreshTokenString),
+		FamilyID:  familyID,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(lifetime),
+		CreatedAt: time.Now(),
+	}
+
+	err = refreshTokens.Create(ctx, &refreshToken)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return refreshTokenString, &refreshToken, nil
+}
+
+// tokenResponseData returns the tokens stored in the session by generateToken
+func tokenResponseData(c *gin.Context) map[string]interface{} {
+	authSession := sessions.Default(c)
+
+	return map[string]interface{}{
+		"token":              authSession.Get("access_token"),
+		"expires_at":         authSession.Get("access_token_expiry"),
+		"refresh_token":      authSession.Get("refresh_token"),
+		"refresh_expires_at": authSession.Get("refresh_token_expiry"),
 	}
-	return nil
 }
 
 // Login godoc
@@ -63,9 +101,11 @@ func generateToken(user models.User, c *gin.Context) error {
 // @Failure 401 {object} responses.AuthResponse
 // @Failure 500 {object} responses.AuthResponse
 // @Router /api/auth/login [post]
-func Login() gin.HandlerFunc {
+func Login(cfg config.AuthConfig, users repositories.UserRepository, refreshTokens repositories.RefreshTokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		var credentials *models.Credentials
+		defer cancel()
 
 		//Validate the request body
 		if err := c.BindWith(&credentials, binding.JSON); err != nil {
@@ -80,12 +120,9 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
-		var user *models.User
-		err := userCollection.FindOne(context.Background(), bson.M{"email": credentials.Email}).Decode(&user)
-		fmt.Println(user.Password)
-		fmt.Println(credentials.Password)
+		user, err := users.FindByEmail(ctx, credentials.Email)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
+			if err == repositories.ErrNotFound {
 				c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid email or password", Data: map[string]interface{}{"data": nil}})
 				return
 			}
@@ -99,24 +136,128 @@ func Login() gin.HandlerFunc {
 			return
 		}
 
+		//Every login starts a new refresh token family
+		familyID, err := uuid.NewRandom()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error generating token", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
 		//Generate JWT token
-		err = generateToken(*user, c)
+		_, err = generateToken(ctx, cfg, refreshTokens, *user, familyID.String(), c)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error generating token", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		//Get token from session
-		authSession := sessions.Default(c)
+		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "Login successful", Data: map[string]interface{}{"data": tokenResponseData(c)}})
 
-		token := authSession.Get("access_token")
-		expiresAt := authSession.Get("access_token_expiry")
-		refreshToken := authSession.Get("refresh_token")
-		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "Login successful", Data: map[string]interface{}{"data": map[string]interface{}{"token": token, "expires_at": expiresAt, "refresh_token": refreshToken}}})
+	}
+}
+
+// Refresh godoc
+// @Summary Refresh tokens
+// @Description Exchanges a valid refresh token for a new access and refresh token pair. Reusing a refresh token revokes every token issued from the same login.
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param refresh body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} responses.AuthResponse
+// @Failure 400 {object} responses.AuthResponse
+// @Failure 401 {object} responses.AuthResponse
+// @Failure 500 {object} responses.AuthResponse
+// @Router /api/auth/refresh [post]
+func Refresh(cfg config.AuthConfig, users repositories.UserRepository, refreshTokens repositories.RefreshTokenRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var request *models.RefreshRequest
+		defer cancel()
 
+		//Validate the request body
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, responses.AuthResponse{Status: http.StatusBadRequest, Message: "Invalid request body", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		if err := validate.Struct(request); err != nil {
+			c.JSON(http.StatusBadRequest, responses.AuthResponse{Status: http.StatusBadRequest, Message: "Invalid input fields", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		storedToken, err := refreshTokens.FindByHash(ctx, utils.HashRefreshToken(request.RefreshToken))
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusUnauthorized, responses.AuthResponse{Status: http.StatusUnauthorized, Message: "Invalid refresh token", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error getting refresh token", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//A token that was already exchanged or revoked is being replayed, so the whole family is compromised
+		if storedToken.UsedAt != nil || storedToken.RevokedAt != nil {
+			rejectReusedRefreshToken(ctx, c, refreshTokens, storedToken.FamilyID)
+			return
+		}
+
+		if !validators.IsValidRefreshToken(storedToken) {
+			c.JSON(http.StatusUnauthorized, responses.AuthResponse{Status: http.StatusUnauthorized, Message: "Refresh token expired", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+
+		//Mark the token as used, only one of two concurrent requests with the same token may succeed
+		marked, err := refreshTokens.MarkUsed(ctx, storedToken.ID, time.Now())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error updating refresh token", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if !marked {
+			rejectReusedRefreshToken(ctx, c, refreshTokens, storedToken.FamilyID)
+			return
+		}
+
+		user, err := users.FindByID(ctx, storedToken.UserID)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				_ = refreshTokens.RevokeFamily(ctx, storedToken.FamilyID, time.Now())
+				c.JSON(http.StatusUnauthorized, responses.AuthResponse{Status: http.StatusUnauthorized, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Generate JWT token in the same family
+		newToken, err := generateToken(ctx, cfg, refreshTokens, *user, storedToken.FamilyID, c)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error generating token", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		err = refreshTokens.SetReplacedBy(ctx, storedToken.ID, newToken.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error updating refresh token", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.AuthResponse{Status: http.StatusOK, Message: "Token refreshed", Data: map[string]interface{}{"data": tokenResponseData(c)}})
 	}
 }
 
+// rejectReusedRefreshToken revokes the token family and clears the session after a refresh token was replayed
+func rejectReusedRefreshToken(ctx context.Context, c *gin.Context, refreshTokens repositories.RefreshTokenRepository, familyID string) {
+	if err := refreshTokens.RevokeFamily(ctx, familyID, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error revoking refresh tokens", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+
+	authSession := sessions.Default(c)
+	authSession.Clear()
+	_ = authSession.Save()
+
+	c.JSON(http.StatusUnauthorized, responses.AuthResponse{Status: http.StatusUnauthorized, Message: "Refresh token reuse detected", Data: map[string]interface{}{"data": nil}})
+}
+
 // Logout godoc
 // @Summary Logout a user
 // @Description Logs out a user and clears the session
@@ -128,10 +269,29 @@ func Login() gin.HandlerFunc {
 // @Failure 401 {object} responses.AuthResponse
 // @Failure 500 {object} responses.AuthResponse
 // @Router /api/auth/logout [post]
-func Logout() gin.HandlerFunc {
+func Logout(refreshTokens repositories.RefreshTokenRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		//delete session
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
 		authSessions := sessions.Default(c)
+
+		//revoke the refresh tokens of this login
+		if refreshToken, ok := authSessions.Get("refresh_token").(string); ok && refreshToken != "" {
+			storedToken, err := refreshTokens.FindByHash(ctx, utils.HashRefreshToken(refreshToken))
+			if err != nil && err != repositories.ErrNotFound {
+				c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error getting refresh token", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			if storedToken != nil {
+				if err := refreshTokens.RevokeFamily(ctx, storedToken.FamilyID, time.Now()); err != nil {
+					c.JSON(http.StatusInternalServerError, responses.AuthResponse{Status: http.StatusInternalServerError, Message: "Error revoking refresh tokens", Data: map[string]interface{}{"data": err.Error()}})
+					return
+				}
+			}
+		}
+
+		//delete session
 		authSessions.Clear()
 		err := authSessions.Save()
 		if err != nil {
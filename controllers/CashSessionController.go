@@ -0,0 +1,523 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// OpenCashSession godoc
+// @Summary Open a cash session
+// @Description Opens a till session of the user at a register with the float counted into the drawer. A user and a register can only have one open session each, cash can only be taken or refunded in one.
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Param session body models.CashSessionNew true "Cash session data"
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 400 {object} responses.CashSessionResponse
+// @Failure 409 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-session [post]
+// @Security BearerAuth
+func OpenCashSession(sessions repositories.CashSessionRepository, organisations repositories.OrganisationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.CashSessionNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, organisationId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.CashSessionResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		currency, err := money.CurrencyAlpha(organisation.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+			return
+		}
+		float, ok := cashAmount(c, *input.OpeningFloat, currency, true)
+		if !ok {
+			return
+		}
+
+		session := &models.CashSession{
+			Register:       strings.TrimSpace(input.Register),
+			UserID:         tenant.Get(c).User.ID,
+			Status:         models.CashSessionOpen,
+			Currency:       currency,
+			OpeningFloat:   float,
+			Movements:      []models.CashMovement{},
+			Notes:          strings.TrimSpace(input.Notes),
+			OpenedAt:       time.Now(),
+			OrganisationID: organisationId,
+		}
+		if err := sessions.Open(ctx, session); err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "Cash session opened", Data: map[string]interface{}{"data": session}})
+	}
+}
+
+// GetCurrentCashSession godoc
+// @Summary Get the open cash session
+// @Description Gets the open till session of the user
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 404 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-session/current [get]
+// @Security BearerAuth
+func GetCurrentCashSession(sessions repositories.CashSessionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		session, err := sessions.FindOpen(ctx, organisationId, tenant.Get(c).User.ID)
+		if err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "Cash session found", Data: map[string]interface{}{"data": session}})
+	}
+}
+
+// AddCashMovement godoc
+// @Summary Pay cash in or out
+// @Description Records cash put into or taken out of the drawer of an open session for anything but a payment, such as change brought from the bank or postage paid from the till. Only the user of the session can.
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Cash session ID"
+// @Param movement body models.CashMovementNew true "Cash movement data"
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 400 {object} responses.CashSessionResponse
+// @Failure 403 {object} responses.CashSessionResponse
+// @Failure 404 {object} responses.CashSessionResponse
+// @Failure 409 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-session/{sessionId}/movement [post]
+// @Security BearerAuth
+func AddCashMovement(sessions repositories.CashSessionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.CashMovementNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		session, ok := ownCashSession(ctx, c, sessions, organisationId, c.Param("sessionId"))
+		if !ok {
+			return
+		}
+		amount, ok := cashAmount(c, *input.Amount, session.Currency, false)
+		if !ok {
+			return
+		}
+
+		movement := models.CashMovement{
+			Type:      input.Type,
+			Amount:    amount,
+			Reason:    strings.TrimSpace(input.Reason),
+			UserID:    tenant.Get(c).User.ID,
+			CreatedAt: time.Now(),
+		}
+		session, err = sessions.AddMovement(ctx, organisationId, session.ID, movement)
+		if err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "Cash movement recorded", Data: map[string]interface{}{"data": session}})
+	}
+}
+
+// CloseCashSession godoc
+// @Summary Close a cash session
+// @Description Closes an open session with the cash counted in the drawer, which the user counts blind. The Z-report taken on closing is numbered from the organisation's Z-report sequence and shows the expected cash and the variance of the count. It never changes afterwards. Only the user of the session can close it.
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Cash session ID"
+// @Param close body models.CashSessionClose true "Counted cash"
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 400 {object} responses.CashSessionResponse
+// @Failure 403 {object} responses.CashSessionResponse
+// @Failure 404 {object} responses.CashSessionResponse
+// @Failure 409 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-session/{sessionId}/close [post]
+// @Security BearerAuth
+func CloseCashSession(sessions repositories.CashSessionRepository, payments repositories.PaymentRepository, invoices repositories.InvoiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var input *models.CashSessionClose
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		session, ok := ownCashSession(ctx, c, sessions, organisationId, c.Param("sessionId"))
+		if !ok {
+			return
+		}
+		counted, ok := cashAmount(c, *input.CountedCash, session.Currency, true)
+		if !ok {
+			return
+		}
+
+		closedAt := time.Now()
+		report, ok := cashSessionReport(ctx, c, payments, invoices, session, models.CashReportZ, closedAt)
+		if !ok {
+			return
+		}
+		if err := report.Count(counted); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Invalid counted cash", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		closedBy := tenant.Get(c).User.ID
+		session.ClosedAt = &closedAt
+		session.ClosedBy = &closedBy
+		if notes := strings.TrimSpace(input.Notes); notes != "" {
+			session.Notes = strings.TrimSpace(session.Notes + "\n" + notes)
+		}
+		if err := sessions.Close(ctx, session, report, models.DefaultZReportNumbering); err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "Cash session closed", Data: map[string]interface{}{"data": session}})
+	}
+}
+
+// GetCashSession godoc
+// @Summary Get a cash session
+// @Description Gets a till session of the organisation, closed sessions with their Z-report
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Cash session ID"
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 404 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-session/{sessionId} [get]
+// @Security BearerAuth
+func GetCashSession(sessions repositories.CashSessionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		sessionObjectId, _ := primitive.ObjectIDFromHex(c.Param("sessionId"))
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		session, err := sessions.FindByID(ctx, organisationId, sessionObjectId)
+		if err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "Cash session found", Data: map[string]interface{}{"data": session}})
+	}
+}
+
+// GetCashSessions godoc
+// @Summary Get cash sessions
+// @Description Gets a page of the till sessions of the organisation, newest first. They can also be sorted by opened_at or register, prefixed with "-" for descending order.
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Param user_id query string false "User ID"
+// @Param register query string false "Register"
+// @Param status query string false "open or closed"
+// @Param sort query string false "Sort field" default(-opened_at)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 400 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-sessions [get]
+// @Security BearerAuth
+func GetCashSessions(sessions repositories.CashSessionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.CashSessionSortFields, "-opened_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.CashSessionSortFields.Names()}})
+			return
+		}
+		filter := models.CashSessionFilter{Register: c.Query("register"), Status: c.Query("status")}
+		if filter.Status != "" && filter.Status != models.CashSessionOpen && filter.Status != models.CashSessionClosed {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Invalid status", Data: map[string]interface{}{"data": filter.Status}})
+			return
+		}
+		if userId := c.Query("user_id"); userId != "" {
+			userObjectId, err := primitive.ObjectIDFromHex(userId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Invalid user_id", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			filter.UserID = &userObjectId
+		}
+
+		result, paging, err := sessions.FindAll(ctx, organisationId, filter, page)
+		if err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "Cash sessions found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// GetCashSessionXReport godoc
+// @Summary Get the X-report of a cash session
+// @Description Sums up an open session so far: the cash the drawer should hold, the payments by tender method and the invoices the user issued by tax rate. Closed sessions have their Z-report instead.
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Cash session ID"
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 404 {object} responses.CashSessionResponse
+// @Failure 409 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-session/{sessionId}/x-report [get]
+// @Security BearerAuth
+func GetCashSessionXReport(sessions repositories.CashSessionRepository, payments repositories.PaymentRepository, invoices repositories.InvoiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		sessionObjectId, _ := primitive.ObjectIDFromHex(c.Param("sessionId"))
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		session, err := sessions.FindByID(ctx, organisationId, sessionObjectId)
+		if err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+		if session.Status != models.CashSessionOpen {
+			c.JSON(http.StatusConflict, responses.CashSessionResponse{Status: http.StatusConflict, Message: "Cash session is closed, its Z-report is final", Data: map[string]interface{}{"data": session.ZReport}})
+			return
+		}
+
+		report, ok := cashSessionReport(ctx, c, payments, invoices, session, models.CashReportX, time.Now())
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "X-report taken", Data: map[string]interface{}{"data": report}})
+	}
+}
+
+// GetCashSessionZReport godoc
+// @Summary Get the Z-report of a cash session
+// @Description Gets the Z-report taken when the session was closed
+// @Tags CashSession
+// @Accept json
+// @Produce json
+// @Param sessionId path string true "Cash session ID"
+// @Success 200 {object} responses.CashSessionResponse
+// @Failure 404 {object} responses.CashSessionResponse
+// @Failure 409 {object} responses.CashSessionResponse
+// @Failure 500 {object} responses.CashSessionResponse
+// @Router /api/cash-session/{sessionId}/z-report [get]
+// @Security BearerAuth
+func GetCashSessionZReport(sessions repositories.CashSessionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		sessionObjectId, _ := primitive.ObjectIDFromHex(c.Param("sessionId"))
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		session, err := sessions.FindByID(ctx, organisationId, sessionObjectId)
+		if err != nil {
+			respondCashSessionError(c, err)
+			return
+		}
+		if session.ZReport == nil {
+			c.JSON(http.StatusConflict, responses.CashSessionResponse{Status: http.StatusConflict, Message: "Cash session is open, it has no Z-report yet", Data: map[string]interface{}{"data": session.Status}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CashSessionResponse{Status: http.StatusOK, Message: "Z-report found", Data: map[string]interface{}{"data": session.ZReport}})
+	}
+}
+
+// ownCashSession returns the open session with the ID if it belongs to the user, or writes the error response
+func ownCashSession(ctx context.Context, c *gin.Context, sessions repositories.CashSessionRepository, organisationId primitive.ObjectID, sessionId string) (*models.CashSession, bool) {
+	sessionObjectId, _ := primitive.ObjectIDFromHex(sessionId)
+	session, err := sessions.FindByID(ctx, organisationId, sessionObjectId)
+	if err != nil {
+		respondCashSessionError(c, err)
+		return nil, false
+	}
+	if session.UserID != tenant.Get(c).User.ID {
+		c.JSON(http.StatusForbidden, responses.CashSessionResponse{Status: http.StatusForbidden, Message: "Only the user of a cash session can handle its cash", Data: map[string]interface{}{"data": session.ID}})
+		return nil, false
+	}
+	if session.Status != models.CashSessionOpen {
+		respondCashSessionError(c, repositories.ErrSessionClosed)
+		return nil, false
+	}
+	return session, true
+}
+
+// cashSessionReport sums up the session with the payments taken in it and the invoices its user issued until the
+// time, or writes the error response
+func cashSessionReport(ctx context.Context, c *gin.Context, payments repositories.PaymentRepository, invoices repositories.InvoiceRepository, session *models.CashSession, reportType string, at time.Time) (*models.CashReport, bool) {
+	taken, _, err := payments.FindAll(ctx, session.OrganisationID, models.PaymentFilter{CashSessionID: &session.ID}, listing.Query{Sort: "received_at"})
+	if err != nil {
+		respondPaymentError(c, err)
+		return nil, false
+	}
+	issued, _, err := invoices.FindAll(ctx, session.OrganisationID, models.InvoiceFilter{IssuedBy: &session.UserID, IssuedFrom: &session.OpenedAt, IssuedUntil: &at}, listing.Query{})
+	if err != nil {
+		respondInvoiceError(c, err)
+		return nil, false
+	}
+	report, err := session.Report(reportType, taken, issued, at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.CashSessionResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+		return nil, false
+	}
+	return report, true
+}
+
+// paymentCashSession returns the open session of the user the payment is taken in, which is nil if the user has none
+// and the tenders hold no cash, or writes the error response
+func paymentCashSession(ctx context.Context, c *gin.Context, sessions repositories.CashSessionRepository, organisationId primitive.ObjectID, tenders []models.PaymentTender) (*primitive.ObjectID, bool) {
+	session, err := sessions.FindOpen(ctx, organisationId, tenant.Get(c).User.ID)
+	if err == nil {
+		return &session.ID, true
+	}
+	if err != repositories.ErrNotFound {
+		respondPaymentError(c, err)
+		return nil, false
+	}
+	for _, tender := range tenders {
+		if tender.Method == models.PaymentCash {
+			c.JSON(http.StatusConflict, responses.PaymentResponse{Status: http.StatusConflict, Message: "Open a cash session to handle cash", Data: map[string]interface{}{"data": tender.Method}})
+			return nil, false
+		}
+	}
+	return nil, true
+}
+
+// cashAmount converts an amount of cash to the session currency, or writes the error response. Only counts may be
+// zero.
+func cashAmount(c *gin.Context, amount money.Money, currency string, count bool) (money.Money, bool) {
+	converted, err := amount.In(currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Invalid amount", Data: map[string]interface{}{"data": err.Error()}})
+		return converted, false
+	}
+	if converted.IsNegative() || (converted.IsZero() && !count) {
+		c.JSON(http.StatusBadRequest, responses.CashSessionResponse{Status: http.StatusBadRequest, Message: "Amounts must be positive", Data: map[string]interface{}{"data": converted}})
+		return converted, false
+	}
+	return converted, true
+}
+
+// respondCashSessionError writes the response for an error returned by the cash session repository
+func respondCashSessionError(c *gin.Context, err error) {
+	switch err {
+	case repositories.ErrNotFound:
+		c.JSON(http.StatusNotFound, responses.CashSessionResponse{Status: http.StatusNotFound, Message: "Cash session not found", Data: map[string]interface{}{"data": err.Error()}})
+	case repositories.ErrSessionOpen:
+		c.JSON(http.StatusConflict, responses.CashSessionResponse{Status: http.StatusConflict, Message: "User or register has an open cash session already", Data: map[string]interface{}{"data": err.Error()}})
+	case repositories.ErrSessionClosed:
+		c.JSON(http.StatusConflict, responses.CashSessionResponse{Status: http.StatusConflict, Message: "Cash session is closed", Data: map[string]interface{}{"data": err.Error()}})
+	default:
+		c.JSON(http.StatusInternalServerError, responses.CashSessionResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+	}
+}
@@ -0,0 +1,454 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+var errUnknownCategory = errors.New("category not found")
+
+// CreateCategory godoc
+// @Summary Create a category
+// @Description Creates a category of the organisation, at the top level or below a parent, after its existing siblings
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param category body models.CategoryNew true "Category data"
+// @Success 200 {object} responses.CategoryResponse
+// @Failure 400 {object} responses.CategoryResponse
+// @Failure 500 {object} responses.CategoryResponse
+// @Router /api/category [post]
+// @Security BearerAuth
+func CreateCategory(categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.CategoryNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		all, err := categories.FindAll(ctx, organisationId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		parent, ok := categoryParent(c, all, input.ParentID)
+		if !ok {
+			return
+		}
+
+		newCategory := models.Category{
+			Name:           strings.TrimSpace(input.Name),
+			Ancestors:      []primitive.ObjectID{},
+			OrganisationID: organisationId,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+		if parent != nil {
+			newCategory.ParentID = &parent.ID
+			newCategory.Ancestors = append(append(newCategory.Ancestors, parent.Ancestors...), parent.ID)
+		}
+		siblings := categorySiblings(all, &newCategory)
+		if !uniqueCategoryName(c, siblings, newCategory.Name) {
+			return
+		}
+		newCategory.Position = len(siblings)
+
+		err = categories.Create(ctx, &newCategory)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CategoryResponse{Status: http.StatusOK, Message: "Category created", Data: map[string]interface{}{"data": newCategory}})
+	}
+}
+
+// GetCategory godoc
+// @Summary Get a category
+// @Description Gets a category of the organisation with the categories below it
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param categoryId path string true "Category ID"
+// @Success 200 {object} responses.CategoryResponse
+// @Failure 400 {object} responses.CategoryResponse
+// @Failure 404 {object} responses.CategoryResponse
+// @Failure 500 {object} responses.CategoryResponse
+// @Router /api/category/{categoryId} [get]
+// @Security BearerAuth
+func GetCategory(categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		categoryId := c.Param("categoryId")
+		categoryObjectId, _ := primitive.ObjectIDFromHex(categoryId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		subtree, err := categories.FindSubtree(ctx, organisationId, categoryObjectId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+
+		//The category is the root of its subtree once its own parent is left out
+		root := *subtree[0]
+		root.ParentID = nil
+		subtree[0] = &root
+		c.JSON(http.StatusOK, responses.CategoryResponse{Status: http.StatusOK, Message: "Category found", Data: map[string]interface{}{"data": models.CategoryTree(subtree)[0]}})
+	}
+}
+
+// GetCategories godoc
+// @Summary Get the category tree
+// @Description Gets the categories of the organisation as trees, siblings in their order
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.CategoryResponse
+// @Failure 400 {object} responses.CategoryResponse
+// @Failure 500 {object} responses.CategoryResponse
+// @Router /api/categories [get]
+// @Security BearerAuth
+func GetCategories(categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		result, err := categories.FindAll(ctx, organisationId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CategoryResponse{Status: http.StatusOK, Message: "Categories found", Data: map[string]interface{}{"data": models.CategoryTree(result)}})
+	}
+}
+
+// UpdateCategory godoc
+// @Summary Rename a category
+// @Description Renames a category of the organisation, it stays where it is in the tree
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param categoryId path string true "Category ID"
+// @Param category body models.CategoryUpdate true "Category data"
+// @Success 200 {object} responses.CategoryResponse
+// @Failure 400 {object} responses.CategoryResponse
+// @Failure 404 {object} responses.CategoryResponse
+// @Failure 500 {object} responses.CategoryResponse
+// @Router /api/category/{categoryId} [put]
+// @Security BearerAuth
+func UpdateCategory(categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		categoryId := c.Param("categoryId")
+		categoryObjectId, _ := primitive.ObjectIDFromHex(categoryId)
+		var input *models.CategoryUpdate
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		all, err := categories.FindAll(ctx, organisationId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		category := findCategory(all, categoryObjectId)
+		if category == nil {
+			respondCategoryError(c, repositories.ErrNotFound)
+			return
+		}
+		category.Name = strings.TrimSpace(input.Name)
+		if !uniqueCategoryName(c, categorySiblings(all, category), category.Name) {
+			return
+		}
+		category.UpdatedAt = time.Now()
+
+		err = categories.Update(ctx, category)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CategoryResponse{Status: http.StatusOK, Message: "Category updated", Data: map[string]interface{}{"data": category}})
+	}
+}
+
+// MoveCategory godoc
+// @Summary Move a category
+// @Description Moves a category with everything below it under another parent or to the top level, or reorders it among its siblings. A category cannot be moved below itself.
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param categoryId path string true "Category ID"
+// @Param move body models.CategoryMove true "New parent and position"
+// @Success 200 {object} responses.CategoryResponse
+// @Failure 400 {object} responses.CategoryResponse
+// @Failure 404 {object} responses.CategoryResponse
+// @Failure 500 {object} responses.CategoryResponse
+// @Router /api/category/{categoryId}/move [post]
+// @Security BearerAuth
+func MoveCategory(categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		categoryId := c.Param("categoryId")
+		categoryObjectId, _ := primitive.ObjectIDFromHex(categoryId)
+		var input *models.CategoryMove
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		all, err := categories.FindAll(ctx, organisationId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		category := findCategory(all, categoryObjectId)
+		if category == nil {
+			respondCategoryError(c, repositories.ErrNotFound)
+			return
+		}
+		parent, ok := categoryParent(c, all, input.ParentID)
+		if !ok {
+			return
+		}
+
+		//Check if the category would end up in its own subtree
+		if parent != nil && (parent.ID == category.ID || parent.IsDescendantOf(category.ID)) {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "A category cannot be moved below itself", Data: map[string]interface{}{"data": input.ParentID}})
+			return
+		}
+
+		category.ParentID = nil
+		category.Ancestors = []primitive.ObjectID{}
+		if parent != nil {
+			category.ParentID = &parent.ID
+			category.Ancestors = append(append(category.Ancestors, parent.Ancestors...), parent.ID)
+		}
+		siblings := categorySiblings(all, category)
+		if !uniqueCategoryName(c, siblings, category.Name) {
+			return
+		}
+
+		position := input.Position
+		if position > len(siblings) {
+			position = len(siblings)
+		}
+		order := make([]primitive.ObjectID, 0, len(siblings)+1)
+		for _, sibling := range siblings[:position] {
+			order = append(order, sibling.ID)
+		}
+		order = append(order, category.ID)
+		for _, sibling := range siblings[position:] {
+			order = append(order, sibling.ID)
+		}
+		category.UpdatedAt = time.Now()
+
+		err = categories.Move(ctx, category, order)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CategoryResponse{Status: http.StatusOK, Message: "Category moved", Data: map[string]interface{}{"data": category}})
+	}
+}
+
+// DeleteCategory godoc
+// @Summary Delete a category
+// @Description Deletes a category of the organisation that has no categories below it and no items in it
+// @Tags Category
+// @Accept json
+// @Produce json
+// @Param categoryId path string true "Category ID"
+// @Success 200 {object} responses.CategoryResponse
+// @Failure 400 {object} responses.CategoryResponse
+// @Failure 404 {object} responses.CategoryResponse
+// @Failure 409 {object} responses.CategoryResponse
+// @Failure 500 {object} responses.CategoryResponse
+// @Router /api/category/{categoryId} [delete]
+// @Security BearerAuth
+func DeleteCategory(categories repositories.CategoryRepository, items repositories.ItemRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		categoryId := c.Param("categoryId")
+		categoryObjectId, _ := primitive.ObjectIDFromHex(categoryId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if categories are nested below it
+		subtree, err := categories.FindSubtree(ctx, organisationId, categoryObjectId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		if len(subtree) > 1 {
+			c.JSON(http.StatusConflict, responses.CategoryResponse{Status: http.StatusConflict, Message: "Category has subcategories", Data: map[string]interface{}{"data": categoryId}})
+			return
+		}
+
+		//Check if items are still listed in it
+		used, err := items.UsesCategory(ctx, organisationId, categoryObjectId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+		if used {
+			c.JSON(http.StatusConflict, responses.CategoryResponse{Status: http.StatusConflict, Message: "Category has items", Data: map[string]interface{}{"data": categoryId}})
+			return
+		}
+
+		err = categories.Delete(ctx, organisationId, categoryObjectId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CategoryResponse{Status: http.StatusOK, Message: "Category deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
+
+// categoryID resolves the category an item is assigned to within the organisation, an empty input means none
+func categoryID(ctx context.Context, categories repositories.CategoryRepository, organisationId primitive.ObjectID, input string) (*primitive.ObjectID, error) {
+	if input == "" {
+		return nil, nil
+	}
+	categoryObjectId, _ := primitive.ObjectIDFromHex(input)
+	category, err := categories.FindByID(ctx, organisationId, categoryObjectId)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, errUnknownCategory
+		}
+		return nil, err
+	}
+	return &category.ID, nil
+}
+
+// categoryParent finds the parent among the categories, an empty input means the top level. It writes the error
+// response if the parent does not exist.
+func categoryParent(c *gin.Context, categories []*models.Category, input string) (*models.Category, bool) {
+	if input == "" {
+		return nil, true
+	}
+	parentObjectId, _ := primitive.ObjectIDFromHex(input)
+	parent := findCategory(categories, parentObjectId)
+	if parent == nil {
+		c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Parent category not found", Data: map[string]interface{}{"data": input}})
+		return nil, false
+	}
+	return parent, true
+}
+
+func findCategory(categories []*models.Category, id primitive.ObjectID) *models.Category {
+	for _, category := range categories {
+		if category.ID == id {
+			return category
+		}
+	}
+	return nil
+}
+
+// categorySiblings returns the other categories with the same parent as the category, in their order
+func categorySiblings(categories []*models.Category, category *models.Category) []*models.Category {
+	siblings := []*models.Category{}
+	for _, sibling := range categories {
+		if sibling.ID != category.ID && sibling.SameParent(category.ParentID) {
+			siblings = append(siblings, sibling)
+		}
+	}
+	return siblings
+}
+
+// uniqueCategoryName checks that none of the siblings has the name and writes the error response if one has
+func uniqueCategoryName(c *gin.Context, siblings []*models.Category, name string) bool {
+	for _, sibling := range siblings {
+		if strings.EqualFold(sibling.Name, name) {
+			c.JSON(http.StatusBadRequest, responses.CategoryResponse{Status: http.StatusBadRequest, Message: "Category name already exists", Data: map[string]interface{}{"data": sibling}})
+			return false
+		}
+	}
+	return true
+}
+
+// respondCategoryError writes the response for an error returned by the category repository
+func respondCategoryError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.CategoryResponse{Status: http.StatusNotFound, Message: "Category not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.CategoryResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
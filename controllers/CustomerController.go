@@ -0,0 +1,404 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// CreateCustomer godoc
+// @Summary Create a customer
+// @Description Creates a customer of the organisation
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customer body models.CustomerNew true "Customer data"
+// @Success 200 {object} responses.CustomerResponse
+// @Failure 400 {object} responses.CustomerResponse
+// @Failure 500 {object} responses.CustomerResponse
+// @Router /api/customer [post]
+// @Security BearerAuth
+func CreateCustomer(customers repositories.CustomerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.CustomerNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		newCustomer := models.Customer{
+			OrganisationID: organisationId,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+		newCustomer.Apply(input)
+
+		err = customers.Create(ctx, &newCustomer)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.CustomerResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CustomerResponse{Status: http.StatusOK, Message: "Customer created", Data: map[string]interface{}{"data": newCustomer}})
+	}
+}
+
+// GetCustomer godoc
+// @Summary Get a customer
+// @Description Gets a customer of the organisation
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path string true "Customer ID"
+// @Success 200 {object} responses.CustomerResponse
+// @Failure 404 {object} responses.CustomerResponse
+// @Failure 500 {object} responses.CustomerResponse
+// @Router /api/customer/{customerId} [get]
+// @Security BearerAuth
+func GetCustomer(customers repositories.CustomerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		customerId := c.Param("customerId")
+		customerObjectId, _ := primitive.ObjectIDFromHex(customerId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		customer, err := customers.FindByID(ctx, organisationId, customerObjectId)
+		if err != nil {
+			respondCustomerError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CustomerResponse{Status: http.StatusOK, Message: "Customer found", Data: map[string]interface{}{"data": customer}})
+	}
+}
+
+// GetCustomers godoc
+// @Summary Get customers
+// @Description Gets a page of the customers of the organisation, optionally only those whose name, email or phone number matches q. They are sorted by name, created_at or updated_at, prefixed with "-" for descending order.
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param q query string false "Name, email or phone number to search for"
+// @Param sort query string false "Sort field" default(name)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.CustomerResponse
+// @Failure 400 {object} responses.CustomerResponse
+// @Failure 500 {object} responses.CustomerResponse
+// @Router /api/customers [get]
+// @Security BearerAuth
+func GetCustomers(customers repositories.CustomerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		query := strings.TrimSpace(c.Query("q"))
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.CustomerSortFields, "name")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.CustomerSortFields.Names()}})
+			return
+		}
+
+		var result []*models.Customer
+		var paging listing.Page
+		if query == "" {
+			result, paging, err = customers.FindAll(ctx, organisationId, page)
+		} else {
+			result, paging, err = customers.Search(ctx, organisationId, query, page)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.CustomerResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CustomerResponse{Status: http.StatusOK, Message: "Customers found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// UpdateCustomer godoc
+// @Summary Update a customer
+// @Description Replaces the details of a customer of the organisation
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path string true "Customer ID"
+// @Param customer body models.CustomerNew true "Customer data"
+// @Success 200 {object} responses.CustomerResponse
+// @Failure 400 {object} responses.CustomerResponse
+// @Failure 404 {object} responses.CustomerResponse
+// @Failure 500 {object} responses.CustomerResponse
+// @Router /api/customer/{customerId} [put]
+// @Security BearerAuth
+func UpdateCustomer(customers repositories.CustomerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		customerId := c.Param("customerId")
+		customerObjectId, _ := primitive.ObjectIDFromHex(customerId)
+		var input *models.CustomerNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		customer, err := customers.FindByID(ctx, organisationId, customerObjectId)
+		if err != nil {
+			respondCustomerError(c, err)
+			return
+		}
+
+		customer.Apply(input)
+		customer.UpdatedAt = time.Now()
+		err = customers.Update(ctx, customer)
+		if err != nil {
+			respondCustomerError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CustomerResponse{Status: http.StatusOK, Message: "Customer updated", Data: map[string]interface{}{"data": customer}})
+	}
+}
+
+// DeleteCustomer godoc
+// @Summary Delete a customer
+// @Description Deletes a customer of the organisation. Customers that vehicles, work orders, estimates, invoices or payments still refer to cannot be deleted, merge them into another customer instead.
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path string true "Customer ID"
+// @Success 200 {object} responses.CustomerResponse
+// @Failure 404 {object} responses.CustomerResponse
+// @Failure 409 {object} responses.CustomerResponse
+// @Failure 500 {object} responses.CustomerResponse
+// @Router /api/customer/{customerId} [delete]
+// @Security BearerAuth
+func DeleteCustomer(customers repositories.CustomerRepository, vehicles repositories.VehicleRepository, workOrders repositories.WorkOrderRepository, estimates repositories.EstimateRepository, invoices repositories.InvoiceRepository, payments repositories.PaymentRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		customerId := c.Param("customerId")
+		customerObjectId, _ := primitive.ObjectIDFromHex(customerId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if anything still refers to the customer
+		references := []struct {
+			repository repositories.CustomerReassigner
+			message    string
+		}{
+			{vehicles, "Customer has vehicles"},
+			{workOrders, "Customer has work orders"},
+			{estimates, "Customer has estimates"},
+			{invoices, "Customer has invoices"},
+			{payments, "Customer has payments"},
+		}
+		for _, reference := range references {
+			used, err := reference.repository.UsesCustomer(ctx, organisationId, customerObjectId)
+			if err != nil {
+				respondCustomerError(c, err)
+				return
+			}
+			if used {
+				c.JSON(http.StatusConflict, responses.CustomerResponse{Status: http.StatusConflict, Message: reference.message, Data: map[string]interface{}{"data": customerId}})
+				return
+			}
+		}
+
+		err = customers.Delete(ctx, organisationId, customerObjectId)
+		if err != nil {
+			respondCustomerError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.CustomerResponse{Status: http.StatusOK, Message: "Customer deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
+
+// MergeCustomers godoc
+// @Summary Merge duplicate customers
+// @Description Merges the given duplicate customers into the customer in the path. Empty details of the kept customer are filled from the duplicates, notes are combined, references to the duplicates are moved to the kept customer and the duplicates are deleted.
+// @Tags Customer
+// @Accept json
+// @Produce json
+// @Param customerId path string true "ID of the customer to keep"
+// @Param duplicates body models.CustomerMerge true "IDs of the duplicate customers"
+// @Success 200 {object} responses.CustomerResponse
+// @Failure 400 {object} responses.CustomerResponse
+// @Failure 404 {object} responses.CustomerResponse
+// @Failure 500 {object} responses.CustomerResponse
+// @Router /api/customer/{customerId}/merge [post]
+// @Security BearerAuth
+func MergeCustomers(customers repositories.CustomerRepository, reassigners ...repositories.CustomerReassigner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		customerId := c.Param("customerId")
+		customerObjectId, _ := primitive.ObjectIDFromHex(customerId)
+		var input *models.CustomerMerge
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		customer, err := customers.FindByID(ctx, organisationId, customerObjectId)
+		if err != nil {
+			respondCustomerError(c, err)
+			return
+		}
+
+		//Load every duplicate before changing anything
+		var duplicates []*models.Customer
+		var duplicateIds []primitive.ObjectID
+		for _, id := range input.CustomerIDs {
+			duplicateObjectId, err := primitive.ObjectIDFromHex(id)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "Invalid customer ID", Data: map[string]interface{}{"data": id}})
+				return
+			}
+			if duplicateObjectId == customer.ID {
+				c.JSON(http.StatusBadRequest, responses.CustomerResponse{Status: http.StatusBadRequest, Message: "A customer cannot be merged into itself", Data: map[string]interface{}{"data": id}})
+				return
+			}
+			duplicate, err := customers.FindByID(ctx, organisationId, duplicateObjectId)
+			if err != nil {
+				respondCustomerError(c, err)
+				return
+			}
+			duplicates = append(duplicates, duplicate)
+			duplicateIds = append(duplicateIds, duplicate.ID)
+		}
+
+		for _, duplicate := range duplicates {
+			mergeCustomer(customer, duplicate)
+		}
+
+		//Move references first so nothing points at a deleted customer if a later step fails
+		for _, reassigner := range reassigners {
+			if err := reassigner.ReassignCustomer(ctx, organisationId, duplicateIds, customer.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, responses.CustomerResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+		}
+
+		customer.UpdatedAt = time.Now()
+		err = customers.Update(ctx, customer)
+		if err != nil {
+			respondCustomerError(c, err)
+			return
+		}
+
+		for _, id := range duplicateIds {
+			if err := customers.Delete(ctx, organisationId, id); err != nil && err != repositories.ErrNotFound {
+				c.JSON(http.StatusInternalServerError, responses.CustomerResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, responses.CustomerResponse{Status: http.StatusOK, Message: "Customers merged", Data: map[string]interface{}{"data": customer}})
+	}
+}
+
+// mergeCustomer fills the empty details of the customer from the duplicate and appends the duplicate's notes.
+// Marketing consent is never taken from the duplicate as it was given for a different record.
+func mergeCustomer(customer *models.Customer, duplicate *models.Customer) {
+	fill := func(target *string, value string) {
+		if *target == "" {
+			*target = value
+		}
+	}
+	fill(&customer.FirstName, duplicate.FirstName)
+	fill(&customer.LastName, duplicate.LastName)
+	fill(&customer.CompanyName, duplicate.CompanyName)
+	fill(&customer.Email, duplicate.Email)
+	fill(&customer.Phone, duplicate.Phone)
+	fill(&customer.TaxID, duplicate.TaxID)
+	if customer.BillingAddress == (models.Address{}) {
+		customer.BillingAddress = duplicate.BillingAddress
+	}
+
+	if duplicate.Notes != "" {
+		if customer.Notes == "" {
+			customer.Notes = duplicate.Notes
+		} else {
+			customer.Notes += "\n\n" + duplicate.Notes
+		}
+	}
+	customer.Refresh()
+}
+
+// respondCustomerError writes the response for an error returned by the customer repository
+func respondCustomerError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.CustomerResponse{Status: http.StatusNotFound, Message: "Customer not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.CustomerResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
@@ -0,0 +1,601 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"poosible-backend/utils"
+	"strings"
+	"time"
+)
+
+// publicEstimatePath is where the link of a shared estimate points, followed by its token
+const publicEstimatePath = "/v1/api/public/estimate/"
+
+// CreateEstimate godoc
+// @Summary Create an estimate
+// @Description Creates a draft estimate of parts and labor for a customer and optionally one of their vehicles. Its lines are checked and priced like those of a work order.
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param estimate body models.EstimateNew true "Estimate data"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 400 {object} responses.EstimateResponse
+// @Failure 404 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/estimate [post]
+// @Security BearerAuth
+func CreateEstimate(estimates repositories.EstimateRepository, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, vehicles repositories.VehicleRepository, items repositories.ItemRepository, users repositories.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.EstimateNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		newEstimate := models.Estimate{
+			Status:         models.EstimateDraft,
+			CreatedBy:      tenant.Get(c).User.ID,
+			OrganisationID: organisationId,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+		if !applyEstimate(ctx, c, organisations, customers, vehicles, items, users, &newEstimate, input) {
+			return
+		}
+
+		err = estimates.Create(ctx, &newEstimate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimate created", Data: map[string]interface{}{"data": newEstimate}})
+	}
+}
+
+// GetEstimate godoc
+// @Summary Get an estimate
+// @Description Gets an estimate of the organisation including the decisions and signature of the customer
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param estimateId path string true "Estimate ID"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 404 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/estimate/{estimateId} [get]
+// @Security BearerAuth
+func GetEstimate(estimates repositories.EstimateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		estimateId := c.Param("estimateId")
+		estimateObjectId, _ := primitive.ObjectIDFromHex(estimateId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		estimate, err := estimates.FindByID(ctx, organisationId, estimateObjectId)
+		if err != nil {
+			respondEstimateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimate found", Data: map[string]interface{}{"data": estimate}})
+	}
+}
+
+// GetEstimates godoc
+// @Summary Get estimates
+// @Description Gets a page of the estimates of the organisation, newest first. They can also be sorted by status, valid_until, created_at or updated_at, prefixed with "-" for descending order.
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param status query string false "Status"
+// @Param customer_id query string false "Customer ID"
+// @Param vehicle_id query string false "Vehicle ID"
+// @Param sort query string false "Sort field" default(-created_at)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 400 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/estimates [get]
+// @Security BearerAuth
+func GetEstimates(estimates repositories.EstimateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.EstimateSortFields, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.EstimateSortFields.Names()}})
+			return
+		}
+		filter := models.EstimateFilter{Status: models.EstimateStatus(c.Query("status"))}
+		if filter.Status != "" && !models.IsValidEstimateStatus(filter.Status) {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Invalid status", Data: map[string]interface{}{"data": filter.Status}})
+			return
+		}
+		for param, target := range map[string]**primitive.ObjectID{"customer_id": &filter.CustomerID, "vehicle_id": &filter.VehicleID} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Invalid " + param, Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			*target = &id
+		}
+
+		result, paging, err := estimates.FindAll(ctx, organisationId, filter, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimates found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// UpdateEstimate godoc
+// @Summary Update an estimate
+// @Description Replaces the customer, vehicle, complaints, lines, validity and notes of an estimate the customer has not answered yet. Changing a shared estimate turns it back into a draft and ends its link, so it has to be shared again.
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param estimateId path string true "Estimate ID"
+// @Param estimate body models.EstimateNew true "Estimate data"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 400 {object} responses.EstimateResponse
+// @Failure 404 {object} responses.EstimateResponse
+// @Failure 409 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/estimate/{estimateId} [put]
+// @Security BearerAuth
+func UpdateEstimate(estimates repositories.EstimateRepository, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, vehicles repositories.VehicleRepository, items repositories.ItemRepository, users repositories.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		estimateId := c.Param("estimateId")
+		estimateObjectId, _ := primitive.ObjectIDFromHex(estimateId)
+		var input *models.EstimateNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		estimate, err := estimates.FindByID(ctx, organisationId, estimateObjectId)
+		if err != nil {
+			respondEstimateError(c, err)
+			return
+		}
+
+		//Check if the customer has answered already
+		if estimate.Status != models.EstimateDraft && estimate.Status != models.EstimateSent {
+			c.JSON(http.StatusConflict, responses.EstimateResponse{Status: http.StatusConflict, Message: "Estimate can no longer be changed", Data: map[string]interface{}{"data": estimate.Status}})
+			return
+		}
+
+		status, shareTokenHash := estimate.Status, estimate.ShareTokenHash
+		if !applyEstimate(ctx, c, organisations, customers, vehicles, items, users, estimate, input) {
+			return
+		}
+		estimate.Status = models.EstimateDraft
+		estimate.ShareTokenHash = ""
+		estimate.SharedAt = nil
+		estimate.ShareExpiresAt = nil
+		estimate.UpdatedAt = time.Now()
+		err = estimates.Update(ctx, estimate, status, shareTokenHash)
+		if err != nil {
+			respondEstimateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimate updated", Data: map[string]interface{}{"data": estimate}})
+	}
+}
+
+// ShareEstimate godoc
+// @Summary Share an estimate
+// @Description Creates the link the customer opens to approve or decline the lines of an estimate and sign their answer. The token of the link is random, only returned here and stored hashed. The link stops working at expires_at, or when the estimate runs out without it. Sharing an estimate again ends its previous link.
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param estimateId path string true "Estimate ID"
+// @Param share body models.EstimateShareNew false "Link expiry"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 400 {object} responses.EstimateResponse
+// @Failure 404 {object} responses.EstimateResponse
+// @Failure 409 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/estimate/{estimateId}/share [post]
+// @Security BearerAuth
+func ShareEstimate(estimates repositories.EstimateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		estimateId := c.Param("estimateId")
+		estimateObjectId, _ := primitive.ObjectIDFromHex(estimateId)
+		input := &models.EstimateShareNew{}
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct, the body is optional
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(input); err != nil {
+				c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+		}
+
+		estimate, err := estimates.FindByID(ctx, organisationId, estimateObjectId)
+		if err != nil {
+			respondEstimateError(c, err)
+			return
+		}
+
+		//Check if the customer has answered already
+		if estimate.Status != models.EstimateDraft && estimate.Status != models.EstimateSent {
+			c.JSON(http.StatusConflict, responses.EstimateResponse{Status: http.StatusConflict, Message: "Estimate was already answered", Data: map[string]interface{}{"data": estimate.Status}})
+			return
+		}
+
+		now := time.Now()
+		if estimate.Expired(now) {
+			c.JSON(http.StatusConflict, responses.EstimateResponse{Status: http.StatusConflict, Message: "Estimate has expired", Data: map[string]interface{}{"data": estimate.ValidUntil}})
+			return
+		}
+		expiresAt := estimate.ValidUntil
+		if input.ExpiresAt != nil {
+			if !input.ExpiresAt.After(now) || input.ExpiresAt.After(estimate.ValidUntil) {
+				c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Link must expire after now and no later than the estimate", Data: map[string]interface{}{"data": input.ExpiresAt}})
+				return
+			}
+			expiresAt = *input.ExpiresAt
+		}
+
+		token, tokenHash, err := utils.NewLinkToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		status, shareTokenHash := estimate.Status, estimate.ShareTokenHash
+		estimate.Status = models.EstimateSent
+		estimate.ShareTokenHash = tokenHash
+		estimate.SharedAt = &now
+		estimate.ShareExpiresAt = &expiresAt
+		estimate.UpdatedAt = now
+		err = estimates.Update(ctx, estimate, status, shareTokenHash)
+		if err != nil {
+			respondEstimateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimate shared", Data: map[string]interface{}{"data": estimate, "token": token, "link": publicEstimatePath + token}})
+	}
+}
+
+// ConvertEstimate godoc
+// @Summary Convert an estimate into a work order
+// @Description Creates an approved work order with the lines the customer approved on the estimate. The signature of the customer is recorded in its status history. An estimate converts once.
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param estimateId path string true "Estimate ID"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 400 {object} responses.EstimateResponse
+// @Failure 404 {object} responses.EstimateResponse
+// @Failure 409 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/estimate/{estimateId}/work-order [post]
+// @Security BearerAuth
+func ConvertEstimate(estimates repositories.EstimateRepository, workOrders repositories.WorkOrderRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		estimateId := c.Param("estimateId")
+		estimateObjectId, _ := primitive.ObjectIDFromHex(estimateId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		estimate, err := estimates.FindByID(ctx, organisationId, estimateObjectId)
+		if err != nil {
+			respondEstimateError(c, err)
+			return
+		}
+
+		//Check if the customer approved any of it
+		if estimate.Status != models.EstimateApproved {
+			c.JSON(http.StatusConflict, responses.EstimateResponse{Status: http.StatusConflict, Message: "Only approved estimates convert into work orders", Data: map[string]interface{}{"data": estimate.Status}})
+			return
+		}
+
+		now := time.Now()
+		userId := tenant.Get(c).User.ID
+		comment := "Approved on the estimate"
+		if estimate.Signature != nil {
+			comment = "Approved on the estimate, signed by " + estimate.Signature.Name
+		}
+		newWorkOrder := models.WorkOrder{
+			CustomerID:    estimate.CustomerID,
+			VehicleID:     estimate.VehicleID,
+			Complaints:    estimate.Complaints,
+			Lines:         estimate.ApprovedLines(),
+			TechnicianIDs: []primitive.ObjectID{},
+			Notes:         estimate.Notes,
+			Status:        models.WorkOrderApproved,
+			History: []models.WorkOrderStatusChange{{
+				From:      models.WorkOrderEstimated,
+				To:        models.WorkOrderApproved,
+				Comment:   comment,
+				UserID:    userId,
+				ChangedAt: now,
+			}},
+			CreatedBy:      userId,
+			OrganisationID: organisationId,
+			UpdatedAt:      now,
+			CreatedAt:      now,
+		}
+		err = workOrders.Create(ctx, &newWorkOrder)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		estimate.Status = models.EstimateConverted
+		estimate.WorkOrderID = &newWorkOrder.ID
+		estimate.UpdatedAt = now
+		err = estimates.Update(ctx, estimate, models.EstimateApproved, estimate.ShareTokenHash)
+		if err != nil {
+			//Someone else converted it first, their work order is the one that stays
+			if err := workOrders.Delete(ctx, organisationId, newWorkOrder.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			respondEstimateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimate converted", Data: map[string]interface{}{"data": estimate, "work_order": newWorkOrder}})
+	}
+}
+
+// GetPublicEstimate godoc
+// @Summary Open a shared estimate
+// @Description Shows the customer the estimate behind a link, together with the shop that sent it. No login is needed, the token of the link is the credential.
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param token path string true "Token of the link"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 404 {object} responses.EstimateResponse
+// @Failure 410 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/public/estimate/{token} [get]
+func GetPublicEstimate(estimates repositories.EstimateRepository, organisations repositories.OrganisationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		estimate, ok := sharedEstimate(ctx, c, estimates, time.Now())
+		if !ok {
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, estimate.OrganisationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimate found", Data: map[string]interface{}{"data": estimate.Public(organisation)}})
+	}
+}
+
+// ReplyToEstimate godoc
+// @Summary Answer a shared estimate
+// @Description Approves or declines every line of the estimate behind a link and signs the answer with the typed name of the customer. The IP address, browser and time of the answer are recorded with the signature. The estimate is approved if any line is and declined otherwise, and it can be answered once.
+// @Tags Estimate
+// @Accept json
+// @Produce json
+// @Param token path string true "Token of the link"
+// @Param reply body models.EstimateReply true "Decisions and signature"
+// @Success 200 {object} responses.EstimateResponse
+// @Failure 400 {object} responses.EstimateResponse
+// @Failure 404 {object} responses.EstimateResponse
+// @Failure 409 {object} responses.EstimateResponse
+// @Failure 410 {object} responses.EstimateResponse
+// @Failure 500 {object} responses.EstimateResponse
+// @Router /api/public/estimate/{token}/reply [post]
+func ReplyToEstimate(estimates repositories.EstimateRepository, organisations repositories.OrganisationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.EstimateReply
+		defer cancel()
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		signature := strings.TrimSpace(input.Signature)
+		if signature == "" {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Signature is required", Data: map[string]interface{}{"data": input.Signature}})
+			return
+		}
+
+		now := time.Now()
+		estimate, ok := sharedEstimate(ctx, c, estimates, now)
+		if !ok {
+			return
+		}
+
+		//Check if the customer has answered already
+		if estimate.Status != models.EstimateSent {
+			c.JSON(http.StatusConflict, responses.EstimateResponse{Status: http.StatusConflict, Message: "Estimate was already answered", Data: map[string]interface{}{"data": estimate.Status}})
+			return
+		}
+
+		if !estimate.Decide(input.Lines) {
+			c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Every line of the estimate needs exactly one decision", Data: map[string]interface{}{"data": input.Lines}})
+			return
+		}
+		//The client address is only read from X-Forwarded-For when the request came through a trusted proxy
+		estimate.Signature = &models.EstimateSignature{
+			Name:      signature,
+			IP:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			SignedAt:  now,
+		}
+		estimate.UpdatedAt = now
+		err := estimates.Update(ctx, estimate, models.EstimateSent, estimate.ShareTokenHash)
+		if err != nil {
+			if err == repositories.ErrVersionConflict {
+				c.JSON(http.StatusConflict, responses.EstimateResponse{Status: http.StatusConflict, Message: "Estimate was changed by the shop, please open the link again", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, estimate.OrganisationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.EstimateResponse{Status: http.StatusOK, Message: "Estimate answered", Data: map[string]interface{}{"data": estimate.Public(organisation)}})
+	}
+}
+
+// sharedEstimate finds the estimate behind the token of a link and writes the error response if the link is unknown or
+// has expired. Both look the same to the customer, except that an expired link says so.
+func sharedEstimate(ctx context.Context, c *gin.Context, estimates repositories.EstimateRepository, at time.Time) (*models.Estimate, bool) {
+	token := c.Param("token")
+	if token == "" {
+		c.JSON(http.StatusNotFound, responses.EstimateResponse{Status: http.StatusNotFound, Message: "Estimate not found", Data: map[string]interface{}{"data": token}})
+		return nil, false
+	}
+
+	estimate, err := estimates.FindByShareToken(ctx, utils.HashLinkToken(token))
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, responses.EstimateResponse{Status: http.StatusNotFound, Message: "Estimate not found", Data: map[string]interface{}{"data": err.Error()}})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+		return nil, false
+	}
+
+	//Answered estimates stay visible to the customer, open ones only while the link lasts
+	if estimate.Status == models.EstimateSent && !estimate.ShareOpen(at) {
+		c.JSON(http.StatusGone, responses.EstimateResponse{Status: http.StatusGone, Message: "Estimate link has expired", Data: map[string]interface{}{"data": estimate.ShareExpiresAt}})
+		return nil, false
+	}
+	return estimate, true
+}
+
+// applyEstimate copies the input onto the estimate after checking and pricing it like a work order, and writes the
+// error response if it is invalid. Every line waits for the decision of the customer again.
+func applyEstimate(ctx context.Context, c *gin.Context, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, vehicles repositories.VehicleRepository, items repositories.ItemRepository, users repositories.UserRepository, estimate *models.Estimate, input *models.EstimateNew) bool {
+	//Check if the estimate would run out before the customer sees it
+	if !input.ValidUntil.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, responses.EstimateResponse{Status: http.StatusBadRequest, Message: "Estimate must be valid until a future date", Data: map[string]interface{}{"data": input.ValidUntil}})
+		return false
+	}
+
+	workOrder := models.WorkOrder{OrganisationID: estimate.OrganisationID}
+	if !applyWorkOrder(ctx, c, organisations, customers, vehicles, items, users, &workOrder, input.WorkOrder()) {
+		return false
+	}
+
+	lines := []models.EstimateLine{}
+	for _, line := range workOrder.Lines {
+		lines = append(lines, models.EstimateLine{WorkOrderLine: line, Decision: models.EstimateLinePending})
+	}
+	estimate.CustomerID = workOrder.CustomerID
+	estimate.VehicleID = workOrder.VehicleID
+	estimate.Complaints = workOrder.Complaints
+	estimate.Lines = lines
+	estimate.Notes = workOrder.Notes
+	estimate.ValidUntil = input.ValidUntil
+	estimate.Signature = nil
+	return true
+}
+
+// respondEstimateError writes the response for an error returned by the estimate repository
+func respondEstimateError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.EstimateResponse{Status: http.StatusNotFound, Message: "Estimate not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	if err == repositories.ErrVersionConflict {
+		c.JSON(http.StatusConflict, responses.EstimateResponse{Status: http.StatusConflict, Message: "Estimate was changed by someone else", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.EstimateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
@@ -0,0 +1,674 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// IssueInvoice godoc
+// @Summary Issue an invoice
+// @Description Issues an invoice for an ad-hoc cart of items and free-text lines. Amounts are frozen and the invoice gets the next number of the organisation's invoice sequence.
+// @Tags Invoice
+// @Accept json
+// @Produce json
+// @Param invoice body models.InvoiceNew true "Invoice data"
+// @Success 200 {object} responses.InvoiceResponse
+// @Failure 400 {object} responses.InvoiceResponse
+// @Failure 404 {object} responses.InvoiceResponse
+// @Failure 409 {object} responses.InvoiceResponse
+// @Failure 500 {object} responses.InvoiceResponse
+// @Router /api/invoice [post]
+// @Security BearerAuth
+func IssueInvoice(invoices repositories.InvoiceRepository, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, items repositories.ItemRepository, taxRates repositories.TaxRateRepository, stock repositories.StockRepository, locations repositories.StockLocationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var input *models.InvoiceNew
+		defer cancel()
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		var customerId *primitive.ObjectID
+		if input.CustomerID != "" {
+			customerObjectId, _ := primitive.ObjectIDFromHex(input.CustomerID)
+			customerId = &customerObjectId
+		}
+		invoice, organisation, ok := newInvoice(ctx, c, organisations, customers, models.InvoiceTypeInvoice, customerId)
+		if !ok {
+			return
+		}
+		invoice.DueDate = input.DueDate
+		invoice.Notes = input.Notes
+		lineTaxRates := newLineTaxRates(taxRates, invoice.OrganisationID)
+
+		for _, lineInput := range input.Lines {
+			line := models.InvoiceLine{
+				Description:     strings.TrimSpace(lineInput.Description),
+				Quantity:        lineInput.Quantity,
+				DiscountPercent: lineInput.DiscountPercent,
+			}
+			price := lineInput.UnitPrice
+			var taxRateId *primitive.ObjectID
+
+			//Items default to their catalogue name and price
+			if lineInput.ItemID != "" {
+				itemObjectId, _ := primitive.ObjectIDFromHex(lineInput.ItemID)
+				item, err := items.FindByID(ctx, invoice.OrganisationID, itemObjectId)
+				if err != nil {
+					if err == repositories.ErrNotFound {
+						c.JSON(http.StatusNotFound, responses.InvoiceResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": lineInput.ItemID}})
+						return
+					}
+					c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+					return
+				}
+				line.ItemID = &item.ID
+				if line.Description == "" {
+					line.Description = item.Name
+				}
+				if price == nil {
+					price = &item.Price
+				}
+				taxRateId = item.TaxRateID
+			}
+			if lineInput.TaxRateID != "" {
+				taxRateObjectId, _ := primitive.ObjectIDFromHex(lineInput.TaxRateID)
+				taxRateId = &taxRateObjectId
+			}
+
+			taxRate, ok := invoiceLineTaxRate(ctx, c, lineTaxRates, taxRateId)
+			if !ok || !addInvoiceLine(c, invoice, line, *price, taxRate) {
+				return
+			}
+		}
+		if !calculateInvoice(c, invoice) {
+			return
+		}
+
+		//Items sold over the counter leave the default location with the invoice
+		lines := []stockLine{}
+		for _, line := range invoice.Lines {
+			lines = append(lines, stockLine{itemId: line.ItemID, quantity: line.Quantity})
+		}
+		reference := &models.StockReference{Type: models.StockReferenceInvoice}
+		movements, err := outgoingStock(ctx, items, locations, invoice.OrganisationID, models.StockSale, reference, tenant.Get(c).User.ID, lines)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		err = invoices.Issue(ctx, invoice, organisation.InvoiceNumberingSettings(), func(ctx context.Context) error {
+			if len(movements) == 0 {
+				return nil
+			}
+			reference.ID = invoice.ID
+			return stock.Record(ctx, invoice.OrganisationID, movements, organisation.AllowNegativeStock, nil)
+		})
+		if err == repositories.ErrInsufficientStock {
+			c.JSON(http.StatusConflict, responses.InvoiceResponse{Status: http.StatusConflict, Message: "Not enough stock on hand", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err == repositories.ErrNumberingChanged {
+			c.JSON(http.StatusConflict, responses.InvoiceResponse{Status: http.StatusConflict, Message: "Invoice numbering was changed, please try again", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.InvoiceResponse{Status: http.StatusOK, Message: "Invoice issued", Data: map[string]interface{}{"data": invoice}})
+	}
+}
+
+// IssueWorkOrderInvoice godoc
+// @Summary Invoice a work order
+// @Description Issues the invoice of a completed work order from its lines and marks the work order invoiced in the same transaction
+// @Tags Invoice
+// @Accept json
+// @Produce json
+// @Param workOrderId path string true "Work order ID"
+// @Param invoice body models.WorkOrderInvoiceNew true "Invoice data"
+// @Success 200 {object} responses.InvoiceResponse
+// @Failure 400 {object} responses.InvoiceResponse
+// @Failure 404 {object} responses.InvoiceResponse
+// @Failure 409 {object} responses.InvoiceResponse
+// @Failure 500 {object} responses.InvoiceResponse
+// @Router /api/work-order/{workOrderId}/invoice [post]
+// @Security BearerAuth
+func IssueWorkOrderInvoice(invoices repositories.InvoiceRepository, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, workOrders repositories.WorkOrderRepository, items repositories.ItemRepository, taxRates repositories.TaxRateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		workOrderId := c.Param("workOrderId")
+		workOrderObjectId, _ := primitive.ObjectIDFromHex(workOrderId)
+		var input *models.WorkOrderInvoiceNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		workOrder, err := workOrders.FindByID(ctx, organisationId, workOrderObjectId)
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+		if workOrder.Status != models.WorkOrderCompleted {
+			c.JSON(http.StatusConflict, responses.InvoiceResponse{Status: http.StatusConflict, Message: "Only completed work orders can be invoiced", Data: map[string]interface{}{"data": workOrder.Status}})
+			return
+		}
+
+		invoice, organisation, ok := newInvoice(ctx, c, organisations, customers, models.InvoiceTypeInvoice, &workOrder.CustomerID)
+		if !ok {
+			return
+		}
+		invoice.WorkOrderID = &workOrder.ID
+		invoice.DueDate = input.DueDate
+		invoice.Notes = input.Notes
+		lineTaxRates := newLineTaxRates(taxRates, organisationId)
+
+		for _, workOrderLine := range workOrder.Lines {
+			line := models.InvoiceLine{
+				ItemID:          workOrderLine.ItemID,
+				Description:     workOrderLine.Description,
+				Quantity:        workOrderLine.Quantity,
+				DiscountPercent: input.DiscountPercent,
+			}
+
+			//Parts are taxed at the rate of their item, labor at the default rate
+			var taxRateId *primitive.ObjectID
+			if workOrderLine.ItemID != nil {
+				item, err := items.FindByID(ctx, organisationId, *workOrderLine.ItemID)
+				if err != nil && err != repositories.ErrNotFound {
+					c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+					return
+				}
+				if item != nil {
+					taxRateId = item.TaxRateID
+				}
+			}
+
+			taxRate, ok := invoiceLineTaxRate(ctx, c, lineTaxRates, taxRateId)
+			if !ok || !addInvoiceLine(c, invoice, line, workOrderLine.UnitPrice, taxRate) {
+				return
+			}
+		}
+		if !calculateInvoice(c, invoice) {
+			return
+		}
+
+		err = invoices.Issue(ctx, invoice, organisation.InvoiceNumberingSettings(), func(ctx context.Context) error {
+			return workOrders.Transition(ctx, organisationId, workOrder.ID, models.WorkOrderStatusChange{
+				From:      models.WorkOrderCompleted,
+				To:        models.WorkOrderInvoiced,
+				Comment:   "Invoice " + invoice.Number,
+				UserID:    invoice.IssuedBy,
+				ChangedAt: invoice.IssuedAt,
+			})
+		})
+		if err != nil {
+			if err == repositories.ErrVersionConflict {
+				c.JSON(http.StatusConflict, responses.InvoiceResponse{Status: http.StatusConflict, Message: "Work order was changed by someone else", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			if err == repositories.ErrNumberingChanged {
+				c.JSON(http.StatusConflict, responses.InvoiceResponse{Status: http.StatusConflict, Message: "Invoice numbering was changed, please try again", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.InvoiceResponse{Status: http.StatusOK, Message: "Invoice issued", Data: map[string]interface{}{"data": invoice}})
+	}
+}
+
+// IssueCreditNote godoc
+// @Summary Issue a credit note
+// @Description Credits lines of an issued invoice with a credit note numbered from the organisation's credit note sequence. Without lines the credit note credits everything earlier credit notes left, a first credit note of the whole invoice reverses it exactly. Quantities can never exceed what is left to credit of a line. With restock the returned items that track stock are taken back into the location, or the default location. The invoice itself is never changed.
+// @Tags Invoice
+// @Accept json
+// @Produce json
+// @Param invoiceId path string true "Invoice ID"
+// @Param creditNote body models.CreditNoteNew true "Credit note data"
+// @Success 200 {object} responses.InvoiceResponse
+// @Failure 400 {object} responses.InvoiceResponse
+// @Failure 404 {object} responses.InvoiceResponse
+// @Failure 409 {object} responses.InvoiceResponse
+// @Failure 500 {object} responses.InvoiceResponse
+// @Router /api/invoice/{invoiceId}/credit-note [post]
+// @Security BearerAuth
+func IssueCreditNote(invoices repositories.InvoiceRepository, items repositories.ItemRepository, stock repositories.StockRepository, locations repositories.StockLocationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		invoiceId := c.Param("invoiceId")
+		invoiceObjectId, _ := primitive.ObjectIDFromHex(invoiceId)
+		var input *models.CreditNoteNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		original, err := invoices.FindByID(ctx, organisationId, invoiceObjectId)
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+		if original.Type != models.InvoiceTypeInvoice {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Only invoices can be credited", Data: map[string]interface{}{"data": original.Type}})
+			return
+		}
+
+		//Returned items go back into stock at the location, checked before the credit note is issued
+		var location *models.StockLocation
+		if input.Restock {
+			var ok bool
+			if location, ok = stockLocation(ctx, c, locations, organisationId, input.LocationID); !ok {
+				return
+			}
+		}
+
+		creditNote := &models.Invoice{Type: models.InvoiceTypeCreditNote, OriginalInvoiceID: &original.ID, OrganisationID: organisationId}
+		issuedAt := time.Now()
+		movements := []*models.StockMovement{}
+		reference := &models.StockReference{Type: models.StockReferenceCreditNote}
+
+		//Check what the earlier credit notes of the invoice left to credit in the transaction the credit note is issued
+		//in, so concurrent credit notes cannot credit the same lines
+		prepare := func(ctx context.Context, creditNotes []*models.Invoice) error {
+			credited, err := original.Credited(creditNotes)
+			if err != nil {
+				return err
+			}
+
+			*creditNote = models.Invoice{
+				Type:              models.InvoiceTypeCreditNote,
+				OriginalInvoiceID: &original.ID,
+				WorkOrderID:       original.WorkOrderID,
+				CustomerID:        original.CustomerID,
+				Seller:            original.Seller,
+				Buyer:             original.Buyer,
+				Currency:          original.Currency,
+				PricesIncludeTax:  original.PricesIncludeTax,
+				Lines:             original.Lines,
+				Subtotal:          original.Subtotal,
+				DiscountTotal:     original.DiscountTotal,
+				NetTotal:          original.NetTotal,
+				TaxTotal:          original.TaxTotal,
+				Total:             original.Total,
+				TaxBreakdown:      original.TaxBreakdown,
+				Reason:            strings.TrimSpace(input.Reason),
+				IssuedBy:          tenant.Get(c).User.ID,
+				IssuedAt:          issuedAt,
+				OrganisationID:    organisationId,
+			}
+
+			if len(creditNotes) > 0 || len(input.Lines) > 0 {
+				lines, err := creditNoteLines(original, credited, input.Lines)
+				if err != nil {
+					return err
+				}
+				creditNote.Lines = lines
+				if err := creditNote.Calculate(); err != nil {
+					return &creditNoteError{status: http.StatusBadRequest, message: "Invalid invoice total", data: err.Error()}
+				}
+				if err := creditFitsInvoice(original, creditNotes, creditNote); err != nil {
+					return err
+				}
+			}
+
+			//Negate the frozen amounts rather than recalculating them, so the credit note reverses the invoice exactly
+			creditNote.Negate()
+			return nil
+		}
+
+		err = invoices.IssueCreditNote(ctx, creditNote, models.DefaultCreditNoteNumbering, prepare, func(ctx context.Context) error {
+			movements = []*models.StockMovement{}
+			if location == nil {
+				return nil
+			}
+			lines := []stockLine{}
+			for _, line := range creditNote.Lines {
+				lines = append(lines, stockLine{itemId: line.ItemID, quantity: line.Quantity})
+			}
+			reference.ID = creditNote.ID
+			var err error
+			movements, err = incomingStock(ctx, items, location, organisationId, models.StockReturn, reference, tenant.Get(c).User.ID, lines)
+			if err != nil || len(movements) == 0 {
+				return err
+			}
+			return stock.Record(ctx, organisationId, movements, true, nil)
+		})
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.InvoiceResponse{Status: http.StatusOK, Message: "Credit note issued", Data: map[string]interface{}{"data": creditNote, "stock_movements": movements}})
+	}
+}
+
+// GetInvoice godoc
+// @Summary Get an invoice
+// @Description Gets an invoice or credit note of the organisation
+// @Tags Invoice
+// @Accept json
+// @Produce json
+// @Param invoiceId path string true "Invoice ID"
+// @Success 200 {object} responses.InvoiceResponse
+// @Failure 404 {object} responses.InvoiceResponse
+// @Failure 500 {object} responses.InvoiceResponse
+// @Router /api/invoice/{invoiceId} [get]
+// @Security BearerAuth
+func GetInvoice(invoices repositories.InvoiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		invoiceId := c.Param("invoiceId")
+		invoiceObjectId, _ := primitive.ObjectIDFromHex(invoiceId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		invoice, err := invoices.FindByID(ctx, organisationId, invoiceObjectId)
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.InvoiceResponse{Status: http.StatusOK, Message: "Invoice found", Data: map[string]interface{}{"data": invoice}})
+	}
+}
+
+// GetInvoices godoc
+// @Summary Get invoices
+// @Description Gets a page of the invoices and credit notes of the organisation, newest first. They can also be sorted by number, issued_at or total, prefixed with "-" for descending order.
+// @Tags Invoice
+// @Accept json
+// @Produce json
+// @Param type query string false "invoice or credit_note"
+// @Param customer_id query string false "Customer ID"
+// @Param sort query string false "Sort field" default(-issued_at)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.InvoiceResponse
+// @Failure 400 {object} responses.InvoiceResponse
+// @Failure 500 {object} responses.InvoiceResponse
+// @Router /api/invoices [get]
+// @Security BearerAuth
+func GetInvoices(invoices repositories.InvoiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.InvoiceSortFields, "-issued_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.InvoiceSortFields.Names()}})
+			return
+		}
+		filter := models.InvoiceFilter{Type: c.Query("type")}
+		if filter.Type != "" && filter.Type != models.InvoiceTypeInvoice && filter.Type != models.InvoiceTypeCreditNote {
+			c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Invalid type", Data: map[string]interface{}{"data": filter.Type}})
+			return
+		}
+		if customerId := c.Query("customer_id"); customerId != "" {
+			customerObjectId, err := primitive.ObjectIDFromHex(customerId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Invalid customer ID", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			filter.CustomerID = &customerObjectId
+		}
+
+		result, paging, err := invoices.FindAll(ctx, organisationId, filter, page)
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.InvoiceResponse{Status: http.StatusOK, Message: "Invoices found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// newInvoice starts an invoice of the caller's organisation with the seller and buyer details frozen, or writes the
+// error response
+func newInvoice(ctx context.Context, c *gin.Context, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, invoiceType string, customerId *primitive.ObjectID) (*models.Invoice, *models.Organisation, bool) {
+	organisationId, err := tenant.Get(c).Organisation()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+		return nil, nil, false
+	}
+
+	organisation, err := organisations.FindByID(ctx, organisationId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+		return nil, nil, false
+	}
+	currency, err := money.CurrencyAlpha(organisation.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+		return nil, nil, false
+	}
+
+	invoice := &models.Invoice{
+		Type: invoiceType,
+		Seller: models.InvoiceParty{
+			Name:    organisation.Name,
+			Address: models.Address{Street: organisation.Address, ZipCode: organisation.ZipCode, Country: organisation.Country},
+			Phone:   organisation.Phone,
+		},
+		Currency:         currency,
+		PricesIncludeTax: organisation.PricesIncludeTax,
+		Lines:            []models.InvoiceLine{},
+		IssuedBy:         tenant.Get(c).User.ID,
+		IssuedAt:         time.Now(),
+		OrganisationID:   organisationId,
+	}
+
+	if customerId != nil {
+		customer, err := customers.FindByID(ctx, organisationId, *customerId)
+		if err != nil {
+			respondCustomerError(c, err)
+			return nil, nil, false
+		}
+		invoice.CustomerID = &customer.ID
+		invoice.Buyer = &models.InvoiceParty{
+			Name:    customer.Name,
+			Address: customer.BillingAddress,
+			Phone:   customer.Phone,
+			Email:   customer.Email,
+			TaxID:   customer.TaxID,
+		}
+	}
+	return invoice, organisation, true
+}
+
+// invoiceLineTaxRate returns the tax rate with the ID or the default rate for a nil ID, or writes the error response
+func invoiceLineTaxRate(ctx context.Context, c *gin.Context, lineTaxRates *lineTaxRates, id *primitive.ObjectID) (*models.TaxRate, bool) {
+	taxRate, err := lineTaxRates.get(ctx, id)
+	if err != nil {
+		if err == errUnknownTaxRate {
+			c.JSON(http.StatusNotFound, responses.InvoiceResponse{Status: http.StatusNotFound, Message: "Tax rate not found", Data: map[string]interface{}{"data": id}})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+		return nil, false
+	}
+	return taxRate, true
+}
+
+// addInvoiceLine prices the line in the invoice currency at the tax rate, calculates it and adds it to the invoice, or
+// writes the error response
+func addInvoiceLine(c *gin.Context, invoice *models.Invoice, line models.InvoiceLine, price money.Money, taxRate *models.TaxRate) bool {
+	unitPrice, err := price.In(invoice.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Invalid price of " + line.Description, Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	line.UnitPrice = unitPrice
+	line.TaxRate = taxRate.Rate()
+
+	if err := line.Calculate(); err != nil {
+		c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Invalid amount of " + line.Description, Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	invoice.Lines = append(invoice.Lines, line)
+	return true
+}
+
+// calculateInvoice sums up the invoice totals, or writes the error response
+func calculateInvoice(c *gin.Context, invoice *models.Invoice) bool {
+	if err := invoice.Calculate(); err != nil {
+		c.JSON(http.StatusBadRequest, responses.InvoiceResponse{Status: http.StatusBadRequest, Message: "Invalid invoice total", Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	return true
+}
+
+// creditNoteError is why a credit note cannot be issued, with the response it is answered with
+type creditNoteError struct {
+	status  int
+	message string
+	data    interface{}
+}
+
+func (e *creditNoteError) Error() string {
+	return e.message
+}
+
+// creditNoteLines returns the lines of the original invoice the input credits, or everything the earlier credit notes
+// left if the input has no lines. Lines credited up to their last unit are credited with what is left of their amounts,
+// so rounding never credits more than the line was invoiced for.
+func creditNoteLines(original *models.Invoice, credited []models.InvoiceLine, input []models.CreditNoteLineNew) ([]models.InvoiceLine, error) {
+	if len(input) == 0 {
+		for i, line := range original.Lines {
+			if left := models.RoundQuantity(line.Quantity - credited[i].Quantity); left > 0 {
+				input = append(input, models.CreditNoteLineNew{Line: i, Quantity: left})
+			}
+		}
+		if len(input) == 0 {
+			return nil, &creditNoteError{status: http.StatusConflict, message: "Invoice was credited in full already", data: original.ID}
+		}
+	}
+
+	lines := []models.InvoiceLine{}
+	seen := map[int]bool{}
+	for _, lineInput := range input {
+		if lineInput.Line >= len(original.Lines) || seen[lineInput.Line] {
+			return nil, &creditNoteError{status: http.StatusBadRequest, message: "Lines must be credited once each and exist on the invoice", data: lineInput.Line}
+		}
+		seen[lineInput.Line] = true
+
+		line := original.Lines[lineInput.Line]
+		left := models.RoundQuantity(line.Quantity - credited[lineInput.Line].Quantity)
+		quantity := models.RoundQuantity(lineInput.Quantity)
+		if quantity > left {
+			return nil, &creditNoteError{status: http.StatusConflict, message: "Quantity exceeds what is left to credit of " + line.Description, data: left}
+		}
+
+		position := lineInput.Line
+		line.OriginalLine = &position
+		line.Quantity = quantity
+		var err error
+		if quantity == left {
+			if line.Amount, err = line.Amount.Sub(credited[position].Amount); err == nil {
+				line.Discount, err = line.Discount.Sub(credited[position].Discount)
+			}
+		} else {
+			err = line.Calculate()
+		}
+		if err != nil {
+			return nil, &creditNoteError{status: http.StatusBadRequest, message: "Invalid amount of " + line.Description, data: err.Error()}
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// creditFitsInvoice checks that the credit note, not negated yet, and the earlier credit notes of the invoice do not
+// credit more than its total
+func creditFitsInvoice(original *models.Invoice, creditNotes []*models.Invoice, creditNote *models.Invoice) error {
+	balance, err := original.Balance(nil, creditNotes)
+	if err == nil {
+		balance.Credited, err = balance.Credited.Add(creditNote.Total)
+	}
+	if err != nil {
+		return &creditNoteError{status: http.StatusBadRequest, message: "Invalid credit note total", data: err.Error()}
+	}
+	if balance.Credited.Amount > original.Total.Amount {
+		return &creditNoteError{status: http.StatusConflict, message: "Credit notes would credit more than the invoice total", data: balance}
+	}
+	return nil
+}
+
+// respondInvoiceError writes the response for an error returned by the invoice repository
+func respondInvoiceError(c *gin.Context, err error) {
+	var creditNoteErr *creditNoteError
+	if errors.As(err, &creditNoteErr) {
+		c.JSON(creditNoteErr.status, responses.InvoiceResponse{Status: creditNoteErr.status, Message: creditNoteErr.message, Data: map[string]interface{}{"data": creditNoteErr.data}})
+		return
+	}
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.InvoiceResponse{Status: http.StatusNotFound, Message: "Invoice not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.InvoiceResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
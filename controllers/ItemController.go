@@ -2,19 +2,30 @@ package controllers
 
 import (
 	"context"
-	"github.com/biter777/countries"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
-	"poosible-backend/config"
+	"poosible-backend/barcode"
+	"poosible-backend/listing"
 	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
 	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"poosible-backend/utils"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var itemCollection *mongo.Collection = config.GetCollection(config.Database, "items")
+var errDuplicateBarcode = errors.New("barcode is given twice")
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+)
 
 // CreateItem godoc
 // @Summary Create an item
@@ -28,26 +39,18 @@ var itemCollection *mongo.Collection = config.GetCollection(config.Database, "it
 // @Failure 500 {object} responses.ItemResponse
 // @Router /api/item [post]
 // @Security BearerAuth
-func CreateItem() gin.HandlerFunc {
+func CreateItem(items repositories.ItemRepository, organisations repositories.OrganisationRepository, taxRates repositories.TaxRateRepository, suppliers repositories.SupplierRepository, categories repositories.CategoryRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		var item *models.ItemNew
-		var organisation *models.Organisation
 		defer cancel()
 
-		user, err := CurrentUser(c)
+		organisationId, err := tenant.Get(c).Organisation()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Error getting current user", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
-
-		if user.OrganisationID == nil {
-			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		organisationId, _ := primitive.ObjectIDFromHex(user.OrganisationID.Hex())
-
 		// Bind the JSON body to the struct
 		if err := c.ShouldBindJSON(&item); err != nil {
 			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
@@ -60,51 +63,59 @@ func CreateItem() gin.HandlerFunc {
 			return
 		}
 
-		//Check if item name exists
-		var itemCheck models.Item
-		itemCheckErr := itemCollection.FindOne(ctx, bson.M{"name": item.Name, "organisation_id": organisationId}).Decode(&itemCheck)
-		if itemCheckErr == nil {
-			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Item name already exists", Data: map[string]interface{}{"data": itemCheck}})
-			return
-		}
-
-		orgErr := organisationCollection.FindOne(ctx, bson.M{"_id": organisationId}).Decode(&organisation)
+		organisation, orgErr := organisations.FindByID(ctx, organisationId)
 		if orgErr != nil {
 			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": orgErr.Error()}})
 			return
 		}
-		//get currency alpha
-		currencies := countries.AllCurrenciesInfo()
-		var currencyAlpha string
-		for _, currency := range currencies {
-			if int(currency.Code) == organisation.Currency {
-				currencyAlpha = currency.Alpha
-			}
-		}
-
-		price := models.Price{
-			Amount:   item.Price.Amount,
-			Currency: currencyAlpha,
+		currency, err := money.CurrencyAlpha(organisation.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+			return
 		}
 
+		//Prices are always in the organisation's currency
 		newItem := models.Item{
-			Name:           item.Name,
-			Description:    item.Description,
 			OrganisationID: organisationId,
-			Price:          price,
+			Price:          money.Zero(currency),
+			Version:        1,
 			UpdatedAt:      time.Now(),
 			CreatedAt:      time.Now(),
 		}
+		if field, err := applyItem(ctx, taxRates, suppliers, categories, item, &newItem); err != nil {
+			message, ok := itemFieldMessages[field]
+			if !ok {
+				message = "Bad request"
+			}
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: message, Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if the name, SKU, a barcode or the part number is already taken
+		message, itemCheck, err := duplicateItem(ctx, items, &newItem)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if itemCheck != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: message, Data: map[string]interface{}{"data": itemCheck}})
+			return
+		}
 
-		// Insert the organisation into the database
-		result, err := itemCollection.InsertOne(ctx, newItem)
+		// Insert the item into the database
+		err = items.Create(ctx, &newItem)
+		if err == repositories.ErrDuplicateItem {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: err.Error(), Data: map[string]interface{}{"data": nil}})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		// Return the organisation
-		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Item created", Data: map[string]interface{}{"data": result}})
+		// Return the item
+		c.Header("ETag", utils.ETag(newItem.Version))
+		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Item created", Data: map[string]interface{}{"data": newItem}})
 
 	}
 }
@@ -121,18 +132,23 @@ func CreateItem() gin.HandlerFunc {
 // @Failure 500 {object} responses.ItemResponse
 // @Router /api/item/{itemId} [get]
 // @Security BearerAuth
-func GetItem() gin.HandlerFunc {
+func GetItem(items repositories.ItemRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		var item *models.Item
 		itemId := c.Param("itemId")
 		itemObjectId, _ := primitive.ObjectIDFromHex(itemId)
 		defer cancel()
 
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
 		// Find the item in the database
-		err := itemCollection.FindOne(ctx, bson.M{"_id": itemObjectId}).Decode(&item)
+		item, err := items.FindByID(ctx, organisationId, itemObjectId)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
+			if err == repositories.ErrNotFound {
 				c.JSON(http.StatusNotFound, responses.ItemResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": err.Error()}})
 				return
 			}
@@ -141,68 +157,583 @@ func GetItem() gin.HandlerFunc {
 		}
 
 		// Return the item
+		c.Header("ETag", utils.ETag(item.Version))
 		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Item found", Data: map[string]interface{}{"data": item}})
 	}
 }
 
 // GetItems godoc
 // @Summary Get items
-// @Description Gets items
+// @Description Gets a page of the items of the organisation. Items are filtered by name, price, category including the categories below it and last change, and sorted by name, price, created_at or updated_at, prefixed with "-" for descending order. The paging of the response carries the cursor of the next page.
 // @Tags Item
 // @Accept json
 // @Produce json
+// @Param name query string false "Part of the name"
+// @Param category_id query string false "Category ID"
+// @Param price_min query string false "Lowest price" example(10.00)
+// @Param price_max query string false "Highest price" example(99.99)
+// @Param updated_since query string false "Only items changed at or after this RFC 3339 time"
+// @Param sort query string false "Sort field" default(name)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
 // @Success 200 {object} responses.ItemResponse
+// @Failure 400 {object} responses.ItemResponse
+// @Failure 404 {object} responses.ItemResponse
 // @Failure 500 {object} responses.ItemResponse
 // @Router /api/items [get]
 // @Security BearerAuth
-func GetItems() gin.HandlerFunc {
+func GetItems(items repositories.ItemRepository, organisations repositories.OrganisationRepository, categories repositories.CategoryRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		var items []*models.Item
 		defer cancel()
 
-		user, err := CurrentUser(c)
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		query, err := listing.Parse(c.Request.URL.Query(), repositories.ItemSortFields, "name")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.ItemSortFields.Names()}})
+			return
+		}
+		filter, ok := itemFilter(ctx, c, organisations, categories, organisationId)
+		if !ok {
+			return
+		}
+
+		// Find the items in the database
+		result, page, err := items.FindAll(ctx, organisationId, filter, query)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		if user.OrganisationID == nil {
+		// Return the items
+		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Items found", Data: map[string]interface{}{"data": result, "paging": page}})
+	}
+}
+
+// LookupItem godoc
+// @Summary Look up an item by code
+// @Description Finds the item with a scanned barcode, a SKU or a manufacturer part number. A UPC-A barcode also finds the item that was given the same code as an EAN-13 and the other way round.
+// @Tags Item
+// @Accept json
+// @Produce json
+// @Param barcode query string false "EAN or UPC barcode"
+// @Param sku query string false "SKU"
+// @Param mpn query string false "Manufacturer part number"
+// @Param manufacturer query string false "Manufacturer of the part number"
+// @Success 200 {object} responses.ItemResponse
+// @Failure 400 {object} responses.ItemResponse
+// @Failure 404 {object} responses.ItemResponse
+// @Failure 500 {object} responses.ItemResponse
+// @Router /api/items/lookup [get]
+// @Security BearerAuth
+func LookupItem(items repositories.ItemRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
 			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		organisationObjectId, _ := primitive.ObjectIDFromHex(user.OrganisationID.Hex())
-
-		// Find the items in the database
-		result, err := itemCollection.Find(ctx, bson.M{"organisation_id": organisationObjectId})
+		var item *models.Item
+		switch {
+		case c.Query("barcode") != "":
+			code, codeErr := barcode.Validate(c.Query("barcode"))
+			if codeErr != nil {
+				c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Invalid barcode", Data: map[string]interface{}{"data": codeErr.Error()}})
+				return
+			}
+			item, err = items.FindByBarcode(ctx, organisationId, barcode.Equivalents(code))
+		case c.Query("sku") != "":
+			item, err = items.FindBySKU(ctx, organisationId, strings.TrimSpace(c.Query("sku")))
+		case c.Query("mpn") != "":
+			item, err = items.FindByPartNumber(ctx, organisationId, strings.TrimSpace(c.Query("manufacturer")), models.NormalizePartNumber(c.Query("mpn")))
+		default:
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "A barcode, sku or mpn is required", Data: map[string]interface{}{"data": nil}})
+			return
+		}
 		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.ItemResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		// Iterate through the result and read in optimal chunks
-		defer result.Close(ctx)
-		for result.Next(ctx) {
-			var item *models.Item
-			err := result.Decode(&item)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+		// Return the item
+		c.Header("ETag", utils.ETag(item.Version))
+		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Item found", Data: map[string]interface{}{"data": item}})
+	}
+}
+
+// SearchItems godoc
+// @Summary Search items
+// @Description Finds the items whose name, description, SKU, manufacturer or part numbers match the words of q, best match first. Words may be cut short, abbreviated or misspelt, so "brk pad frnt golf" finds "Brake pad front" for a Golf. Items matching more of the words come first.
+// @Tags Item
+// @Accept json
+// @Produce json
+// @Param q query string true "Words to search for"
+// @Param limit query int false "Maximum number of items, at most 50" default(20)
+// @Success 200 {object} responses.ItemResponse
+// @Failure 400 {object} responses.ItemResponse
+// @Failure 500 {object} responses.ItemResponse
+// @Router /api/items/search [get]
+// @Security BearerAuth
+func SearchItems(items repositories.ItemRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "A search query is required", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+		limit := defaultSearchLimit
+		if c.Query("limit") != "" {
+			limit, err = strconv.Atoi(c.Query("limit"))
+			if err != nil || limit < 1 || limit > maxSearchLimit {
+				c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Invalid limit", Data: map[string]interface{}{"data": c.Query("limit")}})
 				return
 			}
-			items = append(items, item)
 		}
-		// Return the items
-		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Items found", Data: map[string]interface{}{"data": items}})
+
+		result, err := items.Search(ctx, organisationId, query, limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Items found", Data: map[string]interface{}{"data": result}})
 	}
 }
 
-func UpdateItem() gin.HandlerFunc {
+// UpdateItem godoc
+// @Summary Replace an item
+// @Description Replaces the name, description, price, tax rate, category, stock and reorder settings and the codes of an item. The If-Match header must carry the item's current ETag.
+// @Tags Item
+// @Accept json
+// @Produce json
+// @Param itemId path string true "Item ID"
+// @Param If-Match header string true "ETag of the item being replaced"
+// @Param item body models.ItemNew true "Item data"
+// @Success 200 {object} responses.ItemResponse
+// @Failure 400 {object} responses.ItemResponse
+// @Failure 404 {object} responses.ItemResponse
+// @Failure 412 {object} responses.ItemResponse
+// @Failure 428 {object} responses.ItemResponse
+// @Failure 500 {object} responses.ItemResponse
+// @Router /api/item/{itemId} [put]
+// @Security BearerAuth
+func UpdateItem(items repositories.ItemRepository, taxRates repositories.TaxRateRepository, suppliers repositories.SupplierRepository, categories repositories.CategoryRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var input *models.ItemNew
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
 
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		saveItem(c, items, func(ctx context.Context, item *models.Item) error {
+			_, err := applyItem(ctx, taxRates, suppliers, categories, input, item)
+			return err
+		})
 	}
 }
 
+// PatchItem godoc
+// @Summary Update an item
+// @Description Updates the given fields of an item. The If-Match header must carry the item's current ETag.
+// @Tags Item
+// @Accept json
+// @Produce json
+// @Param itemId path string true "Item ID"
+// @Param If-Match header string true "ETag of the item being updated"
+// @Param item body models.ItemPatch true "Item fields"
+// @Success 200 {object} responses.ItemResponse
+// @Failure 400 {object} responses.ItemResponse
+// @Failure 404 {object} responses.ItemResponse
+// @Failure 412 {object} responses.ItemResponse
+// @Failure 428 {object} responses.ItemResponse
+// @Failure 500 {object} responses.ItemResponse
+// @Router /api/item/{itemId} [patch]
+// @Security BearerAuth
+func PatchItem(items repositories.ItemRepository, taxRates repositories.TaxRateRepository, suppliers repositories.SupplierRepository, categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input *models.ItemPatch
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		saveItem(c, items, func(ctx context.Context, item *models.Item) error {
+			if input.TaxRateID != nil {
+				taxRateId, err := taxRateID(ctx, taxRates, item.OrganisationID, *input.TaxRateID)
+				if err != nil {
+					return err
+				}
+				item.TaxRateID = taxRateId
+			}
+			if input.TrackStock != nil {
+				item.TrackStock = *input.TrackStock
+			}
+			if input.ReorderPoint != nil {
+				item.ReorderPoint = *input.ReorderPoint
+			}
+			if input.ReorderQuantity != nil {
+				item.ReorderQuantity = *input.ReorderQuantity
+			}
+			if input.PreferredSupplierID != nil {
+				supplierId, err := supplierID(ctx, suppliers, item.OrganisationID, *input.PreferredSupplierID)
+				if err != nil {
+					return err
+				}
+				item.PreferredSupplierID = supplierId
+			}
+			if input.CategoryID != nil {
+				categoryId, err := categoryID(ctx, categories, item.OrganisationID, *input.CategoryID)
+				if err != nil {
+					return err
+				}
+				item.CategoryID = categoryId
+			}
+			if input.SKU != nil {
+				item.SKU = strings.TrimSpace(*input.SKU)
+			}
+			if input.Barcodes != nil {
+				barcodes, err := itemBarcodes(*input.Barcodes)
+				if err != nil {
+					return err
+				}
+				item.Barcodes = barcodes
+			}
+			if input.Manufacturer != nil {
+				item.Manufacturer = strings.TrimSpace(*input.Manufacturer)
+			}
+			if input.MPN != nil {
+				item.MPN = models.NormalizePartNumber(*input.MPN)
+			}
+			if input.CrossReferences != nil {
+				item.CrossReferences = itemCrossReferences(*input.CrossReferences)
+			}
+			if input.Price != nil {
+				price, err := itemPrice(*input.Price, item.Price.Currency)
+				if err != nil {
+					return err
+				}
+				item.Price = price
+			}
+			if input.Name != nil {
+				item.Name = *input.Name
+			}
+			if input.Description != nil {
+				item.Description = *input.Description
+			}
+			return nil
+		})
+	}
+}
+
+// itemFieldMessages are the messages CreateItem answers with when a field of the input is invalid, other fields answer
+// "Bad request"
+var itemFieldMessages = map[string]string{
+	"price":    "Invalid price",
+	"barcodes": "Invalid barcode",
+}
+
+// itemFilter reads the filters items are listed and exported by from the query, answering the request if they are invalid
+func itemFilter(ctx context.Context, c *gin.Context, organisations repositories.OrganisationRepository, categories repositories.CategoryRepository, organisationId primitive.ObjectID) (models.ItemFilter, bool) {
+	updatedSince, err := listing.Time(c.Request.URL.Query(), "updated_since")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+		return models.ItemFilter{}, false
+	}
+	filter := models.ItemFilter{Name: strings.TrimSpace(c.Query("name")), UpdatedSince: updatedSince}
+
+	//Price bounds are given like prices, in the organisation's currency
+	if c.Query("price_min") != "" || c.Query("price_max") != "" {
+		organisation, err := organisations.FindByID(ctx, organisationId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return filter, false
+		}
+		currency, err := money.CurrencyAlpha(organisation.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+			return filter, false
+		}
+		for param, bound := range map[string]**int64{"price_min": &filter.MinPrice, "price_max": &filter.MaxPrice} {
+			if value := c.Query(param); value != "" {
+				price, err := money.Parse(value, currency)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Invalid " + param, Data: map[string]interface{}{"data": err.Error()}})
+					return filter, false
+				}
+				*bound = &price.Amount
+			}
+		}
+	}
+
+	if categoryId := c.Query("category_id"); categoryId != "" {
+		categoryObjectId, _ := primitive.ObjectIDFromHex(categoryId)
+		subtree, err := categories.FindSubtree(ctx, organisationId, categoryObjectId)
+		if err != nil {
+			respondCategoryError(c, err)
+			return filter, false
+		}
+		filter.CategoryIDs = []primitive.ObjectID{}
+		for _, category := range subtree {
+			filter.CategoryIDs = append(filter.CategoryIDs, category.ID)
+		}
+	}
+	return filter, true
+}
+
+// applyItem sets the fields of the item from the input, resolving the tax rate, supplier and category within the
+// item's organisation. The price is read in the currency of the item's current price. It returns the JSON name of the
+// field that is invalid together with the error.
+func applyItem(ctx context.Context, taxRates repositories.TaxRateRepository, suppliers repositories.SupplierRepository, categories repositories.CategoryRepository, input *models.ItemNew, item *models.Item) (string, error) {
+	price, err := itemPrice(input.Price, item.Price.Currency)
+	if err != nil {
+		return "price", err
+	}
+	taxRateId, err := taxRateID(ctx, taxRates, item.OrganisationID, input.TaxRateID)
+	if err != nil {
+		return "tax_rate_id", err
+	}
+	supplierId, err := supplierID(ctx, suppliers, item.OrganisationID, input.PreferredSupplierID)
+	if err != nil {
+		return "preferred_supplier_id", err
+	}
+	categoryId, err := categoryID(ctx, categories, item.OrganisationID, input.CategoryID)
+	if err != nil {
+		return "category_id", err
+	}
+	barcodes, err := itemBarcodes(input.Barcodes)
+	if err != nil {
+		return "barcodes", err
+	}
+	item.TaxRateID = taxRateId
+	item.TrackStock = input.TrackStock
+	item.ReorderPoint = input.ReorderPoint
+	item.ReorderQuantity = input.ReorderQuantity
+	item.PreferredSupplierID = supplierId
+	item.CategoryID = categoryId
+	item.SKU = strings.TrimSpace(input.SKU)
+	item.Barcodes = barcodes
+	item.Manufacturer = strings.TrimSpace(input.Manufacturer)
+	item.MPN = models.NormalizePartNumber(input.MPN)
+	item.CrossReferences = itemCrossReferences(input.CrossReferences)
+	item.Name = input.Name
+	item.Description = input.Description
+	item.Price = price
+	return "", nil
+}
+
+// itemPrice returns the price in the currency, items cannot be given away for less than nothing
+func itemPrice(price money.Money, currency string) (money.Money, error) {
+	price, err := price.In(currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if price.IsNegative() {
+		return money.Money{}, money.ErrInvalidAmount
+	}
+	return price, nil
+}
+
+// itemBarcodes validates the barcodes and rejects two codes for the same GTIN
+func itemBarcodes(codes []string) ([]string, error) {
+	barcodes := []string{}
+	seen := map[string]bool{}
+	for _, input := range codes {
+		code, err := barcode.Validate(input)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", input, err)
+		}
+		if seen[code] {
+			return nil, fmt.Errorf("%s: %w", code, errDuplicateBarcode)
+		}
+		for _, equivalent := range barcode.Equivalents(code) {
+			seen[equivalent] = true
+		}
+		barcodes = append(barcodes, code)
+	}
+	return barcodes, nil
+}
+
+// itemCrossReferences normalizes the part numbers the item replaces, dropping repeated ones
+func itemCrossReferences(input []models.PartNumberNew) []models.PartNumber {
+	crossReferences := []models.PartNumber{}
+	seen := map[models.PartNumber]bool{}
+	for _, reference := range input {
+		partNumber := models.PartNumber{
+			Manufacturer: strings.TrimSpace(reference.Manufacturer),
+			Number:       models.NormalizePartNumber(reference.Number),
+			Type:         reference.Type,
+		}
+		if !seen[partNumber] {
+			seen[partNumber] = true
+			crossReferences = append(crossReferences, partNumber)
+		}
+	}
+	return crossReferences
+}
+
+// duplicateItem returns another item of the organisation with the same name, SKU, barcode or manufacturer part
+// number, together with the message saying what clashes
+func duplicateItem(ctx context.Context, items repositories.ItemRepository, item *models.Item) (string, *models.Item, error) {
+	type check struct {
+		message string
+		find    func() (*models.Item, error)
+	}
+	checks := []check{{"Item name already exists", func() (*models.Item, error) {
+		return items.FindByName(ctx, item.OrganisationID, item.Name)
+	}}}
+	if item.SKU != "" {
+		checks = append(checks, check{"SKU already exists", func() (*models.Item, error) {
+			return items.FindBySKU(ctx, item.OrganisationID, item.SKU)
+		}})
+	}
+	if item.MPN != "" {
+		checks = append(checks, check{"Manufacturer part number already exists", func() (*models.Item, error) {
+			return items.FindByPartNumber(ctx, item.OrganisationID, item.Manufacturer, item.MPN)
+		}})
+	}
+	for _, code := range item.Barcodes {
+		equivalents := barcode.Equivalents(code)
+		checks = append(checks, check{"Barcode " + code + " already exists", func() (*models.Item, error) {
+			return items.FindByBarcode(ctx, item.OrganisationID, equivalents)
+		}})
+	}
+
+	for _, check := range checks {
+		itemCheck, err := check.find()
+		if err == repositories.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if itemCheck.ID != item.ID {
+			return check.message, itemCheck, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// saveItem applies the change to the item in the URL if the If-Match header matches its version and writes the result
+func saveItem(c *gin.Context, items repositories.ItemRepository, apply func(ctx context.Context, item *models.Item) error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	itemId := c.Param("itemId")
+	itemObjectId, _ := primitive.ObjectIDFromHex(itemId)
+	defer cancel()
+
+	organisationId, err := tenant.Get(c).Organisation()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+
+	//Check if the client says which version it is changing
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, responses.ItemResponse{Status: http.StatusPreconditionRequired, Message: "If-Match header is required", Data: map[string]interface{}{"data": nil}})
+		return
+	}
+	version, wildcard, err := utils.ParseIfMatch(ifMatch)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Invalid If-Match header", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+
+	item, err := items.FindByID(ctx, organisationId, itemObjectId)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, responses.ItemResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	if wildcard {
+		version = item.Version
+	}
+	if version != item.Version {
+		c.Header("ETag", utils.ETag(item.Version))
+		c.JSON(http.StatusPreconditionFailed, responses.ItemResponse{Status: http.StatusPreconditionFailed, Message: "Item was changed by someone else", Data: map[string]interface{}{"data": item}})
+		return
+	}
+
+	if err := apply(ctx, item); err != nil {
+		c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+
+	//Check if the name, SKU, a barcode or the part number is already taken
+	message, itemCheck, err := duplicateItem(ctx, items, item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	if itemCheck != nil {
+		c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: message, Data: map[string]interface{}{"data": itemCheck}})
+		return
+	}
+
+	item.UpdatedAt = time.Now()
+	err = items.Update(ctx, item, version)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, responses.ItemResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err == repositories.ErrVersionConflict {
+			c.JSON(http.StatusPreconditionFailed, responses.ItemResponse{Status: http.StatusPreconditionFailed, Message: "Item was changed by someone else", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err == repositories.ErrDuplicateItem {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: err.Error(), Data: map[string]interface{}{"data": nil}})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+
+	// Return the item
+	c.Header("ETag", utils.ETag(item.Version))
+	c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Item updated", Data: map[string]interface{}{"data": item}})
+}
+
 // DeleteItem godoc
 // @Summary Delete an item
 // @Description Deletes an item
@@ -215,32 +746,30 @@ func UpdateItem() gin.HandlerFunc {
 // @Failure 500 {object} responses.ItemResponse
 // @Router /api/item/{itemId} [delete]
 // @Security BearerAuth
-func DeleteItem() gin.HandlerFunc {
+func DeleteItem(items repositories.ItemRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		itemId := c.Param("itemId")
 		itemObjectId, _ := primitive.ObjectIDFromHex(itemId)
 		defer cancel()
 
-		// Check if the item exists
-		err := itemCollection.FindOne(ctx, bson.M{"_id": itemObjectId}).Err()
+		organisationId, err := tenant.Get(c).Organisation()
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				c.JSON(http.StatusNotFound, responses.ItemResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": err.Error()}})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		// Find the item in the database
-		result, err := itemCollection.DeleteOne(ctx, bson.M{"_id": itemObjectId})
+		// Delete the item from the database
+		err = items.Delete(ctx, organisationId, itemObjectId)
 		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.ItemResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		// Return the item
-		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Item deleted", Data: map[string]interface{}{"data": result}})
+		c.JSON(http.StatusOK, responses.ItemResponse{Status: http.StatusOK, Message: "Item deleted", Data: map[string]interface{}{"data": nil}})
 	}
 }
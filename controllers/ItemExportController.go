@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/spreadsheet"
+	"poosible-backend/tenant"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// exportTimeout bounds how long an export can take, streaming every item of a large organisation takes a while
+const exportTimeout = 5 * time.Minute
+
+// itemExportColumns are the headers of item exports. They are only ever added to, and are named like the columns item
+// imports read, so an edited export can be imported again.
+var itemExportColumns = []string{
+	"id", "name", "description", "price", "currency", "sku", "barcodes", "manufacturer", "mpn",
+	"tax_rate_id", "preferred_supplier_id", "category_id", "track_stock", "reorder_point", "reorder_quantity",
+	"created_at", "updated_at",
+}
+
+// ExportItems godoc
+// @Summary Export items
+// @Description Downloads the items of the organisation as a CSV or XLSX file, one row per item ordered by name. Items are filtered like GET /items. The file is streamed as the items are read, so a failure halfway cuts the file short: a CSV file then lacks its last rows and an XLSX file cannot be opened.
+// @Tags Item
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param format query string false "csv or xlsx" default(csv)
+// @Param name query string false "Part of the name"
+// @Param category_id query string false "Category ID"
+// @Param price_min query string false "Lowest price" example(10.00)
+// @Param price_max query string false "Highest price" example(99.99)
+// @Param updated_since query string false "Only items changed at or after this RFC 3339 time"
+// @Success 200 {file} file
+// @Failure 400 {object} responses.ItemResponse
+// @Failure 404 {object} responses.ItemResponse
+// @Failure 500 {object} responses.ItemResponse
+// @Router /api/items/export [get]
+// @Security BearerAuth
+func ExportItems(items repositories.ItemRepository, organisations repositories.OrganisationRepository, categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), exportTimeout)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		format := spreadsheet.Format(strings.ToLower(c.DefaultQuery("format", string(spreadsheet.CSV))))
+		if format != spreadsheet.CSV && format != spreadsheet.XLSX {
+			c.JSON(http.StatusBadRequest, responses.ItemResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": spreadsheet.ErrFormat.Error()}})
+			return
+		}
+		filter, ok := itemFilter(ctx, c, organisations, categories, organisationId)
+		if !ok {
+			return
+		}
+
+		//The file is only started with the first item, so an error before it can still be answered as usual
+		var writer spreadsheet.Writer
+		start := func() error {
+			fileName := "items-" + time.Now().UTC().Format("2006-01-02") + "." + string(format)
+			c.Header("Content-Type", spreadsheet.ContentType(format))
+			c.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+			c.Status(http.StatusOK)
+			started, err := spreadsheet.NewWriter(format, c.Writer)
+			if err != nil {
+				return err
+			}
+			writer = started
+			header := make([]interface{}, len(itemExportColumns))
+			for i, column := range itemExportColumns {
+				header[i] = column
+			}
+			return writer.Write(header)
+		}
+
+		err = items.Each(ctx, organisationId, filter, func(item *models.Item) error {
+			if writer == nil {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+			return writer.Write(itemExportRow(item))
+		})
+		if err == nil && writer == nil {
+			err = start()
+		}
+		if err != nil && writer == nil {
+			c.JSON(http.StatusInternalServerError, responses.ItemResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err != nil {
+			//The response is already under way, the file is left unfinished so it is not mistaken for a whole one
+			_ = c.Error(err)
+			return
+		}
+		if err := writer.Close(); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// itemExportRow returns the cells of the item in the order of itemExportColumns
+func itemExportRow(item *models.Item) []interface{} {
+	input := itemNewOf(item)
+	return []interface{}{
+		item.ID.Hex(),
+		input.Name,
+		input.Description,
+		spreadsheet.Number(item.Price.Decimal()),
+		item.Price.Currency,
+		input.SKU,
+		strings.Join(input.Barcodes, ", "),
+		input.Manufacturer,
+		input.MPN,
+		input.TaxRateID,
+		input.PreferredSupplierID,
+		input.CategoryID,
+		input.TrackStock,
+		spreadsheet.Number(strconv.FormatFloat(input.ReorderPoint, 'f', -1, 64)),
+		spreadsheet.Number(strconv.FormatFloat(input.ReorderQuantity, 'f', -1, 64)),
+		item.CreatedAt.UTC().Format(time.RFC3339),
+		item.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,522 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"net/http"
+	"poosible-backend/barcode"
+	"poosible-backend/jobs"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/spreadsheet"
+	"poosible-backend/tenant"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxImportFileSize is the largest file that can be imported, about a hundred thousand rows of a typical part list
+const maxImportFileSize = 10 << 20
+
+// importRequiredColumns are the fields every row needs, so a file without a column for them cannot be imported
+var importRequiredColumns = []string{"name", "description", "price"}
+
+// ImportItems godoc
+// @Summary Import items
+// @Description Uploads a CSV or XLSX file of items and imports it in the background. The first row holds the column headers, which are mapped to item fields by mapping. Every row is checked against the same rules as creating an item and updates the item with the same SKU or name, if there is one, or creates one. Columns missing from the file leave the fields of updated items as they are. A dry run only reports what the import would do. The progress and the errors of every row are read from GET /items/import/{importId}.
+// @Tags Item
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file"
+// @Param dry_run formData bool false "Only check the rows"
+// @Param upsert_by formData string false "sku or name" default(sku)
+// @Param mapping formData string false "JSON object from item fields to column headers"
+// @Success 202 {object} responses.ItemImportResponse
+// @Failure 400 {object} responses.ItemImportResponse
+// @Failure 500 {object} responses.ItemImportResponse
+// @Failure 503 {object} responses.ItemImportResponse
+// @Router /api/items/import [post]
+// @Security BearerAuth
+func ImportItems(imports *jobs.Imports, items repositories.ItemRepository, organisations repositories.OrganisationRepository, taxRates repositories.TaxRateRepository, suppliers repositories.SupplierRepository, categories repositories.CategoryRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input models.ItemImportNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the form to the struct
+		if err := c.ShouldBind(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if input.UpsertBy == "" {
+			input.UpsertBy = "sku"
+		}
+		mapping := map[string]string{}
+		if input.Mapping != "" {
+			if err := json.Unmarshal([]byte(input.Mapping), &mapping); err != nil {
+				c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "Invalid mapping", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+		}
+
+		rows, fileName, err := importFile(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "Invalid file", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		columns, err := importColumns(rows[0], mapping)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "Invalid mapping", Data: map[string]interface{}{"data": err.Error(), "columns": models.ItemImportColumns}})
+			return
+		}
+		if _, ok := columns[input.UpsertBy]; !ok {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "Invalid mapping", Data: map[string]interface{}{"data": "the file has no column for " + input.UpsertBy + " to find the items to update by", "columns": models.ItemImportColumns}})
+			return
+		}
+
+		organisation, orgErr := organisations.FindByID(ctx, organisationId)
+		if orgErr != nil {
+			c.JSON(http.StatusInternalServerError, responses.ItemImportResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": orgErr.Error()}})
+			return
+		}
+		currency, err := money.CurrencyAlpha(organisation.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+			return
+		}
+
+		importer := &itemImporter{
+			items:      items,
+			taxRates:   taxRates,
+			suppliers:  suppliers,
+			categories: categories,
+			currency:   currency,
+			columns:    columns,
+			seen:       map[string]int{},
+		}
+		//Blank rows are skipped, rows keep the number they have in the file
+		for index, row := range rows[1:] {
+			if strings.TrimSpace(strings.Join(row, "")) != "" {
+				importer.rows = append(importer.rows, importLine{number: index + 2, cells: row})
+			}
+		}
+
+		resolved := map[string]string{}
+		for field, column := range columns {
+			resolved[field] = strings.TrimSpace(rows[0][column])
+		}
+		importer.itemImport = &models.ItemImport{
+			FileName:       fileName,
+			DryRun:         input.DryRun,
+			UpsertBy:       input.UpsertBy,
+			Mapping:        resolved,
+			Total:          len(importer.rows),
+			Errors:         []models.ItemImportRowError{},
+			CreatedBy:      tenant.Get(c).User.ID,
+			OrganisationID: organisationId,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+
+		err = imports.Enqueue(ctx, importer.itemImport, importer.row)
+		if err != nil {
+			if err == jobs.ErrImportsBusy {
+				c.JSON(http.StatusServiceUnavailable, responses.ItemImportResponse{Status: http.StatusServiceUnavailable, Message: "Too many imports are waiting", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.ItemImportResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, responses.ItemImportResponse{Status: http.StatusAccepted, Message: "Import queued", Data: map[string]interface{}{"data": importer.itemImport}})
+	}
+}
+
+// GetItemImport godoc
+// @Summary Get an item import
+// @Description Gets the status and progress of an item import, and why its failed rows were not imported
+// @Tags Item
+// @Accept json
+// @Produce json
+// @Param importId path string true "Import ID"
+// @Success 200 {object} responses.ItemImportResponse
+// @Failure 404 {object} responses.ItemImportResponse
+// @Failure 500 {object} responses.ItemImportResponse
+// @Router /api/items/import/{importId} [get]
+// @Security BearerAuth
+func GetItemImport(itemImports repositories.ItemImportRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		importId := c.Param("importId")
+		importObjectId, _ := primitive.ObjectIDFromHex(importId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.ItemImportResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		itemImport, err := itemImports.FindByID(ctx, organisationId, importObjectId)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.ItemImportResponse{Status: http.StatusNotFound, Message: "Import not found", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.ItemImportResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.ItemImportResponse{Status: http.StatusOK, Message: "Import found", Data: map[string]interface{}{"data": itemImport}})
+	}
+}
+
+// importFile reads the rows of the uploaded file and returns them together with the name of the file
+func importFile(c *gin.Context) ([][]string, string, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, "", errors.New("a file is required")
+	}
+	if header.Size > maxImportFileSize {
+		return nil, "", fmt.Errorf("file is larger than %d MB", maxImportFileSize>>20)
+	}
+	format, err := spreadsheet.FormatOf(header.Filename)
+	if err != nil {
+		return nil, "", err
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(io.LimitReader(file, maxImportFileSize))
+	if err != nil {
+		return nil, "", err
+	}
+	rows, err := spreadsheet.Read(format, content)
+	if err != nil {
+		return nil, "", err
+	}
+	return rows, header.Filename, nil
+}
+
+// importColumns returns the column of the header each field is read from. A field is read from the column the mapping
+// names, or from the column named like the field, ignoring case.
+func importColumns(header []string, mapping map[string]string) (map[string]int, error) {
+	known := map[string]bool{}
+	for _, field := range models.ItemImportColumns {
+		known[field] = true
+	}
+	for field := range mapping {
+		if !known[field] {
+			return nil, fmt.Errorf("%s is not an item field that can be imported", field)
+		}
+	}
+
+	headers := map[string]int{}
+	for column, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := headers[name]; !ok && name != "" {
+			headers[name] = column
+		}
+	}
+
+	columns := map[string]int{}
+	for _, field := range models.ItemImportColumns {
+		name, mapped := mapping[field]
+		if !mapped {
+			name = field
+		}
+		column, ok := headers[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			if mapped {
+				return nil, fmt.Errorf("the file has no column %q for %s", name, field)
+			}
+			continue
+		}
+		columns[field] = column
+	}
+	for _, field := range importRequiredColumns {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("the file has no column for %s", field)
+		}
+	}
+	return columns, nil
+}
+
+type importLine struct {
+	number int
+	cells  []string
+}
+
+// itemImporter imports the rows of an upload one by one. It remembers the names and codes of the rows it went through,
+// so a row clashing with an earlier one fails the same way whether or not the import is a dry run.
+type itemImporter struct {
+	items      repositories.ItemRepository
+	taxRates   repositories.TaxRateRepository
+	suppliers  repositories.SupplierRepository
+	categories repositories.CategoryRepository
+	itemImport *models.ItemImport
+	currency   string
+	columns    map[string]int
+	rows       []importLine
+	// seen holds the row number of every name and code taken by an earlier row
+	seen map[string]int
+}
+
+func (importer *itemImporter) row(ctx context.Context, index int) (bool, *models.ItemImportRowError) {
+	line := importer.rows[index]
+	fail := func(field string, err error) (bool, *models.ItemImportRowError) {
+		return false, &models.ItemImportRowError{Row: line.number, Field: field, Message: err.Error()}
+	}
+
+	existing, err := importer.existing(ctx, line)
+	if err != nil {
+		return fail(importer.itemImport.UpsertBy, err)
+	}
+	input := &models.ItemNew{}
+	item := &models.Item{
+		OrganisationID: importer.itemImport.OrganisationID,
+		Price:          money.Zero(importer.currency),
+		Version:        1,
+		CreatedAt:      time.Now(),
+	}
+	if existing != nil {
+		input = itemNewOf(existing)
+		item = existing
+	}
+	if field, err := importer.read(line, input); err != nil {
+		return fail(field, err)
+	}
+
+	// Validate the input
+	if err := validate.Struct(input); err != nil {
+		var validationErrors validator.ValidationErrors
+		if errors.As(err, &validationErrors) {
+			return fail(itemNewField(validationErrors[0].StructField()), err)
+		}
+		return fail("", err)
+	}
+	if field, err := applyItem(ctx, importer.taxRates, importer.suppliers, importer.categories, input, item); err != nil {
+		return fail(field, err)
+	}
+
+	//Check if the name, SKU, a barcode or the part number is taken by an earlier row or another item
+	keys := importKeys(item)
+	for _, key := range keys {
+		if row, ok := importer.seen[key.key]; ok {
+			return fail(key.field, fmt.Errorf("%s is also on row %d", key.field, row))
+		}
+	}
+	message, itemCheck, err := duplicateItem(ctx, importer.items, item)
+	if err != nil {
+		return fail("", err)
+	}
+	if itemCheck != nil {
+		return fail("", errors.New(message))
+	}
+	for _, key := range keys {
+		importer.seen[key.key] = line.number
+	}
+
+	if importer.itemImport.DryRun {
+		return existing == nil, nil
+	}
+	item.UpdatedAt = time.Now()
+	if existing != nil {
+		err = importer.items.Update(ctx, item, item.Version)
+	} else {
+		err = importer.items.Create(ctx, item)
+	}
+	if err != nil {
+		return fail("", err)
+	}
+	return existing == nil, nil
+}
+
+// existing finds the item the row updates by its SKU or name, it is nil if the row creates an item
+func (importer *itemImporter) existing(ctx context.Context, line importLine) (*models.Item, error) {
+	value := importer.cell(line, importer.itemImport.UpsertBy)
+	if value == "" {
+		return nil, nil
+	}
+
+	var item *models.Item
+	var err error
+	if importer.itemImport.UpsertBy == "sku" {
+		item, err = importer.items.FindBySKU(ctx, importer.itemImport.OrganisationID, value)
+	} else {
+		item, err = importer.items.FindByName(ctx, importer.itemImport.OrganisationID, value)
+	}
+	if err == repositories.ErrNotFound {
+		return nil, nil
+	}
+	return item, err
+}
+
+func (importer *itemImporter) cell(line importLine, field string) string {
+	column, ok := importer.columns[field]
+	if !ok || column >= len(line.cells) {
+		return ""
+	}
+	return strings.TrimSpace(line.cells[column])
+}
+
+// read sets the fields of the input the file has a column for, an empty cell clears the field. It returns the field
+// whose value cannot be read together with the error.
+func (importer *itemImporter) read(line importLine, input *models.ItemNew) (string, error) {
+	for field := range importer.columns {
+		value := importer.cell(line, field)
+		switch field {
+		case "name":
+			input.Name = value
+		case "description":
+			input.Description = value
+		case "price":
+			if value == "" {
+				return field, errors.New("price is required")
+			}
+			price, err := money.Parse(importDecimal(value), importer.currency)
+			if err != nil {
+				return field, err
+			}
+			input.Price = price
+		case "sku":
+			input.SKU = value
+		case "barcodes":
+			input.Barcodes = strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' || r == '|' })
+		case "manufacturer":
+			input.Manufacturer = value
+		case "mpn":
+			input.MPN = value
+		case "tax_rate_id":
+			input.TaxRateID = value
+		case "preferred_supplier_id":
+			input.PreferredSupplierID = value
+		case "category_id":
+			input.CategoryID = value
+		case "track_stock":
+			trackStock, err := importBool(value)
+			if err != nil {
+				return field, err
+			}
+			input.TrackStock = trackStock
+		case "reorder_point", "reorder_quantity":
+			quantity := 0.0
+			if value != "" {
+				var err error
+				quantity, err = strconv.ParseFloat(importDecimal(value), 64)
+				if err != nil {
+					return field, fmt.Errorf("%s is not a number", value)
+				}
+			}
+			if field == "reorder_point" {
+				input.ReorderPoint = quantity
+			} else {
+				input.ReorderQuantity = quantity
+			}
+		}
+	}
+	return "", nil
+}
+
+// itemNewOf returns the input that leaves the item as it is
+func itemNewOf(item *models.Item) *models.ItemNew {
+	hex := func(id *primitive.ObjectID) string {
+		if id == nil {
+			return ""
+		}
+		return id.Hex()
+	}
+	crossReferences := []models.PartNumberNew{}
+	for _, reference := range item.CrossReferences {
+		crossReferences = append(crossReferences, models.PartNumberNew{Manufacturer: reference.Manufacturer, Number: reference.Number, Type: reference.Type})
+	}
+	return &models.ItemNew{
+		Name:                item.Name,
+		Description:         item.Description,
+		Price:               item.Price,
+		TaxRateID:           hex(item.TaxRateID),
+		TrackStock:          item.TrackStock,
+		ReorderPoint:        item.ReorderPoint,
+		ReorderQuantity:     item.ReorderQuantity,
+		PreferredSupplierID: hex(item.PreferredSupplierID),
+		CategoryID:          hex(item.CategoryID),
+		SKU:                 item.SKU,
+		Barcodes:            item.Barcodes,
+		Manufacturer:        item.Manufacturer,
+		MPN:                 item.MPN,
+		CrossReferences:     crossReferences,
+	}
+}
+
+// itemNewField returns the JSON name of a field of models.ItemNew
+func itemNewField(name string) string {
+	field, ok := reflect.TypeOf(models.ItemNew{}).FieldByName(name)
+	if !ok {
+		return ""
+	}
+	return strings.Split(field.Tag.Get("json"), ",")[0]
+}
+
+type importKey struct {
+	field string
+	key   string
+}
+
+// importKeys are the name and codes of the item that no other item may have
+func importKeys(item *models.Item) []importKey {
+	keys := []importKey{{"name", "name:" + item.Name}}
+	if item.SKU != "" {
+		keys = append(keys, importKey{"sku", "sku:" + item.SKU})
+	}
+	if item.MPN != "" {
+		keys = append(keys, importKey{"mpn", "mpn:" + item.Manufacturer + "/" + item.MPN})
+	}
+	for _, code := range item.Barcodes {
+		for _, equivalent := range barcode.Equivalents(code) {
+			keys = append(keys, importKey{"barcodes", "barcode:" + equivalent})
+		}
+	}
+	return keys
+}
+
+// importDecimal reads a decimal comma, which spreadsheets in many locales write numbers with
+func importDecimal(value string) string {
+	if !strings.Contains(value, ".") && strings.Count(value, ",") == 1 {
+		return strings.Replace(value, ",", ".", 1)
+	}
+	return value
+}
+
+func importBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "", "no", "n", "false", "0":
+		return false, nil
+	case "yes", "y", "true", "1":
+		return true, nil
+	}
+	return false, fmt.Errorf("%s is not yes or no", value)
+}
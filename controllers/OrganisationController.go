@@ -2,20 +2,23 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"github.com/biter777/countries"
 	"github.com/gin-gonic/gin"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
-	"poosible-backend/config"
+	"poosible-backend/listing"
+	"poosible-backend/middleware"
 	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
 	"poosible-backend/responses"
-	"poosible-backend/utils"
+	"poosible-backend/tenant"
+	"strings"
 	"time"
 )
 
-var organisationCollection *mongo.Collection = config.GetCollection(config.Database, "organisations")
+var errNumberingFixed = errors.New("invoice numbering is fixed once invoices are issued")
 
 // CreateOrganisation godoc
 // @Summary Create an organisation
@@ -29,24 +32,13 @@ var organisationCollection *mongo.Collection = config.GetCollection(config.Datab
 // @Failure 500 {object} responses.OrganisationResponse
 // @Router /api/organisation [post]
 // @Security BearerAuth
-func CreateOrganisation() gin.HandlerFunc {
+func CreateOrganisation(organisations repositories.OrganisationRepository, users repositories.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		claims, err := utils.GetClaims(c)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
-			return
-		}
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		userID, ok := claims["user_id"].(string)
-		userObjectID, _ := primitive.ObjectIDFromHex(userID)
+		user := tenant.Get(c).User
 		var organisation *models.OrganisationNew
 		defer cancel()
 
-		if !ok {
-			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "Invalid user id"}})
-			return
-		}
-
 		// Bind the JSON body to the struct
 		if err := c.ShouldBindJSON(&organisation); err != nil {
 			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
@@ -65,48 +57,50 @@ func CreateOrganisation() gin.HandlerFunc {
 			return
 		}
 
-		//check if user already has an organisation
-		var user *models.User
-		err = userCollection.FindOne(ctx, bson.M{"_id": userObjectID}).Decode(&user)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": err.Error()}})
+		//Check if the currency is a known ISO 4217 currency
+		if _, err := money.CurrencyAlpha(organisation.Currency); err != nil {
+			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "Unknown currency code"}})
 			return
 		}
 
+		//check if user already has an organisation
 		if user.OrganisationID != nil {
 			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "User already has an organisation"}})
 			return
 		}
 
 		newOrganisation := models.Organisation{
-			Name:        organisation.Name,
-			Description: organisation.Description,
-			Phone:       organisation.Phone,
-			Address:     organisation.Address,
-			Logo:        organisation.Logo,
-			Country:     organisation.Country,
-			Currency:    organisation.Currency,
-			ZipCode:     organisation.ZipCode,
-			UpdatedAt:   time.Now(),
-			CreatedAt:   time.Now(),
-		}
-
-		result, err := organisationCollection.InsertOne(ctx, newOrganisation)
+			Name:               organisation.Name,
+			Description:        organisation.Description,
+			Phone:              organisation.Phone,
+			Address:            organisation.Address,
+			Logo:               organisation.Logo,
+			Country:            organisation.Country,
+			Currency:           organisation.Currency,
+			PricesIncludeTax:   organisation.PricesIncludeTax,
+			AllowNegativeStock: organisation.AllowNegativeStock,
+			ZipCode:            organisation.ZipCode,
+			InvoiceNumbering:   organisation.InvoiceNumbering,
+			UpdatedAt:          time.Now(),
+			CreatedAt:          time.Now(),
+		}
+
+		err := organisations.Create(ctx, &newOrganisation)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error creating organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Organisation created successfully", Data: map[string]interface{}{"data": result}})
-
 		//update user organization ID in database
-		update := bson.M{"$set": bson.M{"organisation_id": result.InsertedID}}
-		_, err = userCollection.UpdateOne(ctx, bson.M{"_id": userObjectID}, update)
+		user.OrganisationID = &newOrganisation.ID
+		user.Role = models.RoleOwner
+		err = users.Update(ctx, user)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error settings organization for user", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
+		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Organisation created successfully", Data: map[string]interface{}{"data": newOrganisation}})
 	}
 }
 
@@ -121,60 +115,276 @@ func CreateOrganisation() gin.HandlerFunc {
 // @Failure 500 {object} responses.OrganisationResponse
 // @Router /api/organisation [get]
 // @Security BearerAuth
-func GetOrganisation() gin.HandlerFunc {
+func GetOrganisation(organisations repositories.OrganisationRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		var organisation *models.Organisation
 		defer cancel()
 
-		user, _err := CurrentUser(c)
-		if _err != nil {
-			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": _err.Error()}})
+		organisationID, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "User does not have an organisation"}})
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, organisationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		if user.OrganisationID == nil {
+		currencyAlpha, _ := money.CurrencyAlpha(organisation.Currency)
+
+		organisationPublic := models.OrganisationPublic{
+			ID:                 organisation.ID,
+			Name:               organisation.Name,
+			Description:        organisation.Description,
+			Phone:              organisation.Phone,
+			Logo:               organisation.Logo,
+			Country:            countries.ByNumeric(organisation.Country).Info().Name,
+			Address:            organisation.Address,
+			ZipCode:            organisation.ZipCode,
+			Currency:           currencyAlpha,
+			PricesIncludeTax:   organisation.PricesIncludeTax,
+			AllowNegativeStock: organisation.AllowNegativeStock,
+		}
+
+		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Organisation retrieved successfully", Data: map[string]interface{}{"data": organisationPublic}})
+	}
+}
+
+// AddOrganisationMember godoc
+// @Summary Invite a member
+// @Description Invites an existing user without an organisation to join the organisation with the given role. The user joins only once they accept the invitation, inviting them again replaces the role offered.
+// @Tags Organisation
+// @Accept json
+// @Produce json
+// @Param member body models.MemberNew true "Member data"
+// @Success 200 {object} responses.OrganisationResponse
+// @Failure 400 {object} responses.OrganisationResponse
+// @Failure 403 {object} responses.OrganisationResponse
+// @Failure 404 {object} responses.OrganisationResponse
+// @Failure 500 {object} responses.OrganisationResponse
+// @Router /api/organisation/member [post]
+// @Security BearerAuth
+func AddOrganisationMember(organisations repositories.OrganisationRepository, users repositories.UserRepository, roles repositories.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var member *models.MemberNew
+		defer cancel()
+
+		organisationID, err := tenant.Get(c).Organisation()
+		if err != nil {
 			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "User does not have an organisation"}})
 			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&member); err != nil {
+			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
 
+		// Validate the input
+		if err := validate.Struct(member); err != nil {
+			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
 		}
+		member.Email = strings.ToLower(member.Email)
 
-		organisationObjectID, _err := primitive.ObjectIDFromHex(user.OrganisationID.Hex())
+		permissions, err := middleware.RolePermissions(ctx, roles, organisationID, member.Role)
+		if err != nil {
+			if err == middleware.ErrUnknownRole {
+				c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "Unknown role"}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error resolving permissions", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if !canGrant(c, permissions) {
+			c.JSON(http.StatusForbidden, responses.OrganisationResponse{Status: http.StatusForbidden, Message: "Role grants permissions you do not have", Data: map[string]interface{}{"data": nil}})
+			return
+		}
 
-		err := organisationCollection.FindOne(ctx, bson.M{"_id": organisationObjectID}).Decode(&organisation)
+		user, err := users.FindByEmail(ctx, member.Email)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.OrganisationResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		if user.OrganisationID != nil {
+			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "User already belongs to an organisation"}})
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, organisationID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		//get currency alpha
-		currencies := countries.AllCurrenciesInfo()
-		var currencyAlpha string
-		for _, currency := range currencies {
-			if int(currency.Code) == organisation.Currency {
-				currencyAlpha = currency.Alpha
+		invitation := models.MemberInvitation{
+			OrganisationID:   organisationID,
+			OrganisationName: organisation.Name,
+			Role:             member.Role,
+			InvitedBy:        tenant.Get(c).User.ID,
+			InvitedAt:        primitive.NewDateTimeFromTime(time.Now()),
+		}
+		user.Invitations = append(withoutInvitation(user.Invitations, organisationID), invitation)
+		err = users.Update(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error inviting member", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Invitation sent successfully", Data: map[string]interface{}{"data": invitation}})
+	}
+}
+
+// GetInvitations godoc
+// @Summary Get invitations
+// @Description Gets the invitations to join an organisation that the current user has not answered yet
+// @Tags Organisation
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.OrganisationResponse
+// @Router /api/user/current/invitations [get]
+// @Security BearerAuth
+func GetInvitations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		invitations := tenant.Get(c).User.Invitations
+		if invitations == nil {
+			invitations = []models.MemberInvitation{}
+		}
+
+		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Invitations retrieved successfully", Data: map[string]interface{}{"data": invitations}})
+	}
+}
+
+// AcceptInvitation godoc
+// @Summary Accept an invitation
+// @Description Joins the organisation with the role it offered. The user must not belong to an organisation, joining one drops their other invitations.
+// @Tags Organisation
+// @Accept json
+// @Produce json
+// @Param organisationId path string true "Organisation ID"
+// @Success 200 {object} responses.OrganisationResponse
+// @Failure 400 {object} responses.OrganisationResponse
+// @Failure 404 {object} responses.OrganisationResponse
+// @Failure 500 {object} responses.OrganisationResponse
+// @Router /api/user/current/invitations/{organisationId}/accept [post]
+// @Security BearerAuth
+func AcceptInvitation(users repositories.UserRepository, roles repositories.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		user := tenant.Get(c).User
+
+		invitation, ok := findInvitation(c, user)
+		if !ok {
+			return
+		}
+
+		//Check if user already has an organisation
+		if user.OrganisationID != nil {
+			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "User already belongs to an organisation"}})
+			return
+		}
+
+		//Check if the role still exists, the organisation may have deleted it since
+		_, err := middleware.RolePermissions(ctx, roles, invitation.OrganisationID, invitation.Role)
+		if err != nil {
+			if err == middleware.ErrUnknownRole {
+				c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "The role offered no longer exists"}})
+				return
 			}
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error resolving permissions", Data: map[string]interface{}{"data": err.Error()}})
+			return
 		}
 
-		organisationPublic := models.OrganisationPublic{
-			ID:          organisation.ID,
-			Name:        organisation.Name,
-			Description: organisation.Description,
-			Phone:       organisation.Phone,
-			Logo:        organisation.Logo,
-			Country:     countries.ByNumeric(organisation.Country).Info().Name,
-			Address:     organisation.Address,
-			ZipCode:     organisation.ZipCode,
-			Currency:    currencyAlpha,
+		user.OrganisationID = &invitation.OrganisationID
+		user.Role = invitation.Role
+		user.Invitations = nil
+		err = users.Update(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error joining organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
 		}
 
-		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Organisation retrieved successfully", Data: map[string]interface{}{"data": organisationPublic}})
+		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Invitation accepted successfully", Data: map[string]interface{}{"data": invitation}})
+	}
+}
+
+// DeclineInvitation godoc
+// @Summary Decline an invitation
+// @Description Declines an invitation to join an organisation
+// @Tags Organisation
+// @Accept json
+// @Produce json
+// @Param organisationId path string true "Organisation ID"
+// @Success 200 {object} responses.OrganisationResponse
+// @Failure 400 {object} responses.OrganisationResponse
+// @Failure 404 {object} responses.OrganisationResponse
+// @Failure 500 {object} responses.OrganisationResponse
+// @Router /api/user/current/invitations/{organisationId} [delete]
+// @Security BearerAuth
+func DeclineInvitation(users repositories.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		user := tenant.Get(c).User
+
+		invitation, ok := findInvitation(c, user)
+		if !ok {
+			return
+		}
+
+		user.Invitations = withoutInvitation(user.Invitations, invitation.OrganisationID)
+		err := users.Update(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error declining invitation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Invitation declined successfully", Data: map[string]interface{}{"data": invitation}})
+	}
+}
+
+// findInvitation finds the invitation of the user from the organisation in the path and writes the response if there is none
+func findInvitation(c *gin.Context, user *models.User) (models.MemberInvitation, bool) {
+	organisationID, err := primitive.ObjectIDFromHex(c.Param("organisationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "Invalid organisation ID"}})
+		return models.MemberInvitation{}, false
+	}
+
+	for _, invitation := range user.Invitations {
+		if invitation.OrganisationID == organisationID {
+			return invitation, true
+		}
+	}
+
+	c.JSON(http.StatusNotFound, responses.OrganisationResponse{Status: http.StatusNotFound, Message: "Invitation not found", Data: map[string]interface{}{"data": nil}})
+	return models.MemberInvitation{}, false
+}
+
+// withoutInvitation returns a copy of the invitations without the one from the organisation
+func withoutInvitation(invitations []models.MemberInvitation, organisationID primitive.ObjectID) []models.MemberInvitation {
+	var remaining []models.MemberInvitation
+	for _, invitation := range invitations {
+		if invitation.OrganisationID != organisationID {
+			remaining = append(remaining, invitation)
+		}
 	}
+	return remaining
 }
 
 // UpdateOrganisation godoc
 // @Summary Update an organisation
-// @Description Updates an organisation
+// @Description Updates an organisation. The currency cannot change once the organisation has items, invoices or cash sessions, which hold amounts in it. The prefix and yearly reset of the invoice numbering cannot change once the organisation has issued an invoice.
 // @Tags Organisation
 // @Accept json
 // @Produce json
@@ -182,10 +392,12 @@ func GetOrganisation() gin.HandlerFunc {
 // @Param organisation body models.OrganisationNew true "Organisation object to be updated"
 // @Success 200 {object} responses.OrganisationResponse
 // @Failure 400 {object} responses.OrganisationResponse
+// @Failure 404 {object} responses.OrganisationResponse
+// @Failure 409 {object} responses.OrganisationResponse
 // @Failure 500 {object} responses.OrganisationResponse
 // @Router /api/organisation/{organisationId} [put]
 // @Security BearerAuth
-func UpdateOrganisation() gin.HandlerFunc {
+func UpdateOrganisation(organisations repositories.OrganisationRepository, items repositories.ItemRepository, invoices repositories.InvoiceRepository, sessions repositories.CashSessionRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		organisationID := c.Param("organisationId")
@@ -193,6 +405,12 @@ func UpdateOrganisation() gin.HandlerFunc {
 		var organisation *models.OrganisationNew
 		defer cancel()
 
+		//Organisations of other tenants do not exist for the caller
+		if !tenant.Get(c).Owns(organisationObjectID) {
+			c.JSON(http.StatusNotFound, responses.OrganisationResponse{Status: http.StatusNotFound, Message: "Organisation not found", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+
 		// Bind the JSON body to the struct
 		if err := c.ShouldBindJSON(&organisation); err != nil {
 			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
@@ -205,17 +423,92 @@ func UpdateOrganisation() gin.HandlerFunc {
 			return
 		}
 
-		update := bson.M{"$set": bson.M{"name": organisation.Name, "country_code": organisation.Country, "currency_code": organisation.Country, "zip_code": organisation.ZipCode, "description": organisation.Description, "logo": organisation.Logo, "address": organisation.Address, "phone": organisation.Phone, "updated_at": time.Now()}}
-		_, err := organisationCollection.UpdateOne(ctx, bson.M{"_id": organisationObjectID}, update)
+		//Check if the currency is a known ISO 4217 currency
+		if _, err := money.CurrencyAlpha(organisation.Currency); err != nil {
+			c.JSON(http.StatusBadRequest, responses.OrganisationResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": "Unknown currency code"}})
+			return
+		}
+
+		existingOrganisation, err := organisations.FindByID(ctx, organisationObjectID)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.OrganisationResponse{Status: http.StatusNotFound, Message: "Organisation not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if amounts are stored in the currency already, they would silently turn into amounts of the new one
+		if organisation.Currency != existingOrganisation.Currency && !currencyUnused(ctx, c, organisationObjectID, items, invoices, sessions) {
+			return
+		}
+
+		existingOrganisation.Name = organisation.Name
+		existingOrganisation.Country = organisation.Country
+		existingOrganisation.Currency = organisation.Currency
+		existingOrganisation.PricesIncludeTax = organisation.PricesIncludeTax
+		existingOrganisation.AllowNegativeStock = organisation.AllowNegativeStock
+		existingOrganisation.ZipCode = organisation.ZipCode
+		existingOrganisation.Description = organisation.Description
+		existingOrganisation.Logo = organisation.Logo
+		existingOrganisation.Address = organisation.Address
+		existingOrganisation.Phone = organisation.Phone
+		existingOrganisation.UpdatedAt = time.Now()
+
+		if organisation.InvoiceNumbering == nil {
+			err = organisations.Update(ctx, existingOrganisation)
+		} else {
+			current := existingOrganisation.InvoiceNumberingSettings()
+			restarts := organisation.InvoiceNumbering.Prefix != current.Prefix || organisation.InvoiceNumbering.YearlyReset != current.YearlyReset
+			existingOrganisation.InvoiceNumbering = organisation.InvoiceNumbering
+
+			//The numbering changes while no invoice is issued, so the check cannot miss one issued meanwhile
+			err = invoices.ChangeNumbering(ctx, organisationObjectID, *organisation.InvoiceNumbering, func(ctx context.Context, issued bool) error {
+				//Check if the numbering would restart or rename the numbers of the invoices issued already
+				if issued && restarts {
+					return errNumberingFixed
+				}
+				return organisations.Update(ctx, existingOrganisation)
+			})
+			if err == errNumberingFixed {
+				c.JSON(http.StatusConflict, responses.OrganisationResponse{Status: http.StatusConflict, Message: "Invoice numbering prefix and yearly reset cannot change once invoices are issued", Data: map[string]interface{}{"data": current}})
+				return
+			}
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error updating organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		//get updated organisation
-		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Organisation updated successfully", Data: map[string]interface{}{"data": organisation}})
+		c.JSON(http.StatusOK, responses.OrganisationResponse{Status: http.StatusOK, Message: "Organisation updated successfully", Data: map[string]interface{}{"data": existingOrganisation}})
+	}
+}
 
+// currencyUnused reports whether the organisation has no items, invoices or cash sessions holding amounts in its
+// currency, or writes the error response
+func currencyUnused(ctx context.Context, c *gin.Context, organisationID primitive.ObjectID, items repositories.ItemRepository, invoices repositories.InvoiceRepository, sessions repositories.CashSessionRepository) bool {
+	first := listing.Query{Limit: 1}
+	priced, _, err := items.FindAll(ctx, organisationID, models.ItemFilter{}, first)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting items", Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	issued, _, err := invoices.FindAll(ctx, organisationID, models.InvoiceFilter{}, first)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting invoices", Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	opened, _, err := sessions.FindAll(ctx, organisationID, models.CashSessionFilter{}, first)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error getting cash sessions", Data: map[string]interface{}{"data": err.Error()}})
+		return false
 	}
+	if len(priced) > 0 || len(issued) > 0 || len(opened) > 0 {
+		c.JSON(http.StatusConflict, responses.OrganisationResponse{Status: http.StatusConflict, Message: "Currency cannot change once items, invoices or cash sessions exist", Data: map[string]interface{}{"data": nil}})
+		return false
+	}
+	return true
 }
 
 // DeleteOrganisation godoc
@@ -227,10 +520,11 @@ func UpdateOrganisation() gin.HandlerFunc {
 // @Param organisationId path string true "Organisation ID"
 // @Success 200 {object} responses.OrganisationResponse
 // @Failure 400 {object} responses.OrganisationResponse
+// @Failure 404 {object} responses.OrganisationResponse
 // @Failure 500 {object} responses.OrganisationResponse
 // @Router /api/organisation/{organisationId} [delete]
 // @Security BearerAuth
-func DeleteOrganisation() gin.HandlerFunc {
+func DeleteOrganisation(organisations repositories.OrganisationRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		//get organisation ID
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -238,11 +532,21 @@ func DeleteOrganisation() gin.HandlerFunc {
 		organisationObjectID, _ := primitive.ObjectIDFromHex(organisationID)
 		defer cancel()
 
+		//Organisations of other tenants do not exist for the caller
+		if !tenant.Get(c).Owns(organisationObjectID) {
+			c.JSON(http.StatusNotFound, responses.OrganisationResponse{Status: http.StatusNotFound, Message: "Organisation not found", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+
 		//TODO: check if organisation has users and delete id from users
 
 		//Delete organisation
-		_, err := organisationCollection.DeleteOne(ctx, bson.M{"_id": organisationObjectID})
+		err := organisations.Delete(ctx, organisationObjectID)
 		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.OrganisationResponse{Status: http.StatusNotFound, Message: "Organisation not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, responses.OrganisationResponse{Status: http.StatusInternalServerError, Message: "Error deleting organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
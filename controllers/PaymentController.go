@@ -0,0 +1,442 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/gateway"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// TakePayment godoc
+// @Summary Take a payment
+// @Description Takes a payment against an invoice, split over up to ten tenders of cash, card, bank transfer, voucher, on account or store credit. A payment may leave part of the balance due but never exceed it, the balance due is what is left of the invoice after its credit notes. Cash tenders need an open cash session of the user and work out the change from the cash tendered, and one of them may leave out its amount to pay whatever the other tenders leave. Card tenders are captured through the payment gateway, if any card is declined the payment is not taken and the cards charged for it are refunded. Cards that could not be refunded are listed under transactions to be refunded by hand. Vouchers need their code as reference, on account and store credit need an invoice of a customer, who must have the store credit spent.
+// @Tags Payment
+// @Accept json
+// @Produce json
+// @Param invoiceId path string true "Invoice ID"
+// @Param payment body models.PaymentNew true "Payment data"
+// @Success 200 {object} responses.PaymentResponse
+// @Failure 400 {object} responses.PaymentResponse
+// @Failure 402 {object} responses.PaymentResponse
+// @Failure 404 {object} responses.PaymentResponse
+// @Failure 409 {object} responses.PaymentResponse
+// @Failure 500 {object} responses.PaymentResponse
+// @Failure 502 {object} responses.PaymentResponse
+// @Router /api/invoice/{invoiceId}/payment [post]
+// @Security BearerAuth
+func TakePayment(payments repositories.PaymentRepository, invoices repositories.InvoiceRepository, sessions repositories.CashSessionRepository, cards gateway.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		invoiceId := c.Param("invoiceId")
+		invoiceObjectId, _ := primitive.ObjectIDFromHex(invoiceId)
+		var input *models.PaymentNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		invoice, _, balance, ok := invoicePayments(ctx, c, payments, invoices, organisationId, invoiceObjectId)
+		if !ok {
+			return
+		}
+		if invoice.Type != models.InvoiceTypeInvoice {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Only invoices can be paid", Data: map[string]interface{}{"data": invoice.Type}})
+			return
+		}
+		if balance.Due.IsZero() || balance.Due.IsNegative() {
+			c.JSON(http.StatusConflict, responses.PaymentResponse{Status: http.StatusConflict, Message: "Invoice is paid in full", Data: map[string]interface{}{"data": balance}})
+			return
+		}
+
+		tenders, ok := paymentTenders(c, invoice, balance.Due, cards, input.Tenders)
+		if !ok {
+			return
+		}
+		cashSessionId, ok := paymentCashSession(ctx, c, sessions, organisationId, tenders)
+		if !ok {
+			return
+		}
+		payment := &models.Payment{
+			InvoiceID:      invoice.ID,
+			CustomerID:     invoice.CustomerID,
+			Currency:       invoice.Currency,
+			Tenders:        tenders,
+			Notes:          strings.TrimSpace(input.Notes),
+			ReceivedBy:     tenant.Get(c).User.ID,
+			ReceivedAt:     time.Now(),
+			CashSessionID:  cashSessionId,
+			OrganisationID: organisationId,
+		}
+		if err := payment.Calculate(); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Invalid payment total", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		net, err := balance.Net()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Cards are charged last, once everything else about the payment was checked
+		if failed, err := captureCards(ctx, cards, invoice, payment.Tenders, input.Tenders); err != nil {
+			if len(failed) > 0 {
+				c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Cards were charged but the payment was not taken, refund them by hand", Data: map[string]interface{}{"data": err.Error(), "transactions": failed}})
+				return
+			}
+			if err == gateway.ErrDeclined {
+				c.JSON(http.StatusPaymentRequired, responses.PaymentResponse{Status: http.StatusPaymentRequired, Message: "Card was declined", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			c.JSON(http.StatusBadGateway, responses.PaymentResponse{Status: http.StatusBadGateway, Message: "Payment gateway error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		if err := payments.Record(ctx, payment, net); err != nil {
+			if charged := refundCards(ctx, cards, payment.Tenders, "Payment was not recorded"); len(charged) > 0 {
+				c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Cards were charged but the payment was not recorded, refund them by hand", Data: map[string]interface{}{"data": err.Error(), "transactions": charged}})
+				return
+			}
+			if err == repositories.ErrOverpayment {
+				c.JSON(http.StatusConflict, responses.PaymentResponse{Status: http.StatusConflict, Message: "Payment exceeds the balance due, someone else took a payment meanwhile", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			if err == repositories.ErrInsufficientCredit {
+				c.JSON(http.StatusConflict, responses.PaymentResponse{Status: http.StatusConflict, Message: "Customer does not have enough store credit", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		if balance.Paid, err = balance.Paid.Add(payment.Total); err == nil {
+			balance.Due, err = balance.Due.Sub(payment.Total)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PaymentResponse{Status: http.StatusOK, Message: "Payment taken", Data: map[string]interface{}{"data": payment, "balance": balance}})
+	}
+}
+
+// GetInvoicePayments godoc
+// @Summary Get the payments of an invoice
+// @Description Gets every payment and refund of the invoice, oldest first, together with the balance due after its credit notes
+// @Tags Payment
+// @Accept json
+// @Produce json
+// @Param invoiceId path string true "Invoice ID"
+// @Success 200 {object} responses.PaymentResponse
+// @Failure 404 {object} responses.PaymentResponse
+// @Failure 500 {object} responses.PaymentResponse
+// @Router /api/invoice/{invoiceId}/payments [get]
+// @Security BearerAuth
+func GetInvoicePayments(payments repositories.PaymentRepository, invoices repositories.InvoiceRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		invoiceId := c.Param("invoiceId")
+		invoiceObjectId, _ := primitive.ObjectIDFromHex(invoiceId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		_, result, balance, ok := invoicePayments(ctx, c, payments, invoices, organisationId, invoiceObjectId)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PaymentResponse{Status: http.StatusOK, Message: "Payments found", Data: map[string]interface{}{"data": result, "balance": balance}})
+	}
+}
+
+// GetPayment godoc
+// @Summary Get a payment
+// @Description Gets a payment of the organisation
+// @Tags Payment
+// @Accept json
+// @Produce json
+// @Param paymentId path string true "Payment ID"
+// @Success 200 {object} responses.PaymentResponse
+// @Failure 404 {object} responses.PaymentResponse
+// @Failure 500 {object} responses.PaymentResponse
+// @Router /api/payment/{paymentId} [get]
+// @Security BearerAuth
+func GetPayment(payments repositories.PaymentRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		paymentId := c.Param("paymentId")
+		paymentObjectId, _ := primitive.ObjectIDFromHex(paymentId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		payment, err := payments.FindByID(ctx, organisationId, paymentObjectId)
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PaymentResponse{Status: http.StatusOK, Message: "Payment found", Data: map[string]interface{}{"data": payment}})
+	}
+}
+
+// GetPayments godoc
+// @Summary Get payments
+// @Description Gets a page of the payments of the organisation, newest first. They can also be sorted by received_at or total, prefixed with "-" for descending order.
+// @Tags Payment
+// @Accept json
+// @Produce json
+// @Param invoice_id query string false "Invoice ID"
+// @Param customer_id query string false "Customer ID"
+// @Param method query string false "Payments with a tender of this method"
+// @Param sort query string false "Sort field" default(-received_at)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.PaymentResponse
+// @Failure 400 {object} responses.PaymentResponse
+// @Failure 500 {object} responses.PaymentResponse
+// @Router /api/payments [get]
+// @Security BearerAuth
+func GetPayments(payments repositories.PaymentRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.PaymentSortFields, "-received_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.PaymentSortFields.Names()}})
+			return
+		}
+		filter := models.PaymentFilter{Method: models.PaymentMethod(c.Query("method"))}
+		for param, target := range map[string]**primitive.ObjectID{"invoice_id": &filter.InvoiceID, "customer_id": &filter.CustomerID} {
+			if value := c.Query(param); value != "" {
+				id, err := primitive.ObjectIDFromHex(value)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Invalid " + param, Data: map[string]interface{}{"data": err.Error()}})
+					return
+				}
+				*target = &id
+			}
+		}
+
+		result, paging, err := payments.FindAll(ctx, organisationId, filter, page)
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PaymentResponse{Status: http.StatusOK, Message: "Payments found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// invoicePayments returns the invoice with its payments and refunds and how much of it was paid and credited, or writes the error response
+func invoicePayments(ctx context.Context, c *gin.Context, payments repositories.PaymentRepository, invoices repositories.InvoiceRepository, organisationId primitive.ObjectID, invoiceId primitive.ObjectID) (*models.Invoice, []*models.Payment, models.InvoiceBalance, bool) {
+	invoice, err := invoices.FindByID(ctx, organisationId, invoiceId)
+	if err != nil {
+		respondInvoiceError(c, err)
+		return nil, nil, models.InvoiceBalance{}, false
+	}
+	paid, err := payments.FindByInvoice(ctx, organisationId, invoiceId)
+	if err != nil {
+		respondPaymentError(c, err)
+		return nil, nil, models.InvoiceBalance{}, false
+	}
+	creditNotes := []*models.Invoice{}
+	if invoice.Type == models.InvoiceTypeInvoice {
+		creditNotes, _, err = invoices.FindAll(ctx, organisationId, models.InvoiceFilter{Type: models.InvoiceTypeCreditNote, OriginalInvoiceID: &invoice.ID}, listing.Query{})
+		if err != nil {
+			respondInvoiceError(c, err)
+			return nil, nil, models.InvoiceBalance{}, false
+		}
+	}
+	balance, err := invoice.Balance(paid, creditNotes)
+	if err != nil {
+		respondPaymentError(c, err)
+		return nil, nil, models.InvoiceBalance{}, false
+	}
+	return invoice, paid, balance, true
+}
+
+// paymentTenders prices the tenders in the invoice currency and works out the change of cash tenders, or writes the
+// error response
+func paymentTenders(c *gin.Context, invoice *models.Invoice, due money.Money, cards gateway.PaymentGateway, input []models.PaymentTenderNew) ([]models.PaymentTender, bool) {
+	tenders := make([]models.PaymentTender, len(input))
+	open, remaining := -1, due
+	for i, tenderInput := range input {
+		tender := models.PaymentTender{Method: tenderInput.Method, Reference: strings.TrimSpace(tenderInput.Reference)}
+		switch tender.Method {
+		case models.PaymentCard:
+			if cards == nil {
+				c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Card payments are not set up", Data: map[string]interface{}{"data": tender.Method}})
+				return nil, false
+			}
+		case models.PaymentVoucher:
+			if tender.Reference == "" {
+				c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Vouchers need their code as reference", Data: map[string]interface{}{"data": tender.Method}})
+				return nil, false
+			}
+		case models.PaymentOnAccount, models.PaymentStoreCredit:
+			if invoice.CustomerID == nil {
+				c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Only invoices of a customer can be paid on account or with store credit", Data: map[string]interface{}{"data": tender.Method}})
+				return nil, false
+			}
+		}
+
+		if tenderInput.Tendered != nil {
+			if tender.Method != models.PaymentCash {
+				c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Only cash tenders have a tendered amount", Data: map[string]interface{}{"data": tender.Method}})
+				return nil, false
+			}
+			tendered, ok := paymentAmount(c, *tenderInput.Tendered, invoice.Currency)
+			if !ok {
+				return nil, false
+			}
+			tender.Tendered = &tendered
+		}
+
+		if tenderInput.Amount == nil {
+			if tender.Tendered == nil || open >= 0 {
+				c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Every tender needs an amount, except one cash tender with the cash tendered", Data: map[string]interface{}{"data": i}})
+				return nil, false
+			}
+			open = i
+			tenders[i] = tender
+			continue
+		}
+		amount, ok := paymentAmount(c, *tenderInput.Amount, invoice.Currency)
+		if !ok {
+			return nil, false
+		}
+		tender.Amount = amount
+		var err error
+		if remaining, err = remaining.Sub(amount); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Invalid amount", Data: map[string]interface{}{"data": err.Error()}})
+			return nil, false
+		}
+		tenders[i] = tender
+	}
+	if remaining.IsNegative() {
+		c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Payment exceeds the balance due", Data: map[string]interface{}{"data": due}})
+		return nil, false
+	}
+
+	//The cash tender without an amount pays the rest of the balance, or all of its cash if that is less
+	if open >= 0 {
+		if remaining.IsZero() {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "The other tenders pay the balance due already", Data: map[string]interface{}{"data": due}})
+			return nil, false
+		}
+		tenders[open].Amount = remaining
+		if tenders[open].Tendered.Amount < remaining.Amount {
+			tenders[open].Amount = *tenders[open].Tendered
+		}
+	}
+
+	//Cash handed over beyond the amount of its tender is given back as change
+	for i := range tenders {
+		if tenders[i].Tendered == nil {
+			continue
+		}
+		change, err := tenders[i].Tendered.Sub(tenders[i].Amount)
+		if err != nil || change.IsNegative() {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Cash tendered is less than the amount of its tender", Data: map[string]interface{}{"data": i}})
+			return nil, false
+		}
+		tenders[i].Change = &change
+	}
+	return tenders, true
+}
+
+// paymentAmount converts an amount of a tender to the invoice currency, or writes the error response
+func paymentAmount(c *gin.Context, amount money.Money, currency string) (money.Money, bool) {
+	converted, err := amount.In(currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Invalid amount", Data: map[string]interface{}{"data": err.Error()}})
+		return converted, false
+	}
+	if converted.IsZero() || converted.IsNegative() {
+		c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Amounts must be positive", Data: map[string]interface{}{"data": converted}})
+		return converted, false
+	}
+	return converted, true
+}
+
+// captureCards charges the card tenders through the gateway with the card tokens of their input. If one of them fails
+// the cards charged before it are refunded, and the transactions that could not be refunded are returned with the
+// error.
+func captureCards(ctx context.Context, cards gateway.PaymentGateway, invoice *models.Invoice, tenders []models.PaymentTender, input []models.PaymentTenderNew) ([]string, error) {
+	for i := range tenders {
+		if tenders[i].Method != models.PaymentCard {
+			continue
+		}
+		charge, err := cards.Capture(ctx, gateway.Capture{Amount: tenders[i].Amount, Token: input[i].CardToken, Description: "Invoice " + invoice.Number})
+		if err != nil {
+			return refundCards(ctx, cards, tenders[:i], "Payment was not taken"), err
+		}
+		tenders[i].Card = &models.CardCharge{Gateway: cards.Name(), TransactionID: charge.TransactionID, Brand: charge.Brand, Last4: charge.Last4}
+	}
+	return nil, nil
+}
+
+// refundCards refunds the charged card tenders and returns the transactions that could not be refunded
+func refundCards(ctx context.Context, cards gateway.PaymentGateway, tenders []models.PaymentTender, reason string) []string {
+	failed := []string{}
+	for _, tender := range tenders {
+		if tender.Card == nil {
+			continue
+		}
+		if _, err := cards.Refund(ctx, gateway.Refund{TransactionID: tender.Card.TransactionID, Amount: tender.Amount, Reason: reason}); err != nil {
+			failed = append(failed, tender.Card.TransactionID)
+		}
+	}
+	return failed
+}
+
+// respondPaymentError writes the response for an error returned by the payment repository
+func respondPaymentError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.PaymentResponse{Status: http.StatusNotFound, Message: "Payment not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
@@ -0,0 +1,538 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// CreatePurchaseOrder godoc
+// @Summary Create a purchase order
+// @Description Creates a draft purchase order with a supplier for items that track stock. Lines without a unit cost are priced at the cost of the item's last receipt.
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Param purchaseOrder body models.PurchaseOrderNew true "Purchase order data"
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 400 {object} responses.PurchaseOrderResponse
+// @Failure 404 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/purchase-order [post]
+// @Security BearerAuth
+func CreatePurchaseOrder(purchaseOrders repositories.PurchaseOrderRepository, organisations repositories.OrganisationRepository, suppliers repositories.SupplierRepository, items repositories.ItemRepository, stock repositories.StockRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		var input *models.PurchaseOrderNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		purchaseOrder := &models.PurchaseOrder{
+			Status:         models.PurchaseOrderDraft,
+			Receipts:       []models.PurchaseOrderReceipt{},
+			CreatedBy:      tenant.Get(c).User.ID,
+			OrganisationID: organisationId,
+			Version:        1,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+		if !applyPurchaseOrder(ctx, c, organisations, suppliers, items, stock, purchaseOrder, input) {
+			return
+		}
+
+		err = purchaseOrders.Create(ctx, purchaseOrder)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Purchase order created", Data: map[string]interface{}{"data": purchaseOrder}})
+	}
+}
+
+// GetPurchaseOrder godoc
+// @Summary Get a purchase order
+// @Description Gets a purchase order of the organisation
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Param purchaseOrderId path string true "Purchase order ID"
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 404 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/purchase-order/{purchaseOrderId} [get]
+// @Security BearerAuth
+func GetPurchaseOrder(purchaseOrders repositories.PurchaseOrderRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		purchaseOrderId := c.Param("purchaseOrderId")
+		purchaseOrderObjectId, _ := primitive.ObjectIDFromHex(purchaseOrderId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		purchaseOrder, err := purchaseOrders.FindByID(ctx, organisationId, purchaseOrderObjectId)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Purchase order found", Data: map[string]interface{}{"data": purchaseOrder}})
+	}
+}
+
+// GetPurchaseOrders godoc
+// @Summary Get purchase orders
+// @Description Gets a page of the purchase orders of the organisation, newest first. They can also be sorted by number, status, created_at or updated_at, prefixed with "-" for descending order.
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Param status query string false "Only orders with the status"
+// @Param supplier_id query string false "Only orders from the supplier"
+// @Param item_id query string false "Only orders of the item"
+// @Param sort query string false "Sort field" default(-created_at)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 400 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/purchase-orders [get]
+// @Security BearerAuth
+func GetPurchaseOrders(purchaseOrders repositories.PurchaseOrderRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.PurchaseOrderSortFields, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.PurchaseOrderSortFields.Names()}})
+			return
+		}
+		filter := models.PurchaseOrderFilter{Status: models.PurchaseOrderStatus(c.Query("status"))}
+		if filter.Status != "" && !models.IsValidPurchaseOrderStatus(filter.Status) {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Invalid status", Data: map[string]interface{}{"data": filter.Status}})
+			return
+		}
+		if supplierId := c.Query("supplier_id"); supplierId != "" {
+			supplierObjectId, _ := primitive.ObjectIDFromHex(supplierId)
+			filter.SupplierID = &supplierObjectId
+		}
+		if itemId := c.Query("item_id"); itemId != "" {
+			itemObjectId, _ := primitive.ObjectIDFromHex(itemId)
+			filter.ItemID = &itemObjectId
+		}
+
+		result, paging, err := purchaseOrders.FindAll(ctx, organisationId, filter, page)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Purchase orders found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// UpdatePurchaseOrder godoc
+// @Summary Update a purchase order
+// @Description Replaces the supplier, lines and notes of a purchase order that is still a draft
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Param purchaseOrderId path string true "Purchase order ID"
+// @Param purchaseOrder body models.PurchaseOrderNew true "Purchase order data"
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 400 {object} responses.PurchaseOrderResponse
+// @Failure 404 {object} responses.PurchaseOrderResponse
+// @Failure 409 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/purchase-order/{purchaseOrderId} [put]
+// @Security BearerAuth
+func UpdatePurchaseOrder(purchaseOrders repositories.PurchaseOrderRepository, organisations repositories.OrganisationRepository, suppliers repositories.SupplierRepository, items repositories.ItemRepository, stock repositories.StockRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		purchaseOrderId := c.Param("purchaseOrderId")
+		purchaseOrderObjectId, _ := primitive.ObjectIDFromHex(purchaseOrderId)
+		var input *models.PurchaseOrderNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		purchaseOrder, err := purchaseOrders.FindByID(ctx, organisationId, purchaseOrderObjectId)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+		if purchaseOrder.Status != models.PurchaseOrderDraft {
+			c.JSON(http.StatusConflict, responses.PurchaseOrderResponse{Status: http.StatusConflict, Message: "Only draft purchase orders can be changed", Data: map[string]interface{}{"data": purchaseOrder.Status}})
+			return
+		}
+		if !applyPurchaseOrder(ctx, c, organisations, suppliers, items, stock, purchaseOrder, input) {
+			return
+		}
+		purchaseOrder.UpdatedAt = time.Now()
+
+		err = purchaseOrders.Update(ctx, purchaseOrder, purchaseOrder.Version)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Purchase order updated", Data: map[string]interface{}{"data": purchaseOrder}})
+	}
+}
+
+// TransitionPurchaseOrder godoc
+// @Summary Send or cancel a purchase order
+// @Description Marks a draft purchase order as sent to the supplier, which sets the expected delivery from the supplier's lead time, or cancels an order. Cancelling a partially received order cancels its back-order.
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Param purchaseOrderId path string true "Purchase order ID"
+// @Param transition body models.PurchaseOrderTransition true "New status"
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 400 {object} responses.PurchaseOrderResponse
+// @Failure 404 {object} responses.PurchaseOrderResponse
+// @Failure 409 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/purchase-order/{purchaseOrderId}/status [post]
+// @Security BearerAuth
+func TransitionPurchaseOrder(purchaseOrders repositories.PurchaseOrderRepository, suppliers repositories.SupplierRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		purchaseOrderId := c.Param("purchaseOrderId")
+		purchaseOrderObjectId, _ := primitive.ObjectIDFromHex(purchaseOrderId)
+		var input *models.PurchaseOrderTransition
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		purchaseOrder, err := purchaseOrders.FindByID(ctx, organisationId, purchaseOrderObjectId)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+		if !models.CanTransitionPurchaseOrder(purchaseOrder.Status, input.Status) {
+			c.JSON(http.StatusConflict, responses.PurchaseOrderResponse{Status: http.StatusConflict, Message: "Purchase order cannot move from " + string(purchaseOrder.Status) + " to " + string(input.Status), Data: map[string]interface{}{"data": models.PurchaseOrderTransitions[purchaseOrder.Status]}})
+			return
+		}
+
+		now := time.Now()
+		if input.Status == models.PurchaseOrderSent {
+			supplier, err := suppliers.FindByID(ctx, organisationId, purchaseOrder.SupplierID)
+			if err != nil {
+				respondSupplierError(c, err)
+				return
+			}
+			expectedAt := now.AddDate(0, 0, supplier.LeadTimeDays)
+			purchaseOrder.SentAt = &now
+			purchaseOrder.ExpectedAt = &expectedAt
+		}
+		purchaseOrder.Status = input.Status
+		purchaseOrder.UpdatedAt = now
+
+		err = purchaseOrders.Update(ctx, purchaseOrder, purchaseOrder.Version)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Purchase order status changed", Data: map[string]interface{}{"data": purchaseOrder}})
+	}
+}
+
+// ReceivePurchaseOrder godoc
+// @Summary Receive goods against a purchase order
+// @Description Books a delivery against a sent purchase order. Every received line becomes a receipt stock movement at the actual unit cost, which defaults to the expected one. Lines not delivered in full stay on back-order and the order becomes partially received until everything arrived.
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Param purchaseOrderId path string true "Purchase order ID"
+// @Param receipt body models.PurchaseOrderReceiptNew true "Received goods"
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 400 {object} responses.PurchaseOrderResponse
+// @Failure 404 {object} responses.PurchaseOrderResponse
+// @Failure 409 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/purchase-order/{purchaseOrderId}/receipt [post]
+// @Security BearerAuth
+func ReceivePurchaseOrder(purchaseOrders repositories.PurchaseOrderRepository, stock repositories.StockRepository, locations repositories.StockLocationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		purchaseOrderId := c.Param("purchaseOrderId")
+		purchaseOrderObjectId, _ := primitive.ObjectIDFromHex(purchaseOrderId)
+		var input *models.PurchaseOrderReceiptNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		purchaseOrder, err := purchaseOrders.FindByID(ctx, organisationId, purchaseOrderObjectId)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+		if purchaseOrder.Status != models.PurchaseOrderSent && purchaseOrder.Status != models.PurchaseOrderPartiallyReceived {
+			c.JSON(http.StatusConflict, responses.PurchaseOrderResponse{Status: http.StatusConflict, Message: "Goods can only be received against sent purchase orders", Data: map[string]interface{}{"data": purchaseOrder.Status}})
+			return
+		}
+		location, ok := stockLocation(ctx, c, locations, organisationId, input.LocationID)
+		if !ok {
+			return
+		}
+
+		now := time.Now()
+		receipt := models.PurchaseOrderReceipt{
+			ID:         primitive.NewObjectID(),
+			LocationID: location.ID,
+			Lines:      []models.PurchaseOrderReceiptLine{},
+			Note:       strings.TrimSpace(input.Note),
+			UserID:     tenant.Get(c).User.ID,
+			ReceivedAt: now,
+		}
+		reference := &models.StockReference{Type: models.StockReferencePurchaseOrder, ID: purchaseOrder.ID}
+		movements := []*models.StockMovement{}
+		for _, lineInput := range input.Lines {
+			lineObjectId, _ := primitive.ObjectIDFromHex(lineInput.LineID)
+			line := purchaseOrderLine(purchaseOrder, lineObjectId)
+			if line == nil {
+				c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Purchase order line not found", Data: map[string]interface{}{"data": lineInput.LineID}})
+				return
+			}
+
+			quantity := models.RoundQuantity(lineInput.Quantity)
+			if quantity > line.Outstanding() {
+				c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "More received than is outstanding", Data: map[string]interface{}{"data": line}})
+				return
+			}
+			unitCost := line.UnitCost
+			if lineInput.UnitCost != nil {
+				unitCost, err = lineInput.UnitCost.In(purchaseOrder.Currency)
+				if err != nil || unitCost.IsNegative() {
+					c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Invalid unit cost", Data: map[string]interface{}{"data": lineInput.UnitCost}})
+					return
+				}
+			}
+			line.Received = models.RoundQuantity(line.Received + quantity)
+
+			receipt.Lines = append(receipt.Lines, models.PurchaseOrderReceiptLine{LineID: line.ID, ItemID: line.ItemID, Quantity: quantity, UnitCost: unitCost})
+			cost := unitCost
+			movements = append(movements, &models.StockMovement{
+				Type:       models.StockReceipt,
+				ItemID:     line.ItemID,
+				LocationID: location.ID,
+				Quantity:   quantity,
+				UnitCost:   &cost,
+				Reference:  reference,
+				Note:       purchaseOrder.Number,
+				UserID:     receipt.UserID,
+				CreatedAt:  now,
+			})
+		}
+		purchaseOrder.Receipts = append(purchaseOrder.Receipts, receipt)
+		purchaseOrder.Status = purchaseOrder.ReceiptStatus()
+		purchaseOrder.UpdatedAt = now
+
+		//The stock and the order are written together, so a delivery is never booked twice or only half
+		err = stock.Record(ctx, organisationId, movements, true, func(ctx context.Context) error {
+			return purchaseOrders.Update(ctx, purchaseOrder, purchaseOrder.Version)
+		})
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Goods received", Data: map[string]interface{}{"data": purchaseOrder}})
+	}
+}
+
+// applyPurchaseOrder copies the input onto the purchase order after resolving the supplier and every item within the
+// organisation and pricing the lines in its currency, and writes the error response if one of them is invalid
+func applyPurchaseOrder(ctx context.Context, c *gin.Context, organisations repositories.OrganisationRepository, suppliers repositories.SupplierRepository, items repositories.ItemRepository, stock repositories.StockRepository, purchaseOrder *models.PurchaseOrder, input *models.PurchaseOrderNew) bool {
+	organisationId := purchaseOrder.OrganisationID
+
+	organisation, err := organisations.FindByID(ctx, organisationId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.PurchaseOrderResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	currency, err := money.CurrencyAlpha(organisation.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+		return false
+	}
+
+	supplierId, err := supplierID(ctx, suppliers, organisationId, input.SupplierID)
+	if err != nil {
+		if err == errUnknownSupplier {
+			c.JSON(http.StatusNotFound, responses.PurchaseOrderResponse{Status: http.StatusNotFound, Message: "Supplier not found", Data: map[string]interface{}{"data": input.SupplierID}})
+			return false
+		}
+		respondPurchaseOrderError(c, err)
+		return false
+	}
+
+	lines := []models.PurchaseOrderLine{}
+	for _, lineInput := range input.Lines {
+		itemObjectId, _ := primitive.ObjectIDFromHex(lineInput.ItemID)
+		item, err := items.FindByID(ctx, organisationId, itemObjectId)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.PurchaseOrderResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": lineInput.ItemID}})
+				return false
+			}
+			respondPurchaseOrderError(c, err)
+			return false
+		}
+		if !item.TrackStock {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Item does not track stock", Data: map[string]interface{}{"data": lineInput.ItemID}})
+			return false
+		}
+
+		line := models.PurchaseOrderLine{
+			ID:          primitive.NewObjectID(),
+			ItemID:      item.ID,
+			Description: strings.TrimSpace(lineInput.Description),
+			Quantity:    models.RoundQuantity(lineInput.Quantity),
+			UnitCost:    money.Zero(currency),
+		}
+		if line.Description == "" {
+			line.Description = item.Name
+		}
+		if lineInput.UnitCost != nil {
+			unitCost, err := lineInput.UnitCost.In(currency)
+			if err != nil || unitCost.IsNegative() {
+				c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Invalid unit cost", Data: map[string]interface{}{"data": lineInput.UnitCost}})
+				return false
+			}
+			line.UnitCost = unitCost
+		} else {
+			//Costs recorded in another currency are left for the buyer to fill in
+			lastCost, err := stock.LastUnitCost(ctx, organisationId, item.ID)
+			if err != nil {
+				respondPurchaseOrderError(c, err)
+				return false
+			}
+			if lastCost != nil {
+				if unitCost, err := lastCost.In(currency); err == nil {
+					line.UnitCost = unitCost
+				}
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	purchaseOrder.SupplierID = *supplierId
+	purchaseOrder.Currency = currency
+	purchaseOrder.Lines = lines
+	purchaseOrder.Notes = strings.TrimSpace(input.Notes)
+	if err := purchaseOrder.Calculate(); err != nil {
+		c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Invalid purchase order", Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	return true
+}
+
+// purchaseOrderLine returns the line of the order with the ID, or nil if it has none
+func purchaseOrderLine(purchaseOrder *models.PurchaseOrder, id primitive.ObjectID) *models.PurchaseOrderLine {
+	for i := range purchaseOrder.Lines {
+		if purchaseOrder.Lines[i].ID == id {
+			return &purchaseOrder.Lines[i]
+		}
+	}
+	return nil
+}
+
+// respondPurchaseOrderError writes the response for an error returned by the purchase order repository
+func respondPurchaseOrderError(c *gin.Context, err error) {
+	switch err {
+	case repositories.ErrNotFound:
+		c.JSON(http.StatusNotFound, responses.PurchaseOrderResponse{Status: http.StatusNotFound, Message: "Purchase order not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	case repositories.ErrVersionConflict:
+		c.JSON(http.StatusConflict, responses.PurchaseOrderResponse{Status: http.StatusConflict, Message: "Purchase order was changed by someone else, reload it and try again", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.PurchaseOrderResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
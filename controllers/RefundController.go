@@ -0,0 +1,320 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/gateway"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// RefundCreditNote godoc
+// @Summary Refund a credit note
+// @Description Pays back what a credit note credited, as far as it was paid. A refund never exceeds what the payments of the original invoice paid over what is left of it after its credit notes, nor what the credit note credited. The original method pays the refund back through the tenders the invoice was paid with, newest first, refunding cards through the payment gateway and cash from the open cash session of the user. Store credit gives the customer of the invoice credit they can pay later invoices with. If a card refund fails nothing else is refunded, the cards refunded before it are recorded and the rest can be refunded again.
+// @Tags Payment
+// @Accept json
+// @Produce json
+// @Param invoiceId path string true "Credit note ID"
+// @Param refund body models.RefundNew true "Refund data"
+// @Success 200 {object} responses.PaymentResponse
+// @Failure 400 {object} responses.PaymentResponse
+// @Failure 404 {object} responses.PaymentResponse
+// @Failure 409 {object} responses.PaymentResponse
+// @Failure 500 {object} responses.PaymentResponse
+// @Failure 502 {object} responses.PaymentResponse
+// @Router /api/invoice/{invoiceId}/refund [post]
+// @Security BearerAuth
+func RefundCreditNote(payments repositories.PaymentRepository, invoices repositories.InvoiceRepository, sessions repositories.CashSessionRepository, cards gateway.PaymentGateway) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		creditNoteId := c.Param("invoiceId")
+		creditNoteObjectId, _ := primitive.ObjectIDFromHex(creditNoteId)
+		var input *models.RefundNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		creditNote, err := invoices.FindByID(ctx, organisationId, creditNoteObjectId)
+		if err != nil {
+			respondInvoiceError(c, err)
+			return
+		}
+		if creditNote.Type != models.InvoiceTypeCreditNote || creditNote.OriginalInvoiceID == nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Only credit notes can be refunded", Data: map[string]interface{}{"data": creditNote.Type}})
+			return
+		}
+
+		original, paid, balance, ok := invoicePayments(ctx, c, payments, invoices, organisationId, *creditNote.OriginalInvoiceID)
+		if !ok {
+			return
+		}
+		amount, ok := creditNoteRefundable(c, creditNote, paid, balance)
+		if !ok {
+			return
+		}
+		if input.Amount != nil {
+			requested, ok := paymentAmount(c, *input.Amount, original.Currency)
+			if !ok {
+				return
+			}
+			if requested.Amount > amount.Amount {
+				c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Refund exceeds what the credit note can refund", Data: map[string]interface{}{"data": amount}})
+				return
+			}
+			amount = requested
+		}
+
+		refund := &models.Payment{
+			InvoiceID:      original.ID,
+			CreditNoteID:   &creditNote.ID,
+			CustomerID:     original.CustomerID,
+			Currency:       original.Currency,
+			Notes:          strings.TrimSpace(input.Notes),
+			ReceivedBy:     tenant.Get(c).User.ID,
+			ReceivedAt:     time.Now(),
+			OrganisationID: organisationId,
+		}
+		switch input.Method {
+		case models.RefundStoreCredit:
+			if original.CustomerID == nil {
+				c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Only invoices of a customer can be refunded to store credit", Data: map[string]interface{}{"data": input.Method}})
+				return
+			}
+			refund.Tenders = []models.PaymentTender{{Method: models.PaymentStoreCredit, Amount: amount.Neg()}}
+		default:
+			if refund.Tenders, ok = refundTenders(c, paid, amount, cards); !ok {
+				return
+			}
+		}
+		if refund.CashSessionID, ok = paymentCashSession(ctx, c, sessions, organisationId, refund.Tenders); !ok {
+			return
+		}
+		if err := refund.Calculate(); err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Invalid refund total", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		net, err := balance.Net()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Cards are refunded last, once everything else about the refund was checked
+		refunded, err := refundCardTenders(ctx, cards, refund.Tenders, "Credit note "+creditNote.Number)
+		if err != nil {
+			if len(refunded) == 0 {
+				c.JSON(http.StatusBadGateway, responses.PaymentResponse{Status: http.StatusBadGateway, Message: "Payment gateway error", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			refund.Tenders = refunded
+			if calculateErr := refund.Calculate(); calculateErr != nil {
+				err = calculateErr
+			} else if recordErr := payments.Record(ctx, refund, net); recordErr != nil {
+				err = recordErr
+			}
+			c.JSON(http.StatusBadGateway, responses.PaymentResponse{Status: http.StatusBadGateway, Message: "Card refund failed, only the cards refunded before it were recorded", Data: map[string]interface{}{"data": err.Error(), "refund": refund}})
+			return
+		}
+
+		if err := payments.Record(ctx, refund, net); err != nil {
+			if transactions := cardTransactions(refund.Tenders); len(transactions) > 0 {
+				c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Cards were refunded but the refund was not recorded", Data: map[string]interface{}{"data": err.Error(), "transactions": transactions}})
+				return
+			}
+			if err == repositories.ErrOverpayment {
+				c.JSON(http.StatusConflict, responses.PaymentResponse{Status: http.StatusConflict, Message: "Refund exceeds what was paid, someone else refunded the invoice meanwhile", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		if balance.Paid, err = balance.Paid.Add(refund.Total); err == nil {
+			balance.Due, err = balance.Due.Sub(refund.Total)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PaymentResponse{Status: http.StatusOK, Message: "Refund paid", Data: map[string]interface{}{"data": refund, "balance": balance}})
+	}
+}
+
+// GetStoreCredit godoc
+// @Summary Get the store credit of a customer
+// @Description Gets the store credit the customer was refunded and has not spent yet
+// @Tags Payment
+// @Accept json
+// @Produce json
+// @Param customerId path string true "Customer ID"
+// @Success 200 {object} responses.PaymentResponse
+// @Failure 400 {object} responses.PaymentResponse
+// @Failure 404 {object} responses.PaymentResponse
+// @Failure 500 {object} responses.PaymentResponse
+// @Router /api/customer/{customerId}/store-credit [get]
+// @Security BearerAuth
+func GetStoreCredit(payments repositories.PaymentRepository, customers repositories.CustomerRepository, organisations repositories.OrganisationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		customerId := c.Param("customerId")
+		customerObjectId, _ := primitive.ObjectIDFromHex(customerId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		customer, err := customers.FindByID(ctx, organisationId, customerObjectId)
+		if err != nil {
+			respondCustomerError(c, err)
+			return
+		}
+		organisation, err := organisations.FindByID(ctx, organisationId)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		currency, err := money.CurrencyAlpha(organisation.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+			return
+		}
+
+		credit, err := payments.StoreCredit(ctx, organisationId, customer.ID, currency)
+		if err != nil {
+			respondPaymentError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PaymentResponse{Status: http.StatusOK, Message: "Store credit found", Data: map[string]interface{}{"data": credit}})
+	}
+}
+
+// creditNoteRefundable returns how much of the credit note can still be refunded, or writes the error response. That
+// is what the credit note credited less its earlier refunds, but no more than the payments of the original invoice
+// paid over what is left of it.
+func creditNoteRefundable(c *gin.Context, creditNote *models.Invoice, paid []*models.Payment, balance models.InvoiceBalance) (money.Money, bool) {
+	left := creditNote.Total.Neg()
+	for _, payment := range paid {
+		if payment.CreditNoteID == nil || *payment.CreditNoteID != creditNote.ID {
+			continue
+		}
+		var err error
+		if left, err = left.Add(payment.Total); err != nil {
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return left, false
+		}
+	}
+	if overpaid := balance.Due.Neg(); overpaid.Amount < left.Amount {
+		left = overpaid
+	}
+	if left.IsZero() || left.IsNegative() {
+		c.JSON(http.StatusConflict, responses.PaymentResponse{Status: http.StatusConflict, Message: "Nothing paid is left to refund for the credit note", Data: map[string]interface{}{"data": balance}})
+		return left, false
+	}
+	return left, true
+}
+
+// refundTenders spreads the refund over the tenders of the payments that were not refunded yet, newest first, or
+// writes the error response
+func refundTenders(c *gin.Context, paid []*models.Payment, amount money.Money, cards gateway.PaymentGateway) ([]models.PaymentTender, bool) {
+	tenders := []models.PaymentTender{}
+	remaining := amount
+	for i := len(paid) - 1; i >= 0 && !remaining.IsZero(); i-- {
+		payment := paid[i]
+		if payment.CreditNoteID != nil {
+			continue
+		}
+		refunded, err := payment.Refunded(paid)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return nil, false
+		}
+		for j := len(payment.Tenders) - 1; j >= 0 && !remaining.IsZero(); j-- {
+			tender := payment.Tenders[j]
+			left, err := tender.Amount.Sub(refunded[j])
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, responses.PaymentResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+				return nil, false
+			}
+			if left.IsZero() || left.IsNegative() {
+				continue
+			}
+			if left.Amount > remaining.Amount {
+				left = remaining
+			}
+
+			refund := models.PaymentTender{Method: tender.Method, Amount: left.Neg(), Reference: tender.Reference, RefundOf: &models.TenderReference{PaymentID: payment.ID, Tender: j}}
+			if tender.Card != nil {
+				if cards == nil || cards.Name() != tender.Card.Gateway {
+					c.JSON(http.StatusBadRequest, responses.PaymentResponse{Status: http.StatusBadRequest, Message: "Card refunds are not set up for the gateway the card was charged through", Data: map[string]interface{}{"data": tender.Card.Gateway}})
+					return nil, false
+				}
+				card := *tender.Card
+				refund.Card = &card
+			}
+			tenders = append(tenders, refund)
+			remaining, _ = remaining.Sub(left)
+		}
+	}
+	if !remaining.IsZero() {
+		c.JSON(http.StatusConflict, responses.PaymentResponse{Status: http.StatusConflict, Message: "The tenders the invoice was paid with cannot refund this much, refund to store credit instead", Data: map[string]interface{}{"data": remaining}})
+		return nil, false
+	}
+	return tenders, true
+}
+
+// refundCardTenders refunds the card tenders of a refund through the gateway, replacing the charged transaction with
+// the refund. If one of them fails it returns the card tenders refunded before it with the error.
+func refundCardTenders(ctx context.Context, cards gateway.PaymentGateway, tenders []models.PaymentTender, reason string) ([]models.PaymentTender, error) {
+	refunded := []models.PaymentTender{}
+	for i := range tenders {
+		if tenders[i].Card == nil {
+			continue
+		}
+		transactionId, err := cards.Refund(ctx, gateway.Refund{TransactionID: tenders[i].Card.TransactionID, Amount: tenders[i].Amount.Neg(), Reason: reason})
+		if err != nil {
+			return refunded, err
+		}
+		tenders[i].Card.TransactionID = transactionId
+		refunded = append(refunded, tenders[i])
+	}
+	return refunded, nil
+}
+
+// cardTransactions returns the gateway transactions of the card tenders
+func cardTransactions(tenders []models.PaymentTender) []string {
+	transactions := []string{}
+	for _, tender := range tenders {
+		if tender.Card != nil {
+			transactions = append(transactions, tender.Card.TransactionID)
+		}
+	}
+	return transactions
+}
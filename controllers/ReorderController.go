@@ -0,0 +1,187 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"time"
+)
+
+// GetReorderSuggestions godoc
+// @Summary Get the items that need reordering
+// @Description Gets the items whose stock on hand and on order fell to their reorder point, grouped by preferred supplier. Items without a preferred supplier come last in a group without supplier. The list is kept up to date in the background after every stock movement.
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 400 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/reorder-suggestions [get]
+// @Security BearerAuth
+func GetReorderSuggestions(suggestions repositories.ReorderSuggestionRepository, suppliers repositories.SupplierRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		groups, err := reorderGroups(ctx, suggestions, suppliers, organisationId)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Reorder suggestions found", Data: map[string]interface{}{"data": groups}})
+	}
+}
+
+// OrderReorderSuggestions godoc
+// @Summary Draft purchase orders for the items that need reordering
+// @Description Turns the reorder suggestions into one draft purchase order per preferred supplier, priced at the cost of the last receipt of each item. Items without a preferred supplier are returned as unassigned and stay on the list.
+// @Tags Purchase Order
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.PurchaseOrderResponse
+// @Failure 400 {object} responses.PurchaseOrderResponse
+// @Failure 500 {object} responses.PurchaseOrderResponse
+// @Router /api/reorder-suggestions/purchase-orders [post]
+// @Security BearerAuth
+func OrderReorderSuggestions(suggestions repositories.ReorderSuggestionRepository, suppliers repositories.SupplierRepository, purchaseOrders repositories.PurchaseOrderRepository, organisations repositories.OrganisationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, organisationId)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+		currency, err := money.CurrencyAlpha(organisation.Currency)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+			return
+		}
+
+		groups, err := reorderGroups(ctx, suggestions, suppliers, organisationId)
+		if err != nil {
+			respondPurchaseOrderError(c, err)
+			return
+		}
+
+		created := []*models.PurchaseOrder{}
+		unassigned := []*models.ReorderSuggestion{}
+		for _, group := range groups {
+			if group.SupplierID == nil {
+				unassigned = group.Items
+				continue
+			}
+
+			purchaseOrder := &models.PurchaseOrder{
+				SupplierID:     *group.SupplierID,
+				Status:         models.PurchaseOrderDraft,
+				Currency:       currency,
+				Lines:          []models.PurchaseOrderLine{},
+				Receipts:       []models.PurchaseOrderReceipt{},
+				CreatedBy:      tenant.Get(c).User.ID,
+				OrganisationID: organisationId,
+				Version:        1,
+				UpdatedAt:      time.Now(),
+				CreatedAt:      time.Now(),
+			}
+			for _, suggestion := range group.Items {
+				//Costs recorded in another currency are left for the buyer to fill in
+				unitCost := money.Zero(currency)
+				if suggestion.UnitCost != nil {
+					if cost, err := suggestion.UnitCost.In(currency); err == nil {
+						unitCost = cost
+					}
+				}
+				purchaseOrder.Lines = append(purchaseOrder.Lines, models.PurchaseOrderLine{
+					ID:          primitive.NewObjectID(),
+					ItemID:      suggestion.ItemID,
+					Description: suggestion.ItemName,
+					Quantity:    suggestion.Quantity,
+					UnitCost:    unitCost,
+				})
+			}
+			if err := purchaseOrder.Calculate(); err != nil {
+				c.JSON(http.StatusBadRequest, responses.PurchaseOrderResponse{Status: http.StatusBadRequest, Message: "Invalid purchase order", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+
+			if err := purchaseOrders.Create(ctx, purchaseOrder); err != nil {
+				respondPurchaseOrderError(c, err)
+				return
+			}
+			created = append(created, purchaseOrder)
+
+			//The items are on order now, take them off the list right away rather than waiting for the reorder job
+			for _, suggestion := range group.Items {
+				if err := suggestions.Delete(ctx, organisationId, suggestion.ItemID); err != nil {
+					respondPurchaseOrderError(c, err)
+					return
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, responses.PurchaseOrderResponse{Status: http.StatusOK, Message: "Purchase orders drafted", Data: map[string]interface{}{"data": map[string]interface{}{
+			"purchase_orders": created,
+			"unassigned":      unassigned,
+		}}})
+	}
+}
+
+// reorderGroups groups the reorder suggestions of the organisation by preferred supplier, in the order of the supplier
+// names, with the items preferring no supplier or a deleted one last
+func reorderGroups(ctx context.Context, suggestions repositories.ReorderSuggestionRepository, suppliers repositories.SupplierRepository, organisationId primitive.ObjectID) ([]*models.ReorderGroup, error) {
+	list, err := suggestions.FindAll(ctx, organisationId)
+	if err != nil {
+		return nil, err
+	}
+	all, err := suppliers.FindAll(ctx, organisationId)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[primitive.ObjectID]bool{}
+	for _, supplier := range all {
+		known[supplier.ID] = true
+	}
+	bySupplier := map[primitive.ObjectID][]*models.ReorderSuggestion{}
+	unassigned := []*models.ReorderSuggestion{}
+	for _, suggestion := range list {
+		if suggestion.SupplierID != nil && known[*suggestion.SupplierID] {
+			bySupplier[*suggestion.SupplierID] = append(bySupplier[*suggestion.SupplierID], suggestion)
+		} else {
+			unassigned = append(unassigned, suggestion)
+		}
+	}
+
+	groups := []*models.ReorderGroup{}
+	for _, supplier := range all {
+		if items, ok := bySupplier[supplier.ID]; ok {
+			supplierId := supplier.ID
+			groups = append(groups, &models.ReorderGroup{SupplierID: &supplierId, SupplierName: supplier.Name, Items: items})
+		}
+	}
+	if len(unassigned) > 0 {
+		groups = append(groups, &models.ReorderGroup{Items: unassigned})
+	}
+	return groups, nil
+}
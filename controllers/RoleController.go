@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/middleware"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"time"
+)
+
+// canGrant reports whether the current user holds every permission, so nobody can hand out more than they have
+func canGrant(c *gin.Context, permissions []models.Permission) bool {
+	granted := middleware.Permissions(c)
+	for _, permission := range permissions {
+		if !models.HasPermission(granted, permission) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRole checks the role input and returns a message describing the first problem
+func validateRole(c *gin.Context, role *models.RoleNew) (string, bool) {
+	if _, ok := models.BuiltinRoles[role.Name]; ok {
+		return "Role name is reserved", false
+	}
+
+	for _, permission := range role.Permissions {
+		if !models.IsValidPermission(permission) {
+			return "Unknown permission " + string(permission), false
+		}
+	}
+
+	if !canGrant(c, role.Permissions) {
+		return "Role grants permissions you do not have", false
+	}
+
+	return "", true
+}
+
+// GetRoles godoc
+// @Summary Get roles
+// @Description Gets the built-in roles and the custom roles of the organisation
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.RoleResponse
+// @Failure 400 {object} responses.RoleResponse
+// @Failure 500 {object} responses.RoleResponse
+// @Router /api/roles [get]
+// @Security BearerAuth
+func GetRoles(roles repositories.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationID, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		customRoles, err := roles.FindAll(ctx, organisationID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.RoleResponse{Status: http.StatusOK, Message: "Roles found", Data: map[string]interface{}{"data": map[string]interface{}{"builtin": models.BuiltinRoles, "custom": customRoles}}})
+	}
+}
+
+// CreateRole godoc
+// @Summary Create a role
+// @Description Creates a custom role for the organisation
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Param role body models.RoleNew true "Role data"
+// @Success 200 {object} responses.RoleResponse
+// @Failure 400 {object} responses.RoleResponse
+// @Failure 500 {object} responses.RoleResponse
+// @Router /api/role [post]
+// @Security BearerAuth
+func CreateRole(roles repositories.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var role *models.RoleNew
+		defer cancel()
+
+		organisationID, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&role); err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(role); err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		if errorMessage, ok := validateRole(c, role); !ok {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": errorMessage}})
+			return
+		}
+
+		//Check if role name exists
+		_, err = roles.FindByName(ctx, organisationID, role.Name)
+		if err != nil && err != repositories.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err == nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Role name already exists", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+
+		newRole := models.Role{
+			Name:           role.Name,
+			Permissions:    role.Permissions,
+			OrganisationID: organisationID,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+
+		err = roles.Create(ctx, &newRole)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.RoleResponse{Status: http.StatusOK, Message: "Role created", Data: map[string]interface{}{"data": newRole}})
+	}
+}
+
+// UpdateRole godoc
+// @Summary Update a role
+// @Description Updates a custom role of the organisation
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Param roleId path string true "Role ID"
+// @Param role body models.RoleNew true "Role data"
+// @Success 200 {object} responses.RoleResponse
+// @Failure 400 {object} responses.RoleResponse
+// @Failure 404 {object} responses.RoleResponse
+// @Failure 500 {object} responses.RoleResponse
+// @Router /api/role/{roleId} [put]
+// @Security BearerAuth
+func UpdateRole(roles repositories.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		roleObjectId, _ := primitive.ObjectIDFromHex(c.Param("roleId"))
+		var role *models.RoleNew
+		defer cancel()
+
+		organisationID, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&role); err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(role); err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		if errorMessage, ok := validateRole(c, role); !ok {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": errorMessage}})
+			return
+		}
+
+		existingRole, err := roles.FindByID(ctx, organisationID, roleObjectId)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.RoleResponse{Status: http.StatusNotFound, Message: "Role not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if another role already uses the name
+		namedRole, err := roles.FindByName(ctx, organisationID, role.Name)
+		if err != nil && err != repositories.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err == nil && namedRole.ID != existingRole.ID {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Role name already exists", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+
+		existingRole.Name = role.Name
+		existingRole.Permissions = role.Permissions
+		existingRole.UpdatedAt = time.Now()
+
+		err = roles.Update(ctx, existingRole)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.RoleResponse{Status: http.StatusOK, Message: "Role updated", Data: map[string]interface{}{"data": existingRole}})
+	}
+}
+
+// DeleteRole godoc
+// @Summary Delete a role
+// @Description Deletes a custom role that is not assigned to any user
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Param roleId path string true "Role ID"
+// @Success 200 {object} responses.RoleResponse
+// @Failure 400 {object} responses.RoleResponse
+// @Failure 404 {object} responses.RoleResponse
+// @Failure 500 {object} responses.RoleResponse
+// @Router /api/role/{roleId} [delete]
+// @Security BearerAuth
+func DeleteRole(roles repositories.RoleRepository, users repositories.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		roleObjectId, _ := primitive.ObjectIDFromHex(c.Param("roleId"))
+		defer cancel()
+
+		organisationID, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if the role is still assigned
+		count, err := users.CountByRole(ctx, organisationID, roleObjectId.Hex())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusBadRequest, responses.RoleResponse{Status: http.StatusBadRequest, Message: "Role is assigned to users", Data: map[string]interface{}{"data": count}})
+			return
+		}
+
+		err = roles.Delete(ctx, organisationID, roleObjectId)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.RoleResponse{Status: http.StatusNotFound, Message: "Role not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.RoleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.RoleResponse{Status: http.StatusOK, Message: "Role deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
@@ -0,0 +1,620 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// CreateStockLocation godoc
+// @Summary Create a stock location
+// @Description Creates a place stock is kept at, such as a warehouse or a van. Creating a default location takes the default flag from the previous one.
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Param location body models.StockLocationNew true "Stock location data"
+// @Success 200 {object} responses.StockResponse
+// @Failure 400 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/stock/location [post]
+// @Security BearerAuth
+func CreateStockLocation(locations repositories.StockLocationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.StockLocationNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		newLocation := models.StockLocation{
+			Name:           strings.TrimSpace(input.Name),
+			Default:        input.Default,
+			OrganisationID: organisationId,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+
+		err = locations.Create(ctx, &newLocation)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.StockResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Stock location created", Data: map[string]interface{}{"data": newLocation}})
+	}
+}
+
+// GetStockLocations godoc
+// @Summary Get stock locations
+// @Description Gets the stock locations of the organisation. An organisation without locations gets a default "Main" location.
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.StockResponse
+// @Failure 400 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/stock/locations [get]
+// @Security BearerAuth
+func GetStockLocations(locations repositories.StockLocationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		_, err = locations.EnsureDefault(ctx, organisationId)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+		result, err := locations.FindAll(ctx, organisationId)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Stock locations found", Data: map[string]interface{}{"data": result}})
+	}
+}
+
+// UpdateStockLocation godoc
+// @Summary Update a stock location
+// @Description Renames a stock location or makes it the default one. The default flag moves by making another location the default.
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Param locationId path string true "Stock location ID"
+// @Param location body models.StockLocationNew true "Stock location data"
+// @Success 200 {object} responses.StockResponse
+// @Failure 400 {object} responses.StockResponse
+// @Failure 404 {object} responses.StockResponse
+// @Failure 409 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/stock/location/{locationId} [put]
+// @Security BearerAuth
+func UpdateStockLocation(locations repositories.StockLocationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		locationId := c.Param("locationId")
+		locationObjectId, _ := primitive.ObjectIDFromHex(locationId)
+		var input *models.StockLocationNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		location, err := locations.FindByID(ctx, organisationId, locationObjectId)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+
+		//Check if the organisation would be left without a default location
+		if location.Default && !input.Default {
+			c.JSON(http.StatusConflict, responses.StockResponse{Status: http.StatusConflict, Message: "Make another location the default instead", Data: map[string]interface{}{"data": location.ID}})
+			return
+		}
+		location.Name = strings.TrimSpace(input.Name)
+		location.Default = input.Default
+		location.UpdatedAt = time.Now()
+
+		err = locations.Update(ctx, location)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Stock location updated", Data: map[string]interface{}{"data": location}})
+	}
+}
+
+// DeleteStockLocation godoc
+// @Summary Delete a stock location
+// @Description Deletes a stock location that is not the default one and holds no stock
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Param locationId path string true "Stock location ID"
+// @Success 200 {object} responses.StockResponse
+// @Failure 404 {object} responses.StockResponse
+// @Failure 409 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/stock/location/{locationId} [delete]
+// @Security BearerAuth
+func DeleteStockLocation(locations repositories.StockLocationRepository, stock repositories.StockRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		locationId := c.Param("locationId")
+		locationObjectId, _ := primitive.ObjectIDFromHex(locationId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		location, err := locations.FindByID(ctx, organisationId, locationObjectId)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+		if location.Default {
+			c.JSON(http.StatusConflict, responses.StockResponse{Status: http.StatusConflict, Message: "The default location cannot be deleted", Data: map[string]interface{}{"data": location.ID}})
+			return
+		}
+
+		//Check if stock is still kept at the location
+		balances, err := stock.FindBalances(ctx, organisationId, models.StockBalanceFilter{LocationID: &location.ID})
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+		for _, balance := range balances {
+			if balance.OnHand != 0 {
+				c.JSON(http.StatusConflict, responses.StockResponse{Status: http.StatusConflict, Message: "Stock location still holds stock", Data: map[string]interface{}{"data": balance}})
+				return
+			}
+		}
+
+		err = locations.Delete(ctx, organisationId, location.ID)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Stock location deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
+
+// RecordStockMovement godoc
+// @Summary Record a stock movement
+// @Description Records a receipt, return or adjustment of an item that tracks stock. Receipts and returns take a positive quantity, adjustments add or remove stock by the sign of the quantity. Without a location the default location is used.
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Param movement body models.StockMovementNew true "Stock movement data"
+// @Success 200 {object} responses.StockResponse
+// @Failure 400 {object} responses.StockResponse
+// @Failure 404 {object} responses.StockResponse
+// @Failure 409 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/stock/movement [post]
+// @Security BearerAuth
+func RecordStockMovement(stock repositories.StockRepository, locations repositories.StockLocationRepository, items repositories.ItemRepository, organisations repositories.OrganisationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.StockMovementNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if input.Type != models.StockAdjustment && input.Quantity < 0 {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Only adjustments can remove stock", Data: map[string]interface{}{"data": input.Quantity}})
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, organisationId)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+		item, ok := stockItem(ctx, c, items, organisationId, input.ItemID)
+		if !ok {
+			return
+		}
+		location, ok := stockLocation(ctx, c, locations, organisationId, input.LocationID)
+		if !ok {
+			return
+		}
+
+		movement := &models.StockMovement{
+			Type:       input.Type,
+			ItemID:     item.ID,
+			LocationID: location.ID,
+			Quantity:   input.Quantity,
+			Note:       strings.TrimSpace(input.Note),
+			UserID:     tenant.Get(c).User.ID,
+			CreatedAt:  time.Now(),
+		}
+		if input.UnitCost != nil {
+			currency, err := money.CurrencyAlpha(organisation.Currency)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+				return
+			}
+			unitCost, err := input.UnitCost.In(currency)
+			if err != nil || unitCost.IsNegative() {
+				c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Invalid unit cost", Data: map[string]interface{}{"data": input.UnitCost}})
+				return
+			}
+			movement.UnitCost = &unitCost
+		}
+
+		err = stock.Record(ctx, organisationId, []*models.StockMovement{movement}, organisation.AllowNegativeStock, nil)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Stock movement recorded", Data: map[string]interface{}{"data": movement}})
+	}
+}
+
+// TransferStock godoc
+// @Summary Transfer stock between locations
+// @Description Moves stock of an item from one location to another, recorded as two transfer movements sharing a transfer ID
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Param transfer body models.StockTransferNew true "Stock transfer data"
+// @Success 200 {object} responses.StockResponse
+// @Failure 400 {object} responses.StockResponse
+// @Failure 404 {object} responses.StockResponse
+// @Failure 409 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/stock/transfer [post]
+// @Security BearerAuth
+func TransferStock(stock repositories.StockRepository, locations repositories.StockLocationRepository, items repositories.ItemRepository, organisations repositories.OrganisationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.StockTransferNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		organisation, err := organisations.FindByID(ctx, organisationId)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+		item, ok := stockItem(ctx, c, items, organisationId, input.ItemID)
+		if !ok {
+			return
+		}
+		from, ok := stockLocation(ctx, c, locations, organisationId, input.FromLocationID)
+		if !ok {
+			return
+		}
+		to, ok := stockLocation(ctx, c, locations, organisationId, input.ToLocationID)
+		if !ok {
+			return
+		}
+
+		transferId := primitive.NewObjectID()
+		now := time.Now()
+		movements := []*models.StockMovement{}
+		for _, side := range []struct {
+			location *models.StockLocation
+			quantity float64
+		}{{from, -input.Quantity}, {to, input.Quantity}} {
+			movements = append(movements, &models.StockMovement{
+				Type:       models.StockTransfer,
+				ItemID:     item.ID,
+				LocationID: side.location.ID,
+				Quantity:   side.quantity,
+				TransferID: &transferId,
+				Note:       strings.TrimSpace(input.Note),
+				UserID:     tenant.Get(c).User.ID,
+				CreatedAt:  now,
+			})
+		}
+
+		err = stock.Record(ctx, organisationId, movements, organisation.AllowNegativeStock, nil)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Stock transferred", Data: map[string]interface{}{"data": movements}})
+	}
+}
+
+// GetStockMovements godoc
+// @Summary Get stock movements
+// @Description Gets a page of the stock ledger of the organisation, newest movement first. It can also be sorted by created_at or quantity, prefixed with "-" for descending order.
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Param item_id query string false "Only movements of the item"
+// @Param location_id query string false "Only movements at the location"
+// @Param type query string false "Only movements of the type"
+// @Param sort query string false "Sort field" default(-created_at)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.StockResponse
+// @Failure 400 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/stock/movements [get]
+// @Security BearerAuth
+func GetStockMovements(stock repositories.StockRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.StockMovementSortFields, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.StockMovementSortFields.Names()}})
+			return
+		}
+		filter := models.StockMovementFilter{Type: models.StockMovementType(c.Query("type"))}
+		if filter.Type != "" && !models.IsValidStockMovementType(filter.Type) {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Invalid movement type", Data: map[string]interface{}{"data": filter.Type}})
+			return
+		}
+		if itemId := c.Query("item_id"); itemId != "" {
+			itemObjectId, _ := primitive.ObjectIDFromHex(itemId)
+			filter.ItemID = &itemObjectId
+		}
+		if locationId := c.Query("location_id"); locationId != "" {
+			locationObjectId, _ := primitive.ObjectIDFromHex(locationId)
+			filter.LocationID = &locationObjectId
+		}
+
+		result, paging, err := stock.FindMovements(ctx, organisationId, filter, page)
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Stock movements found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// GetItemStock godoc
+// @Summary Get the stock of an item
+// @Description Gets the stock on hand of an item per location and in total
+// @Tags Stock
+// @Accept json
+// @Produce json
+// @Param itemId path string true "Item ID"
+// @Success 200 {object} responses.StockResponse
+// @Failure 404 {object} responses.StockResponse
+// @Failure 500 {object} responses.StockResponse
+// @Router /api/item/{itemId}/stock [get]
+// @Security BearerAuth
+func GetItemStock(stock repositories.StockRepository, items repositories.ItemRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		item, ok := stockItem(ctx, c, items, organisationId, c.Param("itemId"))
+		if !ok {
+			return
+		}
+		balances, err := stock.FindBalances(ctx, organisationId, models.StockBalanceFilter{ItemID: &item.ID})
+		if err != nil {
+			respondStockError(c, err)
+			return
+		}
+		onHand := 0.0
+		for _, balance := range balances {
+			onHand += balance.OnHand
+		}
+
+		c.JSON(http.StatusOK, responses.StockResponse{Status: http.StatusOK, Message: "Item stock found", Data: map[string]interface{}{"data": map[string]interface{}{
+			"item_id":   item.ID,
+			"on_hand":   models.RoundQuantity(onHand),
+			"locations": balances,
+		}}})
+	}
+}
+
+// stockItem returns the item if it tracks stock, or writes the error response
+func stockItem(ctx context.Context, c *gin.Context, items repositories.ItemRepository, organisationId primitive.ObjectID, itemId string) (*models.Item, bool) {
+	itemObjectId, _ := primitive.ObjectIDFromHex(itemId)
+	item, err := items.FindByID(ctx, organisationId, itemObjectId)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			c.JSON(http.StatusNotFound, responses.StockResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": itemId}})
+			return nil, false
+		}
+		respondStockError(c, err)
+		return nil, false
+	}
+	if !item.TrackStock {
+		c.JSON(http.StatusBadRequest, responses.StockResponse{Status: http.StatusBadRequest, Message: "Item does not track stock", Data: map[string]interface{}{"data": itemId}})
+		return nil, false
+	}
+	return item, true
+}
+
+// stockLocation returns the location, or the default location for an empty ID, or writes the error response
+func stockLocation(ctx context.Context, c *gin.Context, locations repositories.StockLocationRepository, organisationId primitive.ObjectID, locationId string) (*models.StockLocation, bool) {
+	var location *models.StockLocation
+	var err error
+	if locationId == "" {
+		location, err = locations.EnsureDefault(ctx, organisationId)
+	} else {
+		locationObjectId, _ := primitive.ObjectIDFromHex(locationId)
+		location, err = locations.FindByID(ctx, organisationId, locationObjectId)
+	}
+	if err != nil {
+		respondStockError(c, err)
+		return nil, false
+	}
+	return location, true
+}
+
+// stockLine is a quantity of an item on a sale or work order
+type stockLine struct {
+	itemId   *primitive.ObjectID
+	quantity float64
+}
+
+// outgoingStock returns the movements taking the tracked items among the lines out of the default location. The
+// movements share the reference, whose ID may be filled in once the referenced document has one.
+func outgoingStock(ctx context.Context, items repositories.ItemRepository, locations repositories.StockLocationRepository, organisationId primitive.ObjectID, movementType models.StockMovementType, reference *models.StockReference, userId primitive.ObjectID, lines []stockLine) ([]*models.StockMovement, error) {
+	var location *models.StockLocation
+	return lineStock(ctx, items, organisationId, movementType, reference, userId, lines, -1, func() (*models.StockLocation, error) {
+		var err error
+		if location == nil {
+			location, err = locations.EnsureDefault(ctx, organisationId)
+		}
+		return location, err
+	})
+}
+
+// incomingStock returns the movements taking the tracked items among the lines back into the location
+func incomingStock(ctx context.Context, items repositories.ItemRepository, location *models.StockLocation, organisationId primitive.ObjectID, movementType models.StockMovementType, reference *models.StockReference, userId primitive.ObjectID, lines []stockLine) ([]*models.StockMovement, error) {
+	return lineStock(ctx, items, organisationId, movementType, reference, userId, lines, 1, func() (*models.StockLocation, error) {
+		return location, nil
+	})
+}
+
+// lineStock returns the movements of the tracked items among the lines, with the quantities of the lines multiplied by
+// the sign. The location is only looked up if there is any tracked item.
+func lineStock(ctx context.Context, items repositories.ItemRepository, organisationId primitive.ObjectID, movementType models.StockMovementType, reference *models.StockReference, userId primitive.ObjectID, lines []stockLine, sign float64, location func() (*models.StockLocation, error)) ([]*models.StockMovement, error) {
+	movements := []*models.StockMovement{}
+	now := time.Now()
+	for _, line := range lines {
+		if line.itemId == nil {
+			continue
+		}
+		item, err := items.FindByID(ctx, organisationId, *line.itemId)
+		if err == repositories.ErrNotFound {
+			//Items deleted since the line was added no longer track stock
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !item.TrackStock {
+			continue
+		}
+
+		at, err := location()
+		if err != nil {
+			return nil, err
+		}
+		movements = append(movements, &models.StockMovement{
+			Type:       movementType,
+			ItemID:     item.ID,
+			LocationID: at.ID,
+			Quantity:   sign * line.quantity,
+			Reference:  reference,
+			UserID:     userId,
+			CreatedAt:  now,
+		})
+	}
+	return movements, nil
+}
+
+// respondStockError writes the response for an error returned by the stock repositories
+func respondStockError(c *gin.Context, err error) {
+	switch err {
+	case repositories.ErrNotFound:
+		c.JSON(http.StatusNotFound, responses.StockResponse{Status: http.StatusNotFound, Message: "Stock location not found", Data: map[string]interface{}{"data": err.Error()}})
+	case repositories.ErrInsufficientStock:
+		c.JSON(http.StatusConflict, responses.StockResponse{Status: http.StatusConflict, Message: "Not enough stock on hand", Data: map[string]interface{}{"data": err.Error()}})
+	default:
+		c.JSON(http.StatusInternalServerError, responses.StockResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+	}
+}
@@ -0,0 +1,293 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+var errUnknownSupplier = errors.New("supplier not found")
+
+// CreateSupplier godoc
+// @Summary Create a supplier
+// @Description Creates a supplier of the organisation
+// @Tags Supplier
+// @Accept json
+// @Produce json
+// @Param supplier body models.SupplierNew true "Supplier data"
+// @Success 200 {object} responses.SupplierResponse
+// @Failure 400 {object} responses.SupplierResponse
+// @Failure 500 {object} responses.SupplierResponse
+// @Router /api/supplier [post]
+// @Security BearerAuth
+func CreateSupplier(suppliers repositories.SupplierRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.SupplierNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		newSupplier := models.Supplier{
+			Name:             strings.TrimSpace(input.Name),
+			Email:            strings.TrimSpace(input.Email),
+			Phone:            strings.TrimSpace(input.Phone),
+			ContactName:      strings.TrimSpace(input.ContactName),
+			Address:          strings.TrimSpace(input.Address),
+			AccountNumber:    strings.TrimSpace(input.AccountNumber),
+			PaymentTermsDays: input.PaymentTermsDays,
+			LeadTimeDays:     input.LeadTimeDays,
+			OrganisationID:   organisationId,
+			UpdatedAt:        time.Now(),
+			CreatedAt:        time.Now(),
+		}
+
+		err = suppliers.Create(ctx, &newSupplier)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.SupplierResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.SupplierResponse{Status: http.StatusOK, Message: "Supplier created", Data: map[string]interface{}{"data": newSupplier}})
+	}
+}
+
+// GetSupplier godoc
+// @Summary Get a supplier
+// @Description Gets a supplier of the organisation
+// @Tags Supplier
+// @Accept json
+// @Produce json
+// @Param supplierId path string true "Supplier ID"
+// @Success 200 {object} responses.SupplierResponse
+// @Failure 404 {object} responses.SupplierResponse
+// @Failure 500 {object} responses.SupplierResponse
+// @Router /api/supplier/{supplierId} [get]
+// @Security BearerAuth
+func GetSupplier(suppliers repositories.SupplierRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		supplierId := c.Param("supplierId")
+		supplierObjectId, _ := primitive.ObjectIDFromHex(supplierId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		supplier, err := suppliers.FindByID(ctx, organisationId, supplierObjectId)
+		if err != nil {
+			respondSupplierError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.SupplierResponse{Status: http.StatusOK, Message: "Supplier found", Data: map[string]interface{}{"data": supplier}})
+	}
+}
+
+// GetSuppliers godoc
+// @Summary Get suppliers
+// @Description Gets the suppliers of the organisation by name
+// @Tags Supplier
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.SupplierResponse
+// @Failure 400 {object} responses.SupplierResponse
+// @Failure 500 {object} responses.SupplierResponse
+// @Router /api/suppliers [get]
+// @Security BearerAuth
+func GetSuppliers(suppliers repositories.SupplierRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		result, err := suppliers.FindAll(ctx, organisationId)
+		if err != nil {
+			respondSupplierError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.SupplierResponse{Status: http.StatusOK, Message: "Suppliers found", Data: map[string]interface{}{"data": result}})
+	}
+}
+
+// UpdateSupplier godoc
+// @Summary Update a supplier
+// @Description Updates a supplier of the organisation
+// @Tags Supplier
+// @Accept json
+// @Produce json
+// @Param supplierId path string true "Supplier ID"
+// @Param supplier body models.SupplierNew true "Supplier data"
+// @Success 200 {object} responses.SupplierResponse
+// @Failure 400 {object} responses.SupplierResponse
+// @Failure 404 {object} responses.SupplierResponse
+// @Failure 500 {object} responses.SupplierResponse
+// @Router /api/supplier/{supplierId} [put]
+// @Security BearerAuth
+func UpdateSupplier(suppliers repositories.SupplierRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		supplierId := c.Param("supplierId")
+		supplierObjectId, _ := primitive.ObjectIDFromHex(supplierId)
+		var input *models.SupplierNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		supplier, err := suppliers.FindByID(ctx, organisationId, supplierObjectId)
+		if err != nil {
+			respondSupplierError(c, err)
+			return
+		}
+		supplier.Name = strings.TrimSpace(input.Name)
+		supplier.Email = strings.TrimSpace(input.Email)
+		supplier.Phone = strings.TrimSpace(input.Phone)
+		supplier.ContactName = strings.TrimSpace(input.ContactName)
+		supplier.Address = strings.TrimSpace(input.Address)
+		supplier.AccountNumber = strings.TrimSpace(input.AccountNumber)
+		supplier.PaymentTermsDays = input.PaymentTermsDays
+		supplier.LeadTimeDays = input.LeadTimeDays
+		supplier.UpdatedAt = time.Now()
+
+		err = suppliers.Update(ctx, supplier)
+		if err != nil {
+			respondSupplierError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.SupplierResponse{Status: http.StatusOK, Message: "Supplier updated", Data: map[string]interface{}{"data": supplier}})
+	}
+}
+
+// DeleteSupplier godoc
+// @Summary Delete a supplier
+// @Description Deletes a supplier of the organisation that no item prefers and no purchase order was placed with
+// @Tags Supplier
+// @Accept json
+// @Produce json
+// @Param supplierId path string true "Supplier ID"
+// @Success 200 {object} responses.SupplierResponse
+// @Failure 404 {object} responses.SupplierResponse
+// @Failure 409 {object} responses.SupplierResponse
+// @Failure 500 {object} responses.SupplierResponse
+// @Router /api/supplier/{supplierId} [delete]
+// @Security BearerAuth
+func DeleteSupplier(suppliers repositories.SupplierRepository, items repositories.ItemRepository, purchaseOrders repositories.PurchaseOrderRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		supplierId := c.Param("supplierId")
+		supplierObjectId, _ := primitive.ObjectIDFromHex(supplierId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.SupplierResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if items are still reordered from the supplier
+		used, err := items.UsesSupplier(ctx, organisationId, supplierObjectId)
+		if err != nil {
+			respondSupplierError(c, err)
+			return
+		}
+		if used {
+			c.JSON(http.StatusConflict, responses.SupplierResponse{Status: http.StatusConflict, Message: "Supplier is preferred by items", Data: map[string]interface{}{"data": supplierId}})
+			return
+		}
+
+		//Check if purchase orders were placed with the supplier, they keep referring to it
+		orders, _, err := purchaseOrders.FindAll(ctx, organisationId, models.PurchaseOrderFilter{SupplierID: &supplierObjectId}, listing.Query{Limit: 1})
+		if err != nil {
+			respondSupplierError(c, err)
+			return
+		}
+		if len(orders) > 0 {
+			c.JSON(http.StatusConflict, responses.SupplierResponse{Status: http.StatusConflict, Message: "Supplier has purchase orders", Data: map[string]interface{}{"data": supplierId}})
+			return
+		}
+
+		err = suppliers.Delete(ctx, organisationId, supplierObjectId)
+		if err != nil {
+			respondSupplierError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.SupplierResponse{Status: http.StatusOK, Message: "Supplier deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
+
+// supplierID checks that the supplier given as input exists in the organisation, an empty input means no supplier
+func supplierID(ctx context.Context, suppliers repositories.SupplierRepository, organisationId primitive.ObjectID, input string) (*primitive.ObjectID, error) {
+	if input == "" {
+		return nil, nil
+	}
+	supplierObjectId, _ := primitive.ObjectIDFromHex(input)
+	supplier, err := suppliers.FindByID(ctx, organisationId, supplierObjectId)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, errUnknownSupplier
+		}
+		return nil, err
+	}
+	return &supplier.ID, nil
+}
+
+// respondSupplierError writes the response for an error returned by the supplier repository
+func respondSupplierError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.SupplierResponse{Status: http.StatusNotFound, Message: "Supplier not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.SupplierResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
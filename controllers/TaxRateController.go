@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+var errUnknownTaxRate = errors.New("tax rate not found")
+
+// CreateTaxRate godoc
+// @Summary Create a tax rate
+// @Description Creates a tax rate of the organisation. Creating a default rate takes the default flag from the previous one.
+// @Tags Tax Rate
+// @Accept json
+// @Produce json
+// @Param taxRate body models.TaxRateNew true "Tax rate data"
+// @Success 200 {object} responses.TaxRateResponse
+// @Failure 400 {object} responses.TaxRateResponse
+// @Failure 500 {object} responses.TaxRateResponse
+// @Router /api/tax-rate [post]
+// @Security BearerAuth
+func CreateTaxRate(taxRates repositories.TaxRateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.TaxRateNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		newTaxRate := models.TaxRate{
+			Name:           strings.TrimSpace(input.Name),
+			Percent:        input.Percent,
+			Default:        input.Default,
+			OrganisationID: organisationId,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+
+		err = taxRates.Create(ctx, &newTaxRate)
+		if err == nil && newTaxRate.Default {
+			err = taxRates.ClearDefault(ctx, organisationId, newTaxRate.ID)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.TaxRateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.TaxRateResponse{Status: http.StatusOK, Message: "Tax rate created", Data: map[string]interface{}{"data": newTaxRate}})
+	}
+}
+
+// GetTaxRate godoc
+// @Summary Get a tax rate
+// @Description Gets a tax rate of the organisation
+// @Tags Tax Rate
+// @Accept json
+// @Produce json
+// @Param taxRateId path string true "Tax rate ID"
+// @Success 200 {object} responses.TaxRateResponse
+// @Failure 404 {object} responses.TaxRateResponse
+// @Failure 500 {object} responses.TaxRateResponse
+// @Router /api/tax-rate/{taxRateId} [get]
+// @Security BearerAuth
+func GetTaxRate(taxRates repositories.TaxRateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		taxRateId := c.Param("taxRateId")
+		taxRateObjectId, _ := primitive.ObjectIDFromHex(taxRateId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		taxRate, err := taxRates.FindByID(ctx, organisationId, taxRateObjectId)
+		if err != nil {
+			respondTaxRateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.TaxRateResponse{Status: http.StatusOK, Message: "Tax rate found", Data: map[string]interface{}{"data": taxRate}})
+	}
+}
+
+// GetTaxRates godoc
+// @Summary Get tax rates
+// @Description Gets the tax rates of the organisation, highest rate first
+// @Tags Tax Rate
+// @Accept json
+// @Produce json
+// @Success 200 {object} responses.TaxRateResponse
+// @Failure 400 {object} responses.TaxRateResponse
+// @Failure 500 {object} responses.TaxRateResponse
+// @Router /api/tax-rates [get]
+// @Security BearerAuth
+func GetTaxRates(taxRates repositories.TaxRateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		result, err := taxRates.FindAll(ctx, organisationId)
+		if err != nil {
+			respondTaxRateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.TaxRateResponse{Status: http.StatusOK, Message: "Tax rates found", Data: map[string]interface{}{"data": result}})
+	}
+}
+
+// UpdateTaxRate godoc
+// @Summary Update a tax rate
+// @Description Updates a tax rate of the organisation. Invoices issued before keep the rate they were issued with.
+// @Tags Tax Rate
+// @Accept json
+// @Produce json
+// @Param taxRateId path string true "Tax rate ID"
+// @Param taxRate body models.TaxRateNew true "Tax rate data"
+// @Success 200 {object} responses.TaxRateResponse
+// @Failure 400 {object} responses.TaxRateResponse
+// @Failure 404 {object} responses.TaxRateResponse
+// @Failure 500 {object} responses.TaxRateResponse
+// @Router /api/tax-rate/{taxRateId} [put]
+// @Security BearerAuth
+func UpdateTaxRate(taxRates repositories.TaxRateRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		taxRateId := c.Param("taxRateId")
+		taxRateObjectId, _ := primitive.ObjectIDFromHex(taxRateId)
+		var input *models.TaxRateNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		taxRate, err := taxRates.FindByID(ctx, organisationId, taxRateObjectId)
+		if err != nil {
+			respondTaxRateError(c, err)
+			return
+		}
+		taxRate.Name = strings.TrimSpace(input.Name)
+		taxRate.Percent = input.Percent
+		taxRate.Default = input.Default
+		taxRate.UpdatedAt = time.Now()
+
+		err = taxRates.Update(ctx, taxRate)
+		if err == nil && taxRate.Default {
+			err = taxRates.ClearDefault(ctx, organisationId, taxRate.ID)
+		}
+		if err != nil {
+			respondTaxRateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.TaxRateResponse{Status: http.StatusOK, Message: "Tax rate updated", Data: map[string]interface{}{"data": taxRate}})
+	}
+}
+
+// DeleteTaxRate godoc
+// @Summary Delete a tax rate
+// @Description Deletes a tax rate of the organisation that no item is assigned to
+// @Tags Tax Rate
+// @Accept json
+// @Produce json
+// @Param taxRateId path string true "Tax rate ID"
+// @Success 200 {object} responses.TaxRateResponse
+// @Failure 404 {object} responses.TaxRateResponse
+// @Failure 409 {object} responses.TaxRateResponse
+// @Failure 500 {object} responses.TaxRateResponse
+// @Router /api/tax-rate/{taxRateId} [delete]
+// @Security BearerAuth
+func DeleteTaxRate(taxRates repositories.TaxRateRepository, items repositories.ItemRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		taxRateId := c.Param("taxRateId")
+		taxRateObjectId, _ := primitive.ObjectIDFromHex(taxRateId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.TaxRateResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		//Check if items are still taxed at the rate
+		used, err := items.UsesTaxRate(ctx, organisationId, taxRateObjectId)
+		if err != nil {
+			respondTaxRateError(c, err)
+			return
+		}
+		if used {
+			c.JSON(http.StatusConflict, responses.TaxRateResponse{Status: http.StatusConflict, Message: "Tax rate is assigned to items", Data: map[string]interface{}{"data": taxRateId}})
+			return
+		}
+
+		err = taxRates.Delete(ctx, organisationId, taxRateObjectId)
+		if err != nil {
+			respondTaxRateError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.TaxRateResponse{Status: http.StatusOK, Message: "Tax rate deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
+
+// lineTaxRates resolves the tax rates of the lines of one request, loading every rate at most once
+type lineTaxRates struct {
+	taxRates       repositories.TaxRateRepository
+	organisationId primitive.ObjectID
+	loaded         map[primitive.ObjectID]*models.TaxRate
+	defaultRate    *models.TaxRate
+	defaultLoaded  bool
+}
+
+func newLineTaxRates(taxRates repositories.TaxRateRepository, organisationId primitive.ObjectID) *lineTaxRates {
+	return &lineTaxRates{taxRates: taxRates, organisationId: organisationId, loaded: map[primitive.ObjectID]*models.TaxRate{}}
+}
+
+// get returns the tax rate with the ID, or the organisation's default rate for a nil ID. The result is nil if the
+// organisation has no default rate, which taxes the line at zero.
+func (r *lineTaxRates) get(ctx context.Context, id *primitive.ObjectID) (*models.TaxRate, error) {
+	if id == nil {
+		if !r.defaultLoaded {
+			taxRate, err := r.taxRates.FindDefault(ctx, r.organisationId)
+			if err != nil && err != repositories.ErrNotFound {
+				return nil, err
+			}
+			r.defaultRate, r.defaultLoaded = taxRate, true
+		}
+		return r.defaultRate, nil
+	}
+
+	if taxRate, ok := r.loaded[*id]; ok {
+		return taxRate, nil
+	}
+	taxRate, err := r.taxRates.FindByID(ctx, r.organisationId, *id)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, errUnknownTaxRate
+		}
+		return nil, err
+	}
+	r.loaded[*id] = taxRate
+	return taxRate, nil
+}
+
+// taxRateID checks that the tax rate given as input exists in the organisation, an empty input means no tax rate
+func taxRateID(ctx context.Context, taxRates repositories.TaxRateRepository, organisationId primitive.ObjectID, input string) (*primitive.ObjectID, error) {
+	if input == "" {
+		return nil, nil
+	}
+	taxRateObjectId, _ := primitive.ObjectIDFromHex(input)
+	taxRate, err := taxRates.FindByID(ctx, organisationId, taxRateObjectId)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, errUnknownTaxRate
+		}
+		return nil, err
+	}
+	return &taxRate.ID, nil
+}
+
+// respondTaxRateError writes the response for an error returned by the tax rate repository
+func respondTaxRateError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.TaxRateResponse{Status: http.StatusNotFound, Message: "Tax rate not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.TaxRateResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
@@ -2,23 +2,21 @@ package controllers
 
 import (
 	"context"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
-	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
-	"poosible-backend/config"
+	"poosible-backend/middleware"
 	"poosible-backend/models"
+	"poosible-backend/repositories"
 	"poosible-backend/responses"
-	"poosible-backend/utils"
+	"poosible-backend/tenant"
 	"poosible-backend/validators"
+	"strings"
 	"time"
 )
 
-var userCollection *mongo.Collection = config.GetCollection(config.Database, "users")
 var validate = validator.New()
 
 // CreateUser creates a new user
@@ -32,7 +30,7 @@ var validate = validator.New()
 // @Failure 400 {object} responses.UserResponse
 // @Failure 500 {object} responses.UserResponse
 // @Router /api/user [post]
-func CreateUser() gin.HandlerFunc {
+func CreateUser(users repositories.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		var user *models.UserNew
@@ -48,6 +46,7 @@ func CreateUser() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid input fields", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
+		user.Email = strings.ToLower(user.Email)
 
 		//Validate password length and complexity
 		errorMessage, ok := validators.ValidatePassword(user.Password)
@@ -57,10 +56,9 @@ func CreateUser() gin.HandlerFunc {
 		}
 
 		//Check if email already exists
-		var existingUser *models.UserPublic
-		err := userCollection.FindOne(ctx, models.UserPublic{Email: user.Email}).Decode(&existingUser)
+		_, err := users.FindByEmail(ctx, user.Email)
 		if err != nil {
-			if err != mongo.ErrNoDocuments {
+			if err != repositories.ErrNotFound {
 				c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error checking if email already exists", Data: map[string]interface{}{"data": err.Error()}})
 				return
 			}
@@ -83,16 +81,15 @@ func CreateUser() gin.HandlerFunc {
 			Email:     user.Email,
 			Password:  string(hashedPassword),
 			Location:  user.Location,
-			RoleID:    models.USER,
 		}
 
-		result, err := userCollection.InsertOne(ctx, newUser)
+		err = users.Create(ctx, &newUser)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error inserting user into database", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		c.JSON(http.StatusCreated, responses.UserResponse{Status: http.StatusCreated, Message: "User created successfully", Data: map[string]interface{}{"data": result}})
+		c.JSON(http.StatusCreated, responses.UserResponse{Status: http.StatusCreated, Message: "User created successfully", Data: map[string]interface{}{"data": publicUser(&newUser)}})
 	}
 }
 
@@ -109,19 +106,16 @@ func CreateUser() gin.HandlerFunc {
 // @Failure 500 {object} responses.UserResponse
 // @Router /api/user/{userId} [get]
 // @Security BearerAuth
-func GetUser() gin.HandlerFunc {
+func GetUser(users repositories.UserRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		userId := c.Param("userId")
-		var user *models.UserPublic
 		defer cancel()
 		objId, _ := primitive.ObjectIDFromHex(userId)
-		fmt.Println(objId)
-		fmt.Println(userId)
 
-		err := userCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&user)
+		user, err := findReachableUser(ctx, c, users, objId)
 		if err != nil {
-			if err == mongo.ErrNoDocuments {
+			if err == repositories.ErrNotFound {
 				c.JSON(http.StatusNotFound, responses.UserResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
 				return
 			}
@@ -129,13 +123,13 @@ func GetUser() gin.HandlerFunc {
 			return
 		}
 
-		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "User retrieved successfully", Data: map[string]interface{}{"data": user}})
+		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "User retrieved successfully", Data: map[string]interface{}{"data": publicUser(user)}})
 	}
 }
 
 // UpdateUser updates a user with the given user ID.
 // @Summary Update a user
-// @Description Update a user with the given user ID
+// @Description Update a user with the given user ID. Users change the email and password of their own account only, other members can only be renamed or moved by users holding every permission they have, with the email left as it is and the password empty.
 // @Tags Users
 // @Accept json
 // @Produce json
@@ -143,10 +137,12 @@ func GetUser() gin.HandlerFunc {
 // @Param user body models.UserNew true "User object to update"
 // @Success 200 {object} responses.UserResponse
 // @Failure 400 {object} responses.UserResponse
+// @Failure 403 {object} responses.UserResponse
+// @Failure 404 {object} responses.UserResponse
 // @Failure 500 {object} responses.UserResponse
 // @Router /api/user/{userId} [put]
 // @Security BearerAuth
-func UpdateUser() gin.HandlerFunc {
+func UpdateUser(users repositories.UserRepository, roles repositories.RoleRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		userId := c.Param("userId")
@@ -154,75 +150,122 @@ func UpdateUser() gin.HandlerFunc {
 		defer cancel()
 		objId, _ := primitive.ObjectIDFromHex(userId)
 
-		err := c.BindJSON(&user)
+		existingUser, err := findReachableUser(ctx, c, users, objId)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid input fields", Data: map[string]interface{}{"data": err.Error()}})
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.UserResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		//Validate password length and complexity
-		errorMessage, ok := validators.ValidatePassword(user.Password)
-		if !ok {
-			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid password", Data: map[string]interface{}{"data": errorMessage}})
+		err = c.BindJSON(&user)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid input fields", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		// Hash password
-		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error hashing password", Data: map[string]interface{}{"data": err.Error()}})
-			return
+		if tenant.Get(c).User.ID != existingUser.ID {
+			if !canManageUser(ctx, c, roles, existingUser) {
+				return
+			}
+
+			//Only users themselves change how they log in, so nobody can take over an account
+			if user.Password != "" || (user.Email != "" && strings.ToLower(user.Email) != existingUser.Email) {
+				c.JSON(http.StatusForbidden, responses.UserResponse{Status: http.StatusForbidden, Message: "You can only change the email and password of your own account", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+
+			if err := validate.StructExcept(user, "Email", "Password"); err != nil {
+				c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid input fields", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+		} else {
+			if err := validate.Struct(user); err != nil {
+				c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid input fields", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+
+			//Validate password length and complexity
+			errorMessage, ok := validators.ValidatePassword(user.Password)
+			if !ok {
+				c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid password", Data: map[string]interface{}{"data": errorMessage}})
+				return
+			}
+
+			// Hash password
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error hashing password", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			existingUser.Email = strings.ToLower(user.Email)
+			existingUser.Password = string(hashedPassword)
 		}
 
 		//Update user in database
-		update := bson.M{"firstName": user.FirstName, "lastName": user.LastName, "email": user.Email, "password": string(hashedPassword), "location": user.Location}
-		result, err := userCollection.UpdateOne(ctx, bson.M{"_id": objId}, bson.M{"$set": update})
+		existingUser.FirstName = user.FirstName
+		existingUser.LastName = user.LastName
+		existingUser.Location = user.Location
+		existingUser.UpdatedAt = primitive.NewDateTimeFromTime(time.Now())
+
+		err = users.Update(ctx, existingUser)
 		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.UserResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error updating user", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		var updatedUser *models.UserPublic
-		if result.MatchedCount == 1 {
-			err := userCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&updatedUser)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error getting updated user", Data: map[string]interface{}{"data": err.Error()}})
-				return
-			}
-		}
-		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "User updated successfully", Data: map[string]interface{}{"data": updatedUser}})
+		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "User updated successfully", Data: map[string]interface{}{"data": publicUser(existingUser)}})
 	}
 }
 
 // DeleteUser deletes a user with the given user ID.
 // @Summary Delete a user
-// @Description Delete a user with the given user ID
+// @Description Delete a user with the given user ID. Other members can only be deleted by users holding every permission they have.
 // @Tags Users
 // @Accept json
 // @Produce json
 // @Param userId path string true "User ID"
 // @Success 200 {object} responses.UserResponse
+// @Failure 403 {object} responses.UserResponse
 // @Failure 404 {object} responses.UserResponse
 // @Failure 500 {object} responses.UserResponse
 // @Router /api/user/{userId} [delete]
 // @Security BearerAuth
-func DeleteUser() gin.HandlerFunc {
+func DeleteUser(users repositories.UserRepository, roles repositories.RoleRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 		userId := c.Param("userId")
 		defer cancel()
 		objId, _ := primitive.ObjectIDFromHex(userId)
 
-		//TODO: Delete user's organisation and items
-
-		result, err := userCollection.DeleteOne(ctx, bson.M{"_id": objId})
+		user, err := findReachableUser(ctx, c, users, objId)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error deleting user", Data: map[string]interface{}{"data": err.Error()}})
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.UserResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if tenant.Get(c).User.ID != user.ID && !canManageUser(ctx, c, roles, user) {
 			return
 		}
 
-		if result.DeletedCount == 0 {
-			c.JSON(http.StatusNotFound, responses.UserResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+		//TODO: Delete user's organisation and items
+
+		err = users.Delete(ctx, objId)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.UserResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error deleting user", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
@@ -230,69 +273,150 @@ func DeleteUser() gin.HandlerFunc {
 	}
 }
 
-// GetCurrentUser
-// @Summary Get current user
-// @Description Get the details of the session user
+// AssignUserRole assigns a role to a member of the organisation.
+// @Summary Assign a role
+// @Description Assigns a built-in role or the ID of a custom role to a member of the organisation
 // @Tags Users
-// @Accept  json
-// @Produce  json
-// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param userId path string true "User ID"
+// @Param role body models.RoleAssignment true "Role to assign"
 // @Success 200 {object} responses.UserResponse
 // @Failure 400 {object} responses.UserResponse
-// @Failure 401 {object} responses.UserResponse
+// @Failure 403 {object} responses.UserResponse
+// @Failure 404 {object} responses.UserResponse
 // @Failure 500 {object} responses.UserResponse
-// @Router /api/user/current [get]
+// @Router /api/user/{userId}/role [put]
 // @Security BearerAuth
-func GetCurrentUser() gin.HandlerFunc {
+func AssignUserRole(users repositories.UserRepository, roles repositories.RoleRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		claims, err := utils.GetClaims(c)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		userId := c.Param("userId")
+		var assignment *models.RoleAssignment
+		defer cancel()
+		objId, _ := primitive.ObjectIDFromHex(userId)
+
+		t := tenant.Get(c)
+		organisationID, err := t.Organisation()
 		if err != nil {
-			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": err.Error()}})
+			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		userID, ok := claims["user_id"].(string)
-		objId, _ := primitive.ObjectIDFromHex(userID)
-		defer cancel()
-		if !ok {
-			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": "Invalid  user claims"}})
+		if t.User.ID == objId {
+			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "You cannot change your own role", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+
+		if err := c.ShouldBindJSON(&assignment); err != nil {
+			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid request body", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
-		var user *models.UserPublic
 
-		err = userCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&user)
+		if err := validate.Struct(assignment); err != nil {
+			c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid input fields", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		user, err := users.FindMember(ctx, organisationID, objId)
 		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.UserResponse{Status: http.StatusNotFound, Message: "User not found", Data: map[string]interface{}{"data": nil}})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": err.Error()}})
 			return
 		}
 
-		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": user}})
+		if !canManageUser(ctx, c, roles, user) {
+			return
+		}
+
+		permissions, err := middleware.RolePermissions(ctx, roles, organisationID, assignment.Role)
+		if err != nil {
+			if err == middleware.ErrUnknownRole {
+				c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Unknown role", Data: map[string]interface{}{"data": assignment.Role}})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error resolving permissions", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if !canGrant(c, permissions) {
+			c.JSON(http.StatusForbidden, responses.UserResponse{Status: http.StatusForbidden, Message: "Role grants permissions you do not have", Data: map[string]interface{}{"data": nil}})
+			return
+		}
+
+		user.Role = assignment.Role
+		err = users.Update(ctx, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error updating user", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "Role assigned successfully", Data: map[string]interface{}{"data": assignment}})
 	}
 }
 
-func CurrentUser(c *gin.Context) (*models.UserPublic, error) {
-	claims, err := utils.GetClaims(c)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": err.Error()}})
-		return nil, err
+// GetCurrentUser
+// @Summary Get current user
+// @Description Get the details of the session user
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security BearerAuth
+// @Success 200 {object} responses.UserResponse
+// @Failure 400 {object} responses.UserResponse
+// @Failure 401 {object} responses.UserResponse
+// @Failure 500 {object} responses.UserResponse
+// @Router /api/user/current [get]
+// @Security BearerAuth
+func GetCurrentUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := publicUser(tenant.Get(c).User)
+
+		c.JSON(http.StatusOK, responses.UserResponse{Status: http.StatusOK, Message: "success", Data: map[string]interface{}{"data": user}})
 	}
+}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	userID, ok := claims["user_id"].(string)
-	objId, _ := primitive.ObjectIDFromHex(userID)
-	defer cancel()
-	if !ok {
-		c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": "Invalid  user claims"}})
-		return nil, err
+// findReachableUser finds the user with the given ID if the caller may reach them. Users always reach their own
+// record and otherwise only members of their organisation, everybody else does not exist for them.
+func findReachableUser(ctx context.Context, c *gin.Context, users repositories.UserRepository, userID primitive.ObjectID) (*models.User, error) {
+	t := tenant.Get(c)
+	if t.User.ID == userID {
+		return users.FindByID(ctx, userID)
 	}
-	var user *models.UserPublic
 
-	err = userCollection.FindOne(ctx, bson.M{"_id": objId}).Decode(&user)
+	organisationID, err := t.Organisation()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error getting user", Data: map[string]interface{}{"data": err.Error()}})
-		return nil, err
+		return nil, repositories.ErrNotFound
+	}
+
+	return users.FindMember(ctx, organisationID, userID)
+}
+
+// canManageUser reports whether the caller holds every permission of the other user, so nobody can change or remove a
+// user that has more permissions than they have, and writes the error response if not
+func canManageUser(ctx context.Context, c *gin.Context, roles repositories.RoleRepository, user *models.User) bool {
+	permissions, err := middleware.UserPermissions(ctx, roles, user)
+	if err != nil && err != middleware.ErrUnknownRole {
+		c.JSON(http.StatusInternalServerError, responses.UserResponse{Status: http.StatusInternalServerError, Message: "Error resolving permissions", Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	if !canGrant(c, permissions) {
+		c.JSON(http.StatusForbidden, responses.UserResponse{Status: http.StatusForbidden, Message: "You cannot change this user", Data: map[string]interface{}{"data": nil}})
+		return false
 	}
+	return true
+}
 
-	return user, nil
+func publicUser(user *models.User) *models.UserPublic {
+	return &models.UserPublic{
+		ID:             user.ID,
+		FirstName:      user.FirstName,
+		LastName:       user.LastName,
+		Email:          user.Email,
+		Location:       user.Location,
+		OrganisationID: user.OrganisationID,
+		Role:           user.Role,
+	}
 }
@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"poosible-backend/vin"
+	"strings"
+	"time"
+)
+
+// CreateVehicle godoc
+// @Summary Create a vehicle
+// @Description Creates a vehicle of the organisation. A VIN is validated and fills in the make and year when they are not given.
+// @Tags Vehicle
+// @Accept json
+// @Produce json
+// @Param vehicle body models.VehicleNew true "Vehicle data"
+// @Success 200 {object} responses.VehicleResponse
+// @Failure 400 {object} responses.VehicleResponse
+// @Failure 500 {object} responses.VehicleResponse
+// @Router /api/vehicle [post]
+// @Security BearerAuth
+func CreateVehicle(vehicles repositories.VehicleRepository, customers repositories.CustomerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.VehicleNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		newVehicle := models.Vehicle{
+			OrganisationID:   organisationId,
+			OdometerReadings: []models.OdometerReading{},
+			UpdatedAt:        time.Now(),
+			CreatedAt:        time.Now(),
+		}
+		if !applyVehicle(ctx, c, vehicles, customers, &newVehicle, input) {
+			return
+		}
+		if input.Odometer != nil {
+			newVehicle.OdometerReadings = append(newVehicle.OdometerReadings, models.OdometerReading{
+				Value:      input.Odometer.Value,
+				Unit:       input.Odometer.Unit,
+				RecordedBy: tenant.Get(c).User.ID,
+				RecordedAt: time.Now(),
+			})
+		}
+
+		err = vehicles.Create(ctx, &newVehicle)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.VehicleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.VehicleResponse{Status: http.StatusOK, Message: "Vehicle created", Data: map[string]interface{}{"data": newVehicle}})
+	}
+}
+
+// GetVehicle godoc
+// @Summary Get a vehicle
+// @Description Gets a vehicle of the organisation
+// @Tags Vehicle
+// @Accept json
+// @Produce json
+// @Param vehicleId path string true "Vehicle ID"
+// @Success 200 {object} responses.VehicleResponse
+// @Failure 404 {object} responses.VehicleResponse
+// @Failure 500 {object} responses.VehicleResponse
+// @Router /api/vehicle/{vehicleId} [get]
+// @Security BearerAuth
+func GetVehicle(vehicles repositories.VehicleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		vehicleId := c.Param("vehicleId")
+		vehicleObjectId, _ := primitive.ObjectIDFromHex(vehicleId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		vehicle, err := vehicles.FindByID(ctx, organisationId, vehicleObjectId)
+		if err != nil {
+			respondVehicleError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.VehicleResponse{Status: http.StatusOK, Message: "Vehicle found", Data: map[string]interface{}{"data": vehicle}})
+	}
+}
+
+// GetVehicles godoc
+// @Summary Get vehicles
+// @Description Gets a page of the vehicles of the organisation, optionally looked up by plate or VIN or limited to one customer. They are sorted by plate, make, year, created_at or updated_at, prefixed with "-" for descending order.
+// @Tags Vehicle
+// @Accept json
+// @Produce json
+// @Param plate query string false "Licence plate, separators and case are ignored"
+// @Param vin query string false "VIN"
+// @Param customer_id query string false "Customer ID"
+// @Param sort query string false "Sort field" default(plate)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.VehicleResponse
+// @Failure 400 {object} responses.VehicleResponse
+// @Failure 500 {object} responses.VehicleResponse
+// @Router /api/vehicles [get]
+// @Security BearerAuth
+func GetVehicles(vehicles repositories.VehicleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.VehicleSortFields, "plate")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.VehicleSortFields.Names()}})
+			return
+		}
+		filter := models.VehicleFilter{
+			VIN:   vin.Normalize(c.Query("vin")),
+			Plate: models.NormalizePlate(c.Query("plate")),
+		}
+		if customerId := c.Query("customer_id"); customerId != "" {
+			customerObjectId, err := primitive.ObjectIDFromHex(customerId)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Invalid customer ID", Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			filter.CustomerID = &customerObjectId
+		}
+
+		result, paging, err := vehicles.FindAll(ctx, organisationId, filter, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.VehicleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.VehicleResponse{Status: http.StatusOK, Message: "Vehicles found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// UpdateVehicle godoc
+// @Summary Update a vehicle
+// @Description Replaces the details of a vehicle of the organisation. Odometer readings are recorded separately.
+// @Tags Vehicle
+// @Accept json
+// @Produce json
+// @Param vehicleId path string true "Vehicle ID"
+// @Param vehicle body models.VehicleNew true "Vehicle data"
+// @Success 200 {object} responses.VehicleResponse
+// @Failure 400 {object} responses.VehicleResponse
+// @Failure 404 {object} responses.VehicleResponse
+// @Failure 500 {object} responses.VehicleResponse
+// @Router /api/vehicle/{vehicleId} [put]
+// @Security BearerAuth
+func UpdateVehicle(vehicles repositories.VehicleRepository, customers repositories.CustomerRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		vehicleId := c.Param("vehicleId")
+		vehicleObjectId, _ := primitive.ObjectIDFromHex(vehicleId)
+		var input *models.VehicleNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		vehicle, err := vehicles.FindByID(ctx, organisationId, vehicleObjectId)
+		if err != nil {
+			respondVehicleError(c, err)
+			return
+		}
+
+		if !applyVehicle(ctx, c, vehicles, customers, vehicle, input) {
+			return
+		}
+		vehicle.UpdatedAt = time.Now()
+		err = vehicles.Update(ctx, vehicle)
+		if err != nil {
+			respondVehicleError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.VehicleResponse{Status: http.StatusOK, Message: "Vehicle updated", Data: map[string]interface{}{"data": vehicle}})
+	}
+}
+
+// DeleteVehicle godoc
+// @Summary Delete a vehicle
+// @Description Deletes a vehicle of the organisation
+// @Tags Vehicle
+// @Accept json
+// @Produce json
+// @Param vehicleId path string true "Vehicle ID"
+// @Success 200 {object} responses.VehicleResponse
+// @Failure 404 {object} responses.VehicleResponse
+// @Failure 500 {object} responses.VehicleResponse
+// @Router /api/vehicle/{vehicleId} [delete]
+// @Security BearerAuth
+func DeleteVehicle(vehicles repositories.VehicleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		vehicleId := c.Param("vehicleId")
+		vehicleObjectId, _ := primitive.ObjectIDFromHex(vehicleId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		err = vehicles.Delete(ctx, organisationId, vehicleObjectId)
+		if err != nil {
+			respondVehicleError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.VehicleResponse{Status: http.StatusOK, Message: "Vehicle deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
+
+// AddOdometerReading godoc
+// @Summary Record an odometer reading
+// @Description Adds an odometer reading to the history of a vehicle. Readings lower than the previous one are rejected.
+// @Tags Vehicle
+// @Accept json
+// @Produce json
+// @Param vehicleId path string true "Vehicle ID"
+// @Param reading body models.OdometerReadingNew true "Odometer reading"
+// @Success 200 {object} responses.VehicleResponse
+// @Failure 400 {object} responses.VehicleResponse
+// @Failure 404 {object} responses.VehicleResponse
+// @Failure 500 {object} responses.VehicleResponse
+// @Router /api/vehicle/{vehicleId}/odometer [post]
+// @Security BearerAuth
+func AddOdometerReading(vehicles repositories.VehicleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		vehicleId := c.Param("vehicleId")
+		vehicleObjectId, _ := primitive.ObjectIDFromHex(vehicleId)
+		var input *models.OdometerReadingNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		vehicle, err := vehicles.FindByID(ctx, organisationId, vehicleObjectId)
+		if err != nil {
+			respondVehicleError(c, err)
+			return
+		}
+
+		reading := models.OdometerReading{
+			Value:      input.Value,
+			Unit:       input.Unit,
+			RecordedBy: tenant.Get(c).User.ID,
+			RecordedAt: time.Now(),
+		}
+
+		//Check if the odometer went backwards
+		if last := vehicle.LastOdometerReading(); last != nil && reading.Kilometres() < last.Kilometres() {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Odometer reading is lower than the previous reading", Data: map[string]interface{}{"data": last}})
+			return
+		}
+
+		err = vehicles.AddOdometerReading(ctx, organisationId, vehicle.ID, reading)
+		if err != nil {
+			respondVehicleError(c, err)
+			return
+		}
+		vehicle.OdometerReadings = append(vehicle.OdometerReadings, reading)
+		vehicle.UpdatedAt = reading.RecordedAt
+
+		c.JSON(http.StatusOK, responses.VehicleResponse{Status: http.StatusOK, Message: "Odometer reading recorded", Data: map[string]interface{}{"data": vehicle}})
+	}
+}
+
+// DecodeVIN godoc
+// @Summary Decode a VIN
+// @Description Validates a VIN and derives its manufacturer, region and model year without contacting any external service
+// @Tags Vehicle
+// @Accept json
+// @Produce json
+// @Param vin path string true "VIN"
+// @Success 200 {object} responses.VehicleResponse
+// @Failure 400 {object} responses.VehicleResponse
+// @Router /api/vin/{vin} [get]
+// @Security BearerAuth
+func DecodeVIN() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decoded, err := vin.Decode(c.Param("vin"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Invalid VIN", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.VehicleResponse{Status: http.StatusOK, Message: "VIN decoded", Data: map[string]interface{}{"data": decoded}})
+	}
+}
+
+// applyVehicle copies the input onto the vehicle after checking its customer and VIN, and writes the error response if they are invalid
+func applyVehicle(ctx context.Context, c *gin.Context, vehicles repositories.VehicleRepository, customers repositories.CustomerRepository, vehicle *models.Vehicle, input *models.VehicleNew) bool {
+	vehicle.CustomerID = nil
+	if input.CustomerID != "" {
+		customerObjectId, _ := primitive.ObjectIDFromHex(input.CustomerID)
+		customer, err := customers.FindByID(ctx, vehicle.OrganisationID, customerObjectId)
+		if err != nil {
+			respondCustomerError(c, err)
+			return false
+		}
+		vehicle.CustomerID = &customer.ID
+	}
+
+	vehicle.VIN = ""
+	var decoded *vin.Decoded
+	if input.VIN != "" {
+		var err error
+		decoded, err = vin.Decode(input.VIN)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "Invalid VIN", Data: map[string]interface{}{"data": err.Error()}})
+			return false
+		}
+
+		//Check if the VIN is already registered
+		vehicleCheck, err := vehicles.FindByVIN(ctx, vehicle.OrganisationID, decoded.VIN)
+		if err == nil && vehicleCheck.ID != vehicle.ID {
+			c.JSON(http.StatusBadRequest, responses.VehicleResponse{Status: http.StatusBadRequest, Message: "VIN already exists", Data: map[string]interface{}{"data": vehicleCheck}})
+			return false
+		}
+		if err != nil && err != repositories.ErrNotFound {
+			c.JSON(http.StatusInternalServerError, responses.VehicleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return false
+		}
+		vehicle.VIN = decoded.VIN
+	}
+
+	vehicle.Plate = strings.ToUpper(strings.TrimSpace(input.Plate))
+	vehicle.PlateNormalized = models.NormalizePlate(input.Plate)
+	vehicle.Make = strings.TrimSpace(input.Make)
+	vehicle.Model = strings.TrimSpace(input.Model)
+	vehicle.Year = input.Year
+	vehicle.Engine = strings.TrimSpace(input.Engine)
+	vehicle.FuelType = input.FuelType
+
+	if decoded != nil {
+		if vehicle.Make == "" {
+			vehicle.Make = decoded.Manufacturer
+		}
+		if vehicle.Year == 0 {
+			vehicle.Year = decoded.ModelYear
+		}
+	}
+	return true
+}
+
+// respondVehicleError writes the response for an error returned by the vehicle repository
+func respondVehicleError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.VehicleResponse{Status: http.StatusNotFound, Message: "Vehicle not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.VehicleResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
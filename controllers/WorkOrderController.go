@@ -0,0 +1,531 @@
+package controllers
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"poosible-backend/repositories"
+	"poosible-backend/responses"
+	"poosible-backend/tenant"
+	"strings"
+	"time"
+)
+
+// CreateWorkOrder godoc
+// @Summary Create a work order
+// @Description Creates a draft work order (job card) for a customer and optionally one of their vehicles
+// @Tags WorkOrder
+// @Accept json
+// @Produce json
+// @Param workOrder body models.WorkOrderNew true "Work order data"
+// @Success 200 {object} responses.WorkOrderResponse
+// @Failure 400 {object} responses.WorkOrderResponse
+// @Failure 404 {object} responses.WorkOrderResponse
+// @Failure 500 {object} responses.WorkOrderResponse
+// @Router /api/work-order [post]
+// @Security BearerAuth
+func CreateWorkOrder(workOrders repositories.WorkOrderRepository, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, vehicles repositories.VehicleRepository, items repositories.ItemRepository, users repositories.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var input *models.WorkOrderNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		newWorkOrder := models.WorkOrder{
+			Status:         models.WorkOrderDraft,
+			History:        []models.WorkOrderStatusChange{},
+			CreatedBy:      tenant.Get(c).User.ID,
+			OrganisationID: organisationId,
+			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now(),
+		}
+		if !applyWorkOrder(ctx, c, organisations, customers, vehicles, items, users, &newWorkOrder, input) {
+			return
+		}
+
+		err = workOrders.Create(ctx, &newWorkOrder)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.WorkOrderResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.WorkOrderResponse{Status: http.StatusOK, Message: "Work order created", Data: map[string]interface{}{"data": newWorkOrder}})
+	}
+}
+
+// GetWorkOrder godoc
+// @Summary Get a work order
+// @Description Gets a work order of the organisation including its status history
+// @Tags WorkOrder
+// @Accept json
+// @Produce json
+// @Param workOrderId path string true "Work order ID"
+// @Success 200 {object} responses.WorkOrderResponse
+// @Failure 404 {object} responses.WorkOrderResponse
+// @Failure 500 {object} responses.WorkOrderResponse
+// @Router /api/work-order/{workOrderId} [get]
+// @Security BearerAuth
+func GetWorkOrder(workOrders repositories.WorkOrderRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		workOrderId := c.Param("workOrderId")
+		workOrderObjectId, _ := primitive.ObjectIDFromHex(workOrderId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		workOrder, err := workOrders.FindByID(ctx, organisationId, workOrderObjectId)
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.WorkOrderResponse{Status: http.StatusOK, Message: "Work order found", Data: map[string]interface{}{"data": workOrder}})
+	}
+}
+
+// GetWorkOrders godoc
+// @Summary Get work orders
+// @Description Gets a page of the work orders of the organisation, newest first. They can also be sorted by status, created_at or updated_at, prefixed with "-" for descending order.
+// @Tags WorkOrder
+// @Accept json
+// @Produce json
+// @Param status query string false "Status"
+// @Param customer_id query string false "Customer ID"
+// @Param vehicle_id query string false "Vehicle ID"
+// @Param sort query string false "Sort field" default(-created_at)
+// @Param limit query int false "Page size, at most 200" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} responses.WorkOrderResponse
+// @Failure 400 {object} responses.WorkOrderResponse
+// @Failure 500 {object} responses.WorkOrderResponse
+// @Router /api/work-orders [get]
+// @Security BearerAuth
+func GetWorkOrders(workOrders repositories.WorkOrderRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		page, err := listing.Parse(c.Request.URL.Query(), repositories.WorkOrderSortFields, "-created_at")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Invalid paging", Data: map[string]interface{}{"data": err.Error(), "sort": repositories.WorkOrderSortFields.Names()}})
+			return
+		}
+		filter := models.WorkOrderFilter{Status: models.WorkOrderStatus(c.Query("status"))}
+		if filter.Status != "" && !models.IsValidWorkOrderStatus(filter.Status) {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Invalid status", Data: map[string]interface{}{"data": filter.Status}})
+			return
+		}
+		for param, target := range map[string]**primitive.ObjectID{"customer_id": &filter.CustomerID, "vehicle_id": &filter.VehicleID} {
+			value := c.Query(param)
+			if value == "" {
+				continue
+			}
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Invalid " + param, Data: map[string]interface{}{"data": err.Error()}})
+				return
+			}
+			*target = &id
+		}
+
+		result, paging, err := workOrders.FindAll(ctx, organisationId, filter, page)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, responses.WorkOrderResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.WorkOrderResponse{Status: http.StatusOK, Message: "Work orders found", Data: map[string]interface{}{"data": result, "paging": paging}})
+	}
+}
+
+// UpdateWorkOrder godoc
+// @Summary Update a work order
+// @Description Replaces the customer, vehicle, complaints, lines, technicians and notes of a work order that is not completed yet
+// @Tags WorkOrder
+// @Accept json
+// @Produce json
+// @Param workOrderId path string true "Work order ID"
+// @Param workOrder body models.WorkOrderNew true "Work order data"
+// @Success 200 {object} responses.WorkOrderResponse
+// @Failure 400 {object} responses.WorkOrderResponse
+// @Failure 404 {object} responses.WorkOrderResponse
+// @Failure 409 {object} responses.WorkOrderResponse
+// @Failure 500 {object} responses.WorkOrderResponse
+// @Router /api/work-order/{workOrderId} [put]
+// @Security BearerAuth
+func UpdateWorkOrder(workOrders repositories.WorkOrderRepository, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, vehicles repositories.VehicleRepository, items repositories.ItemRepository, users repositories.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		workOrderId := c.Param("workOrderId")
+		workOrderObjectId, _ := primitive.ObjectIDFromHex(workOrderId)
+		var input *models.WorkOrderNew
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		workOrder, err := workOrders.FindByID(ctx, organisationId, workOrderObjectId)
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+		if !workOrder.Editable() {
+			c.JSON(http.StatusConflict, responses.WorkOrderResponse{Status: http.StatusConflict, Message: "Work order can no longer be changed", Data: map[string]interface{}{"data": workOrder.Status}})
+			return
+		}
+
+		if !applyWorkOrder(ctx, c, organisations, customers, vehicles, items, users, workOrder, input) {
+			return
+		}
+		workOrder.UpdatedAt = time.Now()
+		err = workOrders.Update(ctx, workOrder, workOrder.Status)
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.WorkOrderResponse{Status: http.StatusOK, Message: "Work order updated", Data: map[string]interface{}{"data": workOrder}})
+	}
+}
+
+// TransitionWorkOrder godoc
+// @Summary Change the status of a work order
+// @Description Moves a work order along draft → estimated → approved → in_progress ⇄ waiting_parts → completed → invoiced → closed. An estimate may go back to draft for revision. Completed work orders become invoiced only by issuing their invoice. Completing a work order takes the tracked parts it used out of stock. Each change is recorded with the user and time.
+// @Tags WorkOrder
+// @Accept json
+// @Produce json
+// @Param workOrderId path string true "Work order ID"
+// @Param transition body models.WorkOrderTransition true "New status"
+// @Success 200 {object} responses.WorkOrderResponse
+// @Failure 400 {object} responses.WorkOrderResponse
+// @Failure 404 {object} responses.WorkOrderResponse
+// @Failure 409 {object} responses.WorkOrderResponse
+// @Failure 500 {object} responses.WorkOrderResponse
+// @Router /api/work-order/{workOrderId}/status [post]
+// @Security BearerAuth
+func TransitionWorkOrder(workOrders repositories.WorkOrderRepository, organisations repositories.OrganisationRepository, items repositories.ItemRepository, stock repositories.StockRepository, locations repositories.StockLocationRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		workOrderId := c.Param("workOrderId")
+		workOrderObjectId, _ := primitive.ObjectIDFromHex(workOrderId)
+		var input *models.WorkOrderTransition
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Bind the JSON body to the struct
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		// Validate the input
+		if err := validate.Struct(input); err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Bad request", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if !models.IsValidWorkOrderStatus(input.Status) {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Invalid status", Data: map[string]interface{}{"data": input.Status}})
+			return
+		}
+
+		workOrder, err := workOrders.FindByID(ctx, organisationId, workOrderObjectId)
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+
+		change, ok := transitionWorkOrder(c, workOrder, input.Status, input.Comment)
+		if !ok {
+			return
+		}
+		if change.To == models.WorkOrderCompleted {
+			err = consumeWorkOrderStock(ctx, workOrders, organisations, items, stock, locations, workOrder, change)
+		} else {
+			err = workOrders.Transition(ctx, organisationId, workOrder.ID, change)
+		}
+		if err == repositories.ErrInsufficientStock {
+			c.JSON(http.StatusConflict, responses.WorkOrderResponse{Status: http.StatusConflict, Message: "Not enough stock on hand", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+		workOrder.Status = change.To
+		workOrder.UpdatedAt = change.ChangedAt
+		workOrder.History = append(workOrder.History, change)
+
+		c.JSON(http.StatusOK, responses.WorkOrderResponse{Status: http.StatusOK, Message: "Work order status changed", Data: map[string]interface{}{"data": workOrder}})
+	}
+}
+
+// DeleteWorkOrder godoc
+// @Summary Delete a work order
+// @Description Deletes a work order that is still a draft
+// @Tags WorkOrder
+// @Accept json
+// @Produce json
+// @Param workOrderId path string true "Work order ID"
+// @Success 200 {object} responses.WorkOrderResponse
+// @Failure 404 {object} responses.WorkOrderResponse
+// @Failure 409 {object} responses.WorkOrderResponse
+// @Failure 500 {object} responses.WorkOrderResponse
+// @Router /api/work-order/{workOrderId} [delete]
+// @Security BearerAuth
+func DeleteWorkOrder(workOrders repositories.WorkOrderRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		workOrderId := c.Param("workOrderId")
+		workOrderObjectId, _ := primitive.ObjectIDFromHex(workOrderId)
+		defer cancel()
+
+		organisationId, err := tenant.Get(c).Organisation()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "User does not belong to an organisation", Data: map[string]interface{}{"data": err.Error()}})
+			return
+		}
+
+		workOrder, err := workOrders.FindByID(ctx, organisationId, workOrderObjectId)
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+		if workOrder.Status != models.WorkOrderDraft {
+			c.JSON(http.StatusConflict, responses.WorkOrderResponse{Status: http.StatusConflict, Message: "Only draft work orders can be deleted", Data: map[string]interface{}{"data": workOrder.Status}})
+			return
+		}
+
+		err = workOrders.Delete(ctx, organisationId, workOrder.ID)
+		if err != nil {
+			respondWorkOrderError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, responses.WorkOrderResponse{Status: http.StatusOK, Message: "Work order deleted", Data: map[string]interface{}{"data": nil}})
+	}
+}
+
+// transitionWorkOrder checks that the work order may move to the status and returns the change to record, or writes
+// the error response
+func transitionWorkOrder(c *gin.Context, workOrder *models.WorkOrder, status models.WorkOrderStatus, comment string) (models.WorkOrderStatusChange, bool) {
+	if !models.CanTransition(workOrder.Status, status) {
+		c.JSON(http.StatusConflict, responses.WorkOrderResponse{Status: http.StatusConflict, Message: "Work order cannot move from " + string(workOrder.Status) + " to " + string(status), Data: map[string]interface{}{"data": models.WorkOrderTransitions[workOrder.Status]}})
+		return models.WorkOrderStatusChange{}, false
+	}
+	if status == models.WorkOrderInvoiced {
+		c.JSON(http.StatusConflict, responses.WorkOrderResponse{Status: http.StatusConflict, Message: "Work orders are invoiced by issuing an invoice for them", Data: map[string]interface{}{"data": nil}})
+		return models.WorkOrderStatusChange{}, false
+	}
+	if status == models.WorkOrderEstimated && len(workOrder.Lines) == 0 {
+		c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "An estimate needs at least one line", Data: map[string]interface{}{"data": nil}})
+		return models.WorkOrderStatusChange{}, false
+	}
+
+	return models.WorkOrderStatusChange{
+		From:      workOrder.Status,
+		To:        status,
+		Comment:   strings.TrimSpace(comment),
+		UserID:    tenant.Get(c).User.ID,
+		ChangedAt: time.Now(),
+	}, true
+}
+
+// consumeWorkOrderStock completes the work order, taking the tracked parts it used out of the default location in the
+// same transaction
+func consumeWorkOrderStock(ctx context.Context, workOrders repositories.WorkOrderRepository, organisations repositories.OrganisationRepository, items repositories.ItemRepository, stock repositories.StockRepository, locations repositories.StockLocationRepository, workOrder *models.WorkOrder, change models.WorkOrderStatusChange) error {
+	organisation, err := organisations.FindByID(ctx, workOrder.OrganisationID)
+	if err != nil {
+		return err
+	}
+
+	lines := []stockLine{}
+	for _, line := range workOrder.Lines {
+		if line.Type == models.WorkOrderLinePart {
+			lines = append(lines, stockLine{itemId: line.ItemID, quantity: line.Quantity})
+		}
+	}
+	reference := &models.StockReference{Type: models.StockReferenceWorkOrder, ID: workOrder.ID}
+	movements, err := outgoingStock(ctx, items, locations, workOrder.OrganisationID, models.StockConsumption, reference, change.UserID, lines)
+	if err != nil {
+		return err
+	}
+
+	return stock.Record(ctx, workOrder.OrganisationID, movements, organisation.AllowNegativeStock, func(ctx context.Context) error {
+		return workOrders.Transition(ctx, workOrder.OrganisationID, workOrder.ID, change)
+	})
+}
+
+// applyWorkOrder copies the input onto the work order after resolving every referenced customer, vehicle, item and
+// technician within the organisation and pricing the lines in its currency, and writes the error response if one of
+// them is invalid
+func applyWorkOrder(ctx context.Context, c *gin.Context, organisations repositories.OrganisationRepository, customers repositories.CustomerRepository, vehicles repositories.VehicleRepository, items repositories.ItemRepository, users repositories.UserRepository, workOrder *models.WorkOrder, input *models.WorkOrderNew) bool {
+	organisationId := workOrder.OrganisationID
+
+	organisation, err := organisations.FindByID(ctx, organisationId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, responses.WorkOrderResponse{Status: http.StatusInternalServerError, Message: "Error getting organisation", Data: map[string]interface{}{"data": err.Error()}})
+		return false
+	}
+	currency, err := money.CurrencyAlpha(organisation.Currency)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Organisation has no valid currency", Data: map[string]interface{}{"data": organisation.Currency}})
+		return false
+	}
+
+	customerObjectId, _ := primitive.ObjectIDFromHex(input.CustomerID)
+	customer, err := customers.FindByID(ctx, organisationId, customerObjectId)
+	if err != nil {
+		respondCustomerError(c, err)
+		return false
+	}
+
+	var vehicleId *primitive.ObjectID
+	if input.VehicleID != "" {
+		vehicleObjectId, _ := primitive.ObjectIDFromHex(input.VehicleID)
+		vehicle, err := vehicles.FindByID(ctx, organisationId, vehicleObjectId)
+		if err != nil {
+			respondVehicleError(c, err)
+			return false
+		}
+
+		//Check if the vehicle belongs to somebody else
+		if vehicle.CustomerID != nil && *vehicle.CustomerID != customer.ID {
+			c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Vehicle belongs to another customer", Data: map[string]interface{}{"data": vehicle.CustomerID}})
+			return false
+		}
+		vehicleId = &vehicle.ID
+	}
+
+	lines := []models.WorkOrderLine{}
+	for _, lineInput := range input.Lines {
+		line := models.WorkOrderLine{
+			ID:          primitive.NewObjectID(),
+			Type:        lineInput.Type,
+			Description: strings.TrimSpace(lineInput.Description),
+			Quantity:    lineInput.Quantity,
+		}
+		if lineInput.UnitPrice != nil {
+			unitPrice, err := lineInput.UnitPrice.In(currency)
+			if err != nil || unitPrice.IsNegative() {
+				c.JSON(http.StatusBadRequest, responses.WorkOrderResponse{Status: http.StatusBadRequest, Message: "Invalid unit price", Data: map[string]interface{}{"data": lineInput.UnitPrice}})
+				return false
+			}
+			line.UnitPrice = unitPrice
+		}
+
+		//Parts come from the catalogue and default to its name and price
+		if lineInput.Type == models.WorkOrderLinePart {
+			itemObjectId, _ := primitive.ObjectIDFromHex(lineInput.ItemID)
+			item, err := items.FindByID(ctx, organisationId, itemObjectId)
+			if err != nil {
+				if err == repositories.ErrNotFound {
+					c.JSON(http.StatusNotFound, responses.WorkOrderResponse{Status: http.StatusNotFound, Message: "Item not found", Data: map[string]interface{}{"data": lineInput.ItemID}})
+					return false
+				}
+				c.JSON(http.StatusInternalServerError, responses.WorkOrderResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+				return false
+			}
+			line.ItemID = &item.ID
+			if line.Description == "" {
+				line.Description = item.Name
+			}
+			if lineInput.UnitPrice == nil {
+				line.UnitPrice = item.Price
+			}
+		}
+		lines = append(lines, line)
+	}
+
+	technicianIds := []primitive.ObjectID{}
+	for _, technicianId := range input.TechnicianIDs {
+		technicianObjectId, _ := primitive.ObjectIDFromHex(technicianId)
+		technician, err := users.FindMember(ctx, organisationId, technicianObjectId)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.JSON(http.StatusNotFound, responses.WorkOrderResponse{Status: http.StatusNotFound, Message: "Technician not found", Data: map[string]interface{}{"data": technicianId}})
+				return false
+			}
+			c.JSON(http.StatusInternalServerError, responses.WorkOrderResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+			return false
+		}
+		technicianIds = append(technicianIds, technician.ID)
+	}
+
+	complaints := []string{}
+	for _, complaint := range input.Complaints {
+		complaints = append(complaints, strings.TrimSpace(complaint))
+	}
+
+	workOrder.CustomerID = customer.ID
+	workOrder.VehicleID = vehicleId
+	workOrder.Complaints = complaints
+	workOrder.Lines = lines
+	workOrder.TechnicianIDs = technicianIds
+	workOrder.Notes = input.Notes
+	return true
+}
+
+// respondWorkOrderError writes the response for an error returned by the work order repository
+func respondWorkOrderError(c *gin.Context, err error) {
+	if err == repositories.ErrNotFound {
+		c.JSON(http.StatusNotFound, responses.WorkOrderResponse{Status: http.StatusNotFound, Message: "Work order not found", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	if err == repositories.ErrVersionConflict {
+		c.JSON(http.StatusConflict, responses.WorkOrderResponse{Status: http.StatusConflict, Message: "Work order was changed by someone else", Data: map[string]interface{}{"data": err.Error()}})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, responses.WorkOrderResponse{Status: http.StatusInternalServerError, Message: "Internal server error", Data: map[string]interface{}{"data": err.Error()}})
+}
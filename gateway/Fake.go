@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"sync"
+)
+
+// FakeDeclineToken is a card token the Fake gateway declines, any other token is captured
+const FakeDeclineToken = "tok_declined"
+
+// FakeUnrefundableToken is a card token the Fake gateway captures but fails to refund, like a gateway that went away
+// between the two
+const FakeUnrefundableToken = "tok_unrefundable"
+
+var errFakeRefundFailed = errors.New("refund failed")
+
+type fakeCharge struct {
+	captured     int64
+	refunded     int64
+	unrefundable bool
+}
+
+// Fake is a gateway for development and tests that keeps its charges in memory. Tokens like "tok_visa" are charged as
+// a card of that brand ending in 4242.
+type Fake struct {
+	mu      sync.Mutex
+	charges map[string]*fakeCharge
+}
+
+func NewFake() *Fake {
+	return &Fake{charges: map[string]*fakeCharge{}}
+}
+
+func (g *Fake) Name() string {
+	return FakeName
+}
+
+func (g *Fake) Capture(ctx context.Context, capture Capture) (*Charge, error) {
+	if capture.Token == FakeDeclineToken {
+		return nil, ErrDeclined
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	id := "fake_ch_" + primitive.NewObjectID().Hex()
+	g.charges[id] = &fakeCharge{captured: capture.Amount.Amount, unrefundable: capture.Token == FakeUnrefundableToken}
+	brand := strings.TrimPrefix(capture.Token, "tok_")
+	if brand == capture.Token || brand == "" {
+		brand = "card"
+	}
+	return &Charge{TransactionID: id, Brand: brand, Last4: "4242"}, nil
+}
+
+func (g *Fake) Refund(ctx context.Context, refund Refund) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	charge, ok := g.charges[refund.TransactionID]
+	if !ok {
+		return "", ErrUnknownTransaction
+	}
+	if charge.unrefundable {
+		return "", errFakeRefundFailed
+	}
+	if charge.refunded+refund.Amount.Amount > charge.captured {
+		return "", ErrRefundExceedsCapture
+	}
+	charge.refunded += refund.Amount.Amount
+	return "fake_re_" + primitive.NewObjectID().Hex(), nil
+}
+
+// Outstanding returns how much of the captured amounts was not refunded, in minor units of whatever currencies they
+// were captured in
+func (g *Fake) Outstanding() int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	outstanding := int64(0)
+	for _, charge := range g.charges {
+		outstanding += charge.captured - charge.refunded
+	}
+	return outstanding
+}
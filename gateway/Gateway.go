@@ -0,0 +1,64 @@
+// Package gateway captures and refunds card payments through a payment provider. Controllers only see the
+// PaymentGateway interface, a provider is added by implementing it and naming it in New.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"poosible-backend/money"
+)
+
+// FakeName names the Fake gateway, which accepts every card but the decline token without moving any money
+const FakeName = "fake"
+
+var (
+	// ErrDeclined is returned when the card issuer refuses a capture
+	ErrDeclined = errors.New("card was declined")
+	// ErrRefundExceedsCapture is returned when a refund is larger than what is left of the captured amount
+	ErrRefundExceedsCapture = errors.New("refund exceeds the captured amount")
+	// ErrUnknownTransaction is returned when a refund names a transaction the gateway does not know
+	ErrUnknownTransaction = errors.New("unknown transaction")
+)
+
+// Capture asks for an amount to be taken from a card. Token identifies the card as read by the card terminal or the
+// provider's payment form, card numbers never reach the API.
+type Capture struct {
+	Amount      money.Money
+	Token       string
+	Description string
+}
+
+// Charge is a captured card payment
+type Charge struct {
+	TransactionID string
+	Brand         string
+	Last4         string
+}
+
+// Refund asks for part or all of a captured amount to be paid back to the card
+type Refund struct {
+	TransactionID string
+	Amount        money.Money
+	Reason        string
+}
+
+// PaymentGateway is a payment provider cards are charged through
+type PaymentGateway interface {
+	// Name is stored with every charge, so a refund goes back through the gateway that captured it
+	Name() string
+	Capture(ctx context.Context, capture Capture) (*Charge, error)
+	// Refund pays back the amount and returns the ID of the refund transaction
+	Refund(ctx context.Context, refund Refund) (string, error)
+}
+
+// New returns the gateway with the name, or nil for an empty name, which leaves card payments turned off
+func New(name string) (PaymentGateway, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case FakeName:
+		return NewFake(), nil
+	}
+	return nil, fmt.Errorf("unknown payment gateway %q", name)
+}
@@ -0,0 +1,129 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"time"
+)
+
+// ErrImportsBusy is returned when too many imports are waiting to run
+var ErrImportsBusy = errors.New("too many imports are waiting, try again later")
+
+// importProgressRows is how many rows are imported between saves of the progress
+const importProgressRows = 50
+
+// ImportRow imports the row at the index, or only checks it on a dry run. It reports whether the row creates an item
+// rather than updating one, or why the row cannot be imported.
+type ImportRow func(ctx context.Context, index int) (created bool, rowError *models.ItemImportRowError)
+
+type importRun struct {
+	itemImport *models.ItemImport
+	row        ImportRow
+}
+
+// Imports runs item imports in the background one after another, so uploads are answered at once and a large import
+// does not slow down the requests of everyone else. The progress of an import is saved as it goes.
+type Imports struct {
+	imports repositories.ItemImportRepository
+	queue   chan importRun
+}
+
+func NewImports(repos *repositories.Repositories, capacity int) *Imports {
+	return &Imports{imports: repos.ItemImports, queue: make(chan importRun, capacity)}
+}
+
+// Enqueue stores the queued import, whose Total rows are passed to row one by one once the imports before it are done.
+// The job runs a copy of the import, so the caller can go on reading it.
+func (i *Imports) Enqueue(ctx context.Context, itemImport *models.ItemImport, row ImportRow) error {
+	itemImport.Status = models.ItemImportQueued
+	if err := i.imports.Create(ctx, itemImport); err != nil {
+		return err
+	}
+
+	running := *itemImport
+	select {
+	case i.queue <- importRun{itemImport: &running, row: row}:
+		return nil
+	default:
+	}
+	i.finish(ctx, itemImport, ErrImportsBusy)
+	return ErrImportsBusy
+}
+
+// errImportAbandoned is recorded on imports the server stopped before finishing. The rows of an import are only held
+// by the server it was uploaded to, so they cannot be picked up again.
+var errImportAbandoned = errors.New("the server restarted before the import finished, upload the file again")
+
+// FailUnfinished marks the imports a previous server left queued or running as failed. It has to run before the
+// server takes uploads, which would otherwise be failed with them.
+func (i *Imports) FailUnfinished(ctx context.Context) (int64, error) {
+	return i.imports.FailUnfinished(ctx, errImportAbandoned.Error(), time.Now())
+}
+
+// Run imports queued imports until the context is done
+func (i *Imports) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case run := <-i.queue:
+			i.run(ctx, run)
+		}
+	}
+}
+
+func (i *Imports) run(ctx context.Context, run importRun) {
+	itemImport := run.itemImport
+	itemImport.Status = models.ItemImportRunning
+	i.save(ctx, itemImport)
+
+	for index := itemImport.Processed; index < itemImport.Total; index++ {
+		if ctx.Err() != nil {
+			i.finish(context.Background(), itemImport, ctx.Err())
+			return
+		}
+
+		rowCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		created, rowError := run.row(rowCtx, index)
+		cancel()
+		switch {
+		case rowError != nil:
+			itemImport.Fail(*rowError)
+		case created:
+			itemImport.Created++
+		default:
+			itemImport.Updated++
+		}
+		itemImport.Processed++
+
+		if itemImport.Processed%importProgressRows == 0 {
+			i.save(ctx, itemImport)
+		}
+	}
+	i.finish(ctx, itemImport, nil)
+}
+
+// finish records that the import completed, or why it failed
+func (i *Imports) finish(ctx context.Context, itemImport *models.ItemImport, err error) {
+	now := time.Now()
+	itemImport.Status = models.ItemImportCompleted
+	if err != nil {
+		itemImport.Status = models.ItemImportFailed
+		itemImport.Error = err.Error()
+	}
+	itemImport.FinishedAt = &now
+	i.save(ctx, itemImport)
+}
+
+func (i *Imports) save(ctx context.Context, itemImport *models.ItemImport) {
+	saveCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	itemImport.UpdatedAt = time.Now()
+	if err := i.imports.Update(saveCtx, itemImport); err != nil {
+		log.Printf("import: saving import %s: %v", itemImport.ID.Hex(), err)
+	}
+}
@@ -0,0 +1,47 @@
+package jobs_test
+
+import (
+	"context"
+	"poosible-backend/jobs"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestFailUnfinishedImports(t *testing.T) {
+	ctx := context.Background()
+	repos := repositories.NewMemoryRepositories()
+	organisationID := primitive.NewObjectID()
+
+	statuses := []models.ItemImportStatus{models.ItemImportQueued, models.ItemImportRunning, models.ItemImportCompleted, models.ItemImportFailed}
+	want := []models.ItemImportStatus{models.ItemImportFailed, models.ItemImportFailed, models.ItemImportCompleted, models.ItemImportFailed}
+	imports := make([]*models.ItemImport, len(statuses))
+	for n, status := range statuses {
+		imports[n] = &models.ItemImport{Status: status, OrganisationID: organisationID}
+		if err := repos.ItemImports.Create(ctx, imports[n]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failed, err := jobs.NewImports(repos, 1).FailUnfinished(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 2 {
+		t.Fatalf("failed %d imports, want the queued and the running one", failed)
+	}
+	for n, itemImport := range imports {
+		stored, err := repos.ItemImports.FindByID(ctx, organisationID, itemImport.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stored.Status != want[n] {
+			t.Errorf("%s import is %s, want %s", statuses[n], stored.Status, want[n])
+		}
+		if unfinished := n < 2; unfinished && (stored.Error == "" || stored.FinishedAt == nil) {
+			t.Errorf("%s import was failed without a reason or time: %+v", statuses[n], stored)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Package jobs holds the work that runs in the background of the API
+package jobs
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"log"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"sync"
+	"time"
+)
+
+type reorderKey struct {
+	organisationID primitive.ObjectID
+	itemID         primitive.ObjectID
+}
+
+// Reorder keeps the reorder suggestions up to date. Stock movements and item changes queue the items they touch, and
+// the job re-evaluates the queued items in the background so no request waits for it.
+type Reorder struct {
+	items          repositories.ItemRepository
+	stock          repositories.StockRepository
+	purchaseOrders repositories.PurchaseOrderRepository
+	suggestions    repositories.ReorderSuggestionRepository
+	// settle is how long queued items wait, so the transactions that queued them have committed before they are read
+	settle time.Duration
+
+	mu      sync.Mutex
+	pending map[reorderKey]struct{}
+	signal  chan struct{}
+}
+
+func NewReorder(repos *repositories.Repositories, settle time.Duration) *Reorder {
+	return &Reorder{
+		items:          repos.Items,
+		stock:          repos.Stock,
+		purchaseOrders: repos.PurchaseOrders,
+		suggestions:    repos.ReorderSuggestions,
+		settle:         settle,
+		pending:        map[reorderKey]struct{}{},
+		signal:         make(chan struct{}, 1),
+	}
+}
+
+// Watch makes the stock, item and purchase order repositories of the bundle queue the items they change
+func (r *Reorder) Watch(repos *repositories.Repositories) {
+	repos.Stock = &reorderStock{StockRepository: repos.Stock, reorder: r}
+	repos.Items = &reorderItems{ItemRepository: repos.Items, reorder: r}
+	repos.PurchaseOrders = &reorderPurchaseOrders{PurchaseOrderRepository: repos.PurchaseOrders, reorder: r}
+}
+
+// Enqueue queues the item for evaluation. An item queued again before it was evaluated is evaluated once.
+func (r *Reorder) Enqueue(organisationID primitive.ObjectID, itemID primitive.ObjectID) {
+	r.mu.Lock()
+	r.pending[reorderKey{organisationID: organisationID, itemID: itemID}] = struct{}{}
+	r.mu.Unlock()
+
+	select {
+	case r.signal <- struct{}{}:
+	default:
+	}
+}
+
+// Run evaluates queued items until the context is done
+func (r *Reorder) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.signal:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.settle):
+		}
+
+		r.mu.Lock()
+		pending := r.pending
+		r.pending = map[reorderKey]struct{}{}
+		r.mu.Unlock()
+
+		for key := range pending {
+			evaluateCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			if err := r.Evaluate(evaluateCtx, key.organisationID, key.itemID); err != nil {
+				log.Printf("reorder: evaluating item %s: %v", key.itemID.Hex(), err)
+			}
+			cancel()
+		}
+	}
+}
+
+// Evaluate saves the reorder suggestion of the item if its stock on hand and on order is at or below its reorder
+// point, and removes it otherwise
+func (r *Reorder) Evaluate(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) error {
+	item, err := r.items.FindByID(ctx, organisationID, itemID)
+	if err == repositories.ErrNotFound {
+		return r.suggestions.Delete(ctx, organisationID, itemID)
+	}
+	if err != nil {
+		return err
+	}
+	if !item.TrackStock || item.ReorderQuantity <= 0 {
+		return r.suggestions.Delete(ctx, organisationID, itemID)
+	}
+
+	balances, err := r.stock.FindBalances(ctx, organisationID, models.StockBalanceFilter{ItemID: &item.ID})
+	if err != nil {
+		return err
+	}
+	onHand := 0.0
+	for _, balance := range balances {
+		onHand += balance.OnHand
+	}
+	onHand = models.RoundQuantity(onHand)
+	onOrder, err := r.purchaseOrders.OnOrder(ctx, organisationID, item.ID)
+	if err != nil {
+		return err
+	}
+	available := models.RoundQuantity(onHand + onOrder)
+	if available > item.ReorderPoint {
+		return r.suggestions.Delete(ctx, organisationID, itemID)
+	}
+
+	//The last receipt with a cost is the best guess of what the next order costs
+	unitCost, err := r.stock.LastUnitCost(ctx, organisationID, item.ID)
+	if err != nil {
+		return err
+	}
+
+	return r.suggestions.Save(ctx, &models.ReorderSuggestion{
+		ItemID:         item.ID,
+		ItemName:       item.Name,
+		SupplierID:     item.PreferredSupplierID,
+		OnHand:         onHand,
+		OnOrder:        onOrder,
+		ReorderPoint:   item.ReorderPoint,
+		Quantity:       models.ReorderQuantity(available, item.ReorderPoint, item.ReorderQuantity),
+		UnitCost:       unitCost,
+		UpdatedAt:      time.Now(),
+		OrganisationID: organisationID,
+	})
+}
+
+// reorderStock queues the items of recorded movements
+type reorderStock struct {
+	repositories.StockRepository
+	reorder *Reorder
+}
+
+func (s *reorderStock) Record(ctx context.Context, organisationID primitive.ObjectID, movements []*models.StockMovement, allowNegative bool, also func(ctx context.Context) error) error {
+	if err := s.StockRepository.Record(ctx, organisationID, movements, allowNegative, also); err != nil {
+		return err
+	}
+	for _, movement := range movements {
+		s.reorder.Enqueue(organisationID, movement.ItemID)
+	}
+	return nil
+}
+
+// reorderItems queues items whose reorder settings may have changed
+type reorderItems struct {
+	repositories.ItemRepository
+	reorder *Reorder
+}
+
+func (i *reorderItems) Create(ctx context.Context, item *models.Item) error {
+	if err := i.ItemRepository.Create(ctx, item); err != nil {
+		return err
+	}
+	i.reorder.Enqueue(item.OrganisationID, item.ID)
+	return nil
+}
+
+func (i *reorderItems) Update(ctx context.Context, item *models.Item, version int) error {
+	if err := i.ItemRepository.Update(ctx, item, version); err != nil {
+		return err
+	}
+	i.reorder.Enqueue(item.OrganisationID, item.ID)
+	return nil
+}
+
+func (i *reorderItems) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if err := i.ItemRepository.Delete(ctx, organisationID, id); err != nil {
+		return err
+	}
+	i.reorder.Enqueue(organisationID, id)
+	return nil
+}
+
+// reorderPurchaseOrders queues the items of new and changed orders, whose quantities on order may have changed
+type reorderPurchaseOrders struct {
+	repositories.PurchaseOrderRepository
+	reorder *Reorder
+}
+
+func (p *reorderPurchaseOrders) Create(ctx context.Context, order *models.PurchaseOrder) error {
+	if err := p.PurchaseOrderRepository.Create(ctx, order); err != nil {
+		return err
+	}
+	for _, line := range order.Lines {
+		p.reorder.Enqueue(order.OrganisationID, line.ItemID)
+	}
+	return nil
+}
+
+func (p *reorderPurchaseOrders) Update(ctx context.Context, order *models.PurchaseOrder, version int) error {
+	if err := p.PurchaseOrderRepository.Update(ctx, order, version); err != nil {
+		return err
+	}
+	for _, line := range order.Lines {
+		p.reorder.Enqueue(order.OrganisationID, line.ItemID)
+	}
+	return nil
+}
@@ -0,0 +1,345 @@
+// Package listing pages and sorts list endpoints. Pages are cut with keyset cursors: a cursor holds the sort value and
+// ID of the last document of a page, so the next page starts right after it however many documents are added or
+// removed in between, and no page needs to skip over the ones before it.
+package listing
+
+import (
+	"encoding/base64"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+var (
+	ErrLimit  = errors.New("limit must be a number from 1 to " + strconv.Itoa(MaxLimit))
+	ErrSort   = errors.New("the list cannot be sorted by this field")
+	ErrCursor = errors.New("cursor is invalid or belongs to another sort order")
+)
+
+// Query is the page of a list a client asks for. A zero Limit lists every document, which is how handlers that need
+// the whole list call repositories.
+type Query struct {
+	Limit      int
+	Sort       string
+	Descending bool
+	After      *Cursor
+}
+
+// Cursor marks the last document of the previous page
+type Cursor struct {
+	Sort       string             `bson:"s"`
+	Descending bool               `bson:"d"`
+	Value      interface{}        `bson:"v"`
+	ID         primitive.ObjectID `bson:"i"`
+}
+
+// Page describes the page a list response holds
+type Page struct {
+	Limit int    `json:"limit" example:"50"`
+	Sort  string `json:"sort" example:"-updated_at"`
+	// NextCursor is passed as cursor to get the next page, it is empty on the last page
+	NextCursor string `json:"next_cursor,omitempty" example:"Gg..."`
+	HasMore    bool   `json:"has_more" example:"true"`
+}
+
+// Field is a field a list can be sorted by
+type Field[T any] struct {
+	// Key is the key of the field in the stored document
+	Key string
+	// Value returns the field of a document as it is stored, times as primitive.DateTime
+	Value func(document *T) interface{}
+}
+
+// Accepts reports whether the value is of the type the field has, numbers of any size counting as one type. Documents,
+// arrays and anything else a field is never sorted by are refused.
+func (field Field[T]) Accepts(value interface{}) bool {
+	kind := valueKind(value)
+	return kind != "" && kind == valueKind(field.Value(new(T)))
+}
+
+// Fields are the fields a list can be sorted by, keyed by the name clients use
+type Fields[T any] map[string]Field[T]
+
+// Names returns the names of the fields in alphabetical order
+func (fields Fields[T]) Names() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse reads the limit, sort and cursor parameters. sort is the name of one of the fields, prefixed with "-" to sort
+// descending.
+func Parse[T any](values url.Values, fields Fields[T], defaultSort string) (Query, error) {
+	query := Query{Limit: DefaultLimit}
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+		if err != nil || value < 1 || value > MaxLimit {
+			return Query{}, ErrLimit
+		}
+		query.Limit = value
+	}
+
+	sortParam := values.Get("sort")
+	if sortParam == "" {
+		sortParam = defaultSort
+	}
+	query.Descending = strings.HasPrefix(sortParam, "-")
+	query.Sort = strings.TrimPrefix(sortParam, "-")
+	field, ok := fields[query.Sort]
+	if !ok {
+		return Query{}, ErrSort
+	}
+
+	if cursor := values.Get("cursor"); cursor != "" {
+		after, err := DecodeCursor(cursor)
+		if err != nil || after.Sort != query.Sort || after.Descending != query.Descending {
+			return Query{}, ErrCursor
+		}
+		//The cursor comes from the client and its value goes into the filter, so it must be a plain value of the
+		//field's type rather than a document of query operators
+		if !field.Accepts(after.Value) {
+			return Query{}, ErrCursor
+		}
+		query.After = after
+	}
+	return query, nil
+}
+
+// Time reads an RFC 3339 time parameter, it is nil when the parameter is missing
+func Time(values url.Values, name string) (*time.Time, error) {
+	value := values.Get(name)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, errors.New(name + " must be an RFC 3339 time")
+	}
+	return &parsed, nil
+}
+
+// Encode returns the cursor as an opaque URL safe string
+func (cursor Cursor) Encode() string {
+	data, err := bson.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(value string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrCursor
+	}
+	var cursor Cursor
+	if err := bson.Unmarshal(data, &cursor); err != nil {
+		return nil, ErrCursor
+	}
+	return &cursor, nil
+}
+
+// OrSort returns the query sorted by the sort parameter if it has no sort yet
+func (query Query) OrSort(sort string) Query {
+	if query.Sort == "" {
+		query.Descending = strings.HasPrefix(sort, "-")
+		query.Sort = strings.TrimPrefix(sort, "-")
+	}
+	return query
+}
+
+// SortName returns the sort parameter the query was parsed from
+func (query Query) SortName() string {
+	if query.Descending {
+		return "-" + query.Sort
+	}
+	return query.Sort
+}
+
+// Mongo adds the condition that starts after the cursor to the filter and returns the find options that sort and
+// limit the page. One document more than the limit is read so Cut can tell whether another page follows.
+func Mongo[T any](filter bson.M, query Query, fields Fields[T]) (bson.M, *options.FindOptions, error) {
+	opts := options.Find()
+	if query.Sort == "" {
+		return filter, opts, nil
+	}
+	field, ok := fields[query.Sort]
+	if !ok {
+		return nil, nil, ErrSort
+	}
+
+	direction, after := 1, "$gt"
+	if query.Descending {
+		direction, after = -1, "$lt"
+	}
+	opts.SetSort(bson.D{{Key: field.Key, Value: direction}, {Key: "_id", Value: direction}})
+	if query.Limit > 0 {
+		opts.SetLimit(int64(query.Limit + 1))
+	}
+
+	if query.After != nil {
+		condition := bson.M{"$or": bson.A{
+			bson.M{field.Key: bson.M{after: query.After.Value}},
+			bson.M{field.Key: query.After.Value, "_id": bson.M{after: query.After.ID}},
+		}}
+		if len(filter) == 0 {
+			filter = condition
+		} else {
+			filter = bson.M{"$and": bson.A{filter, condition}}
+		}
+	}
+	return filter, opts, nil
+}
+
+// Memory sorts the documents and keeps those after the cursor, up to one more than the limit, the way Mongo does
+func Memory[T any](documents []*T, query Query, fields Fields[T], id func(document *T) primitive.ObjectID) ([]*T, error) {
+	if query.Sort == "" {
+		return documents, nil
+	}
+	field, ok := fields[query.Sort]
+	if !ok {
+		return nil, ErrSort
+	}
+
+	less := func(a *T, b *T) bool {
+		order := Compare(field.Value(a), field.Value(b))
+		if order == 0 {
+			order = strings.Compare(id(a).Hex(), id(b).Hex())
+		}
+		if query.Descending {
+			return order > 0
+		}
+		return order < 0
+	}
+	sort.SliceStable(documents, func(i, j int) bool { return less(documents[i], documents[j]) })
+
+	page := []*T{}
+	for _, document := range documents {
+		if query.After != nil {
+			order := Compare(field.Value(document), query.After.Value)
+			if order == 0 {
+				order = strings.Compare(id(document).Hex(), query.After.ID.Hex())
+			}
+			if (query.Descending && order >= 0) || (!query.Descending && order <= 0) {
+				continue
+			}
+		}
+		page = append(page, document)
+		if query.Limit > 0 && len(page) > query.Limit {
+			break
+		}
+	}
+	return page, nil
+}
+
+// Cut drops the extra document read past the limit and describes the page, with a cursor after its last document
+// when another page follows
+func Cut[T any](documents []*T, query Query, fields Fields[T], id func(document *T) primitive.ObjectID) ([]*T, Page) {
+	page := Page{Limit: query.Limit, Sort: query.SortName()}
+	if query.Limit == 0 || len(documents) <= query.Limit {
+		return documents, page
+	}
+
+	documents = documents[:query.Limit]
+	last := documents[len(documents)-1]
+	page.HasMore = true
+	page.NextCursor = Cursor{
+		Sort:       query.Sort,
+		Descending: query.Descending,
+		Value:      fields[query.Sort].Value(last),
+		ID:         id(last),
+	}.Encode()
+	return documents, page
+}
+
+// Compare orders two sort values of the same field the way Mongo orders them, values read back from a cursor may
+// have been widened, so numbers of any size compare with each other
+func Compare(a interface{}, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	switch x := a.(type) {
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case primitive.DateTime:
+		if y, ok := b.(primitive.DateTime); ok {
+			return Compare(int64(x), int64(y))
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			return Compare(boolNumber(x), boolNumber(y))
+		}
+	}
+	return 0
+}
+
+// valueKind names the kind of a sort value, it is empty for values no field is sorted by
+func valueKind(value interface{}) string {
+	if _, ok := number(value); ok {
+		return "number"
+	}
+	switch value.(type) {
+	case string:
+		return "string"
+	case primitive.DateTime:
+		return "date"
+	case bool:
+		return "bool"
+	}
+	return ""
+}
+
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func boolNumber(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
+	"log"
 	"poosible-backend/config"
+	"poosible-backend/gateway"
+	"poosible-backend/jobs"
+	"poosible-backend/migrations"
+	"poosible-backend/repositories"
 	"poosible-backend/router"
 
 	"time"
@@ -15,12 +21,42 @@ import (
 // @in header
 // @name Authorization
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	r := gin.New()
+	//Client addresses, such as the one recorded with estimate signatures, are only taken from headers set by these
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalln(err)
+	}
 	gob.Register(time.Time{})
-	authStore := cookie.NewStore([]byte("auth-secret"))
+	authStore := cookie.NewStore([]byte(cfg.Auth.SessionSecret))
 	r.Use(sessions.Sessions("auth-session", authStore))
-	config.ConnectDatabase()
+	database := config.ConnectDatabase(cfg.Database)
+	if err := migrations.Run(context.Background(), database); err != nil {
+		log.Fatalln(err)
+	}
+	if err := migrations.EnsureIndexes(context.Background(), database); err != nil {
+		log.Fatalln(err)
+	}
+	repos := repositories.NewMongoRepositories(database)
+	reorder := jobs.NewReorder(repos, 2*time.Second)
+	reorder.Watch(repos)
+	go reorder.Run(context.Background())
+	imports := jobs.NewImports(repos, 100)
+	if failed, err := imports.FailUnfinished(context.Background()); err != nil {
+		log.Fatalln(err)
+	} else if failed > 0 {
+		log.Println("Failed", failed, "imports left unfinished by the previous server")
+	}
+	go imports.Run(context.Background())
+	cards, err := gateway.New(cfg.Payments.Gateway)
+	if err != nil {
+		log.Fatalln(err)
+	}
 	config.SetupSwagger()
-	router.SetupRouter(r)
-	r.Run("0.0.0.0:9090")
+	router.SetupRouter(r, cfg, repos, imports, cards)
+	log.Fatalln(r.Run(cfg.Server.Address))
 }
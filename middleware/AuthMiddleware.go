@@ -4,10 +4,11 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"poosible-backend/config"
 	"strings"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+func AuthMiddleware(cfg config.AuthConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authSession := sessions.Default(c)
 
@@ -33,7 +34,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 				return nil, nil
 			}
-			return []byte("secret_key"), nil
+			return []byte(cfg.JWTSecret), nil
 		})
 
 		if err != nil {
@@ -46,6 +47,19 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"message": "Invalid Token"})
+			return
+		}
+
+		userID, ok := claims["user_id"].(string)
+		if !ok {
+			c.AbortWithStatusJSON(401, gin.H{"message": "Invalid Token"})
+			return
+		}
+		c.Set("user_id", userID)
+
 		c.Next()
 	}
 }
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/tenant"
+)
+
+var ErrUnknownRole = errors.New("unknown role")
+
+// RequirePermission aborts the request unless the role of the current user grants every given permission
+func RequirePermission(permissions ...models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted := Permissions(c)
+		for _, permission := range permissions {
+			if !models.HasPermission(granted, permission) {
+				c.AbortWithStatusJSON(403, gin.H{"message": "Missing permission " + string(permission)})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission lets users act on their own record identified by the route parameter,
+// acting on anybody else requires the permission
+func RequireSelfOrPermission(param string, permission models.Permission) gin.HandlerFunc {
+	requirePermission := RequirePermission(permission)
+	return func(c *gin.Context) {
+		if c.Param(param) != "" && c.Param(param) == c.GetString("user_id") {
+			c.Next()
+			return
+		}
+		requirePermission(c)
+	}
+}
+
+// Permissions returns the permissions resolved for the current user by TenantMiddleware
+func Permissions(c *gin.Context) []models.Permission {
+	t := tenant.Get(c)
+	if t == nil {
+		return nil
+	}
+	return t.Permissions
+}
+
+// UserPermissions resolves the permissions the role of the user grants within their organisation
+func UserPermissions(ctx context.Context, roles repositories.RoleRepository, user *models.User) ([]models.Permission, error) {
+	if user.OrganisationID == nil {
+		return nil, nil
+	}
+
+	role := user.Role
+	// Users created their organisation before roles existed, so they own it
+	if role == "" {
+		role = models.RoleOwner
+	}
+
+	return RolePermissions(ctx, roles, *user.OrganisationID, role)
+}
+
+// RolePermissions returns the permissions of a built-in role or of a custom role of the organisation
+func RolePermissions(ctx context.Context, roles repositories.RoleRepository, organisationID primitive.ObjectID, role string) ([]models.Permission, error) {
+	if permissions, ok := models.BuiltinRoles[role]; ok {
+		return permissions, nil
+	}
+
+	roleID, err := primitive.ObjectIDFromHex(role)
+	if err != nil {
+		return nil, ErrUnknownRole
+	}
+
+	customRole, err := roles.FindByID(ctx, organisationID, roleID)
+	if err != nil {
+		if err == repositories.ErrNotFound {
+			return nil, ErrUnknownRole
+		}
+		return nil, err
+	}
+
+	return customRole.Permissions, nil
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/repositories"
+	"poosible-backend/tenant"
+	"time"
+)
+
+// TenantMiddleware resolves the user of the token, their organisation and their permissions once per request.
+// It has to run after AuthMiddleware.
+func TenantMiddleware(users repositories.UserRepository, roles repositories.RoleRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		userID, err := primitive.ObjectIDFromHex(c.GetString("user_id"))
+		if err != nil {
+			c.AbortWithStatusJSON(401, gin.H{"message": "Invalid Token"})
+			return
+		}
+
+		user, err := users.FindByID(ctx, userID)
+		if err != nil {
+			if err == repositories.ErrNotFound {
+				c.AbortWithStatusJSON(401, gin.H{"message": "User not found"})
+				return
+			}
+			c.AbortWithStatusJSON(500, gin.H{"message": "Error getting user"})
+			return
+		}
+
+		permissions, err := UserPermissions(ctx, roles, user)
+		if err != nil && err != ErrUnknownRole {
+			c.AbortWithStatusJSON(500, gin.H{"message": "Error resolving permissions"})
+			return
+		}
+
+		tenant.Set(c, &tenant.Tenant{
+			User:           user,
+			OrganisationID: user.OrganisationID,
+			Permissions:    permissions,
+		})
+
+		c.Next()
+	}
+}
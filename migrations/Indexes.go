@@ -0,0 +1,122 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/repositories"
+)
+
+// Index is an index a collection needs, either to keep its documents consistent or to answer the queries of the API
+type Index struct {
+	Collection string
+	Model      mongo.IndexModel
+}
+
+// indexes lists every index the API relies on, new indexes are appended at the end
+var indexes = []Index{
+	//Sequence counters are upserted by name, so a second counter of the same name would hand out its numbers again
+	{Collection: "sequences", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "name", Value: 1}},
+		Options: options.Index().SetName("organisation_name").SetUnique(true),
+	}},
+	{Collection: "invoices", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "type", Value: 1}, {Key: "number", Value: 1}},
+		Options: options.Index().SetName("organisation_type_number").SetUnique(true),
+	}},
+	//Balances are upserted per item and location, concurrent first movements would otherwise split one balance in two
+	{Collection: "stock_balances", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "item_id", Value: 1}, {Key: "location_id", Value: 1}},
+		Options: options.Index().SetName("organisation_item_location").SetUnique(true),
+	}},
+	//Item codes are checked before items are written, the indexes stop two items written at once taking the same code.
+	//Items without a code leave it empty, which the partial filters leave out of the indexes.
+	{Collection: "items", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "sku", Value: 1}},
+		Options: options.Index().SetName("organisation_sku").SetUnique(true).SetPartialFilterExpression(bson.M{"sku": bson.M{"$gt": ""}}),
+	}},
+	{Collection: "items", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "barcodes", Value: 1}},
+		Options: options.Index().SetName("organisation_barcodes").SetUnique(true).SetPartialFilterExpression(bson.M{"barcodes": bson.M{"$gt": ""}}),
+	}},
+	{Collection: "items", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "manufacturer", Value: 1}, {Key: "mpn", Value: 1}},
+		Options: options.Index().SetName("organisation_manufacturer_mpn").SetUnique(true).SetPartialFilterExpression(bson.M{"mpn": bson.M{"$gt": ""}}),
+	}},
+	//Created by migration 0004 as well, listed for databases that applied it before it did
+	itemSearchTerms,
+	//Public links look estimates up by the hash of their token alone, so no two estimates may share one. Estimates
+	//that were never shared have no hash and stay out of the index.
+	{Collection: "estimates", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "share_token_hash", Value: 1}},
+		Options: options.Index().SetName("share_token_hash").SetUnique(true).SetPartialFilterExpression(bson.M{"share_token_hash": bson.M{"$gt": ""}}),
+	}},
+	//Every payment and refund reads the earlier payments of its invoice in order, and spending store credit reads the
+	//payments of the customer, both inside the transaction that records it
+	{Collection: "payments", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "invoice_id", Value: 1}, {Key: "received_at", Value: 1}},
+		Options: options.Index().SetName("organisation_invoice_received_at"),
+	}},
+	{Collection: "payments", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "customer_id", Value: 1}},
+		Options: options.Index().SetName("organisation_customer"),
+	}},
+	//Stock without a location is booked to the default one, of which an organisation has exactly one
+	{Collection: "stock_locations", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "organisation_id", Value: 1}},
+		Options: options.Index().SetName("organisation_default").SetUnique(true).SetPartialFilterExpression(bson.M{"default": true}),
+	}},
+	//Refreshing looks tokens up by the hash of the token alone, so no two tokens may share one
+	{Collection: "refresh_tokens", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "token_hash", Value: 1}},
+		Options: options.Index().SetName("token_hash").SetUnique(true),
+	}},
+	//Expired tokens cannot be refreshed anymore, MongoDB removes them once they expire
+	{Collection: "refresh_tokens", Model: mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetName("expires_at").SetExpireAfterSeconds(0),
+	}},
+}
+
+// sortIndexes returns an index for every field a list can be sorted by, on the organisation, the field and the ID the
+// pages are cut by. Descending pages read the same indexes backwards.
+func sortIndexes() []Index {
+	sorted := []Index{}
+	sorted = append(sorted, sortedBy("items", repositories.ItemSortFields)...)
+	sorted = append(sorted, sortedBy("customers", repositories.CustomerSortFields)...)
+	sorted = append(sorted, sortedBy("vehicles", repositories.VehicleSortFields)...)
+	sorted = append(sorted, sortedBy("work_orders", repositories.WorkOrderSortFields)...)
+	sorted = append(sorted, sortedBy("estimates", repositories.EstimateSortFields)...)
+	sorted = append(sorted, sortedBy("invoices", repositories.InvoiceSortFields)...)
+	sorted = append(sorted, sortedBy("payments", repositories.PaymentSortFields)...)
+	sorted = append(sorted, sortedBy("cash_sessions", repositories.CashSessionSortFields)...)
+	sorted = append(sorted, sortedBy("stock_movements", repositories.StockMovementSortFields)...)
+	sorted = append(sorted, sortedBy("purchase_orders", repositories.PurchaseOrderSortFields)...)
+	return sorted
+}
+
+func sortedBy[T any](collection string, fields listing.Fields[T]) []Index {
+	sorted := []Index{}
+	for _, name := range fields.Names() {
+		key := fields[name].Key
+		sorted = append(sorted, Index{Collection: collection, Model: mongo.IndexModel{
+			Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: key, Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("sort_" + key),
+		}})
+	}
+	return sorted
+}
+
+// EnsureIndexes creates the indexes that do not exist yet. Creating an index that exists with the same keys and
+// options does nothing, so it runs on every start after the migrations brought the documents into shape.
+func EnsureIndexes(ctx context.Context, database *mongo.Database) error {
+	for _, index := range append(indexes, sortIndexes()...) {
+		if _, err := database.Collection(index.Collection).Indexes().CreateOne(ctx, index.Model); err != nil {
+			return fmt.Errorf("index %s of %s: %w", *index.Model.Options.Name, index.Collection, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Package migrations brings documents written by older versions of the API up to date when the server starts
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"log"
+	"time"
+)
+
+// Migration changes stored documents once. Up must be safe to run again after it failed halfway, so it only selects
+// documents that still have the old shape.
+type Migration struct {
+	ID          string
+	Description string
+	Up          func(ctx context.Context, database *mongo.Database) error
+}
+
+// all lists the migrations in the order they are applied, new migrations are appended at the end. IDs are never
+// reused, databases may have recorded them already.
+var all = []Migration{
+	{ID: "0001_item_price_minor_units", Description: "Convert item prices from decimal strings to minor units", Up: migrateItemPrices},
+	{ID: "0004_item_search_terms", Description: "Store the words items are searched by", Up: migrateItemSearchTerms},
+	{ID: "0005_single_default_stock_location", Description: "Leave one default stock location per organisation", Up: migrateDefaultStockLocations},
+}
+
+// Run applies every migration that was not applied to the database yet and records it in the migrations collection
+func Run(ctx context.Context, database *mongo.Database) error {
+	applied := database.Collection("migrations")
+	for _, migration := range all {
+		count, err := applied.CountDocuments(ctx, bson.M{"_id": migration.ID})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		log.Println("Applying migration", migration.ID)
+		if err := migration.Up(ctx, database); err != nil {
+			return fmt.Errorf("migration %s: %w", migration.ID, err)
+		}
+		_, err = applied.InsertOne(ctx, bson.M{"_id": migration.ID, "description": migration.Description, "applied_at": time.Now()})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return err
+		}
+	}
+	return nil
+}
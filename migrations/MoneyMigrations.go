@@ -0,0 +1,105 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"math/big"
+	"poosible-backend/money"
+	"strings"
+)
+
+// legacyPrice is how prices were stored before they became money.Money, e.g. {"amount": "123", "currency": "EUR"}
+type legacyPrice struct {
+	Amount   string `bson:"amount"`
+	Currency string `bson:"currency"`
+}
+
+// organisationCurrencies looks up the currency of organisations for prices that were stored without one
+type organisationCurrencies struct {
+	organisations *mongo.Collection
+	cache         map[primitive.ObjectID]string
+}
+
+func newOrganisationCurrencies(database *mongo.Database) *organisationCurrencies {
+	return &organisationCurrencies{organisations: database.Collection("organisations"), cache: map[primitive.ObjectID]string{}}
+}
+
+func (currencies *organisationCurrencies) get(ctx context.Context, organisationID primitive.ObjectID) (string, error) {
+	if currency, ok := currencies.cache[organisationID]; ok {
+		return currency, nil
+	}
+
+	var organisation struct {
+		Currency int `bson:"currency_code"`
+	}
+	err := currencies.organisations.FindOne(ctx, bson.M{"_id": organisationID}).Decode(&organisation)
+	if err != nil {
+		return "", fmt.Errorf("organisation %s: %w", organisationID.Hex(), err)
+	}
+	currency, err := money.CurrencyAlpha(organisation.Currency)
+	if err != nil {
+		return "", fmt.Errorf("organisation %s: %w", organisationID.Hex(), err)
+	}
+	currencies.cache[organisationID] = currency
+	return currency, nil
+}
+
+// convert turns a free-form amount such as "12,5" into money, rounding half to even where it had more decimals than
+// the currency has minor units
+func (price legacyPrice) convert(ctx context.Context, currencies *organisationCurrencies, organisationID primitive.ObjectID) (money.Money, error) {
+	currency := price.Currency
+	if currency == "" {
+		var err error
+		if currency, err = currencies.get(ctx, organisationID); err != nil {
+			return money.Money{}, err
+		}
+	}
+
+	amount := strings.TrimSpace(price.Amount)
+	if amount == "" {
+		return money.Zero(currency), nil
+	}
+	if !strings.Contains(amount, ".") {
+		amount = strings.Replace(amount, ",", ".", 1)
+	}
+	value, ok := new(big.Rat).SetString(amount)
+	if !ok {
+		return money.Money{}, fmt.Errorf("price %q: %w", price.Amount, money.ErrInvalidAmount)
+	}
+	return money.FromRat(value, currency)
+}
+
+func migrateItemPrices(ctx context.Context, database *mongo.Database) error {
+	items := database.Collection("items")
+	currencies := newOrganisationCurrencies(database)
+
+	cursor, err := items.Find(ctx, bson.M{"price.amount": bson.M{"$type": "string"}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item struct {
+			ID             primitive.ObjectID `bson:"_id"`
+			OrganisationID primitive.ObjectID `bson:"organisation_id"`
+			Price          legacyPrice        `bson:"price"`
+		}
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+
+		price, err := item.Price.convert(ctx, currencies, item.OrganisationID)
+		if err != nil {
+			return fmt.Errorf("item %s: %w", item.ID.Hex(), err)
+		}
+		_, err = items.UpdateOne(ctx, bson.M{"_id": item.ID}, bson.M{"$set": bson.M{"price": price}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/models"
+)
+
+// itemSearchTerms indexes the words items are searched by. Searches look items up by the leading characters of the
+// words, which the index answers from its bounds.
+var itemSearchTerms = Index{Collection: "items", Model: mongo.IndexModel{
+	Keys:    bson.D{{Key: "organisation_id", Value: 1}, {Key: "search_terms", Value: 1}},
+	Options: options.Index().SetName("organisation_search_terms"),
+}}
+
+// migrateItemSearchTerms stores the search terms of items that were created before items could be searched and
+// indexes them
+func migrateItemSearchTerms(ctx context.Context, database *mongo.Database) error {
+	items := database.Collection("items")
+	if _, err := items.Indexes().CreateOne(ctx, itemSearchTerms.Model); err != nil {
+		return err
+	}
+
+	cursor, err := items.Find(ctx, bson.M{"search_terms": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item models.Item
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+
+		item.Index()
+		_, err = items.UpdateOne(ctx, bson.M{"_id": item.ID}, bson.M{"$set": bson.M{"search_terms": item.SearchTerms}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// migrateDefaultStockLocations leaves one default location per organisation, which the default flag was cleared from
+// the others after a location was made the default before. The location made the default last keeps it.
+func migrateDefaultStockLocations(ctx context.Context, database *mongo.Database) error {
+	locations := database.Collection("stock_locations")
+	cursor, err := locations.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"default": true}}},
+		{{Key: "$sort", Value: bson.D{{Key: "updated_at", Value: -1}, {Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$organisation_id", "ids": bson.M{"$push": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var defaults struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&defaults); err != nil {
+			return err
+		}
+		_, err = locations.UpdateMany(ctx, bson.M{"_id": bson.M{"$in": defaults.IDs[1:]}}, bson.M{"$set": bson.M{"default": false}})
+		if err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
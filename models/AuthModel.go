@@ -4,3 +4,7 @@ type Credentials struct {
 	Email    string `json:"email" bson:"email" validate:"required" example:"emirkovacevic@protonmail.com"`
 	Password string `json:"password" bson:"password" validate:"required" example:"password123As!"`
 }
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" bson:"refresh_token" validate:"required" example:"4f1c2b8e-93a4-4c0e-8d8e-0f5a3d6f2b71"`
+}
@@ -0,0 +1,212 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/money"
+	"poosible-backend/tax"
+	"time"
+)
+
+const (
+	CashSessionOpen   = "open"
+	CashSessionClosed = "closed"
+)
+
+type CashMovementType string
+
+const (
+	CashPayIn  CashMovementType = "pay_in"
+	CashPayOut CashMovementType = "pay_out"
+)
+
+const (
+	// CashReportX is read mid-shift and changes with the session
+	CashReportX = "x"
+	// CashReportZ is taken when the session closes and never changes
+	CashReportZ = "z"
+)
+
+// DefaultZReportNumbering numbers the Z-reports of an organisation in one sequence across its registers
+var DefaultZReportNumbering = NumberingSettings{Prefix: "Z-", Digits: 6}
+
+type CashSessionNew struct {
+	// Register names the till the session counts the drawer of
+	Register string `json:"register" bson:"register" validate:"required,max=64" example:"Front desk"`
+	// OpeningFloat is the cash counted into the drawer when it is opened
+	OpeningFloat *money.Money `json:"opening_float" bson:"opening_float" validate:"required" swaggertype:"object"`
+	Notes        string       `json:"notes" bson:"notes" example:"Float from the safe"`
+}
+
+type CashMovementNew struct {
+	Type   CashMovementType `json:"type" bson:"type" validate:"required,oneof=pay_in pay_out" example:"pay_out"`
+	Amount *money.Money     `json:"amount" bson:"amount" validate:"required" swaggertype:"object"`
+	Reason string           `json:"reason" bson:"reason" validate:"required,max=200" example:"Parcel postage"`
+}
+
+type CashSessionClose struct {
+	// CountedCash is the cash counted in the drawer without being shown what it should hold
+	CountedCash *money.Money `json:"counted_cash" bson:"counted_cash" validate:"required" swaggertype:"object"`
+	Notes       string       `json:"notes" bson:"notes" example:"Two coins found under the tray"`
+}
+
+// CashMovement is cash put into or taken out of the drawer for anything but a payment
+type CashMovement struct {
+	Type      CashMovementType   `json:"type" bson:"type"`
+	Amount    money.Money        `json:"amount" bson:"amount"`
+	Reason    string             `json:"reason" bson:"reason"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// CashSession is a shift of one user at one register, from counting the float into the drawer to counting the drawer
+// out. A user and a register have at most one open session each.
+type CashSession struct {
+	ID           primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	Register     string              `json:"register" bson:"register"`
+	UserID       primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	Status       string              `json:"status" bson:"status"`
+	Currency     string              `json:"currency" bson:"currency"`
+	OpeningFloat money.Money         `json:"opening_float" bson:"opening_float"`
+	Movements    []CashMovement      `json:"movements" bson:"movements"`
+	Notes        string              `json:"notes,omitempty" bson:"notes,omitempty"`
+	OpenedAt     time.Time           `json:"opened_at" bson:"opened_at"`
+	ClosedAt     *time.Time          `json:"closed_at,omitempty" bson:"closed_at,omitempty"`
+	ClosedBy     *primitive.ObjectID `json:"closed_by,omitempty" bson:"closed_by,omitempty"`
+	// ZReport is taken when the session closes
+	ZReport        *CashReport        `json:"z_report,omitempty" bson:"z_report,omitempty"`
+	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+}
+
+// CashSessionFilter narrows a session listing, empty fields match every session
+type CashSessionFilter struct {
+	UserID   *primitive.ObjectID
+	Register string
+	Status   string
+}
+
+// TenderTotal sums up the tenders of one method, refunds count against it
+type TenderTotal struct {
+	Method PaymentMethod `json:"method" bson:"method"`
+	Count  int           `json:"count" bson:"count"`
+	Amount money.Money   `json:"amount" bson:"amount"`
+}
+
+// CashReport sums up a session. The expected cash is the float plus the cash tenders and pay-ins less the pay-outs,
+// the variance is what the count differs from it by.
+type CashReport struct {
+	Type string `json:"type" bson:"type"`
+	// Number counts the Z-reports of the organisation without gaps
+	Number       string             `json:"number,omitempty" bson:"number,omitempty"`
+	SessionID    primitive.ObjectID `json:"session_id" bson:"session_id"`
+	Register     string             `json:"register" bson:"register"`
+	UserID       primitive.ObjectID `json:"user_id" bson:"user_id"`
+	OpenedAt     time.Time          `json:"opened_at" bson:"opened_at"`
+	TakenAt      time.Time          `json:"taken_at" bson:"taken_at"`
+	OpeningFloat money.Money        `json:"opening_float" bson:"opening_float"`
+	Cash         money.Money        `json:"cash" bson:"cash"`
+	PayIns       money.Money        `json:"pay_ins" bson:"pay_ins"`
+	PayOuts      money.Money        `json:"pay_outs" bson:"pay_outs"`
+	ExpectedCash money.Money        `json:"expected_cash" bson:"expected_cash"`
+	CountedCash  *money.Money       `json:"counted_cash,omitempty" bson:"counted_cash,omitempty"`
+	Variance     *money.Money       `json:"variance,omitempty" bson:"variance,omitempty"`
+	Payments     int                `json:"payments" bson:"payments"`
+	Tenders      []TenderTotal      `json:"tenders" bson:"tenders"`
+	// TaxRates sums up the invoices and credit notes the user issued during the session by tax rate
+	TaxRates []tax.RateTotal `json:"tax_rates" bson:"tax_rates"`
+	Invoices int             `json:"invoices" bson:"invoices"`
+}
+
+// Report sums up the payments taken in the session and the invoices issued by its user while it was open
+func (session *CashSession) Report(reportType string, payments []*Payment, invoices []*Invoice, at time.Time) (*CashReport, error) {
+	report := &CashReport{
+		Type:         reportType,
+		SessionID:    session.ID,
+		Register:     session.Register,
+		UserID:       session.UserID,
+		OpenedAt:     session.OpenedAt,
+		TakenAt:      at,
+		OpeningFloat: session.OpeningFloat,
+		Cash:         money.Zero(session.Currency),
+		PayIns:       money.Zero(session.Currency),
+		PayOuts:      money.Zero(session.Currency),
+		Payments:     len(payments),
+		Tenders:      []TenderTotal{},
+		TaxRates:     []tax.RateTotal{},
+		Invoices:     len(invoices),
+	}
+
+	var err error
+	for _, movement := range session.Movements {
+		if movement.Type == CashPayIn {
+			report.PayIns, err = report.PayIns.Add(movement.Amount)
+		} else {
+			report.PayOuts, err = report.PayOuts.Add(movement.Amount)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	methods := map[PaymentMethod]int{}
+	for _, payment := range payments {
+		for _, tender := range payment.Tenders {
+			i, ok := methods[tender.Method]
+			if !ok {
+				i = len(report.Tenders)
+				methods[tender.Method] = i
+				report.Tenders = append(report.Tenders, TenderTotal{Method: tender.Method, Amount: money.Zero(session.Currency)})
+			}
+			report.Tenders[i].Count++
+			if report.Tenders[i].Amount, err = report.Tenders[i].Amount.Add(tender.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if i, ok := methods[PaymentCash]; ok {
+		report.Cash = report.Tenders[i].Amount
+	}
+
+	rates := map[tax.Rate]int{}
+	for _, invoice := range invoices {
+		for _, rate := range invoice.TaxBreakdown {
+			i, ok := rates[rate.Rate]
+			if !ok {
+				rates[rate.Rate] = len(report.TaxRates)
+				report.TaxRates = append(report.TaxRates, rate)
+				continue
+			}
+			sum := &report.TaxRates[i]
+			if sum.Net, err = sum.Net.Add(rate.Net); err != nil {
+				return nil, err
+			}
+			if sum.Tax, err = sum.Tax.Add(rate.Tax); err != nil {
+				return nil, err
+			}
+			if sum.Gross, err = sum.Gross.Add(rate.Gross); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if report.ExpectedCash, err = report.OpeningFloat.Add(report.Cash); err != nil {
+		return nil, err
+	}
+	if report.ExpectedCash, err = report.ExpectedCash.Add(report.PayIns); err != nil {
+		return nil, err
+	}
+	if report.ExpectedCash, err = report.ExpectedCash.Sub(report.PayOuts); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// Count records the counted cash on the report and works out the variance
+func (report *CashReport) Count(counted money.Money) error {
+	variance, err := counted.Sub(report.ExpectedCash)
+	if err != nil {
+		return err
+	}
+	report.CountedCash = &counted
+	report.Variance = &variance
+	return nil
+}
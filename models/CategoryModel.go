@@ -0,0 +1,96 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"time"
+)
+
+type CategoryNew struct {
+	Name string `json:"name" bson:"name" validate:"required" example:"Brakes"`
+	// ParentID is empty for a top-level category
+	ParentID string `json:"parent_id" bson:"parent_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+}
+
+// CategoryUpdate renames a category, moving it is done with CategoryMove
+type CategoryUpdate struct {
+	Name string `json:"name" bson:"name" validate:"required" example:"Brakes"`
+}
+
+// CategoryMove moves a category together with its subtree below another parent or to another place among its siblings
+type CategoryMove struct {
+	// ParentID is empty to move the category to the top level
+	ParentID string `json:"parent_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	// Position is the place among the siblings starting at 0, a position past the last sibling appends the category
+	Position int `json:"position" validate:"min=0" example:"0"`
+}
+
+type Category struct {
+	ID       primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name     string              `json:"name" bson:"name"`
+	ParentID *primitive.ObjectID `json:"parent_id,omitempty" bson:"parent_id,omitempty"`
+	// Ancestors are the categories above this one, starting at the top level, so a subtree is found in one query
+	Ancestors      []primitive.ObjectID `json:"ancestors" bson:"ancestors"`
+	Position       int                  `json:"position" bson:"position"`
+	OrganisationID primitive.ObjectID   `json:"organisation_id" bson:"organisation_id"`
+	UpdatedAt      time.Time            `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time            `json:"created_at" bson:"created_at"`
+}
+
+// CategoryNode is a category with the categories directly below it
+type CategoryNode struct {
+	*Category
+	Children []*CategoryNode `json:"children"`
+}
+
+// IsDescendantOf reports whether the category lies in the subtree of the other category
+func (category *Category) IsDescendantOf(id primitive.ObjectID) bool {
+	for _, ancestor := range category.Ancestors {
+		if ancestor == id {
+			return true
+		}
+	}
+	return false
+}
+
+// SameParent reports whether the category sits directly below the parent, nil meaning the top level
+func (category *Category) SameParent(parentID *primitive.ObjectID) bool {
+	if category.ParentID == nil || parentID == nil {
+		return category.ParentID == nil && parentID == nil
+	}
+	return *category.ParentID == *parentID
+}
+
+// CategoryTree arranges the categories into trees ordered by position. Categories whose parent is missing are not
+// part of any tree.
+func CategoryTree(categories []*Category) []*CategoryNode {
+	nodes := map[primitive.ObjectID]*CategoryNode{}
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{Category: category, Children: []*CategoryNode{}}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID == nil {
+			roots = append(roots, node)
+		} else if parent, ok := nodes[*category.ParentID]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	sortCategoryNodes(roots)
+	return roots
+}
+
+func sortCategoryNodes(nodes []*CategoryNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Position != nodes[j].Position {
+			return nodes[i].Position < nodes[j].Position
+		}
+		return nodes[i].Name < nodes[j].Name
+	})
+	for _, node := range nodes {
+		sortCategoryNodes(node.Children)
+	}
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+const (
+	CustomerTypeIndividual = "individual"
+	CustomerTypeCompany    = "company"
+)
+
+type Address struct {
+	Street  string `json:"street" bson:"street" example:"123 Main St"`
+	City    string `json:"city" bson:"city" example:"Sarajevo"`
+	ZipCode string `json:"zip_code" bson:"zip_code" example:"71000"`
+	Country int    `json:"country_code" bson:"country_code" example:"70"`
+}
+
+type CustomerNew struct {
+	Type             string  `json:"type" bson:"type" validate:"required,oneof=individual company" example:"individual"`
+	FirstName        string  `json:"first_name" bson:"first_name" validate:"required_if=Type individual" example:"Emir"`
+	LastName         string  `json:"last_name" bson:"last_name" validate:"required_if=Type individual" example:"Kovacevic"`
+	CompanyName      string  `json:"company_name" bson:"company_name" validate:"required_if=Type company" example:"Fixito d.o.o."`
+	Email            string  `json:"email" bson:"email" validate:"omitempty,email" example:"customer@example.com"`
+	Phone            string  `json:"phone" bson:"phone" example:"+387 61 123 456"`
+	BillingAddress   Address `json:"billing_address" bson:"billing_address"`
+	TaxID            string  `json:"tax_id" bson:"tax_id" example:"4200000000000"`
+	Notes            string  `json:"notes" bson:"notes" example:"Prefers calls after 5pm"`
+	MarketingConsent bool    `json:"marketing_consent" bson:"marketing_consent" example:"false"`
+}
+
+type Customer struct {
+	ID                 primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Type               string             `json:"type" bson:"type" validate:"required"`
+	Name               string             `json:"name" bson:"name"`
+	FirstName          string             `json:"first_name" bson:"first_name"`
+	LastName           string             `json:"last_name" bson:"last_name"`
+	CompanyName        string             `json:"company_name" bson:"company_name"`
+	Email              string             `json:"email" bson:"email"`
+	Phone              string             `json:"phone" bson:"phone"`
+	PhoneDigits        string             `json:"-" bson:"phone_digits"`
+	BillingAddress     Address            `json:"billing_address" bson:"billing_address"`
+	TaxID              string             `json:"tax_id" bson:"tax_id"`
+	Notes              string             `json:"notes" bson:"notes"`
+	MarketingConsent   bool               `json:"marketing_consent" bson:"marketing_consent"`
+	MarketingConsentAt *time.Time         `json:"marketing_consent_at,omitempty" bson:"marketing_consent_at,omitempty"`
+	OrganisationID     primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt          time.Time          `json:"updated_at" bson:"updated_at"`
+	CreatedAt          time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type CustomerMerge struct {
+	CustomerIDs []string `json:"customer_ids" validate:"required,min=1,dive,required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+}
+
+// Apply copies the input onto the customer and refreshes the fields derived from it
+func (customer *Customer) Apply(input *CustomerNew) {
+	if input.MarketingConsent && !customer.MarketingConsent {
+		now := time.Now()
+		customer.MarketingConsentAt = &now
+	}
+	if !input.MarketingConsent {
+		customer.MarketingConsentAt = nil
+	}
+
+	customer.Type = input.Type
+	customer.FirstName = strings.TrimSpace(input.FirstName)
+	customer.LastName = strings.TrimSpace(input.LastName)
+	customer.CompanyName = strings.TrimSpace(input.CompanyName)
+	customer.Email = strings.ToLower(strings.TrimSpace(input.Email))
+	customer.Phone = strings.TrimSpace(input.Phone)
+	customer.BillingAddress = input.BillingAddress
+	customer.TaxID = strings.TrimSpace(input.TaxID)
+	customer.Notes = input.Notes
+	customer.MarketingConsent = input.MarketingConsent
+	customer.Refresh()
+}
+
+// Refresh recomputes the display name and the phone digits used for searching
+func (customer *Customer) Refresh() {
+	if customer.Type == CustomerTypeCompany {
+		customer.Name = customer.CompanyName
+	} else {
+		customer.Name = strings.TrimSpace(customer.FirstName + " " + customer.LastName)
+	}
+	customer.PhoneDigits = PhoneDigits(customer.Phone)
+}
+
+// PhoneDigits strips everything but digits from a phone number so numbers match however they were typed
+func PhoneDigits(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, phone)
+}
@@ -1,6 +1,9 @@
 package models
 
+// Built-in roles every organisation has, custom roles are referenced by their ID
 const (
-	ADMIN = iota
-	USER
+	RoleOwner      = "owner"
+	RoleManager    = "manager"
+	RoleTechnician = "technician"
+	RoleCashier    = "cashier"
 )
@@ -0,0 +1,202 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type EstimateStatus string
+
+const (
+	EstimateDraft     EstimateStatus = "draft"
+	EstimateSent      EstimateStatus = "sent"
+	EstimateApproved  EstimateStatus = "approved"
+	EstimateDeclined  EstimateStatus = "declined"
+	EstimateConverted EstimateStatus = "converted"
+)
+
+const (
+	EstimateLinePending  = "pending"
+	EstimateLineApproved = "approved"
+	EstimateLineDeclined = "declined"
+)
+
+type EstimateNew struct {
+	CustomerID string             `json:"customer_id" bson:"customer_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	VehicleID  string             `json:"vehicle_id" bson:"vehicle_id" example:"64b7f0c2e4b0a1a2b3c4d5e7"`
+	Complaints []string           `json:"complaints" bson:"complaints" validate:"dive,required" example:"Squeaking when braking"`
+	Lines      []WorkOrderLineNew `json:"lines" bson:"lines" validate:"required,min=1,max=200,dive"`
+	// ValidUntil is the last moment the customer can accept the estimate at its prices
+	ValidUntil time.Time `json:"valid_until" bson:"valid_until" validate:"required" example:"2024-08-31T23:59:59Z"`
+	Notes      string    `json:"notes" bson:"notes" example:"Pads are at 2mm"`
+}
+
+// WorkOrder returns the input as the work order input it is checked and priced like
+func (input *EstimateNew) WorkOrder() *WorkOrderNew {
+	return &WorkOrderNew{
+		CustomerID:    input.CustomerID,
+		VehicleID:     input.VehicleID,
+		Complaints:    input.Complaints,
+		Lines:         input.Lines,
+		TechnicianIDs: []string{},
+		Notes:         input.Notes,
+	}
+}
+
+type EstimateShareNew struct {
+	// ExpiresAt ends the link before the estimate runs out, the link lasts until valid_until without it
+	ExpiresAt *time.Time `json:"expires_at" bson:"expires_at" example:"2024-08-15T23:59:59Z"`
+}
+
+type EstimateLineDecision struct {
+	LineID   string `json:"line_id" bson:"line_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e9"`
+	Approved bool   `json:"approved" bson:"approved" example:"true"`
+}
+
+// EstimateReply is the answer of the customer to a shared estimate
+type EstimateReply struct {
+	// Lines decides every line of the estimate
+	Lines []EstimateLineDecision `json:"lines" bson:"lines" validate:"required,min=1,max=200,dive"`
+	// Signature is the name the customer types to sign the answer
+	Signature string `json:"signature" bson:"signature" validate:"required,max=100" example:"Jane Doe"`
+}
+
+// EstimateLine is a work order line the customer approves or declines on its own
+type EstimateLine struct {
+	WorkOrderLine `bson:",inline"`
+	Decision      string `json:"decision" bson:"decision"`
+}
+
+// EstimateSignature records who answered a shared estimate, from where and when
+type EstimateSignature struct {
+	Name      string    `json:"name" bson:"name"`
+	IP        string    `json:"ip" bson:"ip"`
+	UserAgent string    `json:"user_agent,omitempty" bson:"user_agent,omitempty"`
+	SignedAt  time.Time `json:"signed_at" bson:"signed_at"`
+}
+
+// Estimate prices work for the customer before it starts. It is shared through a link holding a random token, only
+// the hash of which is stored, and converts into a work order with the lines the customer approved.
+type Estimate struct {
+	ID         primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	CustomerID primitive.ObjectID  `json:"customer_id" bson:"customer_id"`
+	VehicleID  *primitive.ObjectID `json:"vehicle_id,omitempty" bson:"vehicle_id,omitempty"`
+	Complaints []string            `json:"complaints" bson:"complaints"`
+	Lines      []EstimateLine      `json:"lines" bson:"lines"`
+	Notes      string              `json:"notes" bson:"notes"`
+	ValidUntil time.Time           `json:"valid_until" bson:"valid_until"`
+	Status     EstimateStatus      `json:"status" bson:"status"`
+	// ShareTokenHash is the hex encoded SHA-256 digest of the token of the link the estimate was last shared through
+	ShareTokenHash string              `json:"-" bson:"share_token_hash,omitempty"`
+	SharedAt       *time.Time          `json:"shared_at,omitempty" bson:"shared_at,omitempty"`
+	ShareExpiresAt *time.Time          `json:"share_expires_at,omitempty" bson:"share_expires_at,omitempty"`
+	Signature      *EstimateSignature  `json:"signature,omitempty" bson:"signature,omitempty"`
+	WorkOrderID    *primitive.ObjectID `json:"work_order_id,omitempty" bson:"work_order_id,omitempty"`
+	CreatedBy      primitive.ObjectID  `json:"created_by" bson:"created_by"`
+	OrganisationID primitive.ObjectID  `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt      time.Time           `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// EstimateFilter narrows an estimate listing, empty fields match every estimate
+type EstimateFilter struct {
+	Status     EstimateStatus
+	CustomerID *primitive.ObjectID
+	VehicleID  *primitive.ObjectID
+}
+
+// PublicEstimate is what the link shows the customer, without the internals of the shop
+type PublicEstimate struct {
+	Shop       EstimateShop       `json:"shop"`
+	Complaints []string           `json:"complaints"`
+	Lines      []EstimateLine     `json:"lines"`
+	Notes      string             `json:"notes"`
+	ValidUntil time.Time          `json:"valid_until"`
+	ExpiresAt  *time.Time         `json:"expires_at"`
+	Status     EstimateStatus     `json:"status"`
+	Signature  *EstimateSignature `json:"signature,omitempty"`
+}
+
+type EstimateShop struct {
+	Name    string `json:"name"`
+	Phone   string `json:"phone"`
+	Address string `json:"address"`
+	Logo    string `json:"logo"`
+}
+
+// IsValidEstimateStatus reports whether the status is one an estimate can have
+func IsValidEstimateStatus(status EstimateStatus) bool {
+	switch status {
+	case EstimateDraft, EstimateSent, EstimateApproved, EstimateDeclined, EstimateConverted:
+		return true
+	}
+	return false
+}
+
+// Public returns the estimate as the link shows it
+func (estimate *Estimate) Public(organisation *Organisation) PublicEstimate {
+	return PublicEstimate{
+		Shop:       EstimateShop{Name: organisation.Name, Phone: organisation.Phone, Address: organisation.Address, Logo: organisation.Logo},
+		Complaints: estimate.Complaints,
+		Lines:      estimate.Lines,
+		Notes:      estimate.Notes,
+		ValidUntil: estimate.ValidUntil,
+		ExpiresAt:  estimate.ShareExpiresAt,
+		Status:     estimate.Status,
+		Signature:  estimate.Signature,
+	}
+}
+
+// Expired reports whether the estimate can no longer be accepted at its prices
+func (estimate *Estimate) Expired(at time.Time) bool {
+	return at.After(estimate.ValidUntil)
+}
+
+// ShareOpen reports whether the link the estimate was shared through still works
+func (estimate *Estimate) ShareOpen(at time.Time) bool {
+	return estimate.ShareExpiresAt != nil && !at.After(*estimate.ShareExpiresAt) && !estimate.Expired(at)
+}
+
+// Decide applies the decision of the customer to every line. The estimate is approved if any line is, and declined
+// otherwise. It reports false if a line is decided twice, left undecided or does not belong to the estimate.
+func (estimate *Estimate) Decide(decisions []EstimateLineDecision) bool {
+	approved := map[string]bool{}
+	for _, decision := range decisions {
+		if _, ok := approved[decision.LineID]; ok {
+			return false
+		}
+		approved[decision.LineID] = decision.Approved
+	}
+	if len(approved) != len(estimate.Lines) {
+		return false
+	}
+
+	lines := make([]EstimateLine, len(estimate.Lines))
+	status := EstimateDeclined
+	for i, line := range estimate.Lines {
+		ok, decided := approved[line.ID.Hex()]
+		if !decided {
+			return false
+		}
+		line.Decision = EstimateLineDeclined
+		if ok {
+			line.Decision = EstimateLineApproved
+			status = EstimateApproved
+		}
+		lines[i] = line
+	}
+	estimate.Lines = lines
+	estimate.Status = status
+	return true
+}
+
+// ApprovedLines returns the lines the customer approved as work order lines
+func (estimate *Estimate) ApprovedLines() []WorkOrderLine {
+	lines := []WorkOrderLine{}
+	for _, line := range estimate.Lines {
+		if line.Decision == EstimateLineApproved {
+			lines = append(lines, line.WorkOrderLine)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/money"
+	"poosible-backend/tax"
+	"time"
+)
+
+const (
+	InvoiceTypeInvoice    = "invoice"
+	InvoiceTypeCreditNote = "credit_note"
+)
+
+// NumberingSettings describes how the sequential numbers of invoices are formatted
+type NumberingSettings struct {
+	Prefix      string `json:"prefix" bson:"prefix" validate:"max=16" example:"INV-"`
+	YearlyReset bool   `json:"yearly_reset" bson:"yearly_reset" example:"true"`
+	Digits      int    `json:"digits" bson:"digits" validate:"min=1,max=12" example:"6"`
+}
+
+// DefaultInvoiceNumbering is used by organisations that did not configure their invoice numbers
+var DefaultInvoiceNumbering = NumberingSettings{Prefix: "INV-", YearlyReset: true, Digits: 6}
+
+// DefaultCreditNoteNumbering is the numbering of credit notes, which have a sequence of their own
+var DefaultCreditNoteNumbering = NumberingSettings{Prefix: "CN-", YearlyReset: true, Digits: 6}
+
+// Sequence returns the name of the counter a number issued at the given time is taken from
+func (settings NumberingSettings) Sequence(name string, at time.Time) string {
+	if settings.YearlyReset {
+		return fmt.Sprintf("%s:%d", name, at.Year())
+	}
+	return name
+}
+
+// Format returns the number for the value of the sequence
+func (settings NumberingSettings) Format(value int64, at time.Time) string {
+	if settings.YearlyReset {
+		return fmt.Sprintf("%s%d-%0*d", settings.Prefix, at.Year(), settings.Digits, value)
+	}
+	return fmt.Sprintf("%s%0*d", settings.Prefix, settings.Digits, value)
+}
+
+type InvoiceLineNew struct {
+	ItemID          string       `json:"item_id" bson:"item_id" validate:"required_without=Description" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	Description     string       `json:"description" bson:"description" validate:"required_without=ItemID" example:"Brake pads"`
+	Quantity        float64      `json:"quantity" bson:"quantity" validate:"gt=0" example:"2"`
+	UnitPrice       *money.Money `json:"unit_price,omitempty" bson:"unit_price,omitempty" validate:"required_without=ItemID" swaggertype:"object"`
+	DiscountPercent float64      `json:"discount_percent" bson:"discount_percent" validate:"min=0,max=100" example:"10"`
+	// TaxRateID overrides the tax rate of the item, or of the organisation's default rate for free-text lines
+	TaxRateID string `json:"tax_rate_id" bson:"tax_rate_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+}
+
+type InvoiceNew struct {
+	CustomerID string           `json:"customer_id" bson:"customer_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	Lines      []InvoiceLineNew `json:"lines" bson:"lines" validate:"required,min=1,dive"`
+	DueDate    *time.Time       `json:"due_date,omitempty" bson:"due_date,omitempty"`
+	Notes      string           `json:"notes" bson:"notes" example:"Thank you for your business"`
+}
+
+type WorkOrderInvoiceNew struct {
+	DiscountPercent float64    `json:"discount_percent" bson:"discount_percent" validate:"min=0,max=100" example:"0"`
+	DueDate         *time.Time `json:"due_date,omitempty" bson:"due_date,omitempty"`
+	Notes           string     `json:"notes" bson:"notes" example:"Thank you for your business"`
+}
+
+// CreditNoteLineNew credits a quantity of a line of the original invoice
+type CreditNoteLineNew struct {
+	// Line is the position of the line on the original invoice, starting at 0
+	Line     int     `json:"line" bson:"line" validate:"min=0" example:"0"`
+	Quantity float64 `json:"quantity" bson:"quantity" validate:"gt=0" example:"1"`
+}
+
+type CreditNoteNew struct {
+	Reason string `json:"reason" bson:"reason" validate:"required" example:"Wrong part ordered"`
+	// Lines are the lines and quantities credited, without them everything not credited yet is
+	Lines []CreditNoteLineNew `json:"lines,omitempty" bson:"lines,omitempty" validate:"omitempty,max=200,dive"`
+	// Restock takes the returned items that track stock back into the location, or the default location
+	Restock    bool   `json:"restock" bson:"restock" example:"true"`
+	LocationID string `json:"location_id" bson:"location_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+}
+
+// InvoiceParty is a copy of the seller or buyer details taken when the invoice is issued
+type InvoiceParty struct {
+	Name    string  `json:"name" bson:"name"`
+	Address Address `json:"address" bson:"address"`
+	Phone   string  `json:"phone,omitempty" bson:"phone,omitempty"`
+	Email   string  `json:"email,omitempty" bson:"email,omitempty"`
+	TaxID   string  `json:"tax_id,omitempty" bson:"tax_id,omitempty"`
+}
+
+// InvoiceLine holds the amounts of a line in the invoice currency as they were calculated at issue time
+type InvoiceLine struct {
+	ItemID          *primitive.ObjectID `json:"item_id,omitempty" bson:"item_id,omitempty"`
+	Description     string              `json:"description" bson:"description"`
+	Quantity        float64             `json:"quantity" bson:"quantity"`
+	UnitPrice       money.Money         `json:"unit_price" bson:"unit_price"`
+	Amount          money.Money         `json:"amount" bson:"amount"`
+	DiscountPercent float64             `json:"discount_percent" bson:"discount_percent"`
+	Discount        money.Money         `json:"discount" bson:"discount"`
+	Net             money.Money         `json:"net" bson:"net"`
+	TaxRate         tax.Rate            `json:"tax_rate" bson:"tax_rate"`
+	Tax             money.Money         `json:"tax" bson:"tax"`
+	Total           money.Money         `json:"total" bson:"total"`
+	// OriginalLine is the position of the line a credit note line credits on the original invoice
+	OriginalLine *int `json:"original_line,omitempty" bson:"original_line,omitempty"`
+}
+
+// Invoice is an issued invoice or credit note. It is never changed after it was issued, corrections are made by
+// issuing a credit note that references it.
+type Invoice struct {
+	ID                primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	Type              string              `json:"type" bson:"type"`
+	Number            string              `json:"number" bson:"number"`
+	Sequence          string              `json:"-" bson:"sequence"`
+	SequenceValue     int64               `json:"-" bson:"sequence_value"`
+	OriginalInvoiceID *primitive.ObjectID `json:"original_invoice_id,omitempty" bson:"original_invoice_id,omitempty"`
+	WorkOrderID       *primitive.ObjectID `json:"work_order_id,omitempty" bson:"work_order_id,omitempty"`
+	CustomerID        *primitive.ObjectID `json:"customer_id,omitempty" bson:"customer_id,omitempty"`
+	Seller            InvoiceParty        `json:"seller" bson:"seller"`
+	Buyer             *InvoiceParty       `json:"buyer,omitempty" bson:"buyer,omitempty"`
+	Currency          string              `json:"currency" bson:"currency"`
+	PricesIncludeTax  bool                `json:"prices_include_tax" bson:"prices_include_tax"`
+	Lines             []InvoiceLine       `json:"lines" bson:"lines"`
+	Subtotal          money.Money         `json:"subtotal" bson:"subtotal"`
+	DiscountTotal     money.Money         `json:"discount_total" bson:"discount_total"`
+	NetTotal          money.Money         `json:"net_total" bson:"net_total"`
+	TaxTotal          money.Money         `json:"tax_total" bson:"tax_total"`
+	Total             money.Money         `json:"total" bson:"total"`
+	TaxBreakdown      []tax.RateTotal     `json:"tax_breakdown" bson:"tax_breakdown"`
+	Reason            string              `json:"reason,omitempty" bson:"reason,omitempty"`
+	Notes             string              `json:"notes,omitempty" bson:"notes,omitempty"`
+	DueDate           *time.Time          `json:"due_date,omitempty" bson:"due_date,omitempty"`
+	IssuedBy          primitive.ObjectID  `json:"issued_by" bson:"issued_by"`
+	IssuedAt          time.Time           `json:"issued_at" bson:"issued_at"`
+	OrganisationID    primitive.ObjectID  `json:"organisation_id" bson:"organisation_id" validate:"required"`
+}
+
+// InvoiceFilter narrows an invoice listing, empty fields match every invoice
+type InvoiceFilter struct {
+	Type              string
+	CustomerID        *primitive.ObjectID
+	OriginalInvoiceID *primitive.ObjectID
+	IssuedBy          *primitive.ObjectID
+	// IssuedFrom and IssuedUntil bound the issue time, from inclusive and until exclusive
+	IssuedFrom  *time.Time
+	IssuedUntil *time.Time
+}
+
+// Calculate works out the amount and discount of the line from its quantity, unit price and discount percentage. Each
+// is rounded half to even to minor units on its own.
+func (line *InvoiceLine) Calculate() error {
+	var err error
+	if line.Amount, err = line.UnitPrice.Mul(money.Ratio(line.Quantity)); err != nil {
+		return err
+	}
+	line.Discount, err = line.Amount.Mul(money.Percent(line.DiscountPercent))
+	return err
+}
+
+// Calculate taxes the discounted lines at their rates and sums them up into the totals and tax breakdown of the
+// invoice. Lines have to be calculated first.
+func (invoice *Invoice) Calculate() error {
+	invoice.Subtotal = money.Zero(invoice.Currency)
+	invoice.DiscountTotal = money.Zero(invoice.Currency)
+
+	lines := make([]tax.Line, len(invoice.Lines))
+	for i, line := range invoice.Lines {
+		var err error
+		if invoice.Subtotal, err = invoice.Subtotal.Add(line.Amount); err != nil {
+			return err
+		}
+		if invoice.DiscountTotal, err = invoice.DiscountTotal.Add(line.Discount); err != nil {
+			return err
+		}
+		if lines[i].Amount, err = line.Amount.Sub(line.Discount); err != nil {
+			return err
+		}
+		lines[i].Rate = line.TaxRate
+	}
+
+	breakdown, err := tax.Calculate(invoice.Currency, lines, invoice.PricesIncludeTax)
+	if err != nil {
+		return err
+	}
+	for i, lineTax := range breakdown.Lines {
+		invoice.Lines[i].Net = lineTax.Net
+		invoice.Lines[i].Tax = lineTax.Tax
+		invoice.Lines[i].Total = lineTax.Gross
+	}
+	invoice.NetTotal = breakdown.Net
+	invoice.TaxTotal = breakdown.Tax
+	invoice.Total = breakdown.Gross
+	invoice.TaxBreakdown = breakdown.Rates
+	return nil
+}
+
+// Negate flips the sign of every amount, turning a copy of an invoice into the credit note that reverses it exactly
+// without calculating anything again
+func (invoice *Invoice) Negate() {
+	lines := make([]InvoiceLine, len(invoice.Lines))
+	for i, line := range invoice.Lines {
+		line.UnitPrice, line.Amount, line.Discount = line.UnitPrice.Neg(), line.Amount.Neg(), line.Discount.Neg()
+		line.Net, line.Tax, line.Total = line.Net.Neg(), line.Tax.Neg(), line.Total.Neg()
+		lines[i] = line
+	}
+	invoice.Lines = lines
+
+	rates := make([]tax.RateTotal, len(invoice.TaxBreakdown))
+	for i, rate := range invoice.TaxBreakdown {
+		rate.Net, rate.Tax, rate.Gross = rate.Net.Neg(), rate.Tax.Neg(), rate.Gross.Neg()
+		rates[i] = rate
+	}
+	invoice.TaxBreakdown = rates
+
+	invoice.Subtotal, invoice.DiscountTotal = invoice.Subtotal.Neg(), invoice.DiscountTotal.Neg()
+	invoice.NetTotal, invoice.TaxTotal, invoice.Total = invoice.NetTotal.Neg(), invoice.TaxTotal.Neg(), invoice.Total.Neg()
+}
+
+// Credited returns how much of each line of the invoice the credit notes credited, as positive quantities and totals.
+// Lines of credit notes without an original line reversed the invoice in full and credit the line at their position.
+func (invoice *Invoice) Credited(creditNotes []*Invoice) ([]InvoiceLine, error) {
+	credited := make([]InvoiceLine, len(invoice.Lines))
+	for i := range credited {
+		credited[i].Amount = money.Zero(invoice.Currency)
+		credited[i].Discount = money.Zero(invoice.Currency)
+		credited[i].Total = money.Zero(invoice.Currency)
+	}
+	for _, creditNote := range creditNotes {
+		for i, line := range creditNote.Lines {
+			position := i
+			if line.OriginalLine != nil {
+				position = *line.OriginalLine
+			}
+			if position < 0 || position >= len(credited) {
+				continue
+			}
+			sum := &credited[position]
+			var err error
+			sum.Quantity = RoundQuantity(sum.Quantity + line.Quantity)
+			if sum.Amount, err = sum.Amount.Sub(line.Amount); err != nil {
+				return nil, err
+			}
+			if sum.Discount, err = sum.Discount.Sub(line.Discount); err != nil {
+				return nil, err
+			}
+			if sum.Total, err = sum.Total.Sub(line.Total); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return credited, nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type ItemImportStatus string
+
+const (
+	ItemImportQueued    ItemImportStatus = "queued"
+	ItemImportRunning   ItemImportStatus = "running"
+	ItemImportCompleted ItemImportStatus = "completed"
+	ItemImportFailed    ItemImportStatus = "failed"
+)
+
+// ItemImportColumns are the item fields a column of an import can be mapped to. barcodes holds several codes
+// separated by commas, semicolons or bars, and track_stock is yes, no, true, false, 1 or 0.
+var ItemImportColumns = []string{
+	"name", "description", "price", "sku", "barcodes", "manufacturer", "mpn",
+	"tax_rate_id", "preferred_supplier_id", "category_id", "track_stock", "reorder_point", "reorder_quantity",
+}
+
+// MaxItemImportErrors caps how many row errors an import keeps, the failed count keeps counting past it
+const MaxItemImportErrors = 1000
+
+// ItemImportNew are the form fields sent together with the file
+type ItemImportNew struct {
+	// DryRun checks every row without changing any item
+	DryRun bool `form:"dry_run" example:"true"`
+	// UpsertBy is the field that finds the item a row updates, sku or name. Rows matching no item create one, and
+	// when upserting by SKU so do rows without one.
+	UpsertBy string `form:"upsert_by" validate:"omitempty,oneof=sku name" example:"sku"`
+	// Mapping is a JSON object from item fields to the headers of the columns holding them, e.g.
+	// {"name": "Description", "price": "Retail"}. Fields it leaves out are read from the column named like the field.
+	Mapping string `form:"mapping" example:"{\"name\": \"Part name\"}"`
+}
+
+// ItemImportRowError says why a row was not imported. Row is the row number in the file, counting the header.
+type ItemImportRowError struct {
+	Row     int    `json:"row" bson:"row" example:"12"`
+	Field   string `json:"field,omitempty" bson:"field,omitempty" example:"price"`
+	Message string `json:"message" bson:"message" example:"invalid amount"`
+}
+
+// ItemImport is an upload of items from a file, imported in the background. A dry run reports what the import would
+// do, Created and Updated then count the items that would be created and updated.
+type ItemImport struct {
+	ID        primitive.ObjectID   `json:"_id,omitempty" bson:"_id,omitempty"`
+	FileName  string               `json:"file_name" bson:"file_name" example:"parts.xlsx"`
+	DryRun    bool                 `json:"dry_run" bson:"dry_run"`
+	UpsertBy  string               `json:"upsert_by" bson:"upsert_by" example:"sku"`
+	Mapping   map[string]string    `json:"mapping" bson:"mapping"`
+	Status    ItemImportStatus     `json:"status" bson:"status" example:"running"`
+	Total     int                  `json:"total" bson:"total" example:"2500"`
+	Processed int                  `json:"processed" bson:"processed" example:"1200"`
+	Created   int                  `json:"created" bson:"created" example:"1100"`
+	Updated   int                  `json:"updated" bson:"updated" example:"90"`
+	Failed    int                  `json:"failed" bson:"failed" example:"10"`
+	Errors    []ItemImportRowError `json:"errors" bson:"errors"`
+	// Error is why a failed import stopped
+	Error          string             `json:"error,omitempty" bson:"error,omitempty"`
+	CreatedBy      primitive.ObjectID `json:"created_by" bson:"created_by"`
+	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	FinishedAt     *time.Time         `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Fail records why the row was not imported
+func (itemImport *ItemImport) Fail(rowError ItemImportRowError) {
+	itemImport.Failed++
+	if len(itemImport.Errors) < MaxItemImportErrors {
+		itemImport.Errors = append(itemImport.Errors, rowError)
+	}
+}
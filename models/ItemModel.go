@@ -2,28 +2,151 @@ package models
 
 import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/money"
+	"poosible-backend/search"
+	"strings"
 	"time"
 )
 
-//TODO: Add Currency Enums
+type PartNumberType string
 
-type Price struct {
-	Amount   string `json:"amount" bson:"amount" validate:"required" example:"123"`
-	Currency string `json:"currency" bson:"currency"`
+const (
+	PartNumberOEM         PartNumberType = "oem"
+	PartNumberAftermarket PartNumberType = "aftermarket"
+)
+
+// PartNumberNew is a part number of another manufacturer the item can replace
+type PartNumberNew struct {
+	Manufacturer string         `json:"manufacturer" bson:"manufacturer" validate:"required" example:"Volkswagen"`
+	Number       string         `json:"number" bson:"number" validate:"required" example:"06A115561B"`
+	Type         PartNumberType `json:"type" bson:"type" validate:"required,oneof=oem aftermarket" example:"oem"`
+}
+
+type PartNumber struct {
+	Manufacturer string         `json:"manufacturer" bson:"manufacturer"`
+	Number       string         `json:"number" bson:"number"`
+	Type         PartNumberType `json:"type" bson:"type"`
 }
 
 type ItemNew struct {
 	Name        string `json:"name" bson:"name" validate:"required" example:"My Item"`
 	Description string `json:"description" bson:"description" validate:"required" example:"My Item Description"`
-	Price       Price  `json:"price" bson:"price" validate:"required"`
+	// Price is given as "12.50" or {"amount": "12.50", "currency": "EUR"} and is always in the organisation's currency
+	Price money.Money `json:"price" bson:"price" validate:"required" swaggertype:"object"`
+	// TaxRateID is optional, items without it are taxed at the organisation's default rate
+	TaxRateID string `json:"tax_rate_id" bson:"tax_rate_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	// TrackStock keeps a stock ledger for the item, services and labor are not tracked
+	TrackStock bool `json:"track_stock" bson:"track_stock" example:"true"`
+	// ReorderPoint is the stock at or below which the item needs reordering, a zero reorder quantity turns it off
+	ReorderPoint    float64 `json:"reorder_point" bson:"reorder_point" validate:"min=0" example:"5"`
+	ReorderQuantity float64 `json:"reorder_quantity" bson:"reorder_quantity" validate:"min=0" example:"20"`
+	// PreferredSupplierID is the supplier the item is reordered from
+	PreferredSupplierID string `json:"preferred_supplier_id" bson:"preferred_supplier_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	// CategoryID is the category the item is listed in, items without one are uncategorised
+	CategoryID string `json:"category_id" bson:"category_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	// SKU, every barcode and the manufacturer part number are unique within the organisation
+	SKU string `json:"sku" bson:"sku" validate:"max=64" example:"OF-1001"`
+	// Barcodes are EAN-8, UPC-A, EAN-13 or GTIN-14 codes with a valid check digit
+	Barcodes     []string `json:"barcodes" bson:"barcodes" validate:"dive,required" example:"4006381333931"`
+	Manufacturer string   `json:"manufacturer" bson:"manufacturer" example:"Bosch"`
+	// MPN is the manufacturer part number, it is stored upper-cased without spaces, dashes and dots
+	MPN string `json:"mpn" bson:"mpn" example:"0451103336"`
+	// CrossReferences are the OEM and aftermarket part numbers the item replaces, several items may replace the same part
+	CrossReferences []PartNumberNew `json:"cross_references" bson:"cross_references" validate:"dive"`
+}
+
+// ItemPatch holds the fields of a partial item update, nil fields are left unchanged
+type ItemPatch struct {
+	Name        *string      `json:"name" validate:"omitempty,min=1" example:"My Item"`
+	Description *string      `json:"description" validate:"omitempty,min=1" example:"My Item Description"`
+	Price       *money.Money `json:"price" validate:"omitempty" swaggertype:"object"`
+	// TaxRateID set to "" moves the item back to the organisation's default rate
+	TaxRateID       *string  `json:"tax_rate_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	TrackStock      *bool    `json:"track_stock" example:"true"`
+	ReorderPoint    *float64 `json:"reorder_point" validate:"omitempty,min=0" example:"5"`
+	ReorderQuantity *float64 `json:"reorder_quantity" validate:"omitempty,min=0" example:"20"`
+	// PreferredSupplierID set to "" removes the preferred supplier
+	PreferredSupplierID *string `json:"preferred_supplier_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	// CategoryID set to "" leaves the item uncategorised
+	CategoryID *string `json:"category_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	// SKU, Manufacturer and MPN set to "" and Barcodes and CrossReferences set to [] remove them
+	SKU             *string          `json:"sku" validate:"omitempty,max=64" example:"OF-1001"`
+	Barcodes        *[]string        `json:"barcodes" validate:"omitempty,dive,required" example:"4006381333931"`
+	Manufacturer    *string          `json:"manufacturer" example:"Bosch"`
+	MPN             *string          `json:"mpn" example:"0451103336"`
+	CrossReferences *[]PartNumberNew `json:"cross_references" validate:"omitempty,dive"`
 }
 
 type Item struct {
-	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	Name           string             `json:"name" bson:"name" validate:"required" example:"My Item"`
-	Description    string             `json:"description" bson:"description" validate:"required" example:"My Item Description"`
-	Price          Price              `json:"price" bson:"price" validate:"required"`
-	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
-	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
-	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+	ID                  primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name                string              `json:"name" bson:"name" validate:"required" example:"My Item"`
+	Description         string              `json:"description" bson:"description" validate:"required" example:"My Item Description"`
+	Price               money.Money         `json:"price" bson:"price" validate:"required" swaggertype:"object"`
+	TaxRateID           *primitive.ObjectID `json:"tax_rate_id,omitempty" bson:"tax_rate_id,omitempty"`
+	TrackStock          bool                `json:"track_stock" bson:"track_stock"`
+	ReorderPoint        float64             `json:"reorder_point" bson:"reorder_point"`
+	ReorderQuantity     float64             `json:"reorder_quantity" bson:"reorder_quantity"`
+	PreferredSupplierID *primitive.ObjectID `json:"preferred_supplier_id,omitempty" bson:"preferred_supplier_id,omitempty"`
+	CategoryID          *primitive.ObjectID `json:"category_id,omitempty" bson:"category_id,omitempty"`
+	SKU                 string              `json:"sku,omitempty" bson:"sku,omitempty"`
+	Barcodes            []string            `json:"barcodes" bson:"barcodes"`
+	Manufacturer        string              `json:"manufacturer,omitempty" bson:"manufacturer,omitempty"`
+	MPN                 string              `json:"mpn,omitempty" bson:"mpn,omitempty"`
+	CrossReferences     []PartNumber        `json:"cross_references" bson:"cross_references"`
+	OrganisationID      primitive.ObjectID  `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	Version             int                 `json:"version" bson:"version"`
+	UpdatedAt           time.Time           `json:"updated_at" bson:"updated_at"`
+	CreatedAt           time.Time           `json:"created_at" bson:"created_at"`
+	// SearchTerms are the words search finds the item by, kept up to date by the repositories
+	SearchTerms []string `json:"-" bson:"search_terms"`
+}
+
+// ItemFilter limits which items are listed, zero fields do not limit them. nil CategoryIDs list the items of every
+// category and uncategorised ones.
+type ItemFilter struct {
+	// Name matches items whose name contains it, ignoring case
+	Name        string
+	CategoryIDs []primitive.ObjectID
+	// MinPrice and MaxPrice are inclusive bounds in minor units of the organisation's currency
+	MinPrice     *int64
+	MaxPrice     *int64
+	UpdatedSince *time.Time
+}
+
+// NormalizePartNumber upper-cases the part number and removes the spaces, dashes and dots catalogues format it with
+func NormalizePartNumber(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '.' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(number)))
+}
+
+// ItemMatch is an item found by search and how well it matched
+type ItemMatch struct {
+	*Item
+	Matched int     `json:"matched" example:"3"`
+	Score   float64 `json:"score" example:"4.2"`
+}
+
+// SearchFields returns the texts search matches the item against. The name and the codes counter staff type count
+// the most, the description the least.
+func (item *Item) SearchFields() []search.Field {
+	fields := []search.Field{
+		{Text: item.Name, Weight: 3},
+		{Text: item.SKU, Weight: 3, Code: true},
+		{Text: item.MPN, Weight: 3, Code: true},
+		{Text: item.Manufacturer, Weight: 1},
+		{Text: item.Description, Weight: 1},
+	}
+	for _, reference := range item.CrossReferences {
+		fields = append(fields, search.Field{Text: reference.Number, Weight: 2, Code: true}, search.Field{Text: reference.Manufacturer, Weight: 1})
+	}
+	return fields
+}
+
+// Index refreshes the search terms of the item
+func (item *Item) Index() {
+	item.SearchTerms = search.Terms(item.SearchFields()...)
 }
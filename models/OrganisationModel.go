@@ -14,30 +14,49 @@ type OrganisationNew struct {
 	ZipCode     string `json:"zip_code" bson:"zip_code" validate:"required" example:"12345"`
 	Logo        string `json:"logo" bson:"logo"  example:"https://www.example.com/logo.png"`
 	Currency    int    `json:"currency_code" bson:"currency_code" validate:"required" example:"840"`
+	// PricesIncludeTax makes item and line prices gross amounts that already contain the tax
+	PricesIncludeTax bool `json:"prices_include_tax" bson:"prices_include_tax" example:"true"`
+	// AllowNegativeStock lets sales and work orders take more stock than is on hand
+	AllowNegativeStock bool `json:"allow_negative_stock" bson:"allow_negative_stock" example:"false"`
+	// InvoiceNumbering is optional, organisations without it number invoices with DefaultInvoiceNumbering
+	InvoiceNumbering *NumberingSettings `json:"invoice_numbering,omitempty" bson:"invoice_numbering,omitempty" validate:"omitempty"`
 }
 
 type OrganisationPublic struct {
-	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	Name        string             `json:"name" bson:"name" validate:"required" example:"My Organization"`
-	Description string             `json:"description" bson:"description" validate:"required" example:"My Organization Description"`
-	Phone       string             `json:"phone" bson:"phone" validate:"required" example:"1234567890"`
-	Address     string             `json:"address" bson:"address" validate:"required" example:"123 Main St"`
-	Country     string             `json:"country" bson:"country" validate:"required" example:"US"`
-	Currency    string             `json:"currency" bson:"currency" validate:"required" example:"USD"`
-	ZipCode     string             `json:"zip_code" bson:"zip_code" validate:"required" example:"12345"`
-	Logo        string             `json:"logo" bson:"logo" example:"https://www.example.com/logo.png"`
+	ID                 primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name               string             `json:"name" bson:"name" validate:"required" example:"My Organization"`
+	Description        string             `json:"description" bson:"description" validate:"required" example:"My Organization Description"`
+	Phone              string             `json:"phone" bson:"phone" validate:"required" example:"1234567890"`
+	Address            string             `json:"address" bson:"address" validate:"required" example:"123 Main St"`
+	Country            string             `json:"country" bson:"country" validate:"required" example:"US"`
+	Currency           string             `json:"currency" bson:"currency" validate:"required" example:"USD"`
+	PricesIncludeTax   bool               `json:"prices_include_tax" bson:"prices_include_tax" example:"true"`
+	AllowNegativeStock bool               `json:"allow_negative_stock" bson:"allow_negative_stock" example:"false"`
+	ZipCode            string             `json:"zip_code" bson:"zip_code" validate:"required" example:"12345"`
+	Logo               string             `json:"logo" bson:"logo" example:"https://www.example.com/logo.png"`
 }
 
 type Organisation struct {
-	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
-	Name        string             `json:"name" bson:"name" validate:"required" example:"My Organization"`
-	Description string             `json:"description" bson:"description" validate:"required" example:"My Organization Description"`
-	Phone       string             `json:"phone" bson:"phone" validate:"required" example:"1234567890"`
-	Address     string             `json:"address" bson:"address" validate:"required" example:"123 Main St"`
-	Country     int                `json:"country_code" bson:"country_code" validate:"required" example:"36"`
-	Currency    int                `json:"currency_code" bson:"currency_code" validate:"required" example:"840"`
-	ZipCode     string             `json:"zip_code" bson:"zip_code" validate:"required" example:"12345"`
-	Logo        string             `json:"logo" bson:"logo" validate:"required" example:"https://www.example.com/logo.png"`
-	UpdatedAt   time.Time          `json:"updated_at" bson:"updated_at"`
-	CreatedAt   time.Time          `json:"created_at" bson:"created_at"`
+	ID                 primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name               string             `json:"name" bson:"name" validate:"required" example:"My Organization"`
+	Description        string             `json:"description" bson:"description" validate:"required" example:"My Organization Description"`
+	Phone              string             `json:"phone" bson:"phone" validate:"required" example:"1234567890"`
+	Address            string             `json:"address" bson:"address" validate:"required" example:"123 Main St"`
+	Country            int                `json:"country_code" bson:"country_code" validate:"required" example:"36"`
+	Currency           int                `json:"currency_code" bson:"currency_code" validate:"required" example:"840"`
+	PricesIncludeTax   bool               `json:"prices_include_tax" bson:"prices_include_tax"`
+	AllowNegativeStock bool               `json:"allow_negative_stock" bson:"allow_negative_stock"`
+	ZipCode            string             `json:"zip_code" bson:"zip_code" validate:"required" example:"12345"`
+	Logo               string             `json:"logo" bson:"logo" validate:"required" example:"https://www.example.com/logo.png"`
+	InvoiceNumbering   *NumberingSettings `json:"invoice_numbering,omitempty" bson:"invoice_numbering,omitempty"`
+	UpdatedAt          time.Time          `json:"updated_at" bson:"updated_at"`
+	CreatedAt          time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// InvoiceNumberingSettings returns how the organisation numbers its invoices
+func (organisation *Organisation) InvoiceNumberingSettings() NumberingSettings {
+	if organisation.InvoiceNumbering == nil {
+		return DefaultInvoiceNumbering
+	}
+	return *organisation.InvoiceNumbering
 }
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/money"
+	"time"
+)
+
+type PaymentMethod string
+
+const (
+	PaymentCash         PaymentMethod = "cash"
+	PaymentCard         PaymentMethod = "card"
+	PaymentBankTransfer PaymentMethod = "bank_transfer"
+	PaymentVoucher      PaymentMethod = "voucher"
+	// PaymentOnAccount settles the invoice by charging the customer's account, which the customer pays off later
+	PaymentOnAccount PaymentMethod = "on_account"
+	// PaymentStoreCredit pays with credit the customer was given in place of a refund
+	PaymentStoreCredit PaymentMethod = "store_credit"
+)
+
+type RefundMethod string
+
+const (
+	// RefundOriginal pays a refund back through the tenders the invoice was paid with, newest first
+	RefundOriginal RefundMethod = "original"
+	// RefundStoreCredit gives the customer store credit instead of money
+	RefundStoreCredit RefundMethod = "store_credit"
+)
+
+// PaymentTenderNew is the part of a payment made with one method
+type PaymentTenderNew struct {
+	Method PaymentMethod `json:"method" validate:"required,oneof=cash card bank_transfer voucher on_account store_credit" example:"cash"`
+	// Amount is what the tender pays off the invoice. A cash tender may leave it out and give only Tendered, it then
+	// pays what the other tenders leave of the balance due.
+	Amount *money.Money `json:"amount,omitempty" swaggertype:"object"`
+	// Tendered is the cash handed over, the change is what it exceeds Amount by. Only cash tenders have it.
+	Tendered *money.Money `json:"tendered,omitempty" swaggertype:"object"`
+	// Reference is the voucher code, which vouchers need, or the reference of a bank transfer
+	Reference string `json:"reference" validate:"max=64" example:"VCH-2031"`
+	// CardToken is the token of the card as read by the card terminal, which card tenders need
+	CardToken string `json:"card_token" validate:"required_if=Method card" example:"tok_visa"`
+}
+
+type PaymentNew struct {
+	Tenders []PaymentTenderNew `json:"tenders" validate:"required,min=1,max=10,dive"`
+	Notes   string             `json:"notes" example:"Paid at pickup"`
+}
+
+type RefundNew struct {
+	Method RefundMethod `json:"method" validate:"required,oneof=original store_credit" example:"original"`
+	// Amount defaults to everything the credit note can still refund
+	Amount *money.Money `json:"amount,omitempty" swaggertype:"object"`
+	Notes  string       `json:"notes" example:"Returned unused"`
+}
+
+// CardCharge is the capture of a card tender at the payment gateway
+type CardCharge struct {
+	Gateway       string `json:"gateway" bson:"gateway" example:"fake"`
+	TransactionID string `json:"transaction_id" bson:"transaction_id" example:"ch_3NqXy2"`
+	Brand         string `json:"brand" bson:"brand" example:"visa"`
+	Last4         string `json:"last4" bson:"last4" example:"4242"`
+}
+
+type PaymentTender struct {
+	Method PaymentMethod `json:"method" bson:"method"`
+	Amount money.Money   `json:"amount" bson:"amount"`
+	// Tendered and Change are only kept for cash
+	Tendered  *money.Money `json:"tendered,omitempty" bson:"tendered,omitempty"`
+	Change    *money.Money `json:"change,omitempty" bson:"change,omitempty"`
+	Reference string       `json:"reference,omitempty" bson:"reference,omitempty"`
+	Card      *CardCharge  `json:"card,omitempty" bson:"card,omitempty"`
+	// RefundOf is the tender a refund tender pays back
+	RefundOf *TenderReference `json:"refund_of,omitempty" bson:"refund_of,omitempty"`
+}
+
+// TenderReference points at a tender of a payment
+type TenderReference struct {
+	PaymentID primitive.ObjectID `json:"payment_id" bson:"payment_id"`
+	Tender    int                `json:"tender" bson:"tender"`
+}
+
+// Payment is money taken against an invoice, split over one or more tenders. Payments are never changed.
+// Refunds are payments of a credit note with negative amounts, they are recorded against the original invoice.
+type Payment struct {
+	ID           primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	InvoiceID    primitive.ObjectID  `json:"invoice_id" bson:"invoice_id"`
+	CreditNoteID *primitive.ObjectID `json:"credit_note_id,omitempty" bson:"credit_note_id,omitempty"`
+	CustomerID   *primitive.ObjectID `json:"customer_id,omitempty" bson:"customer_id,omitempty"`
+	Currency     string              `json:"currency" bson:"currency"`
+	Tenders      []PaymentTender     `json:"tenders" bson:"tenders"`
+	// Total is what the payment pays off the invoice, the sum of the tender amounts
+	Total money.Money `json:"total" bson:"total"`
+	// Change is the cash given back
+	Change     money.Money        `json:"change" bson:"change"`
+	Notes      string             `json:"notes,omitempty" bson:"notes,omitempty"`
+	ReceivedBy primitive.ObjectID `json:"received_by" bson:"received_by"`
+	// CashSessionID is the till session of the user who took the payment, payments with cash tenders always have one
+	CashSessionID  *primitive.ObjectID `json:"cash_session_id,omitempty" bson:"cash_session_id,omitempty"`
+	ReceivedAt     time.Time           `json:"received_at" bson:"received_at"`
+	OrganisationID primitive.ObjectID  `json:"organisation_id" bson:"organisation_id" validate:"required"`
+}
+
+// PaymentFilter narrows a payment listing, empty fields match every payment
+type PaymentFilter struct {
+	InvoiceID     *primitive.ObjectID
+	CustomerID    *primitive.ObjectID
+	CashSessionID *primitive.ObjectID
+	Method        PaymentMethod
+}
+
+// InvoiceBalance is how much of an invoice was paid
+type InvoiceBalance struct {
+	Total money.Money `json:"total"`
+	// Credited is what the credit notes of the invoice took off its total
+	Credited money.Money `json:"credited"`
+	// Paid is what the payments paid less what was refunded
+	Paid money.Money `json:"paid"`
+	// Due is negative when more was paid than is left of the invoice after its credit notes, which can be refunded
+	Due money.Money `json:"due"`
+}
+
+// Balance sums up the payments and credit notes of the invoice
+func (invoice *Invoice) Balance(payments []*Payment, creditNotes []*Invoice) (InvoiceBalance, error) {
+	balance := InvoiceBalance{Total: invoice.Total, Credited: money.Zero(invoice.Currency), Paid: money.Zero(invoice.Currency)}
+	for _, payment := range payments {
+		var err error
+		if balance.Paid, err = balance.Paid.Add(payment.Total); err != nil {
+			return InvoiceBalance{}, err
+		}
+	}
+	for _, creditNote := range creditNotes {
+		var err error
+		if balance.Credited, err = balance.Credited.Sub(creditNote.Total); err != nil {
+			return InvoiceBalance{}, err
+		}
+	}
+	net, err := balance.Net()
+	if err != nil {
+		return InvoiceBalance{}, err
+	}
+	balance.Due, err = net.Sub(balance.Paid)
+	return balance, err
+}
+
+// Net is what is left of the invoice total after its credit notes
+func (balance InvoiceBalance) Net() (money.Money, error) {
+	return balance.Total.Sub(balance.Credited)
+}
+
+// Calculate sums up the amounts and the change of the tenders
+func (payment *Payment) Calculate() error {
+	payment.Total = money.Zero(payment.Currency)
+	payment.Change = money.Zero(payment.Currency)
+	for _, tender := range payment.Tenders {
+		var err error
+		if payment.Total, err = payment.Total.Add(tender.Amount); err != nil {
+			return err
+		}
+		if tender.Change == nil {
+			continue
+		}
+		if payment.Change, err = payment.Change.Add(*tender.Change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Refunded returns how much of each tender of the payment the refunds paid back, as positive amounts
+func (payment *Payment) Refunded(refunds []*Payment) ([]money.Money, error) {
+	refunded := make([]money.Money, len(payment.Tenders))
+	for i := range refunded {
+		refunded[i] = money.Zero(payment.Currency)
+	}
+	for _, refund := range refunds {
+		for _, tender := range refund.Tenders {
+			if tender.RefundOf == nil || tender.RefundOf.PaymentID != payment.ID || tender.RefundOf.Tender >= len(refunded) {
+				continue
+			}
+			var err error
+			if refunded[tender.RefundOf.Tender], err = refunded[tender.RefundOf.Tender].Sub(tender.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return refunded, nil
+}
+
+// StoreCredit returns the store credit the payments of a customer leave them, refunds to store credit add to it and
+// tenders paid with it take it off
+func StoreCredit(payments []*Payment, currency string) (money.Money, error) {
+	credit := money.Zero(currency)
+	for _, payment := range payments {
+		for _, tender := range payment.Tenders {
+			if tender.Method != PaymentStoreCredit {
+				continue
+			}
+			var err error
+			if credit, err = credit.Sub(tender.Amount); err != nil {
+				return credit, err
+			}
+		}
+	}
+	return credit, nil
+}
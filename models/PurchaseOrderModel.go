@@ -0,0 +1,192 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/money"
+	"time"
+)
+
+type PurchaseOrderStatus string
+
+const (
+	PurchaseOrderDraft             PurchaseOrderStatus = "draft"
+	PurchaseOrderSent              PurchaseOrderStatus = "sent"
+	PurchaseOrderPartiallyReceived PurchaseOrderStatus = "partially_received"
+	PurchaseOrderReceived          PurchaseOrderStatus = "received"
+	PurchaseOrderCancelled         PurchaseOrderStatus = "cancelled"
+)
+
+// PurchaseOrderTransitions lists the statuses a purchase order may be moved to by hand from each status. Orders become
+// partially received and received only by receiving goods against them, cancelling a partially received order
+// cancels its back-order.
+var PurchaseOrderTransitions = map[PurchaseOrderStatus][]PurchaseOrderStatus{
+	PurchaseOrderDraft:             {PurchaseOrderSent, PurchaseOrderCancelled},
+	PurchaseOrderSent:              {PurchaseOrderCancelled},
+	PurchaseOrderPartiallyReceived: {PurchaseOrderCancelled},
+	PurchaseOrderReceived:          {},
+	PurchaseOrderCancelled:         {},
+}
+
+// OpenPurchaseOrderStatuses are the statuses of orders whose goods are still expected
+var OpenPurchaseOrderStatuses = []PurchaseOrderStatus{PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartiallyReceived}
+
+// PurchaseOrderSequence is the name of the sequence purchase orders are numbered from
+const PurchaseOrderSequence = "purchase_order"
+
+// DefaultPurchaseOrderNumbering is the numbering of purchase orders
+var DefaultPurchaseOrderNumbering = NumberingSettings{Prefix: "PO-", YearlyReset: true, Digits: 6}
+
+type PurchaseOrderLineNew struct {
+	ItemID      string  `json:"item_id" bson:"item_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	Description string  `json:"description" bson:"description" example:"Oil filter"`
+	Quantity    float64 `json:"quantity" bson:"quantity" validate:"gt=0" example:"20"`
+	// UnitCost is the expected cost in the organisation's currency, it defaults to the item's last receipt cost
+	UnitCost *money.Money `json:"unit_cost,omitempty" bson:"unit_cost,omitempty" swaggertype:"object"`
+}
+
+type PurchaseOrderNew struct {
+	SupplierID string                 `json:"supplier_id" bson:"supplier_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	Lines      []PurchaseOrderLineNew `json:"lines" bson:"lines" validate:"required,min=1,dive"`
+	Notes      string                 `json:"notes" bson:"notes" example:"Deliver to the back entrance"`
+}
+
+type PurchaseOrderTransition struct {
+	Status PurchaseOrderStatus `json:"status" bson:"status" validate:"required,oneof=sent cancelled" example:"sent"`
+}
+
+type PurchaseOrderReceiptLineNew struct {
+	LineID   string  `json:"line_id" bson:"line_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	Quantity float64 `json:"quantity" bson:"quantity" validate:"gt=0" example:"12"`
+	// UnitCost is the cost actually paid, it defaults to the expected cost of the line
+	UnitCost *money.Money `json:"unit_cost,omitempty" bson:"unit_cost,omitempty" swaggertype:"object"`
+}
+
+// PurchaseOrderReceiptNew is a delivery of goods against a purchase order. Lines not delivered in full stay on
+// back-order.
+type PurchaseOrderReceiptNew struct {
+	// LocationID is where the goods are put, the default location if empty
+	LocationID string                        `json:"location_id" bson:"location_id" example:"64b7f0c2e4b0a1a2b3c4d5e7"`
+	Lines      []PurchaseOrderReceiptLineNew `json:"lines" bson:"lines" validate:"required,min=1,dive"`
+	Note       string                        `json:"note" bson:"note" example:"Delivery note 4711"`
+}
+
+type PurchaseOrderLine struct {
+	ID          primitive.ObjectID `json:"_id" bson:"_id"`
+	ItemID      primitive.ObjectID `json:"item_id" bson:"item_id"`
+	Description string             `json:"description" bson:"description"`
+	Quantity    float64            `json:"quantity" bson:"quantity"`
+	Received    float64            `json:"received" bson:"received"`
+	// UnitCost is the expected cost, the cost actually paid is recorded on the receipt and its stock movement
+	UnitCost money.Money `json:"unit_cost" bson:"unit_cost"`
+	Amount   money.Money `json:"amount" bson:"amount"`
+}
+
+type PurchaseOrderReceiptLine struct {
+	LineID   primitive.ObjectID `json:"line_id" bson:"line_id"`
+	ItemID   primitive.ObjectID `json:"item_id" bson:"item_id"`
+	Quantity float64            `json:"quantity" bson:"quantity"`
+	UnitCost money.Money        `json:"unit_cost" bson:"unit_cost"`
+}
+
+// PurchaseOrderReceipt records one delivery of goods against the order
+type PurchaseOrderReceipt struct {
+	ID         primitive.ObjectID         `json:"_id" bson:"_id"`
+	LocationID primitive.ObjectID         `json:"location_id" bson:"location_id"`
+	Lines      []PurchaseOrderReceiptLine `json:"lines" bson:"lines"`
+	Note       string                     `json:"note,omitempty" bson:"note,omitempty"`
+	UserID     primitive.ObjectID         `json:"user_id" bson:"user_id"`
+	ReceivedAt time.Time                  `json:"received_at" bson:"received_at"`
+}
+
+type PurchaseOrder struct {
+	ID            primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	Number        string              `json:"number" bson:"number"`
+	Sequence      string              `json:"-" bson:"sequence"`
+	SequenceValue int64               `json:"-" bson:"sequence_value"`
+	SupplierID    primitive.ObjectID  `json:"supplier_id" bson:"supplier_id"`
+	Status        PurchaseOrderStatus `json:"status" bson:"status"`
+	Currency      string              `json:"currency" bson:"currency"`
+	Lines         []PurchaseOrderLine `json:"lines" bson:"lines"`
+	Total         money.Money         `json:"total" bson:"total"`
+	Notes         string              `json:"notes,omitempty" bson:"notes,omitempty"`
+	// ExpectedAt is when the goods should arrive, worked out from the supplier's lead time when the order is sent
+	ExpectedAt     *time.Time             `json:"expected_at,omitempty" bson:"expected_at,omitempty"`
+	SentAt         *time.Time             `json:"sent_at,omitempty" bson:"sent_at,omitempty"`
+	Receipts       []PurchaseOrderReceipt `json:"receipts" bson:"receipts"`
+	CreatedBy      primitive.ObjectID     `json:"created_by" bson:"created_by"`
+	OrganisationID primitive.ObjectID     `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	Version        int                    `json:"version" bson:"version"`
+	UpdatedAt      time.Time              `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time              `json:"created_at" bson:"created_at"`
+}
+
+// PurchaseOrderFilter narrows a purchase order listing, empty fields match every order
+type PurchaseOrderFilter struct {
+	Status     PurchaseOrderStatus
+	SupplierID *primitive.ObjectID
+	ItemID     *primitive.ObjectID
+}
+
+// Outstanding returns the quantity of the line that has not been received yet
+func (line PurchaseOrderLine) Outstanding() float64 {
+	if line.Received >= line.Quantity {
+		return 0
+	}
+	return RoundQuantity(line.Quantity - line.Received)
+}
+
+// CanTransitionPurchaseOrder reports whether a purchase order may be moved from one status to the other by hand
+func CanTransitionPurchaseOrder(from PurchaseOrderStatus, to PurchaseOrderStatus) bool {
+	for _, allowed := range PurchaseOrderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidPurchaseOrderStatus reports whether the status is one a purchase order can have
+func IsValidPurchaseOrderStatus(status PurchaseOrderStatus) bool {
+	switch status {
+	case PurchaseOrderDraft, PurchaseOrderSent, PurchaseOrderPartiallyReceived, PurchaseOrderReceived, PurchaseOrderCancelled:
+		return true
+	}
+	return false
+}
+
+// IsOpen reports whether goods are still expected for the order
+func (order *PurchaseOrder) IsOpen() bool {
+	for _, status := range OpenPurchaseOrderStatuses {
+		if order.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Calculate works out the amount of every line and the total of the order
+func (order *PurchaseOrder) Calculate() error {
+	order.Total = money.Zero(order.Currency)
+	for i := range order.Lines {
+		line := &order.Lines[i]
+		amount, err := line.UnitCost.Mul(money.Ratio(line.Quantity))
+		if err != nil {
+			return err
+		}
+		line.Amount = amount
+		if order.Total, err = order.Total.Add(amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReceiptStatus returns the status of the order after goods were received against it
+func (order *PurchaseOrder) ReceiptStatus() PurchaseOrderStatus {
+	for _, line := range order.Lines {
+		if line.Outstanding() > 0 {
+			return PurchaseOrderPartiallyReceived
+		}
+	}
+	return PurchaseOrderReceived
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type RefreshToken struct {
+	ID         primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	TokenHash  string              `json:"-" bson:"token_hash"`
+	FamilyID   string              `json:"family_id" bson:"family_id"`
+	UserID     primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	ExpiresAt  time.Time           `json:"expires_at" bson:"expires_at"`
+	UsedAt     *time.Time          `json:"used_at,omitempty" bson:"used_at,omitempty"`
+	RevokedAt  *time.Time          `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+	ReplacedBy *primitive.ObjectID `json:"replaced_by,omitempty" bson:"replaced_by,omitempty"`
+	CreatedAt  time.Time           `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"poosible-backend/money"
+	"time"
+)
+
+// ReorderSuggestion is an item whose stock, counting what is already on order, fell to its reorder point. It is kept
+// up to date by the reorder job and removed once the item no longer needs reordering.
+type ReorderSuggestion struct {
+	ID           primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	ItemID       primitive.ObjectID  `json:"item_id" bson:"item_id"`
+	ItemName     string              `json:"item_name" bson:"item_name"`
+	SupplierID   *primitive.ObjectID `json:"supplier_id,omitempty" bson:"supplier_id,omitempty"`
+	OnHand       float64             `json:"on_hand" bson:"on_hand"`
+	OnOrder      float64             `json:"on_order" bson:"on_order"`
+	ReorderPoint float64             `json:"reorder_point" bson:"reorder_point"`
+	// Quantity is the reorder quantity, or a multiple of it if one would not lift the stock above the reorder point
+	Quantity float64 `json:"quantity" bson:"quantity"`
+	// UnitCost is the cost of the last receipt of the item, if any was recorded
+	UnitCost       *money.Money       `json:"unit_cost,omitempty" bson:"unit_cost,omitempty"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+}
+
+// ReorderGroup holds the suggestions of the items preferring one supplier, or of the items preferring none
+type ReorderGroup struct {
+	SupplierID   *primitive.ObjectID  `json:"supplier_id"`
+	SupplierName string               `json:"supplier_name,omitempty"`
+	Items        []*ReorderSuggestion `json:"items"`
+}
+
+// ReorderQuantity returns how much of an item to order when the available stock is at or below the reorder point, so
+// that the stock ends up above it
+func ReorderQuantity(available float64, reorderPoint float64, reorderQuantity float64) float64 {
+	batches := math.Floor((reorderPoint-available)/reorderQuantity) + 1
+	return RoundQuantity(batches * reorderQuantity)
+}
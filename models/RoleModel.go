@@ -0,0 +1,210 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Permission string
+
+const (
+	PermissionUsersRead           Permission = "users:read"
+	PermissionUsersWrite          Permission = "users:write"
+	PermissionUsersDelete         Permission = "users:delete"
+	PermissionOrganisationRead    Permission = "organisation:read"
+	PermissionOrganisationWrite   Permission = "organisation:write"
+	PermissionOrganisationDelete  Permission = "organisation:delete"
+	PermissionRolesRead           Permission = "roles:read"
+	PermissionRolesWrite          Permission = "roles:write"
+	PermissionRolesDelete         Permission = "roles:delete"
+	PermissionItemsRead           Permission = "items:read"
+	PermissionItemsWrite          Permission = "items:write"
+	PermissionItemsDelete         Permission = "items:delete"
+	PermissionCustomersRead       Permission = "customers:read"
+	PermissionCustomersWrite      Permission = "customers:write"
+	PermissionCustomersDelete     Permission = "customers:delete"
+	PermissionVehiclesRead        Permission = "vehicles:read"
+	PermissionVehiclesWrite       Permission = "vehicles:write"
+	PermissionVehiclesDelete      Permission = "vehicles:delete"
+	PermissionWorkOrdersRead      Permission = "work_orders:read"
+	PermissionWorkOrdersWrite     Permission = "work_orders:write"
+	PermissionWorkOrdersDelete    Permission = "work_orders:delete"
+	PermissionInvoicesRead        Permission = "invoices:read"
+	PermissionInvoicesWrite       Permission = "invoices:write"
+	PermissionTaxRatesRead        Permission = "tax_rates:read"
+	PermissionTaxRatesWrite       Permission = "tax_rates:write"
+	PermissionTaxRatesDelete      Permission = "tax_rates:delete"
+	PermissionStockRead           Permission = "stock:read"
+	PermissionStockWrite          Permission = "stock:write"
+	PermissionSuppliersRead       Permission = "suppliers:read"
+	PermissionSuppliersWrite      Permission = "suppliers:write"
+	PermissionSuppliersDelete     Permission = "suppliers:delete"
+	PermissionPurchaseOrdersRead  Permission = "purchase_orders:read"
+	PermissionPurchaseOrdersWrite Permission = "purchase_orders:write"
+	PermissionPaymentsRead        Permission = "payments:read"
+	PermissionPaymentsWrite       Permission = "payments:write"
+	// PermissionCashSessionsRead shows the expected cash of sessions, which cashiers counting blind must not see
+	PermissionCashSessionsRead  Permission = "cash_sessions:read"
+	PermissionCashSessionsWrite Permission = "cash_sessions:write"
+	PermissionEstimatesRead     Permission = "estimates:read"
+	PermissionEstimatesWrite    Permission = "estimates:write"
+)
+
+var AllPermissions = []Permission{
+	PermissionUsersRead,
+	PermissionUsersWrite,
+	PermissionUsersDelete,
+	PermissionOrganisationRead,
+	PermissionOrganisationWrite,
+	PermissionOrganisationDelete,
+	PermissionRolesRead,
+	PermissionRolesWrite,
+	PermissionRolesDelete,
+	PermissionItemsRead,
+	PermissionItemsWrite,
+	PermissionItemsDelete,
+	PermissionCustomersRead,
+	PermissionCustomersWrite,
+	PermissionCustomersDelete,
+	PermissionVehiclesRead,
+	PermissionVehiclesWrite,
+	PermissionVehiclesDelete,
+	PermissionWorkOrdersRead,
+	PermissionWorkOrdersWrite,
+	PermissionWorkOrdersDelete,
+	PermissionInvoicesRead,
+	PermissionInvoicesWrite,
+	PermissionTaxRatesRead,
+	PermissionTaxRatesWrite,
+	PermissionTaxRatesDelete,
+	PermissionStockRead,
+	PermissionStockWrite,
+	PermissionSuppliersRead,
+	PermissionSuppliersWrite,
+	PermissionSuppliersDelete,
+	PermissionPurchaseOrdersRead,
+	PermissionPurchaseOrdersWrite,
+	PermissionPaymentsRead,
+	PermissionPaymentsWrite,
+	PermissionCashSessionsRead,
+	PermissionCashSessionsWrite,
+	PermissionEstimatesRead,
+	PermissionEstimatesWrite,
+}
+
+var BuiltinRoles = map[string][]Permission{
+	RoleOwner: AllPermissions,
+	RoleManager: {
+		PermissionUsersRead,
+		PermissionUsersWrite,
+		PermissionOrganisationRead,
+		PermissionOrganisationWrite,
+		PermissionRolesRead,
+		PermissionItemsRead,
+		PermissionItemsWrite,
+		PermissionItemsDelete,
+		PermissionCustomersRead,
+		PermissionCustomersWrite,
+		PermissionCustomersDelete,
+		PermissionVehiclesRead,
+		PermissionVehiclesWrite,
+		PermissionVehiclesDelete,
+		PermissionWorkOrdersRead,
+		PermissionWorkOrdersWrite,
+		PermissionWorkOrdersDelete,
+		PermissionInvoicesRead,
+		PermissionInvoicesWrite,
+		PermissionTaxRatesRead,
+		PermissionTaxRatesWrite,
+		PermissionTaxRatesDelete,
+		PermissionStockRead,
+		PermissionStockWrite,
+		PermissionSuppliersRead,
+		PermissionSuppliersWrite,
+		PermissionSuppliersDelete,
+		PermissionPurchaseOrdersRead,
+		PermissionPurchaseOrdersWrite,
+		PermissionPaymentsRead,
+		PermissionPaymentsWrite,
+		PermissionCashSessionsRead,
+		PermissionCashSessionsWrite,
+		PermissionEstimatesRead,
+		PermissionEstimatesWrite,
+	},
+	RoleTechnician: {
+		PermissionOrganisationRead,
+		PermissionItemsRead,
+		PermissionCustomersRead,
+		PermissionVehiclesRead,
+		PermissionVehiclesWrite,
+		PermissionWorkOrdersRead,
+		PermissionWorkOrdersWrite,
+		PermissionStockRead,
+		PermissionEstimatesRead,
+		PermissionEstimatesWrite,
+	},
+	RoleCashier: {
+		PermissionOrganisationRead,
+		PermissionItemsRead,
+		PermissionItemsWrite,
+		PermissionCustomersRead,
+		PermissionCustomersWrite,
+		PermissionVehiclesRead,
+		PermissionVehiclesWrite,
+		PermissionWorkOrdersRead,
+		PermissionWorkOrdersWrite,
+		PermissionInvoicesRead,
+		PermissionInvoicesWrite,
+		PermissionTaxRatesRead,
+		PermissionStockRead,
+		PermissionSuppliersRead,
+		PermissionPaymentsRead,
+		PermissionPaymentsWrite,
+		PermissionCashSessionsWrite,
+		PermissionEstimatesRead,
+		PermissionEstimatesWrite,
+	},
+}
+
+type RoleNew struct {
+	Name        string       `json:"name" bson:"name" validate:"required" example:"Parts Manager"`
+	Permissions []Permission `json:"permissions" bson:"permissions" validate:"required,min=1" example:"items:read,items:write"`
+}
+
+type Role struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name           string             `json:"name" bson:"name" validate:"required" example:"Parts Manager"`
+	Permissions    []Permission       `json:"permissions" bson:"permissions" validate:"required"`
+	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+}
+
+type RoleAssignment struct {
+	Role string `json:"role" bson:"role" validate:"required" example:"technician"`
+}
+
+type MemberNew struct {
+	Email string `json:"email" bson:"email" validate:"required" example:"technician@example.com"`
+	Role  string `json:"role" bson:"role" validate:"required" example:"technician"`
+}
+
+// IsValidPermission reports whether the permission is known
+func IsValidPermission(permission Permission) bool {
+	for _, p := range AllPermissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the permission is part of the given set
+func HasPermission(permissions []Permission, permission Permission) bool {
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
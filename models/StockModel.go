@@ -0,0 +1,122 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"math"
+	"poosible-backend/money"
+	"time"
+)
+
+type StockMovementType string
+
+const (
+	StockReceipt     StockMovementType = "receipt"
+	StockSale        StockMovementType = "sale"
+	StockConsumption StockMovementType = "consumption"
+	StockAdjustment  StockMovementType = "adjustment"
+	StockReturn      StockMovementType = "return"
+	StockTransfer    StockMovementType = "transfer"
+)
+
+const (
+	StockReferenceInvoice       = "invoice"
+	StockReferenceWorkOrder     = "work_order"
+	StockReferencePurchaseOrder = "purchase_order"
+	StockReferenceCreditNote    = "credit_note"
+)
+
+type StockLocationNew struct {
+	Name string `json:"name" bson:"name" validate:"required" example:"Main warehouse"`
+	// Default locations receive the movements of sales and work orders, an organisation has exactly one
+	Default bool `json:"default" bson:"default" example:"false"`
+}
+
+type StockLocation struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name           string             `json:"name" bson:"name" example:"Main warehouse"`
+	Default        bool               `json:"default" bson:"default"`
+	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// StockMovementNew is a manual movement. Receipts and returns add the quantity, adjustments add or remove it by sign.
+type StockMovementNew struct {
+	Type       StockMovementType `json:"type" bson:"type" validate:"required,oneof=receipt return adjustment" example:"receipt"`
+	ItemID     string            `json:"item_id" bson:"item_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	LocationID string            `json:"location_id" bson:"location_id" example:"64b7f0c2e4b0a1a2b3c4d5e7"`
+	Quantity   float64           `json:"quantity" bson:"quantity" validate:"required,ne=0" example:"10"`
+	UnitCost   *money.Money      `json:"unit_cost,omitempty" bson:"unit_cost,omitempty" swaggertype:"object"`
+	Note       string            `json:"note" bson:"note" example:"Counted after stocktake"`
+}
+
+type StockTransferNew struct {
+	ItemID         string  `json:"item_id" bson:"item_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	FromLocationID string  `json:"from_location_id" bson:"from_location_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e7"`
+	ToLocationID   string  `json:"to_location_id" bson:"to_location_id" validate:"required,nefield=FromLocationID" example:"64b7f0c2e4b0a1a2b3c4d5e8"`
+	Quantity       float64 `json:"quantity" bson:"quantity" validate:"gt=0" example:"2"`
+	Note           string  `json:"note" bson:"note" example:"Van stock"`
+}
+
+// StockReference points at the document a movement was made for
+type StockReference struct {
+	Type string             `json:"type" bson:"type" example:"invoice"`
+	ID   primitive.ObjectID `json:"_id" bson:"_id"`
+}
+
+// StockMovement is an entry of the stock ledger. Entries are never changed or deleted, mistakes are corrected with
+// another movement.
+type StockMovement struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Type       StockMovementType  `json:"type" bson:"type"`
+	ItemID     primitive.ObjectID `json:"item_id" bson:"item_id"`
+	LocationID primitive.ObjectID `json:"location_id" bson:"location_id"`
+	// Quantity is positive for stock coming in and negative for stock going out
+	Quantity       float64             `json:"quantity" bson:"quantity"`
+	BalanceAfter   float64             `json:"balance_after" bson:"balance_after"`
+	UnitCost       *money.Money        `json:"unit_cost,omitempty" bson:"unit_cost,omitempty"`
+	TransferID     *primitive.ObjectID `json:"transfer_id,omitempty" bson:"transfer_id,omitempty"`
+	Reference      *StockReference     `json:"reference,omitempty" bson:"reference,omitempty"`
+	Note           string              `json:"note,omitempty" bson:"note,omitempty"`
+	UserID         primitive.ObjectID  `json:"user_id" bson:"user_id"`
+	CreatedAt      time.Time           `json:"created_at" bson:"created_at"`
+	OrganisationID primitive.ObjectID  `json:"organisation_id" bson:"organisation_id" validate:"required"`
+}
+
+// StockBalance caches the sum of the ledger of an item at a location
+type StockBalance struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	ItemID         primitive.ObjectID `json:"item_id" bson:"item_id"`
+	LocationID     primitive.ObjectID `json:"location_id" bson:"location_id"`
+	OnHand         float64            `json:"on_hand" bson:"on_hand"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+}
+
+// StockMovementFilter narrows a ledger listing, empty fields match every movement
+type StockMovementFilter struct {
+	ItemID     *primitive.ObjectID
+	LocationID *primitive.ObjectID
+	Type       StockMovementType
+}
+
+// StockBalanceFilter narrows a balance listing, empty fields match every balance
+type StockBalanceFilter struct {
+	ItemID     *primitive.ObjectID
+	LocationID *primitive.ObjectID
+}
+
+// IsValidStockMovementType reports whether the type is one of the movement types
+func IsValidStockMovementType(movementType StockMovementType) bool {
+	switch movementType {
+	case StockReceipt, StockSale, StockConsumption, StockAdjustment, StockReturn, StockTransfer:
+		return true
+	}
+	return false
+}
+
+// RoundQuantity rounds a quantity to thousandths, so summing fractional quantities such as litres of oil does not
+// leave float residue in the balances
+func RoundQuantity(quantity float64) float64 {
+	return math.Round(quantity*1000) / 1000
+}
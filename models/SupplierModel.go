@@ -0,0 +1,36 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type SupplierNew struct {
+	Name  string `json:"name" bson:"name" validate:"required" example:"Auto Parts Wholesale"`
+	Email string `json:"email" bson:"email" validate:"omitempty,email" example:"orders@example.com"`
+	Phone string `json:"phone" bson:"phone" example:"+38761000000"`
+	// ContactName is the person orders are placed with
+	ContactName string `json:"contact_name" bson:"contact_name" example:"Amra Hodžić"`
+	Address     string `json:"address" bson:"address" example:"Zmaja od Bosne 12, Sarajevo"`
+	// AccountNumber is the organisation's customer account with the supplier
+	AccountNumber string `json:"account_number" bson:"account_number" example:"C-10442"`
+	// PaymentTermsDays is the number of days invoices of the supplier are due after, 0 is payment on delivery
+	PaymentTermsDays int `json:"payment_terms_days" bson:"payment_terms_days" validate:"min=0" example:"30"`
+	// LeadTimeDays is the usual number of days between sending an order and receiving the goods
+	LeadTimeDays int `json:"lead_time_days" bson:"lead_time_days" validate:"min=0" example:"3"`
+}
+
+type Supplier struct {
+	ID               primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name             string             `json:"name" bson:"name" example:"Auto Parts Wholesale"`
+	Email            string             `json:"email,omitempty" bson:"email,omitempty" example:"orders@example.com"`
+	Phone            string             `json:"phone,omitempty" bson:"phone,omitempty" example:"+38761000000"`
+	ContactName      string             `json:"contact_name,omitempty" bson:"contact_name,omitempty"`
+	Address          string             `json:"address,omitempty" bson:"address,omitempty"`
+	AccountNumber    string             `json:"account_number,omitempty" bson:"account_number,omitempty"`
+	PaymentTermsDays int                `json:"payment_terms_days" bson:"payment_terms_days"`
+	LeadTimeDays     int                `json:"lead_time_days" bson:"lead_time_days"`
+	OrganisationID   primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt        time.Time          `json:"updated_at" bson:"updated_at"`
+	CreatedAt        time.Time          `json:"created_at" bson:"created_at"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/tax"
+	"time"
+)
+
+type TaxRateNew struct {
+	Name    string  `json:"name" bson:"name" validate:"required" example:"Standard VAT"`
+	Percent float64 `json:"percent" bson:"percent" validate:"min=0,max=100" example:"17"`
+	// Default rates apply to items and lines without a tax rate of their own, an organisation has at most one
+	Default bool `json:"default" bson:"default" example:"true"`
+}
+
+type TaxRate struct {
+	ID             primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	Name           string             `json:"name" bson:"name" example:"Standard VAT"`
+	Percent        float64            `json:"percent" bson:"percent" example:"17"`
+	Default        bool               `json:"default" bson:"default" example:"true"`
+	OrganisationID primitive.ObjectID `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt      time.Time          `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Rate returns the rate lines are taxed at, lines without any tax rate are taxed at the zero rate
+func (rate *TaxRate) Rate() tax.Rate {
+	if rate == nil {
+		return tax.Rate{}
+	}
+	return tax.Rate{Key: rate.ID.Hex(), Name: rate.Name, Percent: rate.Percent}
+}
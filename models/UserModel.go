@@ -9,7 +9,7 @@ type UserPublic struct {
 	Email          string              `json:"email" bson:"email" validate:"required"`
 	Location       string              `json:"location" bson:"location" validate:"required"`
 	OrganisationID *primitive.ObjectID `json:"organisation_id,omitempty" bson:"organisation_id,omitempty"`
-	RoleID         int                 `json:"role_id,omitempty" bson:"role_id,omitempty"`
+	Role           string              `json:"role,omitempty" bson:"role,omitempty"`
 }
 
 type UserNew struct {
@@ -28,6 +28,16 @@ type User struct {
 	Password       string              `json:"password" bson:"password" validate:"required"`
 	Location       string              `json:"location" bson:"location" validate:"required"`
 	OrganisationID *primitive.ObjectID `json:"organisation_id,omitempty"  bson:"organisation_id,omitempty"`
-	RoleID         int                 `json:"role_id,omitempty" bson:"role_id,omitempty"`
+	Role           string              `json:"role,omitempty" bson:"role,omitempty"`
+	Invitations    []MemberInvitation  `json:"-" bson:"invitations,omitempty"`
 	UpdatedAt      primitive.DateTime  `json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
+
+// MemberInvitation offers a user a role in an organisation, the user joins only once they accept it
+type MemberInvitation struct {
+	OrganisationID   primitive.ObjectID `json:"organisation_id" bson:"organisation_id"`
+	OrganisationName string             `json:"organisation_name" bson:"organisation_name"`
+	Role             string             `json:"role" bson:"role"`
+	InvitedBy        primitive.ObjectID `json:"invited_by" bson:"invited_by"`
+	InvitedAt        primitive.DateTime `json:"invited_at" bson:"invited_at"`
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
+
+const (
+	FuelTypePetrol   = "petrol"
+	FuelTypeDiesel   = "diesel"
+	FuelTypeHybrid   = "hybrid"
+	FuelTypeElectric = "electric"
+	FuelTypeLPG      = "lpg"
+	FuelTypeCNG      = "cng"
+	FuelTypeOther    = "other"
+)
+
+const (
+	OdometerUnitKilometres = "km"
+	OdometerUnitMiles      = "mi"
+)
+
+type VehicleNew struct {
+	CustomerID string              `json:"customer_id" bson:"customer_id" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	VIN        string              `json:"vin" bson:"vin" validate:"required_without=Plate" example:"1HGCM82633A004352"`
+	Plate      string              `json:"plate" bson:"plate" validate:"required_without=VIN" example:"A12-B-345"`
+	Make       string              `json:"make" bson:"make" example:"Honda"`
+	Model      string              `json:"model" bson:"model" example:"Accord"`
+	Year       int                 `json:"year" bson:"year" validate:"omitempty,min=1886,max=2100" example:"2003"`
+	Engine     string              `json:"engine" bson:"engine" example:"2.4 i-VTEC"`
+	FuelType   string              `json:"fuel_type" bson:"fuel_type" validate:"omitempty,oneof=petrol diesel hybrid electric lpg cng other" example:"petrol"`
+	Odometer   *OdometerReadingNew `json:"odometer,omitempty" bson:"odometer,omitempty"`
+}
+
+type OdometerReadingNew struct {
+	Value int    `json:"value" bson:"value" validate:"min=0" example:"154000"`
+	Unit  string `json:"unit" bson:"unit" validate:"required,oneof=km mi" example:"km"`
+}
+
+type OdometerReading struct {
+	Value      int                `json:"value" bson:"value"`
+	Unit       string             `json:"unit" bson:"unit"`
+	RecordedBy primitive.ObjectID `json:"recorded_by" bson:"recorded_by"`
+	RecordedAt time.Time          `json:"recorded_at" bson:"recorded_at"`
+}
+
+type Vehicle struct {
+	ID               primitive.ObjectID  `json:"_id,omitempty" bson:"_id,omitempty"`
+	CustomerID       *primitive.ObjectID `json:"customer_id,omitempty" bson:"customer_id,omitempty"`
+	VIN              string              `json:"vin" bson:"vin"`
+	Plate            string              `json:"plate" bson:"plate"`
+	PlateNormalized  string              `json:"-" bson:"plate_normalized"`
+	Make             string              `json:"make" bson:"make"`
+	Model            string              `json:"model" bson:"model"`
+	Year             int                 `json:"year" bson:"year"`
+	Engine           string              `json:"engine" bson:"engine"`
+	FuelType         string              `json:"fuel_type" bson:"fuel_type"`
+	OdometerReadings []OdometerReading   `json:"odometer_readings" bson:"odometer_readings"`
+	OrganisationID   primitive.ObjectID  `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt        time.Time           `json:"updated_at" bson:"updated_at"`
+	CreatedAt        time.Time           `json:"created_at" bson:"created_at"`
+}
+
+// VehicleFilter narrows a vehicle listing, empty fields match every vehicle
+type VehicleFilter struct {
+	CustomerID *primitive.ObjectID
+	VIN        string
+	Plate      string
+}
+
+// NormalizePlate upper-cases a licence plate and removes separators so plates match however they were typed
+func NormalizePlate(plate string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '.' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(plate))
+}
+
+// Kilometres returns the reading in kilometres so readings in different units can be compared
+func (reading OdometerReading) Kilometres() float64 {
+	if reading.Unit == OdometerUnitMiles {
+		return float64(reading.Value) * 1.609344
+	}
+	return float64(reading.Value)
+}
+
+// LastOdometerReading returns the most recent reading or nil if none was recorded
+func (vehicle *Vehicle) LastOdometerReading() *OdometerReading {
+	if len(vehicle.OdometerReadings) == 0 {
+		return nil
+	}
+	return &vehicle.OdometerReadings[len(vehicle.OdometerReadings)-1]
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/money"
+	"time"
+)
+
+type WorkOrderStatus string
+
+const (
+	WorkOrderDraft        WorkOrderStatus = "draft"
+	WorkOrderEstimated    WorkOrderStatus = "estimated"
+	WorkOrderApproved     WorkOrderStatus = "approved"
+	WorkOrderInProgress   WorkOrderStatus = "in_progress"
+	WorkOrderWaitingParts WorkOrderStatus = "waiting_parts"
+	WorkOrderCompleted    WorkOrderStatus = "completed"
+	WorkOrderInvoiced     WorkOrderStatus = "invoiced"
+	WorkOrderClosed       WorkOrderStatus = "closed"
+)
+
+// WorkOrderTransitions lists the statuses a work order may move to from each status
+var WorkOrderTransitions = map[WorkOrderStatus][]WorkOrderStatus{
+	WorkOrderDraft:        {WorkOrderEstimated},
+	WorkOrderEstimated:    {WorkOrderApproved, WorkOrderDraft},
+	WorkOrderApproved:     {WorkOrderInProgress},
+	WorkOrderInProgress:   {WorkOrderWaitingParts, WorkOrderCompleted},
+	WorkOrderWaitingParts: {WorkOrderInProgress},
+	WorkOrderCompleted:    {WorkOrderInvoiced},
+	WorkOrderInvoiced:     {WorkOrderClosed},
+	WorkOrderClosed:       {},
+}
+
+const (
+	WorkOrderLinePart  = "part"
+	WorkOrderLineLabor = "labor"
+)
+
+type WorkOrderLineNew struct {
+	Type        string       `json:"type" bson:"type" validate:"required,oneof=part labor" example:"part"`
+	ItemID      string       `json:"item_id" bson:"item_id" validate:"required_if=Type part" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	Description string       `json:"description" bson:"description" validate:"required_if=Type labor" example:"Replace front brake pads"`
+	Quantity    float64      `json:"quantity" bson:"quantity" validate:"gt=0" example:"1.5"`
+	UnitPrice   *money.Money `json:"unit_price,omitempty" bson:"unit_price,omitempty" validate:"required_if=Type labor" swaggertype:"object"`
+}
+
+type WorkOrderNew struct {
+	CustomerID    string             `json:"customer_id" bson:"customer_id" validate:"required" example:"64b7f0c2e4b0a1a2b3c4d5e6"`
+	VehicleID     string             `json:"vehicle_id" bson:"vehicle_id" example:"64b7f0c2e4b0a1a2b3c4d5e7"`
+	Complaints    []string           `json:"complaints" bson:"complaints" validate:"dive,required" example:"Squeaking when braking"`
+	Lines         []WorkOrderLineNew `json:"lines" bson:"lines" validate:"dive"`
+	TechnicianIDs []string           `json:"technician_ids" bson:"technician_ids" validate:"dive,required" example:"64b7f0c2e4b0a1a2b3c4d5e8"`
+	Notes         string             `json:"notes" bson:"notes" example:"Customer waits in the lounge"`
+}
+
+type WorkOrderLine struct {
+	ID          primitive.ObjectID  `json:"_id" bson:"_id"`
+	Type        string              `json:"type" bson:"type"`
+	ItemID      *primitive.ObjectID `json:"item_id,omitempty" bson:"item_id,omitempty"`
+	Description string              `json:"description" bson:"description"`
+	Quantity    float64             `json:"quantity" bson:"quantity"`
+	UnitPrice   money.Money         `json:"unit_price" bson:"unit_price" swaggertype:"object"`
+}
+
+type WorkOrderTransition struct {
+	Status  WorkOrderStatus `json:"status" bson:"status" validate:"required" example:"approved"`
+	Comment string          `json:"comment" bson:"comment" example:"Approved by phone"`
+}
+
+type WorkOrderStatusChange struct {
+	From      WorkOrderStatus    `json:"from" bson:"from"`
+	To        WorkOrderStatus    `json:"to" bson:"to"`
+	Comment   string             `json:"comment,omitempty" bson:"comment,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	ChangedAt time.Time          `json:"changed_at" bson:"changed_at"`
+}
+
+type WorkOrder struct {
+	ID             primitive.ObjectID      `json:"_id,omitempty" bson:"_id,omitempty"`
+	CustomerID     primitive.ObjectID      `json:"customer_id" bson:"customer_id"`
+	VehicleID      *primitive.ObjectID     `json:"vehicle_id,omitempty" bson:"vehicle_id,omitempty"`
+	Complaints     []string                `json:"complaints" bson:"complaints"`
+	Lines          []WorkOrderLine         `json:"lines" bson:"lines"`
+	TechnicianIDs  []primitive.ObjectID    `json:"technician_ids" bson:"technician_ids"`
+	Notes          string                  `json:"notes" bson:"notes"`
+	Status         WorkOrderStatus         `json:"status" bson:"status"`
+	History        []WorkOrderStatusChange `json:"history" bson:"history"`
+	CreatedBy      primitive.ObjectID      `json:"created_by" bson:"created_by"`
+	OrganisationID primitive.ObjectID      `json:"organisation_id" bson:"organisation_id" validate:"required"`
+	UpdatedAt      time.Time               `json:"updated_at" bson:"updated_at"`
+	CreatedAt      time.Time               `json:"created_at" bson:"created_at"`
+}
+
+// WorkOrderFilter narrows a work order listing, empty fields match every work order
+type WorkOrderFilter struct {
+	Status     WorkOrderStatus
+	CustomerID *primitive.ObjectID
+	VehicleID  *primitive.ObjectID
+}
+
+// CanTransition reports whether a work order may move from one status to the other
+func CanTransition(from WorkOrderStatus, to WorkOrderStatus) bool {
+	for _, allowed := range WorkOrderTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidWorkOrderStatus reports whether the status is part of the work order state machine
+func IsValidWorkOrderStatus(status WorkOrderStatus) bool {
+	_, ok := WorkOrderTransitions[status]
+	return ok
+}
+
+// Editable reports whether the content of the work order may still change. Once the work is completed it is fixed
+// for invoicing.
+func (workOrder *WorkOrder) Editable() bool {
+	switch workOrder.Status {
+	case WorkOrderCompleted, WorkOrderInvoiced, WorkOrderClosed:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,353 @@
+// Package money holds amounts as integer minor units of an ISO 4217 currency, so prices and totals never suffer from
+// binary floating point or free-form strings.
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/biter777/countries"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidAmount    = errors.New("invalid amount")
+	ErrUnknownCurrency  = errors.New("unknown currency")
+	ErrCurrencyMismatch = errors.New("currencies do not match")
+	ErrOverflow         = errors.New("amount out of range")
+)
+
+// Money is an amount in minor units of a currency, e.g. {1250 EUR} is 12.50 EUR and {1250 JPY} is 1250 JPY
+type Money struct {
+	Amount   int64  `bson:"amount"`
+	Currency string `bson:"currency"`
+	// decimal holds an amount that was given without a currency until In resolves it
+	decimal string
+}
+
+// Exponent returns the number of decimal digits of the ISO 4217 currency
+func Exponent(currency string) (int, error) {
+	code := countries.CurrencyCodeByName(currency)
+	if !code.IsValid() || code.Digits() < 0 {
+		return 0, ErrUnknownCurrency
+	}
+	return code.Digits(), nil
+}
+
+// CurrencyAlpha returns the alphabetic code of a numeric ISO 4217 currency code, e.g. 978 is EUR
+func CurrencyAlpha(numeric int) (string, error) {
+	code := countries.CurrencyCode(numeric)
+	if !code.IsValid() || code.Digits() < 0 {
+		return "", ErrUnknownCurrency
+	}
+	return code.Alpha(), nil
+}
+
+// New returns the amount in minor units of the currency
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: strings.ToUpper(currency)}
+}
+
+// Zero returns nothing of the currency
+func Zero(currency string) Money {
+	return New(0, currency)
+}
+
+// Parse parses a decimal amount such as "12.50" of the currency. It fails if the amount has more decimals than the
+// currency has minor units.
+func Parse(amount string, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	exponent, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	value, ok := parseDecimal(amount)
+	if !ok {
+		return Money{}, ErrInvalidAmount
+	}
+	value.Mul(value, scale(exponent))
+	if !value.IsInt() {
+		return Money{}, ErrInvalidAmount
+	}
+	if !value.Num().IsInt64() {
+		return Money{}, ErrOverflow
+	}
+	return New(value.Num().Int64(), currency), nil
+}
+
+// FromRat rounds an exact major unit value half to even to minor units of the currency
+func FromRat(value *big.Rat, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	exponent, err := Exponent(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	minor, err := roundHalfEven(new(big.Rat).Mul(value, scale(exponent)))
+	if err != nil {
+		return Money{}, err
+	}
+	return New(minor, currency), nil
+}
+
+// In returns the amount in the currency. Amounts given without a currency take it on, amounts given with another
+// currency fail with ErrCurrencyMismatch.
+func (m Money) In(currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	if m.decimal != "" {
+		return Parse(m.decimal, currency)
+	}
+	if m.Currency == "" {
+		return Money{}, ErrInvalidAmount
+	}
+	if m.Currency != currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return m, nil
+}
+
+// IsZero reports whether the amount is nothing
+func (m Money) IsZero() bool {
+	return m.Amount == 0 && m.decimal == ""
+}
+
+// IsNegative reports whether the amount is below zero
+func (m Money) IsNegative() bool {
+	if m.decimal != "" {
+		return strings.HasPrefix(m.decimal, "-")
+	}
+	return m.Amount < 0
+}
+
+// Neg returns the amount with the opposite sign
+func (m Money) Neg() Money {
+	return New(-m.Amount, m.Currency)
+}
+
+// Add returns the sum of two amounts of the same currency
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	sum := m.Amount + other.Amount
+	if (other.Amount > 0 && sum < m.Amount) || (other.Amount < 0 && sum > m.Amount) {
+		return Money{}, ErrOverflow
+	}
+	return New(sum, m.Currency), nil
+}
+
+// Sub returns the difference of two amounts of the same currency
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Amount == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+	return m.Add(other.Neg())
+}
+
+// Mul multiplies the amount by an exact factor and rounds half to even to whole minor units
+func (m Money) Mul(factor *big.Rat) (Money, error) {
+	minor, err := roundHalfEven(new(big.Rat).Mul(new(big.Rat).SetInt64(m.Amount), factor))
+	if err != nil {
+		return Money{}, err
+	}
+	return New(minor, m.Currency), nil
+}
+
+// Allocate splits the amount in proportion to the ratios without losing a minor unit. Remainders go to the parts with
+// the largest fractions first, so the parts always add up to the amount.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	total := new(big.Int)
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, ErrInvalidAmount
+		}
+		total.Add(total, big.NewInt(ratio))
+	}
+	if total.Sign() == 0 {
+		return nil, ErrInvalidAmount
+	}
+
+	parts := make([]Money, len(ratios))
+	remainders := make([]*big.Int, len(ratios))
+	allocated := new(big.Int)
+	amount := big.NewInt(m.Amount)
+	for i, ratio := range ratios {
+		share, remainder := new(big.Int).QuoRem(new(big.Int).Mul(amount, big.NewInt(ratio)), total, new(big.Int))
+		parts[i] = New(share.Int64(), m.Currency)
+		remainders[i] = remainder.Abs(remainder)
+		allocated.Add(allocated, share)
+	}
+
+	step := int64(1)
+	if m.Amount < 0 {
+		step = -1
+	}
+	for left := new(big.Int).Sub(amount, allocated).Int64() * step; left > 0; left-- {
+		largest := 0
+		for i := range remainders {
+			if remainders[i].Cmp(remainders[largest]) > 0 {
+				largest = i
+			}
+		}
+		parts[largest].Amount += step
+		remainders[largest].SetInt64(-1)
+	}
+	return parts, nil
+}
+
+// Split splits the amount into n parts that differ by at most one minor unit
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Decimal formats the amount in major units with the currency's decimals, e.g. "12.50"
+func (m Money) Decimal() string {
+	if m.decimal != "" {
+		return m.decimal
+	}
+	exponent, err := Exponent(m.Currency)
+	if err != nil {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+
+	sign, minor := "", new(big.Int).SetInt64(m.Amount)
+	if minor.Sign() < 0 {
+		sign = "-"
+		minor.Neg(minor)
+	}
+	text := minor.String()
+	if exponent == 0 {
+		return sign + text
+	}
+	if len(text) <= exponent {
+		text = strings.Repeat("0", exponent-len(text)+1) + text
+	}
+	return sign + text[:len(text)-exponent] + "." + text[len(text)-exponent:]
+}
+
+// String formats the amount with its currency, e.g. "12.50 EUR"
+func (m Money) String() string {
+	return strings.TrimSpace(m.Decimal() + " " + m.Currency)
+}
+
+type moneyJSON struct {
+	Amount   json.RawMessage `json:"amount"`
+	Currency string          `json:"currency"`
+}
+
+// MarshalJSON writes the amount as {"amount": "12.50", "currency": "EUR"}
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Amount   string `json:"amount"`
+		Currency string `json:"currency"`
+	}{m.Decimal(), m.Currency})
+}
+
+// UnmarshalJSON reads "12.50", 12.5 or {"amount": "12.50", "currency": "EUR"}. Amounts without a currency are only
+// checked to be decimals here and get their minor units when In is called with the currency they are meant in.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	input := moneyJSON{Amount: data}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &input); err != nil {
+			return err
+		}
+	}
+
+	amount, err := decimalJSON(input.Amount)
+	if err != nil {
+		return err
+	}
+	if input.Currency == "" {
+		*m = Money{decimal: amount}
+		return nil
+	}
+	parsed, err := Parse(amount, input.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// decimalJSON returns the text of a JSON string or number holding a decimal amount
+func decimalJSON(raw json.RawMessage) (string, error) {
+	var amount string
+	if len(raw) > 0 && raw[0] == '"' {
+		if err := json.Unmarshal(raw, &amount); err != nil {
+			return "", err
+		}
+	} else {
+		var number json.Number
+		if err := json.Unmarshal(raw, &number); err != nil {
+			return "", ErrInvalidAmount
+		}
+		amount = number.String()
+	}
+
+	amount = strings.TrimSpace(amount)
+	if _, ok := parseDecimal(amount); !ok {
+		return "", ErrInvalidAmount
+	}
+	return amount, nil
+}
+
+// parseDecimal parses plain decimal notation only, rejecting fractions, exponents and the like that big.Rat accepts
+func parseDecimal(amount string) (*big.Rat, bool) {
+	amount = strings.TrimSpace(amount)
+	digits := strings.TrimPrefix(amount, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" || strings.Trim(whole+fraction, "0123456789") != "" {
+		return nil, false
+	}
+	return new(big.Rat).SetString(amount)
+}
+
+// Ratio returns the exact decimal value of a quantity or percentage, avoiding the binary representation error of the float
+func Ratio(value float64) *big.Rat {
+	ratio, _ := new(big.Rat).SetString(strconv.FormatFloat(value, 'f', -1, 64))
+	return ratio
+}
+
+// Percent returns the exact fraction of a percentage, e.g. 17 is 17/100
+func Percent(value float64) *big.Rat {
+	return new(big.Rat).Quo(Ratio(value), big.NewRat(100, 1))
+}
+
+func scale(exponent int) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil))
+}
+
+// roundHalfEven rounds to the nearest integer, ties to the even neighbour, so rounding errors do not drift in one direction
+func roundHalfEven(value *big.Rat) (int64, error) {
+	quotient, remainder := new(big.Int).QuoRem(value.Num(), value.Denom(), new(big.Int))
+	twice := new(big.Int).Abs(remainder)
+	twice.Lsh(twice, 1)
+
+	switch twice.Cmp(value.Denom()) {
+	case 1:
+		quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+	case 0:
+		if quotient.Bit(0) == 1 {
+			quotient.Add(quotient, big.NewInt(int64(value.Sign())))
+		}
+	}
+	if !quotient.IsInt64() {
+		return 0, ErrOverflow
+	}
+	return quotient.Int64(), nil
+}
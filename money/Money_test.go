@@ -0,0 +1,236 @@
+package money_test
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"poosible-backend/money"
+	"reflect"
+	"testing"
+)
+
+func TestFromRatRoundsHalfToEven(t *testing.T) {
+	tests := []struct {
+		value    *big.Rat
+		currency string
+		want     int64
+	}{
+		{big.NewRat(1005, 1000), "EUR", 100},
+		{big.NewRat(1015, 1000), "EUR", 102},
+		{big.NewRat(1025, 1000), "EUR", 102},
+		{big.NewRat(10051, 10000), "EUR", 101},
+		{big.NewRat(10049, 10000), "EUR", 100},
+		{big.NewRat(-1005, 1000), "EUR", -100},
+		{big.NewRat(-1015, 1000), "EUR", -102},
+		{big.NewRat(-10051, 10000), "EUR", -101},
+		{big.NewRat(5, 2), "JPY", 2},
+		{big.NewRat(7, 2), "JPY", 4},
+		{big.NewRat(1, 3), "EUR", 33},
+		{big.NewRat(2, 3), "EUR", 67},
+	}
+	for _, test := range tests {
+		got, err := money.FromRat(test.value, test.currency)
+		if err != nil {
+			t.Fatalf("%s %s: %v", test.value.FloatString(4), test.currency, err)
+		}
+		if got.Amount != test.want || got.Currency != test.currency {
+			t.Errorf("%s %s rounded to %v, want %d", test.value.FloatString(4), test.currency, got, test.want)
+		}
+	}
+}
+
+func TestMulRoundsHalfToEven(t *testing.T) {
+	tests := []struct {
+		amount int64
+		factor *big.Rat
+		want   int64
+	}{
+		{1050, money.Percent(10), 105},
+		{25, money.Percent(50), 12},
+		{35, money.Percent(50), 18},
+		{-25, money.Percent(50), -12},
+		{-35, money.Percent(50), -18},
+		{999, money.Ratio(1.5), 1498},
+		{1999, money.Percent(25), 500},
+	}
+	for _, test := range tests {
+		got, err := money.New(test.amount, "EUR").Mul(test.factor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Amount != test.want {
+			t.Errorf("%d × %s is %d, want %d", test.amount, test.factor.RatString(), got.Amount, test.want)
+		}
+	}
+}
+
+func TestAllocateDistributesRemainders(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		ratios []int64
+		want   []int64
+	}{
+		{"even thirds go to the first part", 100, []int64{1, 1, 1}, []int64{34, 33, 33}},
+		{"largest fraction first", 100, []int64{1, 2}, []int64{33, 67}},
+		{"two remainders", 101, []int64{1, 1, 1}, []int64{34, 34, 33}},
+		{"largest fraction beyond the first parts", 10, []int64{3, 3, 4}, []int64{3, 3, 4}},
+		{"fractions of uneven ratios", 5, []int64{1, 3, 3}, []int64{1, 2, 2}},
+		{"negative amounts", -100, []int64{1, 1, 1}, []int64{-34, -33, -33}},
+		{"negative largest fraction", -100, []int64{1, 2}, []int64{-33, -67}},
+		{"zero ratios get nothing", 100, []int64{0, 1, 0}, []int64{0, 100, 0}},
+		{"nothing to allocate", 0, []int64{1, 2}, []int64{0, 0}},
+		{"less than one unit per part", 2, []int64{1, 1, 1, 1}, []int64{1, 1, 0, 0}},
+	}
+	for _, test := range tests {
+		parts, err := money.New(test.amount, "EUR").Allocate(test.ratios...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		got := make([]int64, len(parts))
+		var sum int64
+		for i, part := range parts {
+			got[i] = part.Amount
+			sum += part.Amount
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: allocated %v, want %v", test.name, got, test.want)
+		}
+		if sum != test.amount {
+			t.Errorf("%s: parts add up to %d, want %d", test.name, sum, test.amount)
+		}
+	}
+
+	for _, ratios := range [][]int64{{}, {0, 0}, {1, -1}} {
+		if _, err := money.New(100, "EUR").Allocate(ratios...); !errors.Is(err, money.ErrInvalidAmount) {
+			t.Errorf("allocating by %v answered %v, want %v", ratios, err, money.ErrInvalidAmount)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"12.50", "EUR", 1250, nil},
+		{"12.5", "eur", 1250, nil},
+		{"12", "EUR", 1200, nil},
+		{"-0.05", "EUR", -5, nil},
+		{" 3.10 ", "EUR", 310, nil},
+		{"1250", "JPY", 1250, nil},
+		{"12.505", "EUR", 0, money.ErrInvalidAmount},
+		{"12.5", "JPY", 0, money.ErrInvalidAmount},
+		{"1e3", "EUR", 0, money.ErrInvalidAmount},
+		{"1/2", "EUR", 0, money.ErrInvalidAmount},
+		{".5", "EUR", 0, money.ErrInvalidAmount},
+		{"", "EUR", 0, money.ErrInvalidAmount},
+		{"99999999999999999999", "EUR", 0, money.ErrOverflow},
+		{"1", "ZZZ", 0, money.ErrUnknownCurrency},
+	}
+	for _, test := range tests {
+		got, err := money.Parse(test.amount, test.currency)
+		if !errors.Is(err, test.err) {
+			t.Errorf("parsing %q %s answered %v, want %v", test.amount, test.currency, err, test.err)
+			continue
+		}
+		if err == nil && got.Amount != test.want {
+			t.Errorf("parsed %q %s as %d, want %d", test.amount, test.currency, got.Amount, test.want)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		money money.Money
+		want  string
+	}{
+		{money.New(1250, "EUR"), "12.50"},
+		{money.New(5, "EUR"), "0.05"},
+		{money.New(-5, "EUR"), "-0.05"},
+		{money.New(0, "EUR"), "0.00"},
+		{money.New(1250, "JPY"), "1250"},
+	}
+	for _, test := range tests {
+		if got := test.money.Decimal(); got != test.want {
+			t.Errorf("%d %s formatted as %q, want %q", test.money.Amount, test.money.Currency, got, test.want)
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		currency string
+		want     int64
+		err      error
+	}{
+		{"legacy decimal string", `"12.50"`, "EUR", 1250, nil},
+		{"legacy decimal string in a currency without minor units", `"1250"`, "JPY", 1250, nil},
+		{"number", `12.5`, "EUR", 1250, nil},
+		{"object", `{"amount": "12.50", "currency": "eur"}`, "EUR", 1250, nil},
+		{"object with a number", `{"amount": 12.5, "currency": "EUR"}`, "EUR", 1250, nil},
+		{"legacy decimal string with more decimals than the currency", `"12.505"`, "EUR", 0, money.ErrInvalidAmount},
+		{"object in another currency", `{"amount": "12.50", "currency": "USD"}`, "EUR", 0, money.ErrCurrencyMismatch},
+	}
+	for _, test := range tests {
+		var got money.Money
+		if err := json.Unmarshal([]byte(test.json), &got); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		in, err := got.In(test.currency)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: In answered %v, want %v", test.name, err, test.err)
+			continue
+		}
+		if err == nil && (in.Amount != test.want || in.Currency != test.currency) {
+			t.Errorf("%s: read %v, want %d %s", test.name, in, test.want, test.currency)
+		}
+	}
+
+	for _, invalid := range []string{`"1e3"`, `"abc"`, `"1/2"`, `true`, `{"amount": "1", "currency": "ZZZ"}`, `{"amount": "1.005", "currency": "EUR"}`} {
+		var got money.Money
+		if err := json.Unmarshal([]byte(invalid), &got); err == nil {
+			t.Errorf("read %s as %v, want an error", invalid, got)
+		}
+	}
+
+	var null money.Money
+	if err := json.Unmarshal([]byte(`null`), &null); err != nil || !null.IsZero() {
+		t.Errorf("read null as %v, %v", null, err)
+	}
+
+	written, err := json.Marshal(money.New(-1250, "EUR"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != `{"amount":"-12.50","currency":"EUR"}` {
+		t.Errorf("wrote %s", written)
+	}
+	var read money.Money
+	if err := json.Unmarshal(written, &read); err != nil || read != money.New(-1250, "EUR") {
+		t.Errorf("read back %s as %v, %v", written, read, err)
+	}
+}
+
+func TestCurrencyMismatch(t *testing.T) {
+	euros, dollars := money.New(100, "EUR"), money.New(100, "USD")
+	if _, err := euros.In("USD"); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("euros in dollars answered %v", err)
+	}
+	if in, err := euros.In("eur"); err != nil || in != euros {
+		t.Errorf("euros in euros answered %v, %v", in, err)
+	}
+	if _, err := (money.Money{Amount: 100}).In("EUR"); !errors.Is(err, money.ErrInvalidAmount) {
+		t.Errorf("an amount without a currency answered %v", err)
+	}
+	if _, err := euros.Add(dollars); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("adding dollars to euros answered %v", err)
+	}
+	if _, err := euros.Sub(dollars); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("subtracting dollars from euros answered %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+// ErrSessionOpen is returned when a user or register that has an open cash session opens another one
+var ErrSessionOpen = errors.New("a cash session is open already")
+
+// ErrSessionClosed is returned when a closed cash session is changed
+var ErrSessionClosed = errors.New("cash session is closed")
+
+// CashSessionRepository stores the till sessions of the users. Closed sessions are never changed.
+type CashSessionRepository interface {
+	// Open stores the session unless its user or register has an open session already
+	Open(ctx context.Context, session *models.CashSession) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.CashSession, error)
+	// FindOpen returns the open session of the user, or ErrNotFound
+	FindOpen(ctx context.Context, organisationID primitive.ObjectID, userID primitive.ObjectID) (*models.CashSession, error)
+	// AddMovement adds the movement to the session unless it was closed, and returns the session with it
+	AddMovement(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, movement models.CashMovement) (*models.CashSession, error)
+	// Close closes the session with its Z-report, which is numbered from the organisation's Z-report sequence, unless
+	// it was closed already
+	Close(ctx context.Context, session *models.CashSession, report *models.CashReport, numbering models.NumberingSettings) error
+	// FindAll returns a page of the matching sessions, newest first unless the page is sorted otherwise
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.CashSessionFilter, page listing.Query) ([]*models.CashSession, listing.Page, error)
+}
+
+// CashSessionSortFields are the fields cash sessions can be listed by
+var CashSessionSortFields = listing.Fields[models.CashSession]{
+	"opened_at": {Key: "opened_at", Value: func(s *models.CashSession) interface{} { return dateTime(s.OpenedAt) }},
+	"register":  {Key: "register", Value: func(s *models.CashSession) interface{} { return s.Register }},
+}
+
+func cashSessionID(document *models.CashSession) primitive.ObjectID {
+	return document.ID
+}
+
+type mongoCashSessionRepository struct {
+	collection *mongo.Collection
+	sequences  *mongo.Collection
+}
+
+func NewMongoCashSessionRepository(collection *mongo.Collection, sequences *mongo.Collection) CashSessionRepository {
+	return &mongoCashSessionRepository{collection: collection, sequences: sequences}
+}
+
+func (r *mongoCashSessionRepository) Open(ctx context.Context, session *models.CashSession) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		//Counting the sessions of the user and the register makes concurrent openings of either conflict
+		for _, lock := range []string{"cash_sessions:user:" + session.UserID.Hex(), "cash_sessions:register:" + session.Register} {
+			if _, err := nextSequence(sessionContext, r.sequences, session.OrganisationID, lock); err != nil {
+				return err
+			}
+		}
+		open, err := scope(r.collection, session.OrganisationID).CountDocuments(sessionContext, bson.M{
+			"status": models.CashSessionOpen,
+			"$or":    bson.A{bson.M{"user_id": session.UserID}, bson.M{"register": session.Register}},
+		})
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			return ErrSessionOpen
+		}
+
+		session.ID = primitive.NilObjectID
+		result, err := scope(r.collection, session.OrganisationID).InsertOne(sessionContext, session)
+		if err != nil {
+			return err
+		}
+		session.ID = result.InsertedID.(primitive.ObjectID)
+		return nil
+	})
+}
+
+func (r *mongoCashSessionRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.CashSession, error) {
+	var session *models.CashSession
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&session)
+	return session, notFound(err)
+}
+
+func (r *mongoCashSessionRepository) FindOpen(ctx context.Context, organisationID primitive.ObjectID, userID primitive.ObjectID) (*models.CashSession, error) {
+	var session *models.CashSession
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"user_id": userID, "status": models.CashSessionOpen}).Decode(&session)
+	return session, notFound(err)
+}
+
+func (r *mongoCashSessionRepository) AddMovement(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, movement models.CashMovement) (*models.CashSession, error) {
+	var session *models.CashSession
+	err := scope(r.collection, organisationID).FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.CashSessionOpen},
+		bson.M{"$push": bson.M{"movements": movement}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&session)
+	if err == mongo.ErrNoDocuments {
+		return nil, r.closedOrMissing(ctx, organisationID, id)
+	}
+	return session, err
+}
+
+func (r *mongoCashSessionRepository) Close(ctx context.Context, session *models.CashSession, report *models.CashReport, numbering models.NumberingSettings) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		value, err := nextSequence(sessionContext, r.sequences, session.OrganisationID, numbering.Sequence("z_reports", report.TakenAt))
+		if err != nil {
+			return err
+		}
+		report.Number = numbering.Format(value, report.TakenAt)
+
+		result, err := scope(r.collection, session.OrganisationID).UpdateOne(sessionContext,
+			bson.M{"_id": session.ID, "status": models.CashSessionOpen},
+			bson.M{"$set": bson.M{"status": models.CashSessionClosed, "closed_at": session.ClosedAt, "closed_by": session.ClosedBy, "notes": session.Notes, "z_report": report}},
+		)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return r.closedOrMissing(sessionContext, session.OrganisationID, session.ID)
+		}
+		session.Status = models.CashSessionClosed
+		session.ZReport = report
+		return nil
+	})
+}
+
+func (r *mongoCashSessionRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.CashSessionFilter, page listing.Query) ([]*models.CashSession, listing.Page, error) {
+	query := bson.M{}
+	if filter.UserID != nil {
+		query["user_id"] = *filter.UserID
+	}
+	if filter.Register != "" {
+		query["register"] = filter.Register
+	}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	return findPage(ctx, scope(r.collection, organisationID), query, page.OrSort("-opened_at"), CashSessionSortFields, cashSessionID)
+}
+
+// closedOrMissing tells apart why a session that has to be open was not matched
+func (r *mongoCashSessionRepository) closedOrMissing(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if _, err := r.FindByID(ctx, organisationID, id); err != nil {
+		return err
+	}
+	return ErrSessionClosed
+}
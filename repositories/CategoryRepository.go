@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/models"
+)
+
+type CategoryRepository interface {
+	Create(ctx context.Context, category *models.Category) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Category, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Category, error)
+	// FindSubtree returns the category followed by every category below it
+	FindSubtree(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) ([]*models.Category, error)
+	Update(ctx context.Context, category *models.Category) error
+	// Move stores the new parent and ancestors of the category, rewrites the ancestors of its subtree and numbers the
+	// siblings, which include the category itself, in the given order, all in one transaction
+	Move(ctx context.Context, category *models.Category, siblings []primitive.ObjectID) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+}
+
+type mongoCategoryRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCategoryRepository(collection *mongo.Collection) CategoryRepository {
+	return &mongoCategoryRepository{collection: collection}
+}
+
+func (r *mongoCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	result, err := scope(r.collection, category.OrganisationID).InsertOne(ctx, category)
+	if err != nil {
+		return err
+	}
+	category.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoCategoryRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Category, error) {
+	var category *models.Category
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&category)
+	return category, notFound(err)
+}
+
+func (r *mongoCategoryRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Category, error) {
+	return r.find(ctx, organisationID, bson.M{})
+}
+
+func (r *mongoCategoryRepository) FindSubtree(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) ([]*models.Category, error) {
+	category, err := r.FindByID(ctx, organisationID, id)
+	if err != nil {
+		return nil, err
+	}
+	descendants, err := r.find(ctx, organisationID, bson.M{"ancestors": id})
+	if err != nil {
+		return nil, err
+	}
+	return append([]*models.Category{category}, descendants...), nil
+}
+
+func (r *mongoCategoryRepository) find(ctx context.Context, organisationID primitive.ObjectID, filter bson.M) ([]*models.Category, error) {
+	categories := []*models.Category{}
+	result, err := scope(r.collection, organisationID).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var category *models.Category
+		if err := result.Decode(&category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, result.Err()
+}
+
+func (r *mongoCategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	result, err := scope(r.collection, category.OrganisationID).ReplaceOne(ctx, bson.M{"_id": category.ID}, category)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoCategoryRepository) Move(ctx context.Context, category *models.Category, siblings []primitive.ObjectID) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		categories := scope(r.collection, category.OrganisationID)
+		for position, id := range siblings {
+			if id == category.ID {
+				category.Position = position
+			}
+		}
+		if err := r.Update(sessionContext, category); err != nil {
+			return err
+		}
+
+		//Every descendant keeps the part of its path below the category and gets the category's new path above it
+		path := append(append([]primitive.ObjectID{}, category.Ancestors...), category.ID)
+		below := bson.M{"$slice": bson.A{
+			"$ancestors",
+			bson.M{"$add": bson.A{bson.M{"$indexOfArray": bson.A{"$ancestors", category.ID}}, 1}},
+			bson.M{"$size": "$ancestors"},
+		}}
+		update := mongo.Pipeline{{{Key: "$set", Value: bson.M{"ancestors": bson.M{"$concatArrays": bson.A{path, below}}}}}}
+		if _, err := categories.UpdateMany(sessionContext, bson.M{"ancestors": category.ID}, update); err != nil {
+			return err
+		}
+
+		for position, id := range siblings {
+			if id == category.ID {
+				continue
+			}
+			if _, err := categories.UpdateOne(sessionContext, bson.M{"_id": id}, bson.M{"$set": bson.M{"position": position}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *mongoCategoryRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"regexp"
+)
+
+type CustomerRepository interface {
+	Create(ctx context.Context, customer *models.Customer) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Customer, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, page listing.Query) ([]*models.Customer, listing.Page, error)
+	// Search returns the customers whose name or email contains the query, or whose phone number contains its digits
+	Search(ctx context.Context, organisationID primitive.ObjectID, query string, page listing.Query) ([]*models.Customer, listing.Page, error)
+	Update(ctx context.Context, customer *models.Customer) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+}
+
+// CustomerReassigner is implemented by repositories of documents that reference a customer, so that merging customers
+// can move those references to the customer that is kept and customers still referenced are not deleted
+type CustomerReassigner interface {
+	ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error
+	// UsesCustomer reports whether any document of the organisation references the customer
+	UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error)
+}
+
+// minPhoneSearchDigits is the number of digits a query needs before it is matched against phone numbers
+const minPhoneSearchDigits = 3
+
+// CustomerSortFields are the fields customers can be listed by
+var CustomerSortFields = listing.Fields[models.Customer]{
+	"name":       {Key: "name", Value: func(c *models.Customer) interface{} { return c.Name }},
+	"created_at": {Key: "created_at", Value: func(c *models.Customer) interface{} { return dateTime(c.CreatedAt) }},
+	"updated_at": {Key: "updated_at", Value: func(c *models.Customer) interface{} { return dateTime(c.UpdatedAt) }},
+}
+
+func customerID(customer *models.Customer) primitive.ObjectID {
+	return customer.ID
+}
+
+type mongoCustomerRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoCustomerRepository(collection *mongo.Collection) CustomerRepository {
+	return &mongoCustomerRepository{collection: collection}
+}
+
+func (r *mongoCustomerRepository) Create(ctx context.Context, customer *models.Customer) error {
+	result, err := scope(r.collection, customer.OrganisationID).InsertOne(ctx, customer)
+	if err != nil {
+		return err
+	}
+	customer.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoCustomerRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Customer, error) {
+	var customer *models.Customer
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&customer)
+	return customer, notFound(err)
+}
+
+func (r *mongoCustomerRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, page listing.Query) ([]*models.Customer, listing.Page, error) {
+	return findPage(ctx, scope(r.collection, organisationID), bson.M{}, page.OrSort("name"), CustomerSortFields, customerID)
+}
+
+func (r *mongoCustomerRepository) Search(ctx context.Context, organisationID primitive.ObjectID, query string, page listing.Query) ([]*models.Customer, listing.Page, error) {
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(query), Options: "i"}
+	conditions := bson.A{
+		bson.M{"name": pattern},
+		bson.M{"email": pattern},
+	}
+	if digits := models.PhoneDigits(query); len(digits) >= minPhoneSearchDigits {
+		conditions = append(conditions, bson.M{"phone_digits": primitive.Regex{Pattern: regexp.QuoteMeta(digits)}})
+	}
+	return findPage(ctx, scope(r.collection, organisationID), bson.M{"$or": conditions}, page.OrSort("name"), CustomerSortFields, customerID)
+}
+
+func (r *mongoCustomerRepository) Update(ctx context.Context, customer *models.Customer) error {
+	result, err := scope(r.collection, customer.OrganisationID).ReplaceOne(ctx, bson.M{"_id": customer.ID}, customer)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoCustomerRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
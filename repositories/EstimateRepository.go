@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+type EstimateRepository interface {
+	Create(ctx context.Context, estimate *models.Estimate) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Estimate, error)
+	// FindByShareToken finds the estimate shared under the token hash in any organisation, for the public link
+	FindByShareToken(ctx context.Context, tokenHash string) (*models.Estimate, error)
+	// FindAll returns a page of the matching estimates, newest first unless the page is sorted otherwise
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.EstimateFilter, page listing.Query) ([]*models.Estimate, listing.Page, error)
+	// Update replaces the estimate if its status and share token hash are still the given ones, so a customer answering
+	// the link never races with the shop changing, sharing again or converting the estimate. Every change the shop makes
+	// to a shared estimate ends its link, so an answer through a link the estimate was read from is an answer to what
+	// the customer saw.
+	Update(ctx context.Context, estimate *models.Estimate, status models.EstimateStatus, shareTokenHash string) error
+	CustomerReassigner
+}
+
+// EstimateSortFields are the fields estimates can be listed by
+var EstimateSortFields = listing.Fields[models.Estimate]{
+	"status":      {Key: "status", Value: func(e *models.Estimate) interface{} { return string(e.Status) }},
+	"valid_until": {Key: "valid_until", Value: func(e *models.Estimate) interface{} { return dateTime(e.ValidUntil) }},
+	"created_at":  {Key: "created_at", Value: func(e *models.Estimate) interface{} { return dateTime(e.CreatedAt) }},
+	"updated_at":  {Key: "updated_at", Value: func(e *models.Estimate) interface{} { return dateTime(e.UpdatedAt) }},
+}
+
+func estimateID(document *models.Estimate) primitive.ObjectID {
+	return document.ID
+}
+
+type mongoEstimateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoEstimateRepository(collection *mongo.Collection) EstimateRepository {
+	return &mongoEstimateRepository{collection: collection}
+}
+
+func (r *mongoEstimateRepository) Create(ctx context.Context, estimate *models.Estimate) error {
+	result, err := scope(r.collection, estimate.OrganisationID).InsertOne(ctx, estimate)
+	if err != nil {
+		return err
+	}
+	estimate.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoEstimateRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Estimate, error) {
+	var estimate *models.Estimate
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&estimate)
+	return estimate, notFound(err)
+}
+
+func (r *mongoEstimateRepository) FindByShareToken(ctx context.Context, tokenHash string) (*models.Estimate, error) {
+	var estimate *models.Estimate
+	err := r.collection.FindOne(ctx, bson.M{"share_token_hash": tokenHash}).Decode(&estimate)
+	return estimate, notFound(err)
+}
+
+func (r *mongoEstimateRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.EstimateFilter, page listing.Query) ([]*models.Estimate, listing.Page, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.CustomerID != nil {
+		query["customer_id"] = *filter.CustomerID
+	}
+	if filter.VehicleID != nil {
+		query["vehicle_id"] = *filter.VehicleID
+	}
+
+	return findPage(ctx, scope(r.collection, organisationID), query, page.OrSort("-created_at"), EstimateSortFields, estimateID)
+}
+
+func (r *mongoEstimateRepository) Update(ctx context.Context, estimate *models.Estimate, status models.EstimateStatus, shareTokenHash string) error {
+	//Estimates that are not shared leave the hash out, which equality with null matches
+	var hash interface{} = shareTokenHash
+	if shareTokenHash == "" {
+		hash = nil
+	}
+	result, err := scope(r.collection, estimate.OrganisationID).ReplaceOne(ctx, bson.M{"_id": estimate.ID, "status": status, "share_token_hash": hash}, estimate)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, estimate.OrganisationID, estimate.ID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *mongoEstimateRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	_, err := scope(r.collection, organisationID).UpdateMany(ctx, bson.M{"customer_id": bson.M{"$in": from}}, bson.M{"$set": bson.M{"customer_id": to}})
+	return err
+}
+
+func (r *mongoEstimateRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"customer_id": customerID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
@@ -0,0 +1,190 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+// ErrNumberingChanged is returned when an invoice is issued with a numbering the organisation has changed since
+var ErrNumberingChanged = errors.New("invoice numbering changed")
+
+// InvoiceRepository stores issued invoices and credit notes. There is deliberately no way to change or delete them,
+// only the customer they link to moves when customers are merged. The buyer printed on them stays as it was issued.
+type InvoiceRepository interface {
+	CustomerReassigner
+	// Issue numbers the invoice from the sequence of its type and stores it. The also callback is called with the
+	// context of the transaction that allocates the number and inserts the invoice, so its writes are part of it and
+	// a failure anywhere leaves no gap in the numbering.
+	Issue(ctx context.Context, invoice *models.Invoice, numbering models.NumberingSettings, also func(ctx context.Context) error) error
+	// IssueCreditNote issues the credit note like Issue, after prepare has filled it in from the earlier credit notes
+	// of its original invoice. Credit notes of the same invoice are issued one after another, so prepare always sees
+	// every earlier one. It may be called more than once and has to fill in the whole credit note each time.
+	IssueCreditNote(ctx context.Context, creditNote *models.Invoice, numbering models.NumberingSettings, prepare func(ctx context.Context, creditNotes []*models.Invoice) error, also func(ctx context.Context) error) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Invoice, error)
+	// FindAll returns a page of the matching invoices, newest first unless the page is sorted otherwise
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.InvoiceFilter, page listing.Query) ([]*models.Invoice, listing.Page, error)
+	// ChangeNumbering records the numbering of the invoices of the organisation and calls change, which stores it,
+	// with whether invoices are issued already. Invoices are not issued while it runs, and Issue fails with
+	// ErrNumberingChanged for invoices numbered otherwise afterwards.
+	ChangeNumbering(ctx context.Context, organisationID primitive.ObjectID, numbering models.NumberingSettings, change func(ctx context.Context, issued bool) error) error
+}
+
+// numberingLock names the sequences document that issuing an invoice and changing the numbering both write, so
+// they run one after another. It keeps the numbering last recorded by ChangeNumbering.
+const numberingLock = "numbering:" + models.InvoiceTypeInvoice
+
+type numberingLockDocument struct {
+	Numbering *models.NumberingSettings `bson:"numbering"`
+}
+
+// InvoiceSortFields are the fields invoices and credit notes can be listed by
+var InvoiceSortFields = listing.Fields[models.Invoice]{
+	"number":    {Key: "number", Value: func(i *models.Invoice) interface{} { return i.Number }},
+	"issued_at": {Key: "issued_at", Value: func(i *models.Invoice) interface{} { return dateTime(i.IssuedAt) }},
+	"total":     {Key: "total.amount", Value: func(i *models.Invoice) interface{} { return i.Total.Amount }},
+}
+
+func invoiceID(document *models.Invoice) primitive.ObjectID {
+	return document.ID
+}
+
+type mongoInvoiceRepository struct {
+	collection *mongo.Collection
+	sequences  *mongo.Collection
+}
+
+func NewMongoInvoiceRepository(collection *mongo.Collection, sequences *mongo.Collection) InvoiceRepository {
+	return &mongoInvoiceRepository{collection: collection, sequences: sequences}
+}
+
+func (r *mongoInvoiceRepository) Issue(ctx context.Context, invoice *models.Invoice, numbering models.NumberingSettings, also func(ctx context.Context) error) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		return r.issue(sessionContext, invoice, numbering, also)
+	})
+}
+
+func (r *mongoInvoiceRepository) IssueCreditNote(ctx context.Context, creditNote *models.Invoice, numbering models.NumberingSettings, prepare func(ctx context.Context, creditNotes []*models.Invoice) error, also func(ctx context.Context) error) error {
+	if creditNote.OriginalInvoiceID == nil {
+		return ErrNotFound
+	}
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		//Counting the credit notes of the invoice makes concurrent credit notes of it write the same document, so all
+		//but one of them conflict and are retried after it
+		if _, err := nextSequence(sessionContext, r.sequences, creditNote.OrganisationID, "credit_notes:"+creditNote.OriginalInvoiceID.Hex()); err != nil {
+			return err
+		}
+		creditNotes, _, err := r.FindAll(sessionContext, creditNote.OrganisationID, models.InvoiceFilter{Type: models.InvoiceTypeCreditNote, OriginalInvoiceID: creditNote.OriginalInvoiceID}, listing.Query{})
+		if err != nil {
+			return err
+		}
+		if err := prepare(sessionContext, creditNotes); err != nil {
+			return err
+		}
+		return r.issue(sessionContext, creditNote, numbering, also)
+	})
+}
+
+// issue numbers and stores the invoice in the transaction of the session
+func (r *mongoInvoiceRepository) issue(sessionContext mongo.SessionContext, invoice *models.Invoice, numbering models.NumberingSettings, also func(ctx context.Context) error) error {
+	invoice.ID = primitive.NilObjectID
+	if invoice.Type == models.InvoiceTypeInvoice {
+		var lock numberingLockDocument
+		err := scope(r.sequences, invoice.OrganisationID).FindOneAndUpdate(sessionContext,
+			bson.M{"name": numberingLock},
+			bson.M{"$inc": bson.M{"value": 1}},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&lock)
+		if err != nil {
+			return err
+		}
+		if lock.Numbering != nil && *lock.Numbering != numbering {
+			return ErrNumberingChanged
+		}
+	}
+	invoice.Sequence = numbering.Sequence(invoice.Type, invoice.IssuedAt)
+	value, err := nextSequence(sessionContext, r.sequences, invoice.OrganisationID, invoice.Sequence)
+	if err != nil {
+		return err
+	}
+	invoice.SequenceValue = value
+	invoice.Number = numbering.Format(value, invoice.IssuedAt)
+
+	result, err := scope(r.collection, invoice.OrganisationID).InsertOne(sessionContext, invoice)
+	if err != nil {
+		return err
+	}
+	invoice.ID = result.InsertedID.(primitive.ObjectID)
+
+	if also != nil {
+		return also(sessionContext)
+	}
+	return nil
+}
+
+func (r *mongoInvoiceRepository) ChangeNumbering(ctx context.Context, organisationID primitive.ObjectID, numbering models.NumberingSettings, change func(ctx context.Context, issued bool) error) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		_, err := scope(r.sequences, organisationID).UpdateOne(sessionContext,
+			bson.M{"name": numberingLock},
+			bson.M{"$inc": bson.M{"value": 1}, "$set": bson.M{"numbering": numbering}},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+		count, err := scope(r.collection, organisationID).CountDocuments(sessionContext, bson.M{"type": models.InvoiceTypeInvoice}, options.Count().SetLimit(1))
+		if err != nil {
+			return err
+		}
+		return change(sessionContext, count > 0)
+	})
+}
+
+func (r *mongoInvoiceRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Invoice, error) {
+	var invoice *models.Invoice
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&invoice)
+	return invoice, notFound(err)
+}
+
+func (r *mongoInvoiceRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.InvoiceFilter, page listing.Query) ([]*models.Invoice, listing.Page, error) {
+	query := bson.M{}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+	if filter.CustomerID != nil {
+		query["customer_id"] = *filter.CustomerID
+	}
+	if filter.OriginalInvoiceID != nil {
+		query["original_invoice_id"] = *filter.OriginalInvoiceID
+	}
+	if filter.IssuedBy != nil {
+		query["issued_by"] = *filter.IssuedBy
+	}
+	issuedAt := bson.M{}
+	if filter.IssuedFrom != nil {
+		issuedAt["$gte"] = *filter.IssuedFrom
+	}
+	if filter.IssuedUntil != nil {
+		issuedAt["$lt"] = *filter.IssuedUntil
+	}
+	if len(issuedAt) > 0 {
+		query["issued_at"] = issuedAt
+	}
+
+	return findPage(ctx, scope(r.collection, organisationID), query, page.OrSort("-issued_at"), InvoiceSortFields, invoiceID)
+}
+
+func (r *mongoInvoiceRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	_, err := scope(r.collection, organisationID).UpdateMany(ctx, bson.M{"customer_id": bson.M{"$in": from}}, bson.M{"$set": bson.M{"customer_id": to}})
+	return err
+}
+
+func (r *mongoInvoiceRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"customer_id": customerID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
@@ -0,0 +1,66 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"poosible-backend/models"
+	"time"
+)
+
+// ItemImportRepository holds the item imports and their progress, which only the import job changes
+type ItemImportRepository interface {
+	Create(ctx context.Context, itemImport *models.ItemImport) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.ItemImport, error)
+	// Update replaces the import with its latest progress
+	Update(ctx context.Context, itemImport *models.ItemImport) error
+	// FailUnfinished marks the queued and running imports of every organisation as failed for the reason and returns
+	// how many there were
+	FailUnfinished(ctx context.Context, reason string, at time.Time) (int64, error)
+}
+
+type mongoItemImportRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoItemImportRepository(collection *mongo.Collection) ItemImportRepository {
+	return &mongoItemImportRepository{collection: collection}
+}
+
+func (r *mongoItemImportRepository) Create(ctx context.Context, itemImport *models.ItemImport) error {
+	result, err := scope(r.collection, itemImport.OrganisationID).InsertOne(ctx, itemImport)
+	if err != nil {
+		return err
+	}
+	itemImport.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoItemImportRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.ItemImport, error) {
+	var itemImport *models.ItemImport
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&itemImport)
+	return itemImport, notFound(err)
+}
+
+func (r *mongoItemImportRepository) Update(ctx context.Context, itemImport *models.ItemImport) error {
+	result, err := scope(r.collection, itemImport.OrganisationID).ReplaceOne(ctx, bson.M{"_id": itemImport.ID}, itemImport)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoItemImportRepository) FailUnfinished(ctx context.Context, reason string, at time.Time) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"status": bson.M{"$in": []models.ItemImportStatus{models.ItemImportQueued, models.ItemImportRunning}}},
+		bson.M{"$set": bson.M{"status": models.ItemImportFailed, "error": reason, "finished_at": at, "updated_at": at}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
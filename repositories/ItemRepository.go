@@ -0,0 +1,291 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/search"
+	"regexp"
+	"sort"
+)
+
+// ErrDuplicateItem is returned when another item of the organisation has the same SKU, barcode or part number
+var ErrDuplicateItem = errors.New("SKU, barcode or part number already exists")
+
+type ItemRepository interface {
+	// Create stores the item, or returns ErrDuplicateItem if its SKU, a barcode or its part number is taken
+	Create(ctx context.Context, item *models.Item) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Item, error)
+	FindByName(ctx context.Context, organisationID primitive.ObjectID, name string) (*models.Item, error)
+	FindBySKU(ctx context.Context, organisationID primitive.ObjectID, sku string) (*models.Item, error)
+	// FindByBarcode returns the item carrying any of the barcodes
+	FindByBarcode(ctx context.Context, organisationID primitive.ObjectID, barcodes []string) (*models.Item, error)
+	FindByPartNumber(ctx context.Context, organisationID primitive.ObjectID, manufacturer string, mpn string) (*models.Item, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.ItemFilter, query listing.Query) ([]*models.Item, listing.Page, error)
+	// Each passes the items the filter lists to fn one by one ordered by name, without holding them all in memory. It
+	// stops at the first error fn returns.
+	Each(ctx context.Context, organisationID primitive.ObjectID, filter models.ItemFilter, fn func(item *models.Item) error) error
+	// Search returns up to limit items matching the words of the query, the best match first
+	Search(ctx context.Context, organisationID primitive.ObjectID, query string, limit int) ([]*models.ItemMatch, error)
+	// Update replaces the item if it is still at the given version and increments its version. Like Create it returns
+	// ErrDuplicateItem if the SKU, a barcode or the part number is taken.
+	Update(ctx context.Context, item *models.Item, version int) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+	// UsesTaxRate reports whether any item of the organisation is assigned the tax rate
+	UsesTaxRate(ctx context.Context, organisationID primitive.ObjectID, taxRateID primitive.ObjectID) (bool, error)
+	// UsesCategory reports whether any item of the organisation is listed in the category
+	UsesCategory(ctx context.Context, organisationID primitive.ObjectID, categoryID primitive.ObjectID) (bool, error)
+	// UsesSupplier reports whether any item of the organisation prefers the supplier
+	UsesSupplier(ctx context.Context, organisationID primitive.ObjectID, supplierID primitive.ObjectID) (bool, error)
+}
+
+// ItemSortFields are the fields items can be listed by
+var ItemSortFields = listing.Fields[models.Item]{
+	"name":       {Key: "name", Value: func(i *models.Item) interface{} { return i.Name }},
+	"price":      {Key: "price.amount", Value: func(i *models.Item) interface{} { return i.Price.Amount }},
+	"created_at": {Key: "created_at", Value: func(i *models.Item) interface{} { return dateTime(i.CreatedAt) }},
+	"updated_at": {Key: "updated_at", Value: func(i *models.Item) interface{} { return dateTime(i.UpdatedAt) }},
+}
+
+func itemID(item *models.Item) primitive.ObjectID {
+	return item.ID
+}
+
+// searchCandidates caps how many items sharing a prefix with the query are read to be ranked. Items with a term for
+// every word of the query are read first, so the cap only ever drops items matching part of the query.
+const searchCandidates = 2000
+
+// rankItems scores the candidates against the query words and keeps the best of them
+func rankItems(candidates []*models.Item, words []string, limit int) []*models.ItemMatch {
+	matches := []*models.ItemMatch{}
+	for _, item := range candidates {
+		result := search.Score(words, item.SearchFields())
+		if result.Matched > 0 {
+			matches = append(matches, &models.ItemMatch{Item: item, Matched: result.Matched, Score: result.Score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a := search.Result{Matched: matches[i].Matched, Score: matches[i].Score}
+		b := search.Result{Matched: matches[j].Matched, Score: matches[j].Score}
+		if a == b {
+			return matches[i].Name < matches[j].Name
+		}
+		return a.Better(b)
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+type mongoItemRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoItemRepository(collection *mongo.Collection) ItemRepository {
+	return &mongoItemRepository{collection: collection}
+}
+
+func (r *mongoItemRepository) Create(ctx context.Context, item *models.Item) error {
+	item.Index()
+	result, err := scope(r.collection, item.OrganisationID).InsertOne(ctx, item)
+	if err != nil {
+		return duplicateItem(err)
+	}
+	item.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoItemRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Item, error) {
+	var item *models.Item
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&item)
+	return item, notFound(err)
+}
+
+func (r *mongoItemRepository) FindByName(ctx context.Context, organisationID primitive.ObjectID, name string) (*models.Item, error) {
+	var item *models.Item
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"name": name}).Decode(&item)
+	return item, notFound(err)
+}
+
+func (r *mongoItemRepository) FindBySKU(ctx context.Context, organisationID primitive.ObjectID, sku string) (*models.Item, error) {
+	var item *models.Item
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"sku": sku}).Decode(&item)
+	return item, notFound(err)
+}
+
+func (r *mongoItemRepository) FindByBarcode(ctx context.Context, organisationID primitive.ObjectID, barcodes []string) (*models.Item, error) {
+	var item *models.Item
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"barcodes": bson.M{"$in": barcodes}}).Decode(&item)
+	return item, notFound(err)
+}
+
+func (r *mongoItemRepository) FindByPartNumber(ctx context.Context, organisationID primitive.ObjectID, manufacturer string, mpn string) (*models.Item, error) {
+	var item *models.Item
+	filter := bson.M{"mpn": mpn, "manufacturer": manufacturer}
+	if manufacturer == "" {
+		//The manufacturer field is left out of items without one
+		filter["manufacturer"] = bson.M{"$in": bson.A{"", nil}}
+	}
+	err := scope(r.collection, organisationID).FindOne(ctx, filter).Decode(&item)
+	return item, notFound(err)
+}
+
+func (r *mongoItemRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.ItemFilter, query listing.Query) ([]*models.Item, listing.Page, error) {
+	return findPage(ctx, scope(r.collection, organisationID), itemConditions(filter), query.OrSort("name"), ItemSortFields, itemID)
+}
+
+func (r *mongoItemRepository) Each(ctx context.Context, organisationID primitive.ObjectID, filter models.ItemFilter, fn func(item *models.Item) error) error {
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := scope(r.collection, organisationID).Find(ctx, itemConditions(filter), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var item models.Item
+		if err := cursor.Decode(&item); err != nil {
+			return err
+		}
+		if err := fn(&item); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// itemConditions returns the conditions on items the filter lists
+func itemConditions(filter models.ItemFilter) bson.M {
+	conditions := bson.M{}
+	if filter.Name != "" {
+		conditions["name"] = primitive.Regex{Pattern: regexp.QuoteMeta(filter.Name), Options: "i"}
+	}
+	if filter.CategoryIDs != nil {
+		conditions["category_id"] = bson.M{"$in": filter.CategoryIDs}
+	}
+	price := bson.M{}
+	if filter.MinPrice != nil {
+		price["$gte"] = *filter.MinPrice
+	}
+	if filter.MaxPrice != nil {
+		price["$lte"] = *filter.MaxPrice
+	}
+	if len(price) > 0 {
+		conditions["price.amount"] = price
+	}
+	if filter.UpdatedSince != nil {
+		conditions["updated_at"] = bson.M{"$gte": *filter.UpdatedSince}
+	}
+	return conditions
+}
+
+func (r *mongoItemRepository) Search(ctx context.Context, organisationID primitive.ObjectID, query string, limit int) ([]*models.ItemMatch, error) {
+	words := search.Words(query)
+	prefixes := bson.A{}
+	for _, prefix := range search.Prefixes(words) {
+		prefixes = append(prefixes, primitive.Regex{Pattern: "^" + regexp.QuoteMeta(prefix)})
+	}
+	if len(prefixes) == 0 {
+		return []*models.ItemMatch{}, nil
+	}
+
+	//Only items matching every word of the query can rank first, so they are read before those matching some of it
+	candidates, err := r.findCandidates(ctx, organisationID, bson.M{"search_terms": bson.M{"$all": prefixes}}, searchCandidates)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) > 1 && len(candidates) < searchCandidates {
+		partial := bson.M{"search_terms": bson.M{"$in": prefixes}, "$nor": bson.A{bson.M{"search_terms": bson.M{"$all": prefixes}}}}
+		more, err := r.findCandidates(ctx, organisationID, partial, searchCandidates-len(candidates))
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, more...)
+	}
+	return rankItems(candidates, words, limit), nil
+}
+
+// findCandidates reads up to limit items matching the conditions on their search terms
+func (r *mongoItemRepository) findCandidates(ctx context.Context, organisationID primitive.ObjectID, conditions bson.M, limit int) ([]*models.Item, error) {
+	result, err := scope(r.collection, organisationID).Find(ctx, conditions, options.Find().SetLimit(int64(limit)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	candidates := []*models.Item{}
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var item *models.Item
+		if err := result.Decode(&item); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, item)
+	}
+	return candidates, result.Err()
+}
+
+func (r *mongoItemRepository) Update(ctx context.Context, item *models.Item, version int) error {
+	filter := bson.M{"_id": item.ID, "version": version}
+	if version == 0 {
+		//Items created before versioning have no version field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	item.Version = version + 1
+	item.Index()
+	result, err := scope(r.collection, item.OrganisationID).ReplaceOne(ctx, filter, item)
+	if err != nil {
+		item.Version = version
+		return duplicateItem(err)
+	}
+	if result.MatchedCount == 0 {
+		item.Version = version
+		if _, err := r.FindByID(ctx, item.OrganisationID, item.ID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *mongoItemRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoItemRepository) UsesTaxRate(ctx context.Context, organisationID primitive.ObjectID, taxRateID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"tax_rate_id": taxRateID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *mongoItemRepository) UsesCategory(ctx context.Context, organisationID primitive.ObjectID, categoryID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"category_id": categoryID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *mongoItemRepository) UsesSupplier(ctx context.Context, organisationID primitive.ObjectID, supplierID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"preferred_supplier_id": supplierID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+// duplicateItem turns a violation of the unique SKU, barcode and part number indexes into ErrDuplicateItem. The indexes
+// catch items written at the same time, which both pass the checks of the handlers before either is stored.
+func duplicateItem(err error) error {
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateItem
+	}
+	return err
+}
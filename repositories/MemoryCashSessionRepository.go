@@ -0,0 +1,100 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"sync"
+)
+
+type memoryCashSessionRepository struct {
+	mu        sync.Mutex
+	store     *memoryStore[models.CashSession]
+	sequences memorySequences
+}
+
+func NewMemoryCashSessionRepository() CashSessionRepository {
+	return &memoryCashSessionRepository{store: newMemoryStore[models.CashSession](), sequences: memorySequences{}}
+}
+
+func (r *memoryCashSessionRepository) Open(ctx context.Context, session *models.CashSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	open := r.store.find(func(s *models.CashSession) bool {
+		return s.OrganisationID == session.OrganisationID && s.Status == models.CashSessionOpen && (s.UserID == session.UserID || s.Register == session.Register)
+	})
+	if len(open) > 0 {
+		return ErrSessionOpen
+	}
+	session.ID = primitive.NewObjectID()
+	r.store.put(session.ID, *session)
+	return nil
+}
+
+func (r *memoryCashSessionRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.CashSession, error) {
+	return r.store.first(func(s *models.CashSession) bool { return s.OrganisationID == organisationID && s.ID == id })
+}
+
+func (r *memoryCashSessionRepository) FindOpen(ctx context.Context, organisationID primitive.ObjectID, userID primitive.ObjectID) (*models.CashSession, error) {
+	return r.store.first(func(s *models.CashSession) bool {
+		return s.OrganisationID == organisationID && s.UserID == userID && s.Status == models.CashSessionOpen
+	})
+}
+
+func (r *memoryCashSessionRepository) AddMovement(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, movement models.CashMovement) (*models.CashSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	session, err := r.FindByID(ctx, organisationID, id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != models.CashSessionOpen {
+		return nil, ErrSessionClosed
+	}
+	session.Movements = append(session.Movements, movement)
+	r.store.put(session.ID, *session)
+	return session, nil
+}
+
+func (r *memoryCashSessionRepository) Close(ctx context.Context, session *models.CashSession, report *models.CashReport, numbering models.NumberingSettings) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, err := r.FindByID(ctx, session.OrganisationID, session.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Status != models.CashSessionOpen {
+		return ErrSessionClosed
+	}
+	sequence := numbering.Sequence("z_reports", report.TakenAt)
+	value := r.sequences.peek(session.OrganisationID, sequence)
+	report.Number = numbering.Format(value, report.TakenAt)
+
+	stored.Status = models.CashSessionClosed
+	stored.ClosedAt, stored.ClosedBy, stored.Notes, stored.ZReport = session.ClosedAt, session.ClosedBy, session.Notes, report
+	r.store.put(stored.ID, *stored)
+	r.sequences.commit(session.OrganisationID, sequence, value)
+	session.Status = models.CashSessionClosed
+	session.ZReport = report
+	return nil
+}
+
+func (r *memoryCashSessionRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.CashSessionFilter, query listing.Query) ([]*models.CashSession, listing.Page, error) {
+	sessions := r.store.find(func(s *models.CashSession) bool {
+		if s.OrganisationID != organisationID {
+			return false
+		}
+		if filter.UserID != nil && s.UserID != *filter.UserID {
+			return false
+		}
+		if filter.Register != "" && s.Register != filter.Register {
+			return false
+		}
+		return filter.Status == "" || s.Status == filter.Status
+	})
+	return memoryPage(sessions, query.OrSort("-opened_at"), CashSessionSortFields, cashSessionID)
+}
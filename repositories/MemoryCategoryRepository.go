@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"sort"
+	"sync"
+)
+
+type memoryCategoryRepository struct {
+	mu    sync.Mutex
+	store *memoryStore[models.Category]
+}
+
+func NewMemoryCategoryRepository() CategoryRepository {
+	return &memoryCategoryRepository{store: newMemoryStore[models.Category]()}
+}
+
+func (r *memoryCategoryRepository) Create(ctx context.Context, category *models.Category) error {
+	category.ID = primitive.NewObjectID()
+	r.store.put(category.ID, *category)
+	return nil
+}
+
+func (r *memoryCategoryRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Category, error) {
+	return r.store.first(func(c *models.Category) bool { return c.OrganisationID == organisationID && c.ID == id })
+}
+
+func (r *memoryCategoryRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Category, error) {
+	return r.find(func(c *models.Category) bool { return c.OrganisationID == organisationID }), nil
+}
+
+func (r *memoryCategoryRepository) FindSubtree(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) ([]*models.Category, error) {
+	category, err := r.FindByID(ctx, organisationID, id)
+	if err != nil {
+		return nil, err
+	}
+	descendants := r.find(func(c *models.Category) bool { return c.OrganisationID == organisationID && c.IsDescendantOf(id) })
+	return append([]*models.Category{category}, descendants...), nil
+}
+
+func (r *memoryCategoryRepository) find(match func(*models.Category) bool) []*models.Category {
+	categories := r.store.find(match)
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Position != categories[j].Position {
+			return categories[i].Position < categories[j].Position
+		}
+		return categories[i].Name < categories[j].Name
+	})
+	if categories == nil {
+		categories = []*models.Category{}
+	}
+	return categories
+}
+
+func (r *memoryCategoryRepository) Update(ctx context.Context, category *models.Category) error {
+	updated := r.store.update(func(c *models.Category) bool {
+		return c.OrganisationID == category.OrganisationID && c.ID == category.ID
+	}, func(c *models.Category) { *c = *category })
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryCategoryRepository) Move(ctx context.Context, category *models.Category, siblings []primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for position, id := range siblings {
+		if id == category.ID {
+			category.Position = position
+		}
+	}
+	if err := r.Update(ctx, category); err != nil {
+		return err
+	}
+
+	path := append(append([]primitive.ObjectID{}, category.Ancestors...), category.ID)
+	r.store.update(func(c *models.Category) bool {
+		return c.OrganisationID == category.OrganisationID && c.IsDescendantOf(category.ID)
+	}, func(c *models.Category) {
+		for i, ancestor := range c.Ancestors {
+			if ancestor == category.ID {
+				c.Ancestors = append(append([]primitive.ObjectID{}, path...), c.Ancestors[i+1:]...)
+				return
+			}
+		}
+	})
+
+	for position, id := range siblings {
+		position := position
+		r.store.update(func(c *models.Category) bool {
+			return c.OrganisationID == category.OrganisationID && c.ID == id && id != category.ID
+		}, func(c *models.Category) { c.Position = position })
+	}
+	return nil
+}
+
+func (r *memoryCategoryRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(c *models.Category) bool { return c.OrganisationID == organisationID && c.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
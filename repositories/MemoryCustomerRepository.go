@@ -0,0 +1,63 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"strings"
+)
+
+type memoryCustomerRepository struct {
+	store *memoryStore[models.Customer]
+}
+
+func NewMemoryCustomerRepository() CustomerRepository {
+	return &memoryCustomerRepository{store: newMemoryStore[models.Customer]()}
+}
+
+func (r *memoryCustomerRepository) Create(ctx context.Context, customer *models.Customer) error {
+	customer.ID = primitive.NewObjectID()
+	r.store.put(customer.ID, *customer)
+	return nil
+}
+
+func (r *memoryCustomerRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Customer, error) {
+	return r.store.first(func(c *models.Customer) bool { return c.OrganisationID == organisationID && c.ID == id })
+}
+
+func (r *memoryCustomerRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, page listing.Query) ([]*models.Customer, listing.Page, error) {
+	customers := r.store.find(func(c *models.Customer) bool { return c.OrganisationID == organisationID })
+	return memoryPage(customers, page.OrSort("name"), CustomerSortFields, customerID)
+}
+
+func (r *memoryCustomerRepository) Search(ctx context.Context, organisationID primitive.ObjectID, query string, page listing.Query) ([]*models.Customer, listing.Page, error) {
+	query = strings.ToLower(query)
+	digits := models.PhoneDigits(query)
+	customers := r.store.find(func(c *models.Customer) bool {
+		if c.OrganisationID != organisationID {
+			return false
+		}
+		if strings.Contains(strings.ToLower(c.Name), query) || strings.Contains(strings.ToLower(c.Email), query) {
+			return true
+		}
+		return len(digits) >= minPhoneSearchDigits && strings.Contains(c.PhoneDigits, digits)
+	})
+	return memoryPage(customers, page.OrSort("name"), CustomerSortFields, customerID)
+}
+
+func (r *memoryCustomerRepository) Update(ctx context.Context, customer *models.Customer) error {
+	if r.store.update(func(c *models.Customer) bool {
+		return c.OrganisationID == customer.OrganisationID && c.ID == customer.ID
+	}, func(c *models.Customer) { *c = *customer }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryCustomerRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(c *models.Customer) bool { return c.OrganisationID == organisationID && c.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+type memoryEstimateRepository struct {
+	store *memoryStore[models.Estimate]
+}
+
+func NewMemoryEstimateRepository() EstimateRepository {
+	return &memoryEstimateRepository{store: newMemoryStore[models.Estimate]()}
+}
+
+func (r *memoryEstimateRepository) Create(ctx context.Context, estimate *models.Estimate) error {
+	estimate.ID = primitive.NewObjectID()
+	r.store.put(estimate.ID, *estimate)
+	return nil
+}
+
+func (r *memoryEstimateRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Estimate, error) {
+	return r.store.first(func(e *models.Estimate) bool { return e.OrganisationID == organisationID && e.ID == id })
+}
+
+func (r *memoryEstimateRepository) FindByShareToken(ctx context.Context, tokenHash string) (*models.Estimate, error) {
+	return r.store.first(func(e *models.Estimate) bool { return e.ShareTokenHash != "" && e.ShareTokenHash == tokenHash })
+}
+
+func (r *memoryEstimateRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.EstimateFilter, query listing.Query) ([]*models.Estimate, listing.Page, error) {
+	estimates := r.store.find(func(e *models.Estimate) bool {
+		if e.OrganisationID != organisationID {
+			return false
+		}
+		if filter.Status != "" && e.Status != filter.Status {
+			return false
+		}
+		if filter.CustomerID != nil && e.CustomerID != *filter.CustomerID {
+			return false
+		}
+		return filter.VehicleID == nil || (e.VehicleID != nil && *e.VehicleID == *filter.VehicleID)
+	})
+	return memoryPage(estimates, query.OrSort("-created_at"), EstimateSortFields, estimateID)
+}
+
+func (r *memoryEstimateRepository) Update(ctx context.Context, estimate *models.Estimate, status models.EstimateStatus, shareTokenHash string) error {
+	found := false
+	if r.store.update(func(e *models.Estimate) bool {
+		if e.OrganisationID != estimate.OrganisationID || e.ID != estimate.ID {
+			return false
+		}
+		found = true
+		return e.Status == status && e.ShareTokenHash == shareTokenHash
+	}, func(e *models.Estimate) { *e = *estimate }) == 0 {
+		if !found {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *memoryEstimateRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	r.store.update(func(e *models.Estimate) bool {
+		if e.OrganisationID != organisationID {
+			return false
+		}
+		for _, id := range from {
+			if e.CustomerID == id {
+				return true
+			}
+		}
+		return false
+	}, func(e *models.Estimate) { e.CustomerID = to })
+	return nil
+}
+
+func (r *memoryEstimateRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	estimates := r.store.find(func(e *models.Estimate) bool {
+		return e.OrganisationID == organisationID && e.CustomerID == customerID
+	})
+	return len(estimates) > 0, nil
+}
@@ -0,0 +1,131 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"sync"
+)
+
+type memoryInvoiceRepository struct {
+	mu        sync.Mutex
+	store     *memoryStore[models.Invoice]
+	sequences memorySequences
+	numbering map[primitive.ObjectID]models.NumberingSettings
+}
+
+func NewMemoryInvoiceRepository() InvoiceRepository {
+	return &memoryInvoiceRepository{store: newMemoryStore[models.Invoice](), sequences: memorySequences{}, numbering: map[primitive.ObjectID]models.NumberingSettings{}}
+}
+
+func (r *memoryInvoiceRepository) Issue(ctx context.Context, invoice *models.Invoice, numbering models.NumberingSettings, also func(ctx context.Context) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.issue(ctx, invoice, numbering, also)
+}
+
+func (r *memoryInvoiceRepository) IssueCreditNote(ctx context.Context, creditNote *models.Invoice, numbering models.NumberingSettings, prepare func(ctx context.Context, creditNotes []*models.Invoice) error, also func(ctx context.Context) error) error {
+	if creditNote.OriginalInvoiceID == nil {
+		return ErrNotFound
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	creditNotes, _, err := r.FindAll(ctx, creditNote.OrganisationID, models.InvoiceFilter{Type: models.InvoiceTypeCreditNote, OriginalInvoiceID: creditNote.OriginalInvoiceID}, listing.Query{})
+	if err != nil {
+		return err
+	}
+	if err := prepare(ctx, creditNotes); err != nil {
+		return err
+	}
+	return r.issue(ctx, creditNote, numbering, also)
+}
+
+// issue numbers and stores the invoice, with the lock held
+func (r *memoryInvoiceRepository) issue(ctx context.Context, invoice *models.Invoice, numbering models.NumberingSettings, also func(ctx context.Context) error) error {
+	if recorded, ok := r.numbering[invoice.OrganisationID]; ok && invoice.Type == models.InvoiceTypeInvoice && recorded != numbering {
+		return ErrNumberingChanged
+	}
+	sequence := numbering.Sequence(invoice.Type, invoice.IssuedAt)
+	value := r.sequences.peek(invoice.OrganisationID, sequence)
+	invoice.ID = primitive.NewObjectID()
+	invoice.Sequence = sequence
+	invoice.SequenceValue = value
+	invoice.Number = numbering.Format(value, invoice.IssuedAt)
+	if also != nil {
+		if err := also(ctx); err != nil {
+			return err
+		}
+	}
+
+	r.store.put(invoice.ID, *invoice)
+	r.sequences.commit(invoice.OrganisationID, sequence, value)
+	return nil
+}
+
+func (r *memoryInvoiceRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Invoice, error) {
+	return r.store.first(func(i *models.Invoice) bool { return i.OrganisationID == organisationID && i.ID == id })
+}
+
+func (r *memoryInvoiceRepository) ChangeNumbering(ctx context.Context, organisationID primitive.ObjectID, numbering models.NumberingSettings, change func(ctx context.Context, issued bool) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	issued := r.store.find(func(i *models.Invoice) bool {
+		return i.OrganisationID == organisationID && i.Type == models.InvoiceTypeInvoice
+	})
+	if err := change(ctx, len(issued) > 0); err != nil {
+		return err
+	}
+	r.numbering[organisationID] = numbering
+	return nil
+}
+
+func (r *memoryInvoiceRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.InvoiceFilter, query listing.Query) ([]*models.Invoice, listing.Page, error) {
+	invoices := r.store.find(func(i *models.Invoice) bool {
+		if i.OrganisationID != organisationID {
+			return false
+		}
+		if filter.Type != "" && i.Type != filter.Type {
+			return false
+		}
+		if filter.CustomerID != nil && (i.CustomerID == nil || *i.CustomerID != *filter.CustomerID) {
+			return false
+		}
+		if filter.OriginalInvoiceID != nil && (i.OriginalInvoiceID == nil || *i.OriginalInvoiceID != *filter.OriginalInvoiceID) {
+			return false
+		}
+		if filter.IssuedBy != nil && i.IssuedBy != *filter.IssuedBy {
+			return false
+		}
+		if filter.IssuedFrom != nil && i.IssuedAt.Before(*filter.IssuedFrom) {
+			return false
+		}
+		return filter.IssuedUntil == nil || i.IssuedAt.Before(*filter.IssuedUntil)
+	})
+	return memoryPage(invoices, query.OrSort("-issued_at"), InvoiceSortFields, invoiceID)
+}
+
+func (r *memoryInvoiceRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	r.store.update(func(i *models.Invoice) bool {
+		if i.OrganisationID != organisationID || i.CustomerID == nil {
+			return false
+		}
+		for _, id := range from {
+			if *i.CustomerID == id {
+				return true
+			}
+		}
+		return false
+	}, func(i *models.Invoice) { i.CustomerID = &to })
+	return nil
+}
+
+func (r *memoryInvoiceRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	invoices := r.store.find(func(i *models.Invoice) bool {
+		return i.OrganisationID == organisationID && i.CustomerID != nil && *i.CustomerID == customerID
+	})
+	return len(invoices) > 0, nil
+}
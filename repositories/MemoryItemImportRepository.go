@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"time"
+)
+
+type memoryItemImportRepository struct {
+	store *memoryStore[models.ItemImport]
+}
+
+func NewMemoryItemImportRepository() ItemImportRepository {
+	return &memoryItemImportRepository{store: newMemoryStore[models.ItemImport]()}
+}
+
+func (r *memoryItemImportRepository) Create(ctx context.Context, itemImport *models.ItemImport) error {
+	itemImport.ID = primitive.NewObjectID()
+	r.store.put(itemImport.ID, *itemImport)
+	return nil
+}
+
+func (r *memoryItemImportRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.ItemImport, error) {
+	return r.store.first(func(i *models.ItemImport) bool { return i.OrganisationID == organisationID && i.ID == id })
+}
+
+func (r *memoryItemImportRepository) Update(ctx context.Context, itemImport *models.ItemImport) error {
+	updated := r.store.update(func(i *models.ItemImport) bool {
+		return i.OrganisationID == itemImport.OrganisationID && i.ID == itemImport.ID
+	}, func(i *models.ItemImport) {
+		*i = *itemImport
+	})
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryItemImportRepository) FailUnfinished(ctx context.Context, reason string, at time.Time) (int64, error) {
+	failed := r.store.update(func(i *models.ItemImport) bool {
+		return i.Status == models.ItemImportQueued || i.Status == models.ItemImportRunning
+	}, func(i *models.ItemImport) {
+		i.Status = models.ItemImportFailed
+		i.Error = reason
+		i.FinishedAt = &at
+		i.UpdatedAt = at
+	})
+	return int64(failed), nil
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/search"
+	"sort"
+	"strings"
+)
+
+type memoryItemRepository struct {
+	store *memoryStore[models.Item]
+}
+
+func NewMemoryItemRepository() ItemRepository {
+	return &memoryItemRepository{store: newMemoryStore[models.Item]()}
+}
+
+func (r *memoryItemRepository) Create(ctx context.Context, item *models.Item) error {
+	item.ID = primitive.NewObjectID()
+	item.Index()
+	r.store.put(item.ID, *item)
+	return nil
+}
+
+func (r *memoryItemRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Item, error) {
+	return r.store.first(func(i *models.Item) bool { return i.OrganisationID == organisationID && i.ID == id })
+}
+
+func (r *memoryItemRepository) FindByName(ctx context.Context, organisationID primitive.ObjectID, name string) (*models.Item, error) {
+	return r.store.first(func(i *models.Item) bool { return i.OrganisationID == organisationID && i.Name == name })
+}
+
+func (r *memoryItemRepository) FindBySKU(ctx context.Context, organisationID primitive.ObjectID, sku string) (*models.Item, error) {
+	return r.store.first(func(i *models.Item) bool { return i.OrganisationID == organisationID && i.SKU == sku })
+}
+
+func (r *memoryItemRepository) FindByBarcode(ctx context.Context, organisationID primitive.ObjectID, barcodes []string) (*models.Item, error) {
+	return r.store.first(func(i *models.Item) bool {
+		if i.OrganisationID != organisationID {
+			return false
+		}
+		for _, code := range i.Barcodes {
+			for _, barcode := range barcodes {
+				if code == barcode {
+					return true
+				}
+			}
+		}
+		return false
+	})
+}
+
+func (r *memoryItemRepository) FindByPartNumber(ctx context.Context, organisationID primitive.ObjectID, manufacturer string, mpn string) (*models.Item, error) {
+	return r.store.first(func(i *models.Item) bool {
+		return i.OrganisationID == organisationID && i.Manufacturer == manufacturer && i.MPN == mpn
+	})
+}
+
+func (r *memoryItemRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.ItemFilter, query listing.Query) ([]*models.Item, listing.Page, error) {
+	items := r.store.find(func(i *models.Item) bool {
+		return i.OrganisationID == organisationID && itemListed(i, filter)
+	})
+	return memoryPage(items, query.OrSort("name"), ItemSortFields, itemID)
+}
+
+func (r *memoryItemRepository) Each(ctx context.Context, organisationID primitive.ObjectID, filter models.ItemFilter, fn func(item *models.Item) error) error {
+	items := r.store.find(func(i *models.Item) bool {
+		return i.OrganisationID == organisationID && itemListed(i, filter)
+	})
+	sort.SliceStable(items, func(a, b int) bool { return items[a].Name < items[b].Name })
+	for _, item := range items {
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itemListed reports whether the filter lists the item
+func itemListed(i *models.Item, filter models.ItemFilter) bool {
+	if !strings.Contains(strings.ToLower(i.Name), strings.ToLower(filter.Name)) {
+		return false
+	}
+	if filter.MinPrice != nil && i.Price.Amount < *filter.MinPrice {
+		return false
+	}
+	if filter.MaxPrice != nil && i.Price.Amount > *filter.MaxPrice {
+		return false
+	}
+	if filter.UpdatedSince != nil && i.UpdatedAt.Before(*filter.UpdatedSince) {
+		return false
+	}
+	if filter.CategoryIDs == nil {
+		return true
+	}
+	for _, categoryID := range filter.CategoryIDs {
+		if i.CategoryID != nil && *i.CategoryID == categoryID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryItemRepository) Search(ctx context.Context, organisationID primitive.ObjectID, query string, limit int) ([]*models.ItemMatch, error) {
+	words := search.Words(query)
+	prefixes := search.Prefixes(words)
+	candidates := r.store.find(func(i *models.Item) bool {
+		return i.OrganisationID == organisationID && search.Candidate(i.SearchTerms, prefixes)
+	})
+	return rankItems(candidates, words, limit), nil
+}
+
+func (r *memoryItemRepository) Update(ctx context.Context, item *models.Item, version int) error {
+	item.Index()
+	found := false
+	updated := r.store.update(func(i *models.Item) bool {
+		if i.OrganisationID != item.OrganisationID || i.ID != item.ID {
+			return false
+		}
+		found = true
+		return i.Version == version
+	}, func(i *models.Item) {
+		*i = *item
+		i.Version = version + 1
+	})
+	if !found {
+		return ErrNotFound
+	}
+	if updated == 0 {
+		return ErrVersionConflict
+	}
+	item.Version = version + 1
+	return nil
+}
+
+func (r *memoryItemRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(i *models.Item) bool { return i.OrganisationID == organisationID && i.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryItemRepository) UsesTaxRate(ctx context.Context, organisationID primitive.ObjectID, taxRateID primitive.ObjectID) (bool, error) {
+	items := r.store.find(func(i *models.Item) bool {
+		return i.OrganisationID == organisationID && i.TaxRateID != nil && *i.TaxRateID == taxRateID
+	})
+	return len(items) > 0, nil
+}
+
+func (r *memoryItemRepository) UsesCategory(ctx context.Context, organisationID primitive.ObjectID, categoryID primitive.ObjectID) (bool, error) {
+	items := r.store.find(func(i *models.Item) bool {
+		return i.OrganisationID == organisationID && i.CategoryID != nil && *i.CategoryID == categoryID
+	})
+	return len(items) > 0, nil
+}
+
+func (r *memoryItemRepository) UsesSupplier(ctx context.Context, organisationID primitive.ObjectID, supplierID primitive.ObjectID) (bool, error) {
+	items := r.store.find(func(i *models.Item) bool {
+		return i.OrganisationID == organisationID && i.PreferredSupplierID != nil && *i.PreferredSupplierID == supplierID
+	})
+	return len(items) > 0, nil
+}
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+)
+
+type memoryOrganisationRepository struct {
+	store *memoryStore[models.Organisation]
+}
+
+func NewMemoryOrganisationRepository() OrganisationRepository {
+	return &memoryOrganisationRepository{store: newMemoryStore[models.Organisation]()}
+}
+
+func (r *memoryOrganisationRepository) Create(ctx context.Context, organisation *models.Organisation) error {
+	organisation.ID = primitive.NewObjectID()
+	r.store.put(organisation.ID, *organisation)
+	return nil
+}
+
+func (r *memoryOrganisationRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Organisation, error) {
+	return r.store.first(func(o *models.Organisation) bool { return o.ID == id })
+}
+
+func (r *memoryOrganisationRepository) Update(ctx context.Context, organisation *models.Organisation) error {
+	matched := r.store.update(func(o *models.Organisation) bool { return o.ID == organisation.ID }, func(o *models.Organisation) { *o = *organisation })
+	if matched == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryOrganisationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if r.store.delete(func(o *models.Organisation) bool { return o.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
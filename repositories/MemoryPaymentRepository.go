@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"sort"
+	"sync"
+)
+
+type memoryPaymentRepository struct {
+	mu    sync.Mutex
+	store *memoryStore[models.Payment]
+}
+
+func NewMemoryPaymentRepository() PaymentRepository {
+	return &memoryPaymentRepository{store: newMemoryStore[models.Payment]()}
+}
+
+func (r *memoryPaymentRepository) Record(ctx context.Context, payment *models.Payment, net money.Money) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	payments, err := r.FindByInvoice(ctx, payment.OrganisationID, payment.InvoiceID)
+	if err != nil {
+		return err
+	}
+	fits, err := paymentsFit(payments, payment, net)
+	if err != nil {
+		return err
+	}
+	if !fits {
+		return ErrOverpayment
+	}
+	if spendsStoreCredit(payment) {
+		if payment.CustomerID == nil {
+			return ErrInsufficientCredit
+		}
+		if fits, err = creditFits(r.findStoreCredit(payment.OrganisationID, *payment.CustomerID), payment); err != nil {
+			return err
+		}
+		if !fits {
+			return ErrInsufficientCredit
+		}
+	}
+
+	payment.ID = primitive.NewObjectID()
+	r.store.put(payment.ID, *payment)
+	return nil
+}
+
+func (r *memoryPaymentRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Payment, error) {
+	return r.store.first(func(p *models.Payment) bool { return p.OrganisationID == organisationID && p.ID == id })
+}
+
+func (r *memoryPaymentRepository) FindByInvoice(ctx context.Context, organisationID primitive.ObjectID, invoiceID primitive.ObjectID) ([]*models.Payment, error) {
+	payments := r.store.find(func(p *models.Payment) bool { return p.OrganisationID == organisationID && p.InvoiceID == invoiceID })
+	if payments == nil {
+		payments = []*models.Payment{}
+	}
+	sort.SliceStable(payments, func(i, j int) bool { return payments[i].ReceivedAt.Before(payments[j].ReceivedAt) })
+	return payments, nil
+}
+
+func (r *memoryPaymentRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.PaymentFilter, query listing.Query) ([]*models.Payment, listing.Page, error) {
+	payments := r.store.find(func(p *models.Payment) bool {
+		if p.OrganisationID != organisationID {
+			return false
+		}
+		if filter.InvoiceID != nil && p.InvoiceID != *filter.InvoiceID {
+			return false
+		}
+		if filter.CustomerID != nil && (p.CustomerID == nil || *p.CustomerID != *filter.CustomerID) {
+			return false
+		}
+		if filter.CashSessionID != nil && (p.CashSessionID == nil || *p.CashSessionID != *filter.CashSessionID) {
+			return false
+		}
+		if filter.Method == "" {
+			return true
+		}
+		for _, tender := range p.Tenders {
+			if tender.Method == filter.Method {
+				return true
+			}
+		}
+		return false
+	})
+	return memoryPage(payments, query.OrSort("-received_at"), PaymentSortFields, paymentID)
+}
+
+func (r *memoryPaymentRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.store.update(func(p *models.Payment) bool {
+		if p.OrganisationID != organisationID || p.CustomerID == nil {
+			return false
+		}
+		for _, id := range from {
+			if *p.CustomerID == id {
+				return true
+			}
+		}
+		return false
+	}, func(p *models.Payment) { p.CustomerID = &to })
+	return nil
+}
+
+func (r *memoryPaymentRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	payments := r.store.find(func(p *models.Payment) bool {
+		return p.OrganisationID == organisationID && p.CustomerID != nil && *p.CustomerID == customerID
+	})
+	return len(payments) > 0, nil
+}
+
+func (r *memoryPaymentRepository) StoreCredit(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID, currency string) (money.Money, error) {
+	return models.StoreCredit(r.findStoreCredit(organisationID, customerID), currency)
+}
+
+// findStoreCredit returns the payments of the customer
+func (r *memoryPaymentRepository) findStoreCredit(organisationID primitive.ObjectID, customerID primitive.ObjectID) []*models.Payment {
+	return r.store.find(func(p *models.Payment) bool {
+		return p.OrganisationID == organisationID && p.CustomerID != nil && *p.CustomerID == customerID
+	})
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"sync"
+)
+
+type memoryPurchaseOrderRepository struct {
+	mu        sync.Mutex
+	store     *memoryStore[models.PurchaseOrder]
+	sequences memorySequences
+}
+
+func NewMemoryPurchaseOrderRepository() PurchaseOrderRepository {
+	return &memoryPurchaseOrderRepository{store: newMemoryStore[models.PurchaseOrder](), sequences: memorySequences{}}
+}
+
+func (r *memoryPurchaseOrderRepository) Create(ctx context.Context, order *models.PurchaseOrder) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	order.ID = primitive.NewObjectID()
+	order.Sequence = models.DefaultPurchaseOrderNumbering.Sequence(models.PurchaseOrderSequence, order.CreatedAt)
+	order.SequenceValue = r.sequences.peek(order.OrganisationID, order.Sequence)
+	order.Number = models.DefaultPurchaseOrderNumbering.Format(order.SequenceValue, order.CreatedAt)
+	r.store.put(order.ID, *order)
+	r.sequences.commit(order.OrganisationID, order.Sequence, order.SequenceValue)
+	return nil
+}
+
+func (r *memoryPurchaseOrderRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.PurchaseOrder, error) {
+	return r.store.first(func(o *models.PurchaseOrder) bool { return o.OrganisationID == organisationID && o.ID == id })
+}
+
+func (r *memoryPurchaseOrderRepository) Update(ctx context.Context, order *models.PurchaseOrder, version int) error {
+	found := false
+	updated := r.store.update(func(o *models.PurchaseOrder) bool {
+		if o.OrganisationID != order.OrganisationID || o.ID != order.ID {
+			return false
+		}
+		found = true
+		return o.Version == version
+	}, func(o *models.PurchaseOrder) {
+		*o = *order
+		o.Version = version + 1
+	})
+	if !found {
+		return ErrNotFound
+	}
+	if updated == 0 {
+		return ErrVersionConflict
+	}
+	order.Version = version + 1
+	return nil
+}
+
+func (r *memoryPurchaseOrderRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.PurchaseOrderFilter, query listing.Query) ([]*models.PurchaseOrder, listing.Page, error) {
+	orders := r.store.find(func(o *models.PurchaseOrder) bool {
+		if o.OrganisationID != organisationID {
+			return false
+		}
+		if filter.Status != "" && o.Status != filter.Status {
+			return false
+		}
+		if filter.ItemID != nil && !orderHasItem(o, *filter.ItemID) {
+			return false
+		}
+		return filter.SupplierID == nil || o.SupplierID == *filter.SupplierID
+	})
+	return memoryPage(orders, query.OrSort("-created_at"), PurchaseOrderSortFields, purchaseOrderID)
+}
+
+func (r *memoryPurchaseOrderRepository) OnOrder(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) (float64, error) {
+	orders := r.store.find(func(o *models.PurchaseOrder) bool {
+		if o.OrganisationID != organisationID || !orderHasItem(o, itemID) {
+			return false
+		}
+		for _, status := range models.OpenPurchaseOrderStatuses {
+			if o.Status == status {
+				return true
+			}
+		}
+		return false
+	})
+	return onOrder(orders, itemID), nil
+}
+
+func orderHasItem(order *models.PurchaseOrder, itemID primitive.ObjectID) bool {
+	for _, line := range order.Lines {
+		if line.ItemID == itemID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"time"
+)
+
+type memoryRefreshTokenRepository struct {
+	store *memoryStore[models.RefreshToken]
+}
+
+func NewMemoryRefreshTokenRepository() RefreshTokenRepository {
+	return &memoryRefreshTokenRepository{store: newMemoryStore[models.RefreshToken]()}
+}
+
+func (r *memoryRefreshTokenRepository) Create(ctx context.Context, refreshToken *models.RefreshToken) error {
+	refreshToken.ID = primitive.NewObjectID()
+	r.store.put(refreshToken.ID, *refreshToken)
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	return r.store.first(func(t *models.RefreshToken) bool { return t.TokenHash == tokenHash })
+}
+
+func (r *memoryRefreshTokenRepository) MarkUsed(ctx context.Context, id primitive.ObjectID, usedAt time.Time) (bool, error) {
+	matched := r.store.update(func(t *models.RefreshToken) bool {
+		return t.ID == id && t.UsedAt == nil && t.RevokedAt == nil
+	}, func(t *models.RefreshToken) { t.UsedAt = &usedAt })
+	return matched == 1, nil
+}
+
+func (r *memoryRefreshTokenRepository) SetReplacedBy(ctx context.Context, id primitive.ObjectID, replacedBy primitive.ObjectID) error {
+	r.store.update(func(t *models.RefreshToken) bool { return t.ID == id }, func(t *models.RefreshToken) { t.ReplacedBy = &replacedBy })
+	return nil
+}
+
+func (r *memoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	r.store.update(func(t *models.RefreshToken) bool {
+		return t.FamilyID == familyID && t.RevokedAt == nil
+	}, func(t *models.RefreshToken) { t.RevokedAt = &revokedAt })
+	return nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"sort"
+	"sync"
+)
+
+type memoryReorderSuggestionRepository struct {
+	mu    sync.Mutex
+	store *memoryStore[models.ReorderSuggestion]
+}
+
+func NewMemoryReorderSuggestionRepository() ReorderSuggestionRepository {
+	return &memoryReorderSuggestionRepository{store: newMemoryStore[models.ReorderSuggestion]()}
+}
+
+func (r *memoryReorderSuggestionRepository) Save(ctx context.Context, suggestion *models.ReorderSuggestion) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, err := r.store.first(func(s *models.ReorderSuggestion) bool {
+		return s.OrganisationID == suggestion.OrganisationID && s.ItemID == suggestion.ItemID
+	})
+	if err == nil {
+		suggestion.ID = existing.ID
+	} else {
+		suggestion.ID = primitive.NewObjectID()
+	}
+	r.store.put(suggestion.ID, *suggestion)
+	return nil
+}
+
+func (r *memoryReorderSuggestionRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) error {
+	r.store.delete(func(s *models.ReorderSuggestion) bool {
+		return s.OrganisationID == organisationID && s.ItemID == itemID
+	})
+	return nil
+}
+
+func (r *memoryReorderSuggestionRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.ReorderSuggestion, error) {
+	suggestions := r.store.find(func(s *models.ReorderSuggestion) bool { return s.OrganisationID == organisationID })
+	sort.SliceStable(suggestions, func(i, j int) bool { return suggestions[i].ItemName < suggestions[j].ItemName })
+	if suggestions == nil {
+		suggestions = []*models.ReorderSuggestion{}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+)
+
+type memoryRoleRepository struct {
+	store *memoryStore[models.Role]
+}
+
+func NewMemoryRoleRepository() RoleRepository {
+	return &memoryRoleRepository{store: newMemoryStore[models.Role]()}
+}
+
+func (r *memoryRoleRepository) Create(ctx context.Context, role *models.Role) error {
+	role.ID = primitive.NewObjectID()
+	r.store.put(role.ID, *role)
+	return nil
+}
+
+func (r *memoryRoleRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Role, error) {
+	return r.store.first(func(role *models.Role) bool { return role.OrganisationID == organisationID && role.ID == id })
+}
+
+func (r *memoryRoleRepository) FindByName(ctx context.Context, organisationID primitive.ObjectID, name string) (*models.Role, error) {
+	return r.store.first(func(role *models.Role) bool { return role.OrganisationID == organisationID && role.Name == name })
+}
+
+func (r *memoryRoleRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Role, error) {
+	return r.store.find(func(role *models.Role) bool { return role.OrganisationID == organisationID }), nil
+}
+
+func (r *memoryRoleRepository) Update(ctx context.Context, role *models.Role) error {
+	matched := r.store.update(func(existing *models.Role) bool {
+		return existing.OrganisationID == role.OrganisationID && existing.ID == role.ID
+	}, func(existing *models.Role) { *existing = *role })
+	if matched == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryRoleRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(role *models.Role) bool { return role.OrganisationID == organisationID && role.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryStockLocationRepository struct {
+	mu    sync.Mutex
+	store *memoryStore[models.StockLocation]
+}
+
+func NewMemoryStockLocationRepository() StockLocationRepository {
+	return &memoryStockLocationRepository{store: newMemoryStore[models.StockLocation]()}
+}
+
+func (r *memoryStockLocationRepository) Create(ctx context.Context, location *models.StockLocation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	location.ID = primitive.NewObjectID()
+	if location.Default {
+		r.clearDefault(location.OrganisationID, location.ID)
+	}
+	r.store.put(location.ID, *location)
+	return nil
+}
+
+func (r *memoryStockLocationRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.StockLocation, error) {
+	return r.store.first(func(l *models.StockLocation) bool { return l.OrganisationID == organisationID && l.ID == id })
+}
+
+func (r *memoryStockLocationRepository) EnsureDefault(ctx context.Context, organisationID primitive.ObjectID) (*models.StockLocation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	location, err := r.store.first(func(l *models.StockLocation) bool { return l.OrganisationID == organisationID && l.Default })
+	if err != ErrNotFound {
+		return location, err
+	}
+	location = &models.StockLocation{
+		ID:             primitive.NewObjectID(),
+		Name:           "Main",
+		Default:        true,
+		OrganisationID: organisationID,
+		UpdatedAt:      time.Now(),
+		CreatedAt:      time.Now(),
+	}
+	r.store.put(location.ID, *location)
+	return location, nil
+}
+
+func (r *memoryStockLocationRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.StockLocation, error) {
+	locations := r.store.find(func(l *models.StockLocation) bool { return l.OrganisationID == organisationID })
+	sort.SliceStable(locations, func(i, j int) bool { return locations[i].Name < locations[j].Name })
+	if locations == nil {
+		locations = []*models.StockLocation{}
+	}
+	return locations, nil
+}
+
+func (r *memoryStockLocationRepository) Update(ctx context.Context, location *models.StockLocation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.FindByID(ctx, location.OrganisationID, location.ID); err != nil {
+		return err
+	}
+	if location.Default {
+		r.clearDefault(location.OrganisationID, location.ID)
+	}
+	r.store.update(func(l *models.StockLocation) bool {
+		return l.OrganisationID == location.OrganisationID && l.ID == location.ID
+	}, func(l *models.StockLocation) { *l = *location })
+	return nil
+}
+
+// clearDefault removes the default flag from every location of the organisation except the given one
+func (r *memoryStockLocationRepository) clearDefault(organisationID primitive.ObjectID, except primitive.ObjectID) {
+	r.store.update(func(l *models.StockLocation) bool {
+		return l.OrganisationID == organisationID && l.ID != except
+	}, func(l *models.StockLocation) { l.Default = false })
+}
+
+func (r *memoryStockLocationRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(l *models.StockLocation) bool { return l.OrganisationID == organisationID && l.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
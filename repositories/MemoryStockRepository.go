@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+	"sync"
+)
+
+type memoryStockRepository struct {
+	mu        sync.Mutex
+	movements *memoryStore[models.StockMovement]
+	balances  *memoryStore[models.StockBalance]
+}
+
+func NewMemoryStockRepository() StockRepository {
+	return &memoryStockRepository{movements: newMemoryStore[models.StockMovement](), balances: newMemoryStore[models.StockBalance]()}
+}
+
+func (r *memoryStockRepository) Record(ctx context.Context, organisationID primitive.ObjectID, movements []*models.StockMovement, allowNegative bool, also func(ctx context.Context) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	//Work out every balance before storing anything, so a failing movement or also leaves the ledger untouched
+	changed := map[[2]primitive.ObjectID]*models.StockBalance{}
+	for _, movement := range movements {
+		movement.ID = primitive.NewObjectID()
+		movement.OrganisationID = organisationID
+		movement.Quantity = models.RoundQuantity(movement.Quantity)
+
+		key := [2]primitive.ObjectID{movement.ItemID, movement.LocationID}
+		balance, ok := changed[key]
+		if !ok {
+			balance, _ = r.balances.first(func(b *models.StockBalance) bool {
+				return b.OrganisationID == organisationID && b.ItemID == movement.ItemID && b.LocationID == movement.LocationID
+			})
+			if balance == nil {
+				balance = &models.StockBalance{ID: primitive.NewObjectID(), ItemID: movement.ItemID, LocationID: movement.LocationID, OrganisationID: organisationID}
+			}
+			changed[key] = balance
+		}
+
+		onHand := models.RoundQuantity(balance.OnHand + movement.Quantity)
+		if movement.Quantity < 0 && onHand < 0 && !allowNegative {
+			return ErrInsufficientStock
+		}
+		balance.OnHand = onHand
+		balance.UpdatedAt = movement.CreatedAt
+		movement.BalanceAfter = onHand
+	}
+
+	if also != nil {
+		if err := also(ctx); err != nil {
+			return err
+		}
+	}
+
+	for _, balance := range changed {
+		r.balances.put(balance.ID, *balance)
+	}
+	for _, movement := range movements {
+		r.movements.put(movement.ID, *movement)
+	}
+	return nil
+}
+
+func (r *memoryStockRepository) FindMovements(ctx context.Context, organisationID primitive.ObjectID, filter models.StockMovementFilter, query listing.Query) ([]*models.StockMovement, listing.Page, error) {
+	movements := r.movements.find(func(m *models.StockMovement) bool {
+		if m.OrganisationID != organisationID {
+			return false
+		}
+		if filter.ItemID != nil && m.ItemID != *filter.ItemID {
+			return false
+		}
+		if filter.LocationID != nil && m.LocationID != *filter.LocationID {
+			return false
+		}
+		return filter.Type == "" || m.Type == filter.Type
+	})
+	return memoryPage(movements, query.OrSort("-created_at"), StockMovementSortFields, stockMovementID)
+}
+
+func (r *memoryStockRepository) FindBalances(ctx context.Context, organisationID primitive.ObjectID, filter models.StockBalanceFilter) ([]*models.StockBalance, error) {
+	balances := r.balances.find(func(b *models.StockBalance) bool {
+		if b.OrganisationID != organisationID {
+			return false
+		}
+		if filter.ItemID != nil && b.ItemID != *filter.ItemID {
+			return false
+		}
+		return filter.LocationID == nil || b.LocationID == *filter.LocationID
+	})
+	if balances == nil {
+		balances = []*models.StockBalance{}
+	}
+	return balances, nil
+}
+
+func (r *memoryStockRepository) LastUnitCost(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) (*money.Money, error) {
+	receipts, _, err := r.FindMovements(ctx, organisationID, models.StockMovementFilter{ItemID: &itemID, Type: models.StockReceipt}, listing.Query{})
+	if err != nil {
+		return nil, err
+	}
+	for _, receipt := range receipts {
+		if receipt.UnitCost != nil {
+			return receipt.UnitCost, nil
+		}
+	}
+	return nil, nil
+}
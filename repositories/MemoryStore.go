@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"sort"
+	"sync"
+)
+
+// memoryStore keeps the documents of an in-memory repository by ID. Documents are copied through BSON when they are
+// stored and handed out, like MongoDB does, so callers never share their slices, maps or pointers with the store.
+type memoryStore[T any] struct {
+	mu        sync.RWMutex
+	documents map[primitive.ObjectID]T
+}
+
+func newMemoryStore[T any]() *memoryStore[T] {
+	return &memoryStore[T]{documents: map[primitive.ObjectID]T{}}
+}
+
+func (s *memoryStore[T]) put(id primitive.ObjectID, document T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents[id] = clone(document)
+}
+
+// clone returns a deep copy of the document, made by encoding and decoding it as MongoDB would
+func clone[T any](document T) T {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		panic(err)
+	}
+	var copied T
+	if err := bson.Unmarshal(raw, &copied); err != nil {
+		panic(err)
+	}
+	return copied
+}
+
+// find returns the matching documents ordered by ID, which is the order they were created in
+func (s *memoryStore[T]) find(match func(*T) bool) []*T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]primitive.ObjectID, 0, len(s.documents))
+	for id := range s.documents {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Hex() < ids[j].Hex() })
+
+	var documents []*T
+	for _, id := range ids {
+		document := s.documents[id]
+		if match(&document) {
+			copied := clone(document)
+			documents = append(documents, &copied)
+		}
+	}
+	return documents
+}
+
+func (s *memoryStore[T]) first(match func(*T) bool) (*T, error) {
+	documents := s.find(match)
+	if len(documents) == 0 {
+		return nil, ErrNotFound
+	}
+	return documents[0], nil
+}
+
+// update applies the change to every matching document atomically and returns how many documents matched
+func (s *memoryStore[T]) update(match func(*T) bool, apply func(*T)) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matched := 0
+	for id, document := range s.documents {
+		if match(&document) {
+			apply(&document)
+			s.documents[id] = clone(document)
+			matched++
+		}
+	}
+	return matched
+}
+
+// delete removes every matching document and returns how many were removed
+func (s *memoryStore[T]) delete(match func(*T) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := 0
+	for id, document := range s.documents {
+		if match(&document) {
+			delete(s.documents, id)
+			deleted++
+		}
+	}
+	return deleted
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"sort"
+)
+
+type memorySupplierRepository struct {
+	store *memoryStore[models.Supplier]
+}
+
+func NewMemorySupplierRepository() SupplierRepository {
+	return &memorySupplierRepository{store: newMemoryStore[models.Supplier]()}
+}
+
+func (r *memorySupplierRepository) Create(ctx context.Context, supplier *models.Supplier) error {
+	supplier.ID = primitive.NewObjectID()
+	r.store.put(supplier.ID, *supplier)
+	return nil
+}
+
+func (r *memorySupplierRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Supplier, error) {
+	return r.store.first(func(s *models.Supplier) bool { return s.OrganisationID == organisationID && s.ID == id })
+}
+
+func (r *memorySupplierRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Supplier, error) {
+	suppliers := r.store.find(func(s *models.Supplier) bool { return s.OrganisationID == organisationID })
+	sort.SliceStable(suppliers, func(i, j int) bool { return suppliers[i].Name < suppliers[j].Name })
+	if suppliers == nil {
+		suppliers = []*models.Supplier{}
+	}
+	return suppliers, nil
+}
+
+func (r *memorySupplierRepository) Update(ctx context.Context, supplier *models.Supplier) error {
+	updated := r.store.update(func(s *models.Supplier) bool {
+		return s.OrganisationID == supplier.OrganisationID && s.ID == supplier.ID
+	}, func(s *models.Supplier) { *s = *supplier })
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memorySupplierRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(s *models.Supplier) bool { return s.OrganisationID == organisationID && s.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
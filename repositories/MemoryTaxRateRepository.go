@@ -0,0 +1,68 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+	"sort"
+)
+
+type memoryTaxRateRepository struct {
+	store *memoryStore[models.TaxRate]
+}
+
+func NewMemoryTaxRateRepository() TaxRateRepository {
+	return &memoryTaxRateRepository{store: newMemoryStore[models.TaxRate]()}
+}
+
+func (r *memoryTaxRateRepository) Create(ctx context.Context, taxRate *models.TaxRate) error {
+	taxRate.ID = primitive.NewObjectID()
+	r.store.put(taxRate.ID, *taxRate)
+	return nil
+}
+
+func (r *memoryTaxRateRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.TaxRate, error) {
+	return r.store.first(func(t *models.TaxRate) bool { return t.OrganisationID == organisationID && t.ID == id })
+}
+
+func (r *memoryTaxRateRepository) FindDefault(ctx context.Context, organisationID primitive.ObjectID) (*models.TaxRate, error) {
+	return r.store.first(func(t *models.TaxRate) bool { return t.OrganisationID == organisationID && t.Default })
+}
+
+func (r *memoryTaxRateRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.TaxRate, error) {
+	taxRates := r.store.find(func(t *models.TaxRate) bool { return t.OrganisationID == organisationID })
+	sort.SliceStable(taxRates, func(i, j int) bool {
+		if taxRates[i].Percent != taxRates[j].Percent {
+			return taxRates[i].Percent > taxRates[j].Percent
+		}
+		return taxRates[i].Name < taxRates[j].Name
+	})
+	if taxRates == nil {
+		taxRates = []*models.TaxRate{}
+	}
+	return taxRates, nil
+}
+
+func (r *memoryTaxRateRepository) Update(ctx context.Context, taxRate *models.TaxRate) error {
+	updated := r.store.update(func(t *models.TaxRate) bool {
+		return t.OrganisationID == taxRate.OrganisationID && t.ID == taxRate.ID
+	}, func(t *models.TaxRate) { *t = *taxRate })
+	if updated == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryTaxRateRepository) ClearDefault(ctx context.Context, organisationID primitive.ObjectID, except primitive.ObjectID) error {
+	r.store.update(func(t *models.TaxRate) bool {
+		return t.OrganisationID == organisationID && t.ID != except
+	}, func(t *models.TaxRate) { t.Default = false })
+	return nil
+}
+
+func (r *memoryTaxRateRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(t *models.TaxRate) bool { return t.OrganisationID == organisationID && t.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+)
+
+type memoryUserRepository struct {
+	store *memoryStore[models.User]
+}
+
+func NewMemoryUserRepository() UserRepository {
+	return &memoryUserRepository{store: newMemoryStore[models.User]()}
+}
+
+func (r *memoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	user.ID = primitive.NewObjectID()
+	r.store.put(user.ID, *user)
+	return nil
+}
+
+func (r *memoryUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	return r.store.first(func(u *models.User) bool { return u.ID == id })
+}
+
+func (r *memoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.store.first(func(u *models.User) bool { return u.Email == email })
+}
+
+func (r *memoryUserRepository) FindMember(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.User, error) {
+	return r.store.first(func(u *models.User) bool {
+		return u.ID == id && u.OrganisationID != nil && *u.OrganisationID == organisationID
+	})
+}
+
+func (r *memoryUserRepository) CountByRole(ctx context.Context, organisationID primitive.ObjectID, role string) (int64, error) {
+	users := r.store.find(func(u *models.User) bool {
+		return u.Role == role && u.OrganisationID != nil && *u.OrganisationID == organisationID
+	})
+	return int64(len(users)), nil
+}
+
+func (r *memoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	matched := r.store.update(func(u *models.User) bool { return u.ID == user.ID }, func(u *models.User) { *u = *user })
+	if matched == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	if r.store.delete(func(u *models.User) bool { return u.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
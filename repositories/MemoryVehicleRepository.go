@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+type memoryVehicleRepository struct {
+	store *memoryStore[models.Vehicle]
+}
+
+func NewMemoryVehicleRepository() VehicleRepository {
+	return &memoryVehicleRepository{store: newMemoryStore[models.Vehicle]()}
+}
+
+func (r *memoryVehicleRepository) Create(ctx context.Context, vehicle *models.Vehicle) error {
+	vehicle.ID = primitive.NewObjectID()
+	r.store.put(vehicle.ID, *vehicle)
+	return nil
+}
+
+func (r *memoryVehicleRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Vehicle, error) {
+	return r.store.first(func(v *models.Vehicle) bool { return v.OrganisationID == organisationID && v.ID == id })
+}
+
+func (r *memoryVehicleRepository) FindByVIN(ctx context.Context, organisationID primitive.ObjectID, vin string) (*models.Vehicle, error) {
+	return r.store.first(func(v *models.Vehicle) bool { return v.OrganisationID == organisationID && v.VIN == vin })
+}
+
+func (r *memoryVehicleRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.VehicleFilter, query listing.Query) ([]*models.Vehicle, listing.Page, error) {
+	vehicles := r.store.find(func(v *models.Vehicle) bool {
+		if v.OrganisationID != organisationID {
+			return false
+		}
+		if filter.CustomerID != nil && (v.CustomerID == nil || *v.CustomerID != *filter.CustomerID) {
+			return false
+		}
+		if filter.VIN != "" && v.VIN != filter.VIN {
+			return false
+		}
+		return filter.Plate == "" || v.PlateNormalized == filter.Plate
+	})
+	return memoryPage(vehicles, query.OrSort("plate"), VehicleSortFields, vehicleID)
+}
+
+func (r *memoryVehicleRepository) Update(ctx context.Context, vehicle *models.Vehicle) error {
+	if r.store.update(func(v *models.Vehicle) bool {
+		return v.OrganisationID == vehicle.OrganisationID && v.ID == vehicle.ID
+	}, func(v *models.Vehicle) { *v = *vehicle }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryVehicleRepository) AddOdometerReading(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, reading models.OdometerReading) error {
+	if r.store.update(func(v *models.Vehicle) bool {
+		return v.OrganisationID == organisationID && v.ID == id
+	}, func(v *models.Vehicle) {
+		v.OdometerReadings = append(append([]models.OdometerReading{}, v.OdometerReadings...), reading)
+		v.UpdatedAt = reading.RecordedAt
+	}) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryVehicleRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(v *models.Vehicle) bool { return v.OrganisationID == organisationID && v.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryVehicleRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	r.store.update(func(v *models.Vehicle) bool {
+		if v.OrganisationID != organisationID || v.CustomerID == nil {
+			return false
+		}
+		for _, id := range from {
+			if *v.CustomerID == id {
+				return true
+			}
+		}
+		return false
+	}, func(v *models.Vehicle) { v.CustomerID = &to })
+	return nil
+}
+
+func (r *memoryVehicleRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	vehicles := r.store.find(func(v *models.Vehicle) bool {
+		return v.OrganisationID == organisationID && v.CustomerID != nil && *v.CustomerID == customerID
+	})
+	return len(vehicles) > 0, nil
+}
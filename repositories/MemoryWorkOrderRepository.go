@@ -0,0 +1,109 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+type memoryWorkOrderRepository struct {
+	store *memoryStore[models.WorkOrder]
+}
+
+func NewMemoryWorkOrderRepository() WorkOrderRepository {
+	return &memoryWorkOrderRepository{store: newMemoryStore[models.WorkOrder]()}
+}
+
+func (r *memoryWorkOrderRepository) Create(ctx context.Context, workOrder *models.WorkOrder) error {
+	workOrder.ID = primitive.NewObjectID()
+	r.store.put(workOrder.ID, *workOrder)
+	return nil
+}
+
+func (r *memoryWorkOrderRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.WorkOrder, error) {
+	return r.store.first(func(w *models.WorkOrder) bool { return w.OrganisationID == organisationID && w.ID == id })
+}
+
+func (r *memoryWorkOrderRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.WorkOrderFilter, query listing.Query) ([]*models.WorkOrder, listing.Page, error) {
+	workOrders := r.store.find(func(w *models.WorkOrder) bool {
+		if w.OrganisationID != organisationID {
+			return false
+		}
+		if filter.Status != "" && w.Status != filter.Status {
+			return false
+		}
+		if filter.CustomerID != nil && w.CustomerID != *filter.CustomerID {
+			return false
+		}
+		return filter.VehicleID == nil || (w.VehicleID != nil && *w.VehicleID == *filter.VehicleID)
+	})
+	return memoryPage(workOrders, query.OrSort("-created_at"), WorkOrderSortFields, workOrderID)
+}
+
+func (r *memoryWorkOrderRepository) Update(ctx context.Context, workOrder *models.WorkOrder, status models.WorkOrderStatus) error {
+	found := false
+	if r.store.update(func(w *models.WorkOrder) bool {
+		if w.OrganisationID != workOrder.OrganisationID || w.ID != workOrder.ID {
+			return false
+		}
+		found = true
+		return w.Status == status
+	}, func(w *models.WorkOrder) { *w = *workOrder }) == 0 {
+		if !found {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *memoryWorkOrderRepository) Transition(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, change models.WorkOrderStatusChange) error {
+	found := false
+	if r.store.update(func(w *models.WorkOrder) bool {
+		if w.OrganisationID != organisationID || w.ID != id {
+			return false
+		}
+		found = true
+		return w.Status == change.From
+	}, func(w *models.WorkOrder) {
+		w.Status = change.To
+		w.UpdatedAt = change.ChangedAt
+		w.History = append(append([]models.WorkOrderStatusChange{}, w.History...), change)
+	}) == 0 {
+		if !found {
+			return ErrNotFound
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *memoryWorkOrderRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	if r.store.delete(func(w *models.WorkOrder) bool { return w.OrganisationID == organisationID && w.ID == id }) == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *memoryWorkOrderRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	r.store.update(func(w *models.WorkOrder) bool {
+		if w.OrganisationID != organisationID {
+			return false
+		}
+		for _, id := range from {
+			if w.CustomerID == id {
+				return true
+			}
+		}
+		return false
+	}, func(w *models.WorkOrder) { w.CustomerID = to })
+	return nil
+}
+
+func (r *memoryWorkOrderRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	workOrders := r.store.find(func(w *models.WorkOrder) bool {
+		return w.OrganisationID == organisationID && w.CustomerID == customerID
+	})
+	return len(workOrders) > 0, nil
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"poosible-backend/models"
+)
+
+type OrganisationRepository interface {
+	Create(ctx context.Context, organisation *models.Organisation) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.Organisation, error)
+	Update(ctx context.Context, organisation *models.Organisation) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type mongoOrganisationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoOrganisationRepository(collection *mongo.Collection) OrganisationRepository {
+	return &mongoOrganisationRepository{collection: collection}
+}
+
+func (r *mongoOrganisationRepository) Create(ctx context.Context, organisation *models.Organisation) error {
+	result, err := r.collection.InsertOne(ctx, organisation)
+	if err != nil {
+		return err
+	}
+	organisation.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoOrganisationRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.Organisation, error) {
+	var organisation *models.Organisation
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&organisation)
+	return organisation, notFound(err)
+}
+
+func (r *mongoOrganisationRepository) Update(ctx context.Context, organisation *models.Organisation) error {
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": organisation.ID}, organisation)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoOrganisationRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/listing"
+	"time"
+)
+
+// findPage reads the page of the documents matching the filter that the query asks for
+func findPage[T any](ctx context.Context, collection *scopedCollection, filter bson.M, query listing.Query, fields listing.Fields[T], id func(document *T) primitive.ObjectID) ([]*T, listing.Page, error) {
+	filter, opts, err := listing.Mongo(filter, query, fields)
+	if err != nil {
+		return nil, listing.Page{}, err
+	}
+	result, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, listing.Page{}, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	documents := []*T{}
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var document *T
+		if err := result.Decode(&document); err != nil {
+			return nil, listing.Page{}, err
+		}
+		documents = append(documents, document)
+	}
+	if err := result.Err(); err != nil {
+		return nil, listing.Page{}, err
+	}
+
+	documents, page := listing.Cut(documents, query, fields, id)
+	return documents, page, nil
+}
+
+// memoryPage is the in-memory counterpart of findPage for documents that already match the filter
+func memoryPage[T any](documents []*T, query listing.Query, fields listing.Fields[T], id func(document *T) primitive.ObjectID) ([]*T, listing.Page, error) {
+	documents, err := listing.Memory(documents, query, fields, id)
+	if err != nil {
+		return nil, listing.Page{}, err
+	}
+	documents, page := listing.Cut(documents, query, fields, id)
+	return documents, page, nil
+}
+
+// dateTime returns the time as Mongo stores it, to the millisecond, so cursors compare the same in memory
+func dateTime(t time.Time) interface{} {
+	return primitive.NewDateTimeFromTime(t)
+}
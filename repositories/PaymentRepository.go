@@ -0,0 +1,220 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+)
+
+// ErrOverpayment is returned when a payment would take the payments of an invoice over its total, or a refund would
+// pay back more than was paid over it
+var ErrOverpayment = errors.New("payment exceeds the balance due")
+
+// ErrInsufficientCredit is returned when a payment spends more store credit than the customer has
+var ErrInsufficientCredit = errors.New("payment exceeds the store credit of the customer")
+
+// PaymentRepository stores the payments taken against invoices. Like invoices they are never changed or deleted, only
+// the customer they link to moves when customers are merged, and with it their store credit.
+type PaymentRepository interface {
+	CustomerReassigner
+	// Record stores the payment unless it takes the payments of its invoice over what is left of the invoice total
+	// after its credit notes, or a refund takes them under it. Payments spending store credit must leave the customer
+	// some. Payments of the same invoice or customer are recorded one at a time, so two tills taking the rest of a
+	// balance at once cannot both succeed.
+	Record(ctx context.Context, payment *models.Payment, net money.Money) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Payment, error)
+	// FindByInvoice returns every payment of the invoice, oldest first
+	FindByInvoice(ctx context.Context, organisationID primitive.ObjectID, invoiceID primitive.ObjectID) ([]*models.Payment, error)
+	// FindAll returns a page of the matching payments, newest first unless the page is sorted otherwise
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.PaymentFilter, page listing.Query) ([]*models.Payment, listing.Page, error)
+	// StoreCredit returns the store credit the customer has left
+	StoreCredit(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID, currency string) (money.Money, error)
+}
+
+// PaymentSortFields are the fields payments can be listed by
+var PaymentSortFields = listing.Fields[models.Payment]{
+	"received_at": {Key: "received_at", Value: func(p *models.Payment) interface{} { return dateTime(p.ReceivedAt) }},
+	"total":       {Key: "total.amount", Value: func(p *models.Payment) interface{} { return p.Total.Amount }},
+}
+
+func paymentID(document *models.Payment) primitive.ObjectID {
+	return document.ID
+}
+
+// paymentsFit sums up the payments and reports whether the payment fits into the net invoice total on top of them. A
+// refund fits if it leaves at least the net total paid.
+func paymentsFit(payments []*models.Payment, payment *models.Payment, net money.Money) (bool, error) {
+	paid := payment.Total
+	for _, existing := range payments {
+		var err error
+		if paid, err = paid.Add(existing.Total); err != nil {
+			return false, err
+		}
+	}
+	if payment.Total.IsNegative() {
+		return paid.Amount >= net.Amount, nil
+	}
+	return paid.Amount <= net.Amount, nil
+}
+
+// spendsStoreCredit reports whether the payment takes store credit off its customer
+func spendsStoreCredit(payment *models.Payment) bool {
+	for _, tender := range payment.Tenders {
+		if tender.Method == models.PaymentStoreCredit && !tender.Amount.IsNegative() {
+			return true
+		}
+	}
+	return false
+}
+
+// creditFits reports whether the customer has the store credit the payment spends on top of their other payments
+func creditFits(payments []*models.Payment, payment *models.Payment) (bool, error) {
+	if payment.CustomerID == nil {
+		return false, nil
+	}
+	credit, err := models.StoreCredit(append(payments, payment), payment.Currency)
+	if err != nil {
+		return false, err
+	}
+	return !credit.IsNegative(), nil
+}
+
+type mongoPaymentRepository struct {
+	collection *mongo.Collection
+	sequences  *mongo.Collection
+}
+
+func NewMongoPaymentRepository(collection *mongo.Collection, sequences *mongo.Collection) PaymentRepository {
+	return &mongoPaymentRepository{collection: collection, sequences: sequences}
+}
+
+func (r *mongoPaymentRepository) Record(ctx context.Context, payment *models.Payment, net money.Money) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		//Counting the payments of the invoice makes concurrent payments of it write the same document, so all but one
+		//of them conflict and are retried after it
+		if _, err := nextSequence(sessionContext, r.sequences, payment.OrganisationID, "payments:"+payment.InvoiceID.Hex()); err != nil {
+			return err
+		}
+		payments, err := r.FindByInvoice(sessionContext, payment.OrganisationID, payment.InvoiceID)
+		if err != nil {
+			return err
+		}
+		fits, err := paymentsFit(payments, payment, net)
+		if err != nil {
+			return err
+		}
+		if !fits {
+			return ErrOverpayment
+		}
+
+		//Spending store credit locks the credit of the customer the same way
+		if spendsStoreCredit(payment) {
+			if payment.CustomerID == nil {
+				return ErrInsufficientCredit
+			}
+			if _, err := nextSequence(sessionContext, r.sequences, payment.OrganisationID, "store_credit:"+payment.CustomerID.Hex()); err != nil {
+				return err
+			}
+			credited, err := r.findStoreCredit(sessionContext, payment.OrganisationID, *payment.CustomerID)
+			if err != nil {
+				return err
+			}
+			if fits, err = creditFits(credited, payment); err != nil {
+				return err
+			}
+			if !fits {
+				return ErrInsufficientCredit
+			}
+		}
+
+		payment.ID = primitive.NilObjectID
+		result, err := scope(r.collection, payment.OrganisationID).InsertOne(sessionContext, payment)
+		if err != nil {
+			return err
+		}
+		payment.ID = result.InsertedID.(primitive.ObjectID)
+		return nil
+	})
+}
+
+func (r *mongoPaymentRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Payment, error) {
+	var payment *models.Payment
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&payment)
+	return payment, notFound(err)
+}
+
+func (r *mongoPaymentRepository) FindByInvoice(ctx context.Context, organisationID primitive.ObjectID, invoiceID primitive.ObjectID) ([]*models.Payment, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "received_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := scope(r.collection, organisationID).Find(ctx, bson.M{"invoice_id": invoiceID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	payments := []*models.Payment{}
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
+func (r *mongoPaymentRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.PaymentFilter, page listing.Query) ([]*models.Payment, listing.Page, error) {
+	query := bson.M{}
+	if filter.InvoiceID != nil {
+		query["invoice_id"] = *filter.InvoiceID
+	}
+	if filter.CustomerID != nil {
+		query["customer_id"] = *filter.CustomerID
+	}
+	if filter.CashSessionID != nil {
+		query["cash_session_id"] = *filter.CashSessionID
+	}
+	if filter.Method != "" {
+		query["tenders.method"] = filter.Method
+	}
+	return findPage(ctx, scope(r.collection, organisationID), query, page.OrSort("-received_at"), PaymentSortFields, paymentID)
+}
+
+// ReassignCustomer takes the store credit lock of every customer involved, so the credit of a duplicate cannot be
+// spent while it moves
+func (r *mongoPaymentRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		for _, id := range append([]primitive.ObjectID{to}, from...) {
+			if _, err := nextSequence(sessionContext, r.sequences, organisationID, "store_credit:"+id.Hex()); err != nil {
+				return err
+			}
+		}
+		_, err := scope(r.collection, organisationID).UpdateMany(sessionContext, bson.M{"customer_id": bson.M{"$in": from}}, bson.M{"$set": bson.M{"customer_id": to}})
+		return err
+	})
+}
+
+func (r *mongoPaymentRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"customer_id": customerID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
+
+func (r *mongoPaymentRepository) StoreCredit(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID, currency string) (money.Money, error) {
+	payments, err := r.findStoreCredit(ctx, organisationID, customerID)
+	if err != nil {
+		return money.Zero(currency), err
+	}
+	return models.StoreCredit(payments, currency)
+}
+
+// findStoreCredit returns the payments of the customer with a store credit tender
+func (r *mongoPaymentRepository) findStoreCredit(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) ([]*models.Payment, error) {
+	cursor, err := scope(r.collection, organisationID).Find(ctx, bson.M{"customer_id": customerID, "tenders.method": models.PaymentStoreCredit})
+	if err != nil {
+		return nil, err
+	}
+	payments := []*models.Payment{}
+	if err := cursor.All(ctx, &payments); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
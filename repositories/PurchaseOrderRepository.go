@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+// PurchaseOrderSortFields are the fields purchase orders can be listed by
+var PurchaseOrderSortFields = listing.Fields[models.PurchaseOrder]{
+	"number":     {Key: "number", Value: func(o *models.PurchaseOrder) interface{} { return o.Number }},
+	"status":     {Key: "status", Value: func(o *models.PurchaseOrder) interface{} { return string(o.Status) }},
+	"created_at": {Key: "created_at", Value: func(o *models.PurchaseOrder) interface{} { return dateTime(o.CreatedAt) }},
+	"updated_at": {Key: "updated_at", Value: func(o *models.PurchaseOrder) interface{} { return dateTime(o.UpdatedAt) }},
+}
+
+func purchaseOrderID(document *models.PurchaseOrder) primitive.ObjectID {
+	return document.ID
+}
+
+type PurchaseOrderRepository interface {
+	// Create numbers the order from the purchase order sequence and stores it
+	Create(ctx context.Context, order *models.PurchaseOrder) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.PurchaseOrder, error)
+	// Update replaces the order if it is still at the given version and increments its version
+	Update(ctx context.Context, order *models.PurchaseOrder, version int) error
+	// FindAll returns a page of the matching orders, newest first unless the page is sorted otherwise
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.PurchaseOrderFilter, page listing.Query) ([]*models.PurchaseOrder, listing.Page, error)
+	// OnOrder returns the quantity of the item that open orders still expect
+	OnOrder(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) (float64, error)
+}
+
+type mongoPurchaseOrderRepository struct {
+	collection *mongo.Collection
+	sequences  *mongo.Collection
+}
+
+func NewMongoPurchaseOrderRepository(collection *mongo.Collection, sequences *mongo.Collection) PurchaseOrderRepository {
+	return &mongoPurchaseOrderRepository{collection: collection, sequences: sequences}
+}
+
+func (r *mongoPurchaseOrderRepository) Create(ctx context.Context, order *models.PurchaseOrder) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		order.ID = primitive.NilObjectID
+		order.Sequence = models.DefaultPurchaseOrderNumbering.Sequence(models.PurchaseOrderSequence, order.CreatedAt)
+		value, err := nextSequence(sessionContext, r.sequences, order.OrganisationID, order.Sequence)
+		if err != nil {
+			return err
+		}
+		order.SequenceValue = value
+		order.Number = models.DefaultPurchaseOrderNumbering.Format(value, order.CreatedAt)
+
+		result, err := scope(r.collection, order.OrganisationID).InsertOne(sessionContext, order)
+		if err != nil {
+			return err
+		}
+		order.ID = result.InsertedID.(primitive.ObjectID)
+		return nil
+	})
+}
+
+func (r *mongoPurchaseOrderRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.PurchaseOrder, error) {
+	var order *models.PurchaseOrder
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&order)
+	return order, notFound(err)
+}
+
+func (r *mongoPurchaseOrderRepository) Update(ctx context.Context, order *models.PurchaseOrder, version int) error {
+	order.Version = version + 1
+	result, err := scope(r.collection, order.OrganisationID).ReplaceOne(ctx, bson.M{"_id": order.ID, "version": version}, order)
+	if err != nil {
+		order.Version = version
+		return err
+	}
+	if result.MatchedCount == 0 {
+		order.Version = version
+		if _, err := r.FindByID(ctx, order.OrganisationID, order.ID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *mongoPurchaseOrderRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.PurchaseOrderFilter, page listing.Query) ([]*models.PurchaseOrder, listing.Page, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.SupplierID != nil {
+		query["supplier_id"] = *filter.SupplierID
+	}
+	if filter.ItemID != nil {
+		query["lines.item_id"] = *filter.ItemID
+	}
+	return findPage(ctx, scope(r.collection, organisationID), query, page.OrSort("-created_at"), PurchaseOrderSortFields, purchaseOrderID)
+}
+
+func (r *mongoPurchaseOrderRepository) OnOrder(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) (float64, error) {
+	orders, err := r.find(ctx, organisationID, bson.M{"status": bson.M{"$in": models.OpenPurchaseOrderStatuses}, "lines.item_id": itemID})
+	if err != nil {
+		return 0, err
+	}
+	return onOrder(orders, itemID), nil
+}
+
+func (r *mongoPurchaseOrderRepository) find(ctx context.Context, organisationID primitive.ObjectID, query bson.M) ([]*models.PurchaseOrder, error) {
+	orders := []*models.PurchaseOrder{}
+	result, err := scope(r.collection, organisationID).Find(ctx, query, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var order *models.PurchaseOrder
+		if err := result.Decode(&order); err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, result.Err()
+}
+
+// onOrder sums the outstanding quantities of the item on the orders
+func onOrder(orders []*models.PurchaseOrder, itemID primitive.ObjectID) float64 {
+	quantity := 0.0
+	for _, order := range orders {
+		for _, line := range order.Lines {
+			if line.ItemID == itemID {
+				quantity += line.Outstanding()
+			}
+		}
+	}
+	return models.RoundQuantity(quantity)
+}
@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"poosible-backend/models"
+	"time"
+)
+
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, refreshToken *models.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkUsed marks a token that was neither used nor revoked as used, it reports false if another request got there first
+	MarkUsed(ctx context.Context, id primitive.ObjectID, usedAt time.Time) (bool, error)
+	SetReplacedBy(ctx context.Context, id primitive.ObjectID, replacedBy primitive.ObjectID) error
+	RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error
+}
+
+type mongoRefreshTokenRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRefreshTokenRepository(collection *mongo.Collection) RefreshTokenRepository {
+	return &mongoRefreshTokenRepository{collection: collection}
+}
+
+func (r *mongoRefreshTokenRepository) Create(ctx context.Context, refreshToken *models.RefreshToken) error {
+	result, err := r.collection.InsertOne(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	refreshToken.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoRefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var refreshToken *models.RefreshToken
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&refreshToken)
+	return refreshToken, notFound(err)
+}
+
+func (r *mongoRefreshTokenRepository) MarkUsed(ctx context.Context, id primitive.ObjectID, usedAt time.Time) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id, "used_at": nil, "revoked_at": nil}, bson.M{"$set": bson.M{"used_at": usedAt}})
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount == 1, nil
+}
+
+func (r *mongoRefreshTokenRepository) SetReplacedBy(ctx context.Context, id primitive.ObjectID, replacedBy primitive.ObjectID) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"replaced_by": replacedBy}})
+	return err
+}
+
+func (r *mongoRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, revokedAt time.Time) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{"family_id": familyID, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": revokedAt}})
+	return err
+}
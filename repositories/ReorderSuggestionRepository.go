@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/models"
+)
+
+// ReorderSuggestionRepository holds the items that need reordering, one suggestion per item
+type ReorderSuggestionRepository interface {
+	// Save stores the suggestion, replacing the one of the same item
+	Save(ctx context.Context, suggestion *models.ReorderSuggestion) error
+	// Delete removes the suggestion of the item, if it has one
+	Delete(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) error
+	// FindAll returns the suggestions of the organisation by item name
+	FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.ReorderSuggestion, error)
+}
+
+type mongoReorderSuggestionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoReorderSuggestionRepository(collection *mongo.Collection) ReorderSuggestionRepository {
+	return &mongoReorderSuggestionRepository{collection: collection}
+}
+
+func (r *mongoReorderSuggestionRepository) Save(ctx context.Context, suggestion *models.ReorderSuggestion) error {
+	var saved *models.ReorderSuggestion
+	suggestion.ID = primitive.NilObjectID
+	err := scope(r.collection, suggestion.OrganisationID).FindOneAndUpdate(ctx,
+		bson.M{"item_id": suggestion.ItemID},
+		bson.M{"$set": suggestion},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&saved)
+	if err != nil {
+		return err
+	}
+	suggestion.ID = saved.ID
+	return nil
+}
+
+func (r *mongoReorderSuggestionRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) error {
+	_, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"item_id": itemID})
+	return err
+}
+
+func (r *mongoReorderSuggestionRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.ReorderSuggestion, error) {
+	suggestions := []*models.ReorderSuggestion{}
+	result, err := scope(r.collection, organisationID).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "item_name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var suggestion *models.ReorderSuggestion
+		if err := result.Decode(&suggestion); err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, result.Err()
+}
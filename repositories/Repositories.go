@@ -0,0 +1,96 @@
+package repositories
+
+import (
+	"errors"
+	"go.mongodb.org/mongo-driver/mongo"
+	"poosible-backend/config"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// ErrVersionConflict is returned when a document was changed by someone else since it was read
+var ErrVersionConflict = errors.New("version conflict")
+
+// Repositories bundles every repository the handlers depend on
+type Repositories struct {
+	Users              UserRepository
+	Organisations      OrganisationRepository
+	Items              ItemRepository
+	Roles              RoleRepository
+	RefreshTokens      RefreshTokenRepository
+	Customers          CustomerRepository
+	Vehicles           VehicleRepository
+	WorkOrders         WorkOrderRepository
+	Invoices           InvoiceRepository
+	TaxRates           TaxRateRepository
+	StockLocations     StockLocationRepository
+	Stock              StockRepository
+	Suppliers          SupplierRepository
+	PurchaseOrders     PurchaseOrderRepository
+	ReorderSuggestions ReorderSuggestionRepository
+	Categories         CategoryRepository
+	ItemImports        ItemImportRepository
+	Payments           PaymentRepository
+	CashSessions       CashSessionRepository
+	Estimates          EstimateRepository
+}
+
+// NewMongoRepositories returns repositories backed by the collections of the database
+func NewMongoRepositories(database *mongo.Database) *Repositories {
+	return &Repositories{
+		Users:              NewMongoUserRepository(config.GetCollection(database, "users")),
+		Organisations:      NewMongoOrganisationRepository(config.GetCollection(database, "organisations")),
+		Items:              NewMongoItemRepository(config.GetCollection(database, "items")),
+		Roles:              NewMongoRoleRepository(config.GetCollection(database, "roles")),
+		RefreshTokens:      NewMongoRefreshTokenRepository(config.GetCollection(database, "refresh_tokens")),
+		Customers:          NewMongoCustomerRepository(config.GetCollection(database, "customers")),
+		Vehicles:           NewMongoVehicleRepository(config.GetCollection(database, "vehicles")),
+		WorkOrders:         NewMongoWorkOrderRepository(config.GetCollection(database, "work_orders")),
+		Invoices:           NewMongoInvoiceRepository(config.GetCollection(database, "invoices"), config.GetCollection(database, "sequences")),
+		TaxRates:           NewMongoTaxRateRepository(config.GetCollection(database, "tax_rates")),
+		StockLocations:     NewMongoStockLocationRepository(config.GetCollection(database, "stock_locations")),
+		Stock:              NewMongoStockRepository(config.GetCollection(database, "stock_movements"), config.GetCollection(database, "stock_balances")),
+		Suppliers:          NewMongoSupplierRepository(config.GetCollection(database, "suppliers")),
+		PurchaseOrders:     NewMongoPurchaseOrderRepository(config.GetCollection(database, "purchase_orders"), config.GetCollection(database, "sequences")),
+		ReorderSuggestions: NewMongoReorderSuggestionRepository(config.GetCollection(database, "reorder_suggestions")),
+		Categories:         NewMongoCategoryRepository(config.GetCollection(database, "categories")),
+		ItemImports:        NewMongoItemImportRepository(config.GetCollection(database, "item_imports")),
+		Payments:           NewMongoPaymentRepository(config.GetCollection(database, "payments"), config.GetCollection(database, "sequences")),
+		CashSessions:       NewMongoCashSessionRepository(config.GetCollection(database, "cash_sessions"), config.GetCollection(database, "sequences")),
+		Estimates:          NewMongoEstimateRepository(config.GetCollection(database, "estimates")),
+	}
+}
+
+// NewMemoryRepositories returns repositories that keep everything in memory, for tests and local tooling
+func NewMemoryRepositories() *Repositories {
+	return &Repositories{
+		Users:              NewMemoryUserRepository(),
+		Organisations:      NewMemoryOrganisationRepository(),
+		Items:              NewMemoryItemRepository(),
+		Roles:              NewMemoryRoleRepository(),
+		RefreshTokens:      NewMemoryRefreshTokenRepository(),
+		Customers:          NewMemoryCustomerRepository(),
+		Vehicles:           NewMemoryVehicleRepository(),
+		WorkOrders:         NewMemoryWorkOrderRepository(),
+		Invoices:           NewMemoryInvoiceRepository(),
+		TaxRates:           NewMemoryTaxRateRepository(),
+		StockLocations:     NewMemoryStockLocationRepository(),
+		Stock:              NewMemoryStockRepository(),
+		Suppliers:          NewMemorySupplierRepository(),
+		PurchaseOrders:     NewMemoryPurchaseOrderRepository(),
+		ReorderSuggestions: NewMemoryReorderSuggestionRepository(),
+		Categories:         NewMemoryCategoryRepository(),
+		ItemImports:        NewMemoryItemImportRepository(),
+		Payments:           NewMemoryPaymentRepository(),
+		CashSessions:       NewMemoryCashSessionRepository(),
+		Estimates:          NewMemoryEstimateRepository(),
+	}
+}
+
+// notFound translates the driver error for a missing document into ErrNotFound
+func notFound(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"poosible-backend/models"
+)
+
+type RoleRepository interface {
+	Create(ctx context.Context, role *models.Role) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Role, error)
+	FindByName(ctx context.Context, organisationID primitive.ObjectID, name string) (*models.Role, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Role, error)
+	Update(ctx context.Context, role *models.Role) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+}
+
+type mongoRoleRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRoleRepository(collection *mongo.Collection) RoleRepository {
+	return &mongoRoleRepository{collection: collection}
+}
+
+func (r *mongoRoleRepository) Create(ctx context.Context, role *models.Role) error {
+	result, err := scope(r.collection, role.OrganisationID).InsertOne(ctx, role)
+	if err != nil {
+		return err
+	}
+	role.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoRoleRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Role, error) {
+	var role *models.Role
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&role)
+	return role, notFound(err)
+}
+
+func (r *mongoRoleRepository) FindByName(ctx context.Context, organisationID primitive.ObjectID, name string) (*models.Role, error) {
+	var role *models.Role
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"name": name}).Decode(&role)
+	return role, notFound(err)
+}
+
+func (r *mongoRoleRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Role, error) {
+	var roles []*models.Role
+	result, err := scope(r.collection, organisationID).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var role *models.Role
+		if err := result.Decode(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, result.Err()
+}
+
+func (r *mongoRoleRepository) Update(ctx context.Context, role *models.Role) error {
+	result, err := scope(r.collection, role.OrganisationID).ReplaceOne(ctx, bson.M{"_id": role.ID}, role)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoRoleRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// scopedCollection wraps a collection so that every filter is restricted to one organisation and every inserted
+// document belongs to it. Documents of other organisations behave as if they did not exist.
+type scopedCollection struct {
+	collection     *mongo.Collection
+	organisationID primitive.ObjectID
+}
+
+// scope restricts the collection to the documents of the organisation
+func scope(collection *mongo.Collection, organisationID primitive.ObjectID) *scopedCollection {
+	return &scopedCollection{collection: collection, organisationID: organisationID}
+}
+
+func (s *scopedCollection) filter(filter bson.M) bson.M {
+	scoped := bson.M{}
+	for key, value := range filter {
+		scoped[key] = value
+	}
+	scoped["organisation_id"] = s.organisationID
+	return scoped
+}
+
+func (s *scopedCollection) FindOne(ctx context.Context, filter bson.M, opts ...*options.FindOneOptions) *mongo.SingleResult {
+	return s.collection.FindOne(ctx, s.filter(filter), opts...)
+}
+
+func (s *scopedCollection) Find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	return s.collection.Find(ctx, s.filter(filter), opts...)
+}
+
+func (s *scopedCollection) CountDocuments(ctx context.Context, filter bson.M, opts ...*options.CountOptions) (int64, error) {
+	return s.collection.CountDocuments(ctx, s.filter(filter), opts...)
+}
+
+// InsertOne inserts the document with its organisation_id set to the organisation of the scope
+func (s *scopedCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+
+	var scoped bson.D
+	if err := bson.Unmarshal(data, &scoped); err != nil {
+		return nil, err
+	}
+
+	found := false
+	for i := range scoped {
+		if scoped[i].Key == "organisation_id" {
+			scoped[i].Value = s.organisationID
+			found = true
+		}
+	}
+	if !found {
+		scoped = append(scoped, bson.E{Key: "organisation_id", Value: s.organisationID})
+	}
+
+	return s.collection.InsertOne(ctx, scoped, opts...)
+}
+
+func (s *scopedCollection) UpdateOne(ctx context.Context, filter bson.M, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return s.collection.UpdateOne(ctx, s.filter(filter), update, opts...)
+}
+
+func (s *scopedCollection) ReplaceOne(ctx context.Context, filter bson.M, replacement interface{}, opts ...*options.ReplaceOptions) (*mongo.UpdateResult, error) {
+	return s.collection.ReplaceOne(ctx, s.filter(filter), replacement, opts...)
+}
+
+func (s *scopedCollection) UpdateMany(ctx context.Context, filter bson.M, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	return s.collection.UpdateMany(ctx, s.filter(filter), update, opts...)
+}
+
+func (s *scopedCollection) FindOneAndUpdate(ctx context.Context, filter bson.M, update interface{}, opts ...*options.FindOneAndUpdateOptions) *mongo.SingleResult {
+	return s.collection.FindOneAndUpdate(ctx, s.filter(filter), update, opts...)
+}
+
+func (s *scopedCollection) DeleteOne(ctx context.Context, filter bson.M, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return s.collection.DeleteOne(ctx, s.filter(filter), opts...)
+}
+
+func (s *scopedCollection) DeleteMany(ctx context.Context, filter bson.M, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	return s.collection.DeleteMany(ctx, s.filter(filter), opts...)
+}
@@ -0,0 +1,38 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type sequence struct {
+	Name  string `bson:"name"`
+	Value int64  `bson:"value"`
+}
+
+// nextSequence increments the named counter of the organisation and returns its new value, starting at 1. Called
+// inside a transaction the increment is rolled back with it, which keeps the numbers it hands out free of gaps.
+func nextSequence(ctx context.Context, sequences *mongo.Collection, organisationID primitive.ObjectID, name string) (int64, error) {
+	var counter sequence
+	err := scope(sequences, organisationID).FindOneAndUpdate(ctx,
+		bson.M{"name": name},
+		bson.M{"$inc": bson.M{"value": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	return counter.Value, err
+}
+
+// memorySequences is the in-memory counterpart of the sequences collection. It is not safe for concurrent use, the
+// repositories using it hold their own lock while they allocate.
+type memorySequences map[string]int64
+
+func (s memorySequences) peek(organisationID primitive.ObjectID, name string) int64 {
+	return s[organisationID.Hex()+"/"+name] + 1
+}
+
+func (s memorySequences) commit(organisationID primitive.ObjectID, name string, value int64) {
+	s[organisationID.Hex()+"/"+name] = value
+}
@@ -0,0 +1,125 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/models"
+	"time"
+)
+
+type StockLocationRepository interface {
+	// Create stores the location. A default location takes the default from the other locations in the same
+	// transaction, so the organisation never has two.
+	Create(ctx context.Context, location *models.StockLocation) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.StockLocation, error)
+	// EnsureDefault returns the default location of the organisation, creating a "Main" location if it has none yet
+	EnsureDefault(ctx context.Context, organisationID primitive.ObjectID) (*models.StockLocation, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.StockLocation, error)
+	// Update replaces the location, taking the default from the other locations like Create
+	Update(ctx context.Context, location *models.StockLocation) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+}
+
+type mongoStockLocationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoStockLocationRepository(collection *mongo.Collection) StockLocationRepository {
+	return &mongoStockLocationRepository{collection: collection}
+}
+
+func (r *mongoStockLocationRepository) Create(ctx context.Context, location *models.StockLocation) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		//The unique index on the default location refuses a second one, so the old default goes first
+		if location.Default {
+			if err := r.clearDefault(sessionContext, location.OrganisationID, primitive.NilObjectID); err != nil {
+				return err
+			}
+		}
+		location.ID = primitive.NilObjectID
+		result, err := scope(r.collection, location.OrganisationID).InsertOne(sessionContext, location)
+		if err != nil {
+			return err
+		}
+		location.ID = result.InsertedID.(primitive.ObjectID)
+		return nil
+	})
+}
+
+func (r *mongoStockLocationRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.StockLocation, error) {
+	var location *models.StockLocation
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&location)
+	return location, notFound(err)
+}
+
+func (r *mongoStockLocationRepository) EnsureDefault(ctx context.Context, organisationID primitive.ObjectID) (*models.StockLocation, error) {
+	var location *models.StockLocation
+	now := time.Now()
+	err := scope(r.collection, organisationID).FindOneAndUpdate(ctx,
+		bson.M{"default": true},
+		bson.M{"$setOnInsert": bson.M{"name": "Main", "updated_at": now, "created_at": now}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&location)
+	//A concurrent call created the default first, the unique index refused the second one
+	if mongo.IsDuplicateKeyError(err) {
+		err = scope(r.collection, organisationID).FindOne(ctx, bson.M{"default": true}).Decode(&location)
+	}
+	return location, err
+}
+
+func (r *mongoStockLocationRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.StockLocation, error) {
+	locations := []*models.StockLocation{}
+	result, err := scope(r.collection, organisationID).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var location *models.StockLocation
+		if err := result.Decode(&location); err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+	return locations, result.Err()
+}
+
+func (r *mongoStockLocationRepository) Update(ctx context.Context, location *models.StockLocation) error {
+	return inTransaction(ctx, r.collection.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		if location.Default {
+			if err := r.clearDefault(sessionContext, location.OrganisationID, location.ID); err != nil {
+				return err
+			}
+		}
+		result, err := scope(r.collection, location.OrganisationID).ReplaceOne(sessionContext, bson.M{"_id": location.ID}, location)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// clearDefault removes the default flag from every location of the organisation except the given one
+func (r *mongoStockLocationRepository) clearDefault(ctx context.Context, organisationID primitive.ObjectID, except primitive.ObjectID) error {
+	_, err := scope(r.collection, organisationID).UpdateMany(ctx, bson.M{"_id": bson.M{"$ne": except}, "default": true}, bson.M{"$set": bson.M{"default": false}})
+	return err
+}
+
+func (r *mongoStockLocationRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+	"poosible-backend/money"
+)
+
+// ErrInsufficientStock is returned when a movement would take a balance below zero
+var ErrInsufficientStock = errors.New("insufficient stock")
+
+// StockRepository keeps the stock ledger and the balances cached from it
+type StockRepository interface {
+	// Record appends the movements to the ledger and applies them to the balances, setting their BalanceAfter. The
+	// also callback is called with the context of the same transaction, so the movements, the balances and its
+	// writes succeed or fail together. Unless allowNegative is set a movement that would take a balance below zero
+	// fails with ErrInsufficientStock.
+	Record(ctx context.Context, organisationID primitive.ObjectID, movements []*models.StockMovement, allowNegative bool, also func(ctx context.Context) error) error
+	// FindMovements returns a page of the matching ledger entries, newest first unless the page is sorted otherwise
+	FindMovements(ctx context.Context, organisationID primitive.ObjectID, filter models.StockMovementFilter, page listing.Query) ([]*models.StockMovement, listing.Page, error)
+	FindBalances(ctx context.Context, organisationID primitive.ObjectID, filter models.StockBalanceFilter) ([]*models.StockBalance, error)
+	// LastUnitCost returns the unit cost of the latest receipt of the item that recorded one, or nil if none did
+	LastUnitCost(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) (*money.Money, error)
+}
+
+// StockMovementSortFields are the fields ledger entries can be listed by
+var StockMovementSortFields = listing.Fields[models.StockMovement]{
+	"created_at": {Key: "created_at", Value: func(m *models.StockMovement) interface{} { return dateTime(m.CreatedAt) }},
+	"quantity":   {Key: "quantity", Value: func(m *models.StockMovement) interface{} { return m.Quantity }},
+}
+
+func stockMovementID(document *models.StockMovement) primitive.ObjectID {
+	return document.ID
+}
+
+type mongoStockRepository struct {
+	movements *mongo.Collection
+	balances  *mongo.Collection
+}
+
+func NewMongoStockRepository(movements *mongo.Collection, balances *mongo.Collection) StockRepository {
+	return &mongoStockRepository{movements: movements, balances: balances}
+}
+
+func (r *mongoStockRepository) Record(ctx context.Context, organisationID primitive.ObjectID, movements []*models.StockMovement, allowNegative bool, also func(ctx context.Context) error) error {
+	return inTransaction(ctx, r.movements.Database().Client(), func(sessionContext mongo.SessionContext) error {
+		for _, movement := range movements {
+			movement.ID = primitive.NilObjectID
+			movement.OrganisationID = organisationID
+			movement.Quantity = models.RoundQuantity(movement.Quantity)
+
+			balance, err := r.apply(sessionContext, organisationID, movement, allowNegative)
+			if err != nil {
+				return err
+			}
+			movement.BalanceAfter = balance.OnHand
+
+			result, err := scope(r.movements, organisationID).InsertOne(sessionContext, movement)
+			if err != nil {
+				return err
+			}
+			movement.ID = result.InsertedID.(primitive.ObjectID)
+		}
+
+		if also != nil {
+			return also(sessionContext)
+		}
+		return nil
+	})
+}
+
+// apply adds the movement to its balance. The addition is rounded by the database so the stored balance stays exact.
+func (r *mongoStockRepository) apply(ctx context.Context, organisationID primitive.ObjectID, movement *models.StockMovement, allowNegative bool) (*models.StockBalance, error) {
+	filter := bson.M{"item_id": movement.ItemID, "location_id": movement.LocationID}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"on_hand":    bson.M{"$round": bson.A{bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$on_hand", 0}}, movement.Quantity}}, 3}},
+		"updated_at": movement.CreatedAt,
+	}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	if movement.Quantity < 0 && !allowNegative {
+		//A missing balance is zero, which no outgoing movement can be taken from
+		filter["on_hand"] = bson.M{"$gte": -movement.Quantity}
+	} else {
+		opts.SetUpsert(true)
+	}
+
+	var balance *models.StockBalance
+	err := scope(r.balances, organisationID).FindOneAndUpdate(ctx, filter, update, opts).Decode(&balance)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrInsufficientStock
+	}
+	return balance, err
+}
+
+func (r *mongoStockRepository) FindMovements(ctx context.Context, organisationID primitive.ObjectID, filter models.StockMovementFilter, page listing.Query) ([]*models.StockMovement, listing.Page, error) {
+	query := bson.M{}
+	if filter.ItemID != nil {
+		query["item_id"] = *filter.ItemID
+	}
+	if filter.LocationID != nil {
+		query["location_id"] = *filter.LocationID
+	}
+	if filter.Type != "" {
+		query["type"] = filter.Type
+	}
+
+	return findPage(ctx, scope(r.movements, organisationID), query, page.OrSort("-created_at"), StockMovementSortFields, stockMovementID)
+}
+
+func (r *mongoStockRepository) FindBalances(ctx context.Context, organisationID primitive.ObjectID, filter models.StockBalanceFilter) ([]*models.StockBalance, error) {
+	query := bson.M{}
+	if filter.ItemID != nil {
+		query["item_id"] = *filter.ItemID
+	}
+	if filter.LocationID != nil {
+		query["location_id"] = *filter.LocationID
+	}
+
+	balances := []*models.StockBalance{}
+	result, err := scope(r.balances, organisationID).Find(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var balance *models.StockBalance
+		if err := result.Decode(&balance); err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	return balances, result.Err()
+}
+
+func (r *mongoStockRepository) LastUnitCost(ctx context.Context, organisationID primitive.ObjectID, itemID primitive.ObjectID) (*money.Money, error) {
+	var movement *models.StockMovement
+	err := scope(r.movements, organisationID).FindOne(ctx,
+		bson.M{"item_id": itemID, "type": models.StockReceipt, "unit_cost": bson.M{"$exists": true}},
+		options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}),
+	).Decode(&movement)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return movement.UnitCost, nil
+}
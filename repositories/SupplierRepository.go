@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/models"
+)
+
+type SupplierRepository interface {
+	Create(ctx context.Context, supplier *models.Supplier) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Supplier, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Supplier, error)
+	Update(ctx context.Context, supplier *models.Supplier) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+}
+
+type mongoSupplierRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoSupplierRepository(collection *mongo.Collection) SupplierRepository {
+	return &mongoSupplierRepository{collection: collection}
+}
+
+func (r *mongoSupplierRepository) Create(ctx context.Context, supplier *models.Supplier) error {
+	result, err := scope(r.collection, supplier.OrganisationID).InsertOne(ctx, supplier)
+	if err != nil {
+		return err
+	}
+	supplier.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoSupplierRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Supplier, error) {
+	var supplier *models.Supplier
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&supplier)
+	return supplier, notFound(err)
+}
+
+func (r *mongoSupplierRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.Supplier, error) {
+	suppliers := []*models.Supplier{}
+	result, err := scope(r.collection, organisationID).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var supplier *models.Supplier
+		if err := result.Decode(&supplier); err != nil {
+			return nil, err
+		}
+		suppliers = append(suppliers, supplier)
+	}
+	return suppliers, result.Err()
+}
+
+func (r *mongoSupplierRepository) Update(ctx context.Context, supplier *models.Supplier) error {
+	result, err := scope(r.collection, supplier.OrganisationID).ReplaceOne(ctx, bson.M{"_id": supplier.ID}, supplier)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoSupplierRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/models"
+)
+
+type TaxRateRepository interface {
+	Create(ctx context.Context, taxRate *models.TaxRate) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.TaxRate, error)
+	// FindDefault returns the rate of lines without one of their own, or ErrNotFound if the organisation has none
+	FindDefault(ctx context.Context, organisationID primitive.ObjectID) (*models.TaxRate, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.TaxRate, error)
+	Update(ctx context.Context, taxRate *models.TaxRate) error
+	// ClearDefault removes the default flag from every rate of the organisation except the given one
+	ClearDefault(ctx context.Context, organisationID primitive.ObjectID, except primitive.ObjectID) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+}
+
+type mongoTaxRateRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoTaxRateRepository(collection *mongo.Collection) TaxRateRepository {
+	return &mongoTaxRateRepository{collection: collection}
+}
+
+func (r *mongoTaxRateRepository) Create(ctx context.Context, taxRate *models.TaxRate) error {
+	result, err := scope(r.collection, taxRate.OrganisationID).InsertOne(ctx, taxRate)
+	if err != nil {
+		return err
+	}
+	taxRate.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoTaxRateRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.TaxRate, error) {
+	var taxRate *models.TaxRate
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&taxRate)
+	return taxRate, notFound(err)
+}
+
+func (r *mongoTaxRateRepository) FindDefault(ctx context.Context, organisationID primitive.ObjectID) (*models.TaxRate, error) {
+	var taxRate *models.TaxRate
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"default": true}).Decode(&taxRate)
+	return taxRate, notFound(err)
+}
+
+func (r *mongoTaxRateRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID) ([]*models.TaxRate, error) {
+	taxRates := []*models.TaxRate{}
+	result, err := scope(r.collection, organisationID).Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "percent", Value: -1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+
+	// Iterate through the result and read in optimal chunks
+	defer result.Close(ctx)
+	for result.Next(ctx) {
+		var taxRate *models.TaxRate
+		if err := result.Decode(&taxRate); err != nil {
+			return nil, err
+		}
+		taxRates = append(taxRates, taxRate)
+	}
+	return taxRates, result.Err()
+}
+
+func (r *mongoTaxRateRepository) Update(ctx context.Context, taxRate *models.TaxRate) error {
+	result, err := scope(r.collection, taxRate.OrganisationID).ReplaceOne(ctx, bson.M{"_id": taxRate.ID}, taxRate)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoTaxRateRepository) ClearDefault(ctx context.Context, organisationID primitive.ObjectID, except primitive.ObjectID) error {
+	_, err := scope(r.collection, organisationID).UpdateMany(ctx, bson.M{"_id": bson.M{"$ne": except}, "default": true}, bson.M{"$set": bson.M{"default": false}})
+	return err
+}
+
+func (r *mongoTaxRateRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
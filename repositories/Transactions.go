@@ -0,0 +1,25 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// inTransaction runs fn in a transaction of the client. If ctx already belongs to a transaction, for example because
+// the repository is called from the also callback of another repository, fn joins it instead of starting its own.
+func inTransaction(ctx context.Context, client *mongo.Client, fn func(ctx mongo.SessionContext) error) error {
+	if sessionContext, ok := ctx.(mongo.SessionContext); ok {
+		return fn(sessionContext)
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessionContext mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessionContext)
+	})
+	return err
+}
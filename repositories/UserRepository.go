@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"poosible-backend/models"
+)
+
+type UserRepository interface {
+	Create(ctx context.Context, user *models.User) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error)
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// FindMember finds a user only if they belong to the organisation
+	FindMember(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.User, error)
+	CountByRole(ctx context.Context, organisationID primitive.ObjectID, role string) (int64, error)
+	Update(ctx context.Context, user *models.User) error
+	Delete(ctx context.Context, id primitive.ObjectID) error
+}
+
+type mongoUserRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoUserRepository(collection *mongo.Collection) UserRepository {
+	return &mongoUserRepository{collection: collection}
+}
+
+func (r *mongoUserRepository) Create(ctx context.Context, user *models.User) error {
+	result, err := r.collection.InsertOne(ctx, user)
+	if err != nil {
+		return err
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoUserRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.User, error) {
+	var user *models.User
+	err := r.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	return user, notFound(err)
+}
+
+func (r *mongoUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user *models.User
+	err := r.collection.FindOne(ctx, bson.M{"email": email}).Decode(&user)
+	return user, notFound(err)
+}
+
+func (r *mongoUserRepository) FindMember(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.User, error) {
+	var user *models.User
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&user)
+	return user, notFound(err)
+}
+
+func (r *mongoUserRepository) CountByRole(ctx context.Context, organisationID primitive.ObjectID, role string) (int64, error) {
+	return scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"role": role})
+}
+
+func (r *mongoUserRepository) Update(ctx context.Context, user *models.User) error {
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": user.ID}, user)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoUserRepository) Delete(ctx context.Context, id primitive.ObjectID) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+type VehicleRepository interface {
+	Create(ctx context.Context, vehicle *models.Vehicle) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Vehicle, error)
+	FindByVIN(ctx context.Context, organisationID primitive.ObjectID, vin string) (*models.Vehicle, error)
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.VehicleFilter, query listing.Query) ([]*models.Vehicle, listing.Page, error)
+	Update(ctx context.Context, vehicle *models.Vehicle) error
+	AddOdometerReading(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, reading models.OdometerReading) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+	CustomerReassigner
+}
+
+// VehicleSortFields are the fields vehicles can be listed by
+var VehicleSortFields = listing.Fields[models.Vehicle]{
+	"plate":      {Key: "plate_normalized", Value: func(v *models.Vehicle) interface{} { return v.PlateNormalized }},
+	"make":       {Key: "make", Value: func(v *models.Vehicle) interface{} { return v.Make }},
+	"year":       {Key: "year", Value: func(v *models.Vehicle) interface{} { return v.Year }},
+	"created_at": {Key: "created_at", Value: func(v *models.Vehicle) interface{} { return dateTime(v.CreatedAt) }},
+	"updated_at": {Key: "updated_at", Value: func(v *models.Vehicle) interface{} { return dateTime(v.UpdatedAt) }},
+}
+
+func vehicleID(vehicle *models.Vehicle) primitive.ObjectID {
+	return vehicle.ID
+}
+
+type mongoVehicleRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoVehicleRepository(collection *mongo.Collection) VehicleRepository {
+	return &mongoVehicleRepository{collection: collection}
+}
+
+func (r *mongoVehicleRepository) Create(ctx context.Context, vehicle *models.Vehicle) error {
+	result, err := scope(r.collection, vehicle.OrganisationID).InsertOne(ctx, vehicle)
+	if err != nil {
+		return err
+	}
+	vehicle.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoVehicleRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.Vehicle, error) {
+	var vehicle *models.Vehicle
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&vehicle)
+	return vehicle, notFound(err)
+}
+
+func (r *mongoVehicleRepository) FindByVIN(ctx context.Context, organisationID primitive.ObjectID, vin string) (*models.Vehicle, error) {
+	var vehicle *models.Vehicle
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"vin": vin}).Decode(&vehicle)
+	return vehicle, notFound(err)
+}
+
+func (r *mongoVehicleRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.VehicleFilter, page listing.Query) ([]*models.Vehicle, listing.Page, error) {
+	query := bson.M{}
+	if filter.CustomerID != nil {
+		query["customer_id"] = *filter.CustomerID
+	}
+	if filter.VIN != "" {
+		query["vin"] = filter.VIN
+	}
+	if filter.Plate != "" {
+		query["plate_normalized"] = filter.Plate
+	}
+
+	return findPage(ctx, scope(r.collection, organisationID), query, page.OrSort("plate"), VehicleSortFields, vehicleID)
+}
+
+func (r *mongoVehicleRepository) Update(ctx context.Context, vehicle *models.Vehicle) error {
+	result, err := scope(r.collection, vehicle.OrganisationID).ReplaceOne(ctx, bson.M{"_id": vehicle.ID}, vehicle)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoVehicleRepository) AddOdometerReading(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, reading models.OdometerReading) error {
+	update := bson.M{
+		"$push": bson.M{"odometer_readings": reading},
+		"$set":  bson.M{"updated_at": reading.RecordedAt},
+	}
+	result, err := scope(r.collection, organisationID).UpdateOne(ctx, bson.M{"_id": id}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoVehicleRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoVehicleRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	_, err := scope(r.collection, organisationID).UpdateMany(ctx, bson.M{"customer_id": bson.M{"$in": from}}, bson.M{"$set": bson.M{"customer_id": to}})
+	return err
+}
+
+func (r *mongoVehicleRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"customer_id": customerID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
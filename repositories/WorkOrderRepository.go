@@ -0,0 +1,126 @@
+package repositories
+
+import (
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"poosible-backend/listing"
+	"poosible-backend/models"
+)
+
+type WorkOrderRepository interface {
+	Create(ctx context.Context, workOrder *models.WorkOrder) error
+	FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.WorkOrder, error)
+	// FindAll returns a page of the matching work orders, newest first unless the page is sorted otherwise
+	FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.WorkOrderFilter, page listing.Query) ([]*models.WorkOrder, listing.Page, error)
+	// Update replaces the work order if its status is still the given one, so edits never race with a transition
+	Update(ctx context.Context, workOrder *models.WorkOrder, status models.WorkOrderStatus) error
+	// Transition moves the work order to change.To and records the change, provided it is still in change.From
+	Transition(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, change models.WorkOrderStatusChange) error
+	Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error
+	CustomerReassigner
+}
+
+// WorkOrderSortFields are the fields work orders can be listed by
+var WorkOrderSortFields = listing.Fields[models.WorkOrder]{
+	"status":     {Key: "status", Value: func(w *models.WorkOrder) interface{} { return string(w.Status) }},
+	"created_at": {Key: "created_at", Value: func(w *models.WorkOrder) interface{} { return dateTime(w.CreatedAt) }},
+	"updated_at": {Key: "updated_at", Value: func(w *models.WorkOrder) interface{} { return dateTime(w.UpdatedAt) }},
+}
+
+func workOrderID(document *models.WorkOrder) primitive.ObjectID {
+	return document.ID
+}
+
+type mongoWorkOrderRepository struct {
+	collection *mongo.Collection
+}
+
+func NewMongoWorkOrderRepository(collection *mongo.Collection) WorkOrderRepository {
+	return &mongoWorkOrderRepository{collection: collection}
+}
+
+func (r *mongoWorkOrderRepository) Create(ctx context.Context, workOrder *models.WorkOrder) error {
+	result, err := scope(r.collection, workOrder.OrganisationID).InsertOne(ctx, workOrder)
+	if err != nil {
+		return err
+	}
+	workOrder.ID = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+func (r *mongoWorkOrderRepository) FindByID(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) (*models.WorkOrder, error) {
+	var workOrder *models.WorkOrder
+	err := scope(r.collection, organisationID).FindOne(ctx, bson.M{"_id": id}).Decode(&workOrder)
+	return workOrder, notFound(err)
+}
+
+func (r *mongoWorkOrderRepository) FindAll(ctx context.Context, organisationID primitive.ObjectID, filter models.WorkOrderFilter, page listing.Query) ([]*models.WorkOrder, listing.Page, error) {
+	query := bson.M{}
+	if filter.Status != "" {
+		query["status"] = filter.Status
+	}
+	if filter.CustomerID != nil {
+		query["customer_id"] = *filter.CustomerID
+	}
+	if filter.VehicleID != nil {
+		query["vehicle_id"] = *filter.VehicleID
+	}
+
+	return findPage(ctx, scope(r.collection, organisationID), query, page.OrSort("-created_at"), WorkOrderSortFields, workOrderID)
+}
+
+func (r *mongoWorkOrderRepository) Update(ctx context.Context, workOrder *models.WorkOrder, status models.WorkOrderStatus) error {
+	result, err := scope(r.collection, workOrder.OrganisationID).ReplaceOne(ctx, bson.M{"_id": workOrder.ID, "status": status}, workOrder)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, workOrder.OrganisationID, workOrder.ID); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *mongoWorkOrderRepository) Transition(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID, change models.WorkOrderStatusChange) error {
+	update := bson.M{
+		"$set":  bson.M{"status": change.To, "updated_at": change.ChangedAt},
+		"$push": bson.M{"history": change},
+	}
+	result, err := scope(r.collection, organisationID).UpdateOne(ctx, bson.M{"_id": id, "status": change.From}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		if _, err := r.FindByID(ctx, organisationID, id); err != nil {
+			return err
+		}
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+func (r *mongoWorkOrderRepository) Delete(ctx context.Context, organisationID primitive.ObjectID, id primitive.ObjectID) error {
+	result, err := scope(r.collection, organisationID).DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *mongoWorkOrderRepository) ReassignCustomer(ctx context.Context, organisationID primitive.ObjectID, from []primitive.ObjectID, to primitive.ObjectID) error {
+	_, err := scope(r.collection, organisationID).UpdateMany(ctx, bson.M{"customer_id": bson.M{"$in": from}}, bson.M{"$set": bson.M{"customer_id": to}})
+	return err
+}
+
+func (r *mongoWorkOrderRepository) UsesCustomer(ctx context.Context, organisationID primitive.ObjectID, customerID primitive.ObjectID) (bool, error) {
+	count, err := scope(r.collection, organisationID).CountDocuments(ctx, bson.M{"customer_id": customerID}, options.Count().SetLimit(1))
+	return count > 0, err
+}
@@ -0,0 +1,51 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLoginRejectsWrongPassword(t *testing.T) {
+	server := newTestServer(t)
+	server.signUp("ana@example.com")
+
+	c := server.client()
+	c.expectStatus(c.login("ana@example.com", "Wrong12345"), http.StatusBadRequest, "login with wrong password")
+	c.expectStatus(c.login("nobody@example.com", "Password123"), http.StatusBadRequest, "login of unknown user")
+}
+
+func TestProtectedRoutesNeedToken(t *testing.T) {
+	server := newTestServer(t)
+	c := server.client()
+
+	c.expectStatus(c.do(http.MethodGet, "/user/current", nil), http.StatusUnauthorized, "current user without token")
+	c.token = "not-a-token"
+	c.expectStatus(c.do(http.MethodGet, "/user/current", nil), http.StatusUnauthorized, "current user with bad token")
+}
+
+func TestRefreshRotatesTokenAndDetectsReuse(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUp("ana@example.com")
+	response := c.login("ana@example.com", "Password123")
+	refreshToken := response.data()["refresh_token"].(string)
+
+	response = c.do(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": refreshToken})
+	c.expectStatus(response, http.StatusOK, "refresh")
+	rotated := response.data()["refresh_token"].(string)
+	if rotated == refreshToken {
+		t.Fatal("refresh returned the same refresh token")
+	}
+
+	//Replaying the first token revokes the whole family, including the rotated token
+	c.expectStatus(c.do(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": refreshToken}), http.StatusUnauthorized, "replayed refresh")
+	c.expectStatus(c.do(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": rotated}), http.StatusUnauthorized, "refresh after reuse")
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUp("ana@example.com")
+	refreshToken := c.login("ana@example.com", "Password123").data()["refresh_token"].(string)
+
+	c.expectStatus(c.do(http.MethodPost, "/auth/logout", nil), http.StatusOK, "logout")
+	c.expectStatus(c.do(http.MethodPost, "/auth/refresh", map[string]string{"refresh_token": refreshToken}), http.StatusUnauthorized, "refresh after logout")
+}
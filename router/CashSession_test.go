@@ -0,0 +1,123 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// tenderTotals returns the amount and count of each tender method of a report
+func tenderTotals(t *testing.T, report map[string]interface{}) (map[string]float64, map[string]int) {
+	amounts, counts := map[string]float64{}, map[string]int{}
+	for _, tender := range report["tenders"].([]interface{}) {
+		tender := tender.(map[string]interface{})
+		amounts[tender["method"].(string)] = amount(t, tender["amount"])
+		counts[tender["method"].(string)] = int(tender["count"].(float64))
+	}
+	return amounts, counts
+}
+
+// rateTotals sums up the tax and gross amounts of the tax rates of a report or invoice
+func rateTotals(t *testing.T, rates interface{}) (float64, float64) {
+	var taxed, gross float64
+	for _, rate := range rates.([]interface{}) {
+		rate := rate.(map[string]interface{})
+		taxed += amount(t, rate["tax"])
+		gross += amount(t, rate["gross"])
+	}
+	return taxed, gross
+}
+
+func TestCashSessionReports(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	session := c.openCashSession("100")
+	c.expectStatus(c.do(http.MethodPost, "/cash-session", m{"register": "Till 2", "opening_float": "0"}), http.StatusConflict, "open a second session")
+
+	//Part of the invoice is paid by card, the rest in cash with change, and part of it is refunded from the drawer
+	response := c.do(http.MethodPost, "/invoice", m{"lines": []m{{"description": "Labour", "quantity": 2, "unit_price": "40"}}})
+	c.expectStatus(response, http.StatusOK, "issue invoice")
+	invoice, total := response.data()["_id"].(string), amount(t, response.data()["total"])
+	invoiceTax, invoiceGross := rateTotals(t, response.data()["tax_breakdown"])
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "card", "amount": "20", "card_token": "tok_visa"}, m{"method": "cash", "tendered": "500"})), http.StatusOK, "pay by card and cash")
+	response = c.do(http.MethodPost, "/invoice/"+invoice+"/credit-note", m{"reason": "Returned", "lines": []m{{"line": 0, "quantity": 1}}})
+	c.expectStatus(response, http.StatusOK, "issue credit note")
+	creditNote := response.data()["_id"].(string)
+	creditTax, creditGross := rateTotals(t, response.data()["tax_breakdown"])
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+creditNote+"/refund", m{"method": "original", "amount": "5"}), http.StatusOK, "refund from the drawer")
+
+	c.expectStatus(c.do(http.MethodPost, "/cash-session/"+session+"/movement", m{"type": "pay_in", "amount": "10", "reason": "Coins from the safe"}), http.StatusOK, "pay in")
+	c.expectStatus(c.do(http.MethodPost, "/cash-session/"+session+"/movement", m{"type": "pay_out", "amount": "2.50", "reason": "Postage"}), http.StatusOK, "pay out")
+	c.expectStatus(c.do(http.MethodPost, "/cash-session/"+session+"/movement", m{"type": "pay_out", "amount": "0", "reason": "Nothing"}), http.StatusBadRequest, "pay out nothing")
+
+	//The till is counted blind, the session shows what was moved but not what the drawer should hold
+	response = c.do(http.MethodGet, "/cash-session/current", nil)
+	c.expectStatus(response, http.StatusOK, "get current session")
+	for _, key := range []string{"expected_cash", "variance", "z_report"} {
+		if _, ok := response.data()[key]; ok {
+			t.Fatalf("open session shows %s: %v", key, response.data())
+		}
+	}
+
+	cash := total - 20 - 5
+	expected := 100 + cash + 10 - 2.5
+	response = c.do(http.MethodGet, "/cash-session/"+session+"/x-report", nil)
+	c.expectStatus(response, http.StatusOK, "take X-report")
+	report := response.data()
+	if got := amount(t, report["expected_cash"]); got != expected {
+		t.Fatalf("X-report expects %v, want %v", got, expected)
+	}
+	if _, ok := report["variance"]; ok {
+		t.Fatalf("X-report has a variance before the count: %v", report)
+	}
+	amounts, counts := tenderTotals(t, report)
+	if amounts["card"] != 20 || counts["card"] != 1 || amounts["cash"] != cash || counts["cash"] != 2 {
+		t.Fatalf("X-report tenders are %v counted %v, want card 20 once and cash %v twice", amounts, counts, cash)
+	}
+	if report["payments"].(float64) != 2 || report["invoices"].(float64) != 2 {
+		t.Fatalf("X-report has %v payments and %v invoices, want 2 each", report["payments"], report["invoices"])
+	}
+	if taxed, gross := rateTotals(t, report["tax_rates"]); taxed != invoiceTax+creditTax || gross != invoiceGross+creditGross {
+		t.Fatalf("X-report taxes %v of %v, want %v of %v", taxed, gross, invoiceTax+creditTax, invoiceGross+creditGross)
+	}
+
+	//Closing counts the drawer and works out the variance
+	c.expectStatus(c.do(http.MethodGet, "/cash-session/"+session+"/z-report", nil), http.StatusConflict, "get Z-report of an open session")
+	response = c.do(http.MethodPost, "/cash-session/"+session+"/close", m{"counted_cash": decimal(expected - 1.5)})
+	c.expectStatus(response, http.StatusOK, "close session")
+	z := response.data()["z_report"].(map[string]interface{})
+	if z["type"] != "z" || z["number"] != "Z-000001" || amount(t, z["variance"]) != -1.5 || amount(t, z["counted_cash"]) != expected-1.5 || amount(t, z["expected_cash"]) != expected {
+		t.Fatalf("Z-report is %v", z)
+	}
+
+	//The Z-report is final
+	c.expectStatus(c.do(http.MethodPost, "/cash-session/"+session+"/close", m{"counted_cash": decimal(expected)}), http.StatusConflict, "close twice")
+	c.expectStatus(c.do(http.MethodGet, "/cash-session/"+session+"/x-report", nil), http.StatusConflict, "take X-report of a closed session")
+	c.expectStatus(c.do(http.MethodPost, "/cash-session/"+session+"/movement", m{"type": "pay_in", "amount": "1", "reason": "Late"}), http.StatusConflict, "pay in after closing")
+	later, _ := c.issueInvoice("", 1, "10")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+later+"/payment", pay(m{"method": "cash", "amount": "1"})), http.StatusConflict, "take cash after closing")
+
+	next := c.openCashSession("0")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+later+"/payment", pay(m{"method": "cash", "amount": "1"})), http.StatusOK, "take cash in the next session")
+	response = c.do(http.MethodPost, "/cash-session/"+next+"/close", m{"counted_cash": "1"})
+	c.expectStatus(response, http.StatusOK, "close the next session")
+	if number := response.data()["z_report"].(map[string]interface{})["number"]; number != "Z-000002" {
+		t.Fatalf("next Z-report is numbered %v", number)
+	}
+
+	response = c.do(http.MethodGet, "/cash-session/"+session+"/z-report", nil)
+	c.expectStatus(response, http.StatusOK, "get Z-report")
+	if got := response.data(); got["number"] != "Z-000001" || amount(t, got["variance"]) != -1.5 || amount(t, got["expected_cash"]) != expected || got["payments"].(float64) != 2 {
+		t.Fatalf("Z-report changed after closing: %v", got)
+	}
+}
+
+func TestCashSessionBelongsToItsUser(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	session := c.openCashSession("100")
+
+	other := server.signUpOwner("other@example.com")
+	other.expectStatus(other.do(http.MethodPost, "/cash-session/"+session+"/close", m{"counted_cash": "100"}), http.StatusNotFound, "close the session of another organisation")
+	c.expectStatus(c.do(http.MethodPost, "/cash-session/"+session+"/close", m{"counted_cash": "-1"}), http.StatusBadRequest, "count a negative drawer")
+	c.expectStatus(c.do(http.MethodPost, "/cash-session/"+session+"/close", m{"counted_cash": "100"}), http.StatusOK, "close")
+}
@@ -0,0 +1,28 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDeleteReferencedCustomer(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+
+	unused := c.createCustomer("Ivo")
+	c.expectStatus(c.do(http.MethodDelete, "/customer/"+unused, nil), http.StatusOK, "delete a customer nothing refers to")
+	c.expectStatus(c.do(http.MethodDelete, "/customer/"+unused, nil), http.StatusNotFound, "delete a deleted customer")
+
+	owner := c.createCustomer("Ana")
+	c.expectStatus(c.do(http.MethodPost, "/vehicle", m{"plate": "ZG-1234-AB", "customer_id": owner}), http.StatusOK, "create vehicle")
+	c.expectStatus(c.do(http.MethodDelete, "/customer/"+owner, nil), http.StatusConflict, "delete the owner of a vehicle")
+
+	billed := c.createCustomer("Marko")
+	c.issueInvoice(billed, 1, "100")
+	c.expectStatus(c.do(http.MethodDelete, "/customer/"+billed, nil), http.StatusConflict, "delete a billed customer")
+
+	//Merging moves the references away, so the duplicate is gone and the kept customer stays referenced
+	c.expectStatus(c.do(http.MethodPost, "/customer/"+owner+"/merge", m{"customer_ids": []string{billed}}), http.StatusOK, "merge customers")
+	c.expectStatus(c.do(http.MethodGet, "/customer/"+billed, nil), http.StatusNotFound, "get the merged duplicate")
+	c.expectStatus(c.do(http.MethodDelete, "/customer/"+owner, nil), http.StatusConflict, "delete the kept customer")
+}
@@ -0,0 +1,160 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"poosible-backend/utils"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// createEstimate creates a draft estimate of two labour lines and returns its ID and the IDs of its lines
+func (c *testClient) createEstimate() (string, []string) {
+	c.server.t.Helper()
+	customer := c.createCustomer("Ivo")
+	response := c.do(http.MethodPost, "/estimate", m{"customer_id": customer, "valid_until": time.Now().Add(72 * time.Hour).UTC().Format(time.RFC3339), "lines": []m{
+		{"type": "labor", "description": "Fit pads", "quantity": 1, "unit_price": "50"},
+		{"type": "labor", "description": "Flush fluid", "quantity": 1, "unit_price": "30"},
+	}})
+	c.expectStatus(response, http.StatusOK, "create estimate")
+	lines := []string{}
+	for _, line := range response.data()["lines"].([]interface{}) {
+		lines = append(lines, line.(map[string]interface{})["_id"].(string))
+	}
+	return response.data()["_id"].(string), lines
+}
+
+// shareEstimate shares the estimate and returns the token of its link
+func (c *testClient) shareEstimate(estimate string) string {
+	c.server.t.Helper()
+	response := c.do(http.MethodPost, "/estimate/"+estimate+"/share", nil)
+	c.expectStatus(response, http.StatusOK, "share estimate")
+	return response.Body.Data["token"].(string)
+}
+
+func reply(signature string, lines []string, approved ...bool) m {
+	decisions := []m{}
+	for i, approve := range approved {
+		decisions = append(decisions, m{"line_id": lines[i], "approved": approve})
+	}
+	return m{"signature": signature, "lines": decisions}
+}
+
+func TestPublicEstimateToken(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	anonymous := server.client()
+	estimate, _ := c.createEstimate()
+
+	first := c.shareEstimate(estimate)
+	second := c.shareEstimate(estimate)
+	anonymous.expectStatus(anonymous.do(http.MethodGet, "/public/estimate/"+first, nil), http.StatusNotFound, "open a link shared again")
+	anonymous.expectStatus(anonymous.do(http.MethodGet, "/public/estimate/"+second[:len(second)-1], nil), http.StatusNotFound, "open an unknown link")
+
+	response := anonymous.do(http.MethodGet, "/public/estimate/"+second, nil)
+	anonymous.expectStatus(response, http.StatusOK, "open the link")
+	if _, ok := response.data()["organisation_id"]; ok {
+		t.Fatalf("public estimate shows its organisation: %v", response.data())
+	}
+	if _, ok := response.data()["share_token_hash"]; ok {
+		t.Fatalf("public estimate shows its token hash: %v", response.data())
+	}
+
+	//Changing the estimate ends its link
+	response = c.do(http.MethodGet, "/estimate/"+estimate, nil)
+	c.expectStatus(response, http.StatusOK, "get estimate")
+	response = c.do(http.MethodPut, "/estimate/"+estimate, m{"customer_id": response.data()["customer_id"], "valid_until": response.data()["valid_until"], "lines": []m{
+		{"type": "labor", "description": "Fit pads", "quantity": 1, "unit_price": "60"},
+	}})
+	c.expectStatus(response, http.StatusOK, "change estimate")
+	anonymous.expectStatus(anonymous.do(http.MethodGet, "/public/estimate/"+second, nil), http.StatusNotFound, "open the link of a changed estimate")
+}
+
+func TestPublicEstimateExpiry(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	anonymous := server.client()
+	estimate, lines := c.createEstimate()
+
+	c.expectStatus(c.do(http.MethodPost, "/estimate/"+estimate+"/share", m{"expires_at": time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)}), http.StatusBadRequest, "share with a link expired already")
+	c.expectStatus(c.do(http.MethodPost, "/estimate/"+estimate+"/share", m{"expires_at": time.Now().Add(96 * time.Hour).UTC().Format(time.RFC3339)}), http.StatusBadRequest, "share with a link outlasting the estimate")
+	token := c.shareEstimate(estimate)
+
+	//Let the link run out
+	ctx := context.Background()
+	stored, err := server.repos.Estimates.FindByShareToken(ctx, utils.HashLinkToken(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	stored.ShareExpiresAt = &expired
+	if err := server.repos.Estimates.Update(ctx, stored, stored.Status, stored.ShareTokenHash); err != nil {
+		t.Fatal(err)
+	}
+
+	anonymous.expectStatus(anonymous.do(http.MethodGet, "/public/estimate/"+token, nil), http.StatusGone, "open an expired link")
+	anonymous.expectStatus(anonymous.do(http.MethodPost, "/public/estimate/"+token+"/reply", reply("Ivo Kovac", lines, true, true)), http.StatusGone, "answer through an expired link")
+
+	//A new link works again
+	token = c.shareEstimate(estimate)
+	anonymous.expectStatus(anonymous.do(http.MethodPost, "/public/estimate/"+token+"/reply", reply("Ivo Kovac", lines, true, true)), http.StatusOK, "answer through a new link")
+}
+
+func TestReplyToEstimate(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	anonymous := server.client()
+	estimate, lines := c.createEstimate()
+	token := c.shareEstimate(estimate)
+	path := "/public/estimate/" + token + "/reply"
+
+	anonymous.expectStatus(anonymous.do(http.MethodPost, path, reply("Ivo Kovac", lines, true)), http.StatusBadRequest, "leave a line undecided")
+	anonymous.expectStatus(anonymous.do(http.MethodPost, path, reply("  ", lines, true, false)), http.StatusBadRequest, "sign with a blank name")
+
+	response := anonymous.do(http.MethodPost, path, reply("Ivo Kovac", lines, true, false), "User-Agent", "browser")
+	anonymous.expectStatus(response, http.StatusOK, "answer")
+	signature, _ := response.data()["signature"].(map[string]interface{})
+	if response.data()["status"] != string(models.EstimateApproved) || signature["name"] != "Ivo Kovac" || signature["user_agent"] != "browser" {
+		t.Fatalf("answer was recorded as %v", response.data())
+	}
+	anonymous.expectStatus(anonymous.do(http.MethodPost, path, reply("Ivo Kovac", lines, false, false)), http.StatusConflict, "answer twice")
+	anonymous.expectStatus(anonymous.do(http.MethodGet, "/public/estimate/"+token, nil), http.StatusOK, "open an answered estimate")
+
+	response = c.do(http.MethodPost, "/estimate/"+estimate+"/work-order", nil)
+	c.expectStatus(response, http.StatusOK, "convert the approved estimate")
+	if workOrderLines := response.Body.Data["work_order"].(map[string]interface{})["lines"].([]interface{}); len(workOrderLines) != 1 {
+		t.Fatalf("work order has %d lines, want the approved one", len(workOrderLines))
+	}
+}
+
+func TestStaleReplyConflicts(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	estimate, _ := c.createEstimate()
+	token := c.shareEstimate(estimate)
+
+	//The customer reads the estimate through the link, then the shop shares it again before they answer
+	ctx := context.Background()
+	read, err := server.repos.Estimates.FindByShareToken(ctx, utils.HashLinkToken(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.shareEstimate(estimate)
+
+	read.Status = models.EstimateApproved
+	if err := server.repos.Estimates.Update(ctx, read, models.EstimateSent, read.ShareTokenHash); err != repositories.ErrVersionConflict {
+		t.Fatalf("answer through the old link answered %v, want %v", err, repositories.ErrVersionConflict)
+	}
+	id, _ := primitive.ObjectIDFromHex(estimate)
+	stored, err := server.repos.Estimates.FindByID(ctx, read.OrganisationID, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != models.EstimateSent || stored.ShareTokenHash == read.ShareTokenHash {
+		t.Fatalf("answer through the old link replaced the estimate shared again: %v", stored.Status)
+	}
+}
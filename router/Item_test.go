@@ -0,0 +1,92 @@
+package router_test
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"net/http"
+	"poosible-backend/listing"
+	"testing"
+)
+
+func TestItemLifecycle(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+
+	response := c.do(http.MethodPost, "/item", map[string]interface{}{"name": "Oil filter", "description": "Spin-on", "price": "12.50", "sku": "OF-1"})
+	c.expectStatus(response, http.StatusOK, "create item")
+	id := response.data()["_id"].(string)
+	price := response.data()["price"].(map[string]interface{})
+	if price["currency"] != "EUR" {
+		t.Fatalf("item priced in %v, want the organisation currency EUR", price["currency"])
+	}
+
+	response = c.do(http.MethodGet, "/item/"+id, nil)
+	c.expectStatus(response, http.StatusOK, "get item")
+	if response.data()["name"] != "Oil filter" {
+		t.Fatalf("got item %v", response.data())
+	}
+	etag := response.Header.Get("ETag")
+
+	response = c.do(http.MethodPost, "/item", map[string]interface{}{"name": "Other filter", "description": "Spin-on", "price": "9", "sku": "OF-1"})
+	c.expectStatus(response, http.StatusBadRequest, "create item with duplicate SKU")
+
+	response = c.do(http.MethodPut, "/item/"+id, map[string]interface{}{"name": "Oil filter XL", "description": "Spin-on", "price": "14"})
+	c.expectStatus(response, http.StatusPreconditionRequired, "update item without If-Match")
+	response = c.do(http.MethodPut, "/item/"+id, map[string]interface{}{"name": "Oil filter XL", "description": "Spin-on", "price": "14"}, "If-Match", etag)
+	c.expectStatus(response, http.StatusOK, "update item")
+	response = c.do(http.MethodPut, "/item/"+id, map[string]interface{}{"name": "Oil filter L", "description": "Spin-on", "price": "13"}, "If-Match", etag)
+	c.expectStatus(response, http.StatusPreconditionFailed, "update item with stale If-Match")
+
+	response = c.do(http.MethodGet, "/items", nil)
+	c.expectStatus(response, http.StatusOK, "list items")
+	if items, _ := response.Body.Data["data"].([]interface{}); len(items) != 1 {
+		t.Fatalf("listed %d items, want 1", len(items))
+	}
+
+	c.expectStatus(c.do(http.MethodDelete, "/item/"+id, nil), http.StatusOK, "delete item")
+	c.expectStatus(c.do(http.MethodGet, "/item/"+id, nil), http.StatusNotFound, "get deleted item")
+}
+
+func TestItemNeedsOrganisationAndValidInput(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUp("ana@example.com")
+
+	response := c.do(http.MethodPost, "/item", map[string]interface{}{"name": "Oil filter", "description": "Spin-on", "price": "12.50"})
+	if response.Code == http.StatusOK {
+		t.Fatal("user without an organisation created an item")
+	}
+
+	owner := server.signUpOwner("owner@example.com")
+	response = owner.do(http.MethodPost, "/item", map[string]interface{}{"description": "No name", "price": "1"})
+	owner.expectStatus(response, http.StatusBadRequest, "create item without name")
+	response = owner.do(http.MethodPost, "/item", map[string]interface{}{"name": "Pad", "description": "Brake pad", "price": "-1"})
+	owner.expectStatus(response, http.StatusBadRequest, "create item with negative price")
+}
+
+func TestItemCursorsHoldPlainValues(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	for _, name := range []string{"Air filter", "Oil filter"} {
+		c.expectStatus(c.do(http.MethodPost, "/item", map[string]interface{}{"name": name, "description": "d", "price": "10"}), http.StatusOK, "create "+name)
+	}
+
+	response := c.do(http.MethodGet, "/items?limit=1", nil)
+	c.expectStatus(response, http.StatusOK, "list first page")
+	paging, _ := response.Body.Data["paging"].(map[string]interface{})
+	next, _ := paging["next_cursor"].(string)
+	if next == "" {
+		t.Fatalf("first page has no next cursor: %v", paging)
+	}
+	c.expectStatus(c.do(http.MethodGet, "/items?limit=1&cursor="+next, nil), http.StatusOK, "list second page")
+
+	//Cursors are decoded from the client, their values must not smuggle query operators or other types into the filter
+	forged := map[string]interface{}{
+		"operator": bson.M{"$ne": nil},
+		"array":    bson.A{"Air filter"},
+		"number":   int64(1),
+	}
+	for what, value := range forged {
+		cursor := listing.Cursor{Sort: "name", Value: value, ID: primitive.NewObjectID()}.Encode()
+		c.expectStatus(c.do(http.MethodGet, "/items?cursor="+cursor, nil), http.StatusBadRequest, "list after a forged "+what+" cursor")
+	}
+}
@@ -0,0 +1,129 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestOrganisationLifecycle(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+
+	response := c.do(http.MethodGet, "/organisation", nil)
+	c.expectStatus(response, http.StatusOK, "get organisation")
+	id := response.data()["_id"].(string)
+
+	//A user owns one organisation at a time
+	response = c.do(http.MethodPost, "/organisation", map[string]interface{}{"name": "Second", "description": "d", "phone": "1", "country_code": 191, "address": "a", "zip_code": "1", "currency_code": 978})
+	c.expectStatus(response, http.StatusBadRequest, "create a second organisation")
+
+	response = c.do(http.MethodPut, "/organisation/"+id, map[string]interface{}{"name": "Renamed", "description": "d", "phone": "2", "country_code": 191, "address": "a", "zip_code": "1", "currency_code": 978})
+	c.expectStatus(response, http.StatusOK, "update organisation")
+	response = c.do(http.MethodGet, "/organisation", nil)
+	if response.data()["name"] != "Renamed" {
+		t.Fatalf("organisation is called %v after the update", response.data()["name"])
+	}
+
+	c.expectStatus(c.do(http.MethodDelete, "/organisation/"+id, nil), http.StatusOK, "delete organisation")
+}
+
+func TestMemberRolesAreLimitedToOwnPermissions(t *testing.T) {
+	server := newTestServer(t)
+	owner := server.signUpOwner("owner@example.com")
+	cashier := server.signUp("cashier@example.com")
+	server.signUp("new@example.com")
+	owner.addMember(cashier, "cashier@example.com", "cashier")
+
+	response := cashier.do(http.MethodPost, "/organisation/member", map[string]string{"email": "new@example.com", "role": "owner"})
+	cashier.expectStatus(response, http.StatusForbidden, "cashier adds an owner")
+	response = cashier.do(http.MethodPut, "/organisation/"+cashier.organisationID(), map[string]interface{}{"name": "Mine", "description": "d", "phone": "2", "country_code": 191, "address": "a", "zip_code": "1", "currency_code": 978})
+	cashier.expectStatus(response, http.StatusForbidden, "cashier updates the organisation")
+}
+
+func TestMembersJoinOnlyOnceTheyAcceptTheInvitation(t *testing.T) {
+	server := newTestServer(t)
+	owner := server.signUpOwner("owner@example.com")
+	other := server.signUpOwner("other@example.com")
+	technician := server.signUp("tech@example.com")
+	organisationID := owner.organisationID()
+
+	owner.expectStatus(owner.do(http.MethodPost, "/organisation/member", map[string]string{"email": "tech@example.com", "role": "technician"}), http.StatusOK, "invite technician")
+	other.expectStatus(other.do(http.MethodPost, "/organisation/member", map[string]string{"email": "tech@example.com", "role": "manager"}), http.StatusOK, "invite technician elsewhere")
+	technician.expectStatus(technician.do(http.MethodGet, "/organisation", nil), http.StatusForbidden, "invited user reads the organisation")
+	response := technician.do(http.MethodGet, "/user/current", nil)
+	if _, ok := response.data()["organisation_id"]; ok {
+		t.Fatalf("invited user belongs to %v before accepting", response.data()["organisation_id"])
+	}
+
+	response = technician.do(http.MethodGet, "/user/current/invitations", nil)
+	technician.expectStatus(response, http.StatusOK, "list invitations")
+	if invitations := response.Body.Data["data"].([]interface{}); len(invitations) != 2 {
+		t.Fatalf("got %d invitations, want 2", len(invitations))
+	}
+
+	technician.expectStatus(technician.do(http.MethodDelete, "/user/current/invitations/"+other.organisationID(), nil), http.StatusOK, "decline invitation")
+	technician.expectStatus(technician.do(http.MethodPost, "/user/current/invitations/"+other.organisationID()+"/accept", nil), http.StatusNotFound, "accept a declined invitation")
+	technician.expectStatus(technician.do(http.MethodPost, "/user/current/invitations/"+organisationID+"/accept", nil), http.StatusOK, "accept invitation")
+	if technician.organisationID() != organisationID {
+		t.Fatalf("user joined %s, want %s", technician.organisationID(), organisationID)
+	}
+	technician.expectStatus(technician.do(http.MethodGet, "/organisation", nil), http.StatusOK, "member reads the organisation")
+	owner.expectStatus(owner.do(http.MethodPost, "/organisation/member", map[string]string{"email": "tech@example.com", "role": "manager"}), http.StatusBadRequest, "invite a member")
+}
+
+func TestInvoiceNumberingIsFixedOnceInvoicesAreIssued(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	id := c.organisationID()
+	organisation := func(prefix string, yearlyReset bool, digits int) map[string]interface{} {
+		return map[string]interface{}{"name": "Shop", "description": "d", "phone": "2", "country_code": 191, "address": "a", "zip_code": "1", "currency_code": 978,
+			"invoice_numbering": map[string]interface{}{"prefix": prefix, "yearly_reset": yearlyReset, "digits": digits}}
+	}
+
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation("SHOP-", true, 6)), http.StatusOK, "set numbering before any invoice")
+
+	response := c.do(http.MethodPost, "/invoice", map[string]interface{}{"lines": []map[string]interface{}{
+		{"description": "Labour", "quantity": 1, "unit_price": map[string]interface{}{"amount": 1000, "currency": "EUR"}},
+	}})
+	c.expectStatus(response, http.StatusOK, "issue invoice")
+
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation("NEW-", true, 6)), http.StatusConflict, "change prefix after an invoice")
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation("SHOP-", false, 6)), http.StatusConflict, "turn off yearly reset after an invoice")
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation("SHOP-", true, 8)), http.StatusOK, "widen the digits after an invoice")
+
+	//An invoice numbered from the settings read before the change is refused instead of issued with them
+	organisationID, _ := primitive.ObjectIDFromHex(id)
+	stale := &models.Invoice{OrganisationID: organisationID, Type: models.InvoiceTypeInvoice, IssuedAt: time.Now()}
+	if err := server.repos.Invoices.Issue(context.Background(), stale, models.NumberingSettings{Prefix: "SHOP-", YearlyReset: true, Digits: 6}, nil); err != repositories.ErrNumberingChanged {
+		t.Fatalf("issuing with the old numbering returned %v, want %v", err, repositories.ErrNumberingChanged)
+	}
+}
+
+func TestCurrencyIsFixedOnceAmountsExist(t *testing.T) {
+	server := newTestServer(t)
+	organisation := func(currency int) map[string]interface{} {
+		return map[string]interface{}{"name": "Shop", "description": "d", "phone": "2", "country_code": 191, "address": "a", "zip_code": "1", "currency_code": currency}
+	}
+
+	c := server.signUpOwner("owner@example.com")
+	id := c.organisationID()
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation(840)), http.StatusOK, "change currency before any amount")
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation(978)), http.StatusOK, "change currency back")
+	c.expectStatus(c.do(http.MethodPost, "/item", map[string]interface{}{"name": "Oil filter", "description": "Spin-on", "price": "12.50"}), http.StatusOK, "create item")
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation(840)), http.StatusConflict, "change currency after an item")
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+id, organisation(978)), http.StatusOK, "keep the currency after an item")
+
+	c = server.signUpOwner("invoicer@example.com")
+	c.issueInvoice("", 1, "100")
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+c.organisationID(), organisation(840)), http.StatusConflict, "change currency after an invoice")
+
+	c = server.signUpOwner("cashier@example.com")
+	c.openCashSession("50")
+	c.expectStatus(c.do(http.MethodPut, "/organisation/"+c.organisationID(), organisation(840)), http.StatusConflict, "change currency after a cash session")
+}
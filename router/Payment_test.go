@@ -0,0 +1,324 @@
+package router_test
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+type m = map[string]interface{}
+
+// amount reads the decimal amount of money in a response
+func amount(t *testing.T, value interface{}) float64 {
+	t.Helper()
+	money, _ := value.(map[string]interface{})
+	parsed, err := strconv.ParseFloat(money["amount"].(string), 64)
+	if err != nil {
+		t.Fatalf("%v is not money: %v", value, err)
+	}
+	return parsed
+}
+
+// issueInvoice issues an invoice of quantity units of labour at the unit price and returns its ID and total
+func (c *testClient) issueInvoice(customerID string, quantity int, unitPrice string) (string, float64) {
+	c.server.t.Helper()
+	invoice := m{"lines": []m{{"description": "Labour", "quantity": quantity, "unit_price": unitPrice}}}
+	if customerID != "" {
+		invoice["customer_id"] = customerID
+	}
+	response := c.do(http.MethodPost, "/invoice", invoice)
+	c.expectStatus(response, http.StatusOK, "issue invoice")
+	return response.data()["_id"].(string), amount(c.server.t, response.data()["total"])
+}
+
+// createCustomer creates a customer and returns their ID
+func (c *testClient) createCustomer(firstName string) string {
+	c.server.t.Helper()
+	response := c.do(http.MethodPost, "/customer", m{"type": "individual", "first_name": firstName, "last_name": "Kovac"})
+	c.expectStatus(response, http.StatusOK, "create customer")
+	return response.data()["_id"].(string)
+}
+
+// openCashSession opens a cash session of the user and returns its ID
+func (c *testClient) openCashSession(openingFloat string) string {
+	c.server.t.Helper()
+	response := c.do(http.MethodPost, "/cash-session", m{"register": "Till 1", "opening_float": openingFloat})
+	c.expectStatus(response, http.StatusOK, "open cash session")
+	return response.data()["_id"].(string)
+}
+
+func pay(tenders ...m) m {
+	return m{"tenders": tenders}
+}
+
+// balance returns the balance of the invoice after the payment in the response
+func balance(response testResponse) map[string]interface{} {
+	balance, _ := response.Body.Data["balance"].(map[string]interface{})
+	return balance
+}
+
+func TestSplitTenders(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	invoice, total := c.issueInvoice("", 1, "100")
+
+	response := c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(
+		m{"method": "card", "amount": "10", "card_token": "tok_visa"},
+		m{"method": "bank_transfer", "amount": "5", "reference": "SEPA-1"},
+		m{"method": "voucher", "amount": "2.50", "reference": "V-1"},
+	))
+	c.expectStatus(response, http.StatusOK, "pay with three tenders")
+	if paid := amount(t, response.data()["total"]); paid != 17.5 {
+		t.Fatalf("payment total is %v, want 17.5", paid)
+	}
+	if due := amount(t, balance(response)["due"]); due != total-17.5 {
+		t.Fatalf("due is %v, want %v", due, total-17.5)
+	}
+	card, _ := response.data()["tenders"].([]interface{})[0].(map[string]interface{})["card"].(map[string]interface{})
+	if card["brand"] != "visa" || card["last4"] != "4242" || card["transaction_id"] == "" {
+		t.Fatalf("card tender was not captured: %v", card)
+	}
+	if outstanding := server.cards.Outstanding(); outstanding != 1000 {
+		t.Fatalf("gateway holds %d, want 1000", outstanding)
+	}
+
+	//A voucher needs its code and on account payments need a customer
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "voucher", "amount": "1"})), http.StatusBadRequest, "voucher without code")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "on_account", "amount": "1"})), http.StatusBadRequest, "on account without customer")
+}
+
+func TestOpenCashTenderGivesChange(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	invoice, total := c.issueInvoice("", 1, "100")
+
+	//Cash needs an open cash session of the user
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "cash", "tendered": "500"})), http.StatusConflict, "cash without a cash session")
+	c.openCashSession("0")
+
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "cash", "tendered": "10"}, m{"method": "cash", "tendered": "10"})), http.StatusBadRequest, "two open cash tenders")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "cash", "amount": "10", "tendered": "5"})), http.StatusBadRequest, "tendered less than the amount")
+
+	//The open cash tender pays what the card leaves and gives the rest back
+	response := c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(
+		m{"method": "card", "amount": "20", "card_token": "tok_visa"},
+		m{"method": "cash", "tendered": "500"},
+	))
+	c.expectStatus(response, http.StatusOK, "pay the rest in cash")
+	cash := response.data()["tenders"].([]interface{})[1].(map[string]interface{})
+	if paid := amount(t, cash["amount"]); paid != total-20 {
+		t.Fatalf("cash tender paid %v, want %v", paid, total-20)
+	}
+	if change := amount(t, response.data()["change"]); change != 500-(total-20) {
+		t.Fatalf("change is %v, want %v", change, 500-(total-20))
+	}
+	if due := amount(t, balance(response)["due"]); due != 0 {
+		t.Fatalf("due is %v after paying in full", due)
+	}
+}
+
+func TestOverpaymentIsRefused(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	invoice, total := c.issueInvoice("", 1, "100")
+	path := "/invoice/" + invoice + "/payment"
+
+	c.expectStatus(c.do(http.MethodPost, path, pay(m{"method": "bank_transfer", "amount": strconv.FormatFloat(total+0.01, 'f', 2, 64)})), http.StatusBadRequest, "pay more than is due")
+	c.expectStatus(c.do(http.MethodPost, path, pay(m{"method": "bank_transfer", "amount": "60"}, m{"method": "card", "amount": strconv.FormatFloat(total-59.99, 'f', 2, 64), "card_token": "tok_visa"})), http.StatusBadRequest, "tenders adding up to more than is due")
+	if outstanding := server.cards.Outstanding(); outstanding != 0 {
+		t.Fatalf("a refused payment charged %d to cards", outstanding)
+	}
+
+	c.expectStatus(c.do(http.MethodPost, path, pay(m{"method": "bank_transfer", "amount": strconv.FormatFloat(total, 'f', 2, 64)})), http.StatusOK, "pay in full")
+	c.expectStatus(c.do(http.MethodPost, path, pay(m{"method": "bank_transfer", "amount": "1"})), http.StatusConflict, "pay an invoice paid in full")
+}
+
+func TestDeclinedCardRefundsChargedCards(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	invoice, _ := c.issueInvoice("", 1, "100")
+
+	response := c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(
+		m{"method": "card", "amount": "10", "card_token": "tok_visa"},
+		m{"method": "card", "amount": "20", "card_token": "tok_mastercard"},
+		m{"method": "card", "amount": "5", "card_token": "tok_declined"},
+	))
+	c.expectStatus(response, http.StatusPaymentRequired, "pay with a declined card")
+	if outstanding := server.cards.Outstanding(); outstanding != 0 {
+		t.Fatalf("cards charged before the declined one keep %d captured", outstanding)
+	}
+
+	response = c.do(http.MethodGet, "/invoice/"+invoice+"/payments", nil)
+	c.expectStatus(response, http.StatusOK, "get payments")
+	if payments := response.Body.Data["data"].([]interface{}); len(payments) != 0 {
+		t.Fatalf("declined payment was recorded: %v", payments)
+	}
+}
+
+func TestPaymentsFitUnderCreditNote(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	customer := c.createCustomer("Ivo")
+	invoice, total := c.issueInvoice(customer, 4, "25")
+	path := "/invoice/" + invoice + "/payment"
+	c.expectStatus(c.do(http.MethodPost, path, pay(m{"method": "bank_transfer", "amount": "60"})), http.StatusOK, "pay part")
+
+	//Crediting half the invoice leaves less due than was paid
+	response := c.do(http.MethodPost, "/invoice/"+invoice+"/credit-note", m{"reason": "Returned", "lines": []m{{"line": 0, "quantity": 2}}})
+	c.expectStatus(response, http.StatusOK, "credit half")
+	creditNote := response.data()["_id"].(string)
+	overpaid := 60 - total/2
+
+	c.expectStatus(c.do(http.MethodPost, path, pay(m{"method": "bank_transfer", "amount": "1"})), http.StatusConflict, "pay an overpaid invoice")
+	response = c.do(http.MethodGet, "/invoice/"+invoice+"/payments", nil)
+	if due := amount(t, balance(response)["due"]); due != -overpaid {
+		t.Fatalf("due is %v after the credit note, want %v", due, -overpaid)
+	}
+
+	//A refund pays back no more than was overpaid, however much the credit note credited
+	refund := "/invoice/" + creditNote + "/refund"
+	c.expectStatus(c.do(http.MethodPost, refund, m{"method": "original", "amount": strconv.FormatFloat(overpaid+0.01, 'f', 2, 64)}), http.StatusBadRequest, "refund more than was overpaid")
+	response = c.do(http.MethodPost, refund, m{"method": "original"})
+	c.expectStatus(response, http.StatusOK, "refund what was overpaid")
+	if refunded := amount(t, response.data()["total"]); refunded != -overpaid {
+		t.Fatalf("refunded %v, want %v", refunded, -overpaid)
+	}
+	if due := amount(t, balance(response)["due"]); due != 0 {
+		t.Fatalf("due is %v after the refund", due)
+	}
+	c.expectStatus(c.do(http.MethodPost, refund, m{"method": "original"}), http.StatusConflict, "refund twice")
+}
+
+func decimal(value float64) string {
+	return strconv.FormatFloat(value, 'f', 2, 64)
+}
+
+// creditNote credits quantity units of the first line of the invoice and returns the ID and total of the credit note
+func (c *testClient) creditNote(invoice string, quantity int) (string, float64) {
+	c.server.t.Helper()
+	response := c.do(http.MethodPost, "/invoice/"+invoice+"/credit-note", m{"reason": "Returned", "lines": []m{{"line": 0, "quantity": quantity}}})
+	c.expectStatus(response, http.StatusOK, "issue credit note")
+	return response.data()["_id"].(string), amount(c.server.t, response.data()["total"])
+}
+
+// storeCredit returns the store credit the customer has left
+func (c *testClient) storeCredit(customer string) float64 {
+	c.server.t.Helper()
+	response := c.do(http.MethodGet, "/customer/"+customer+"/store-credit", nil)
+	c.expectStatus(response, http.StatusOK, "get store credit")
+	return amount(c.server.t, response.Body.Data["data"])
+}
+
+func TestRefundToStoreCredit(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	customer := c.createCustomer("Ivo")
+	invoice, total := c.issueInvoice(customer, 4, "25")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "bank_transfer", "amount": decimal(total)})), http.StatusOK, "pay in full")
+	creditNote, credited := c.creditNote(invoice, 1)
+
+	response := c.do(http.MethodPost, "/invoice/"+creditNote+"/refund", m{"method": "store_credit"})
+	c.expectStatus(response, http.StatusOK, "refund to store credit")
+	if refunded := amount(t, response.data()["total"]); refunded != credited {
+		t.Fatalf("refunded %v, want %v", refunded, credited)
+	}
+	if credit := c.storeCredit(customer); credit != -credited {
+		t.Fatalf("store credit is %v, want %v", credit, -credited)
+	}
+
+	//Store credit pays other invoices of the customer, but no more than is left of it
+	other, _ := c.issueInvoice(customer, 1, "100")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+other+"/payment", pay(m{"method": "store_credit", "amount": decimal(-credited + 0.01)})), http.StatusConflict, "spend more store credit than is left")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+other+"/payment", pay(m{"method": "store_credit", "amount": decimal(-credited)})), http.StatusOK, "spend the store credit")
+	if credit := c.storeCredit(customer); credit != 0 {
+		t.Fatalf("store credit is %v after spending it", credit)
+	}
+
+	//Invoices without a customer have nobody to hold the credit
+	anonymous, total := c.issueInvoice("", 1, "100")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+anonymous+"/payment", pay(m{"method": "bank_transfer", "amount": decimal(total)})), http.StatusOK, "pay in full")
+	creditNote, _ = c.creditNote(anonymous, 1)
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+creditNote+"/refund", m{"method": "store_credit"}), http.StatusBadRequest, "refund an invoice without customer to store credit")
+}
+
+func TestCreditNoteRefundable(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	invoice, _ := c.issueInvoice("", 4, "25")
+
+	//Nothing was paid, so the credit note only lowers what is due
+	unpaid, _ := c.creditNote(invoice, 1)
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+unpaid+"/refund", m{"method": "original"}), http.StatusConflict, "refund an unpaid invoice")
+
+	response := c.do(http.MethodGet, "/invoice/"+invoice+"/payments", nil)
+	due := amount(t, balance(response)["due"])
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "card", "amount": decimal(due), "card_token": "tok_visa"})), http.StatusOK, "pay what is due")
+
+	//Each credit note refunds no more than it credited
+	first, firstCredited := c.creditNote(invoice, 1)
+	second, secondCredited := c.creditNote(invoice, 1)
+	refund := "/invoice/" + first + "/refund"
+	c.expectStatus(c.do(http.MethodPost, refund, m{"method": "original", "amount": decimal(-firstCredited + 0.01)}), http.StatusBadRequest, "refund more than the credit note credited")
+	c.expectStatus(c.do(http.MethodPost, refund, m{"method": "original", "amount": decimal(-firstCredited / 2)}), http.StatusOK, "refund part")
+	response = c.do(http.MethodPost, refund, m{"method": "original"})
+	c.expectStatus(response, http.StatusOK, "refund the rest")
+	if refunded := amount(t, response.data()["total"]); decimal(refunded) != decimal(firstCredited-firstCredited/2) {
+		t.Fatalf("refunded %v of the rest, want %v", refunded, firstCredited-firstCredited/2)
+	}
+	c.expectStatus(c.do(http.MethodPost, refund, m{"method": "original"}), http.StatusConflict, "refund a refunded credit note")
+
+	response = c.do(http.MethodPost, "/invoice/"+second+"/refund", m{"method": "original"})
+	c.expectStatus(response, http.StatusOK, "refund the second credit note")
+	if refunded := amount(t, response.data()["total"]); refunded != secondCredited {
+		t.Fatalf("refunded %v, want %v", refunded, secondCredited)
+	}
+	if outstanding, want := server.cards.Outstanding(), int64((due+firstCredited+secondCredited)*100+0.5); outstanding != want {
+		t.Fatalf("gateway holds %d, want %d", outstanding, want)
+	}
+}
+
+func TestMergeCustomersMovesInvoicesAndPayments(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	kept, duplicate := c.createCustomer("Ivo"), c.createCustomer("Ivan")
+	invoice, total := c.issueInvoice(duplicate, 4, "25")
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(m{"method": "bank_transfer", "amount": decimal(total)})), http.StatusOK, "pay in full")
+	creditNote, credited := c.creditNote(invoice, 1)
+	c.expectStatus(c.do(http.MethodPost, "/invoice/"+creditNote+"/refund", m{"method": "store_credit"}), http.StatusOK, "refund to store credit")
+
+	c.expectStatus(c.do(http.MethodPost, "/customer/"+kept+"/merge", m{"customer_ids": []string{duplicate}}), http.StatusOK, "merge customers")
+
+	response := c.do(http.MethodGet, "/invoices?customer_id="+kept, nil)
+	c.expectStatus(response, http.StatusOK, "get invoices of the kept customer")
+	if invoices := response.Body.Data["data"].([]interface{}); len(invoices) != 2 {
+		t.Fatalf("kept customer has %d invoices and credit notes, want 2", len(invoices))
+	}
+	response = c.do(http.MethodGet, "/payments?customer_id="+kept, nil)
+	c.expectStatus(response, http.StatusOK, "get payments of the kept customer")
+	if payments := response.Body.Data["data"].([]interface{}); len(payments) != 2 {
+		t.Fatalf("kept customer has %d payments and refunds, want 2", len(payments))
+	}
+	if credit := c.storeCredit(kept); credit != -credited {
+		t.Fatalf("kept customer has %v store credit, want %v", credit, -credited)
+	}
+}
+
+func TestDeclinedCardReportsUnrefundedCards(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	invoice, _ := c.issueInvoice("", 1, "100")
+
+	response := c.do(http.MethodPost, "/invoice/"+invoice+"/payment", pay(
+		m{"method": "card", "amount": "10", "card_token": "tok_visa"},
+		m{"method": "card", "amount": "20", "card_token": "tok_unrefundable"},
+		m{"method": "card", "amount": "5", "card_token": "tok_declined"},
+	))
+	c.expectStatus(response, http.StatusInternalServerError, "pay with a declined card after one that cannot be refunded")
+	transactions, _ := response.Body.Data["transactions"].([]interface{})
+	if len(transactions) != 1 || transactions[0] == "" {
+		t.Fatalf("response names %v as charged, want the card that could not be refunded", response.Body.Data["transactions"])
+	}
+	if outstanding := server.cards.Outstanding(); outstanding != 2000 {
+		t.Fatalf("gateway holds %d, want the 2000 that could not be refunded", outstanding)
+	}
+}
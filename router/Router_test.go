@@ -0,0 +1,166 @@
+package router_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"poosible-backend/config"
+	"poosible-backend/gateway"
+	"poosible-backend/jobs"
+	"poosible-backend/repositories"
+	"poosible-backend/router"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+// testServer serves the API from in-memory repositories
+type testServer struct {
+	t      *testing.T
+	engine *gin.Engine
+	repos  *repositories.Repositories
+	cards  *gateway.Fake
+}
+
+// testClient calls the API as one user, keeping the session cookie and the access token between calls
+type testClient struct {
+	server  *testServer
+	cookies []*http.Cookie
+	token   string
+}
+
+type testResponse struct {
+	Code   int
+	Header http.Header
+	Body   struct {
+		Status  int                    `json:"status"`
+		Message string                 `json:"message"`
+		Data    map[string]interface{} `json:"data"`
+	}
+}
+
+// data returns the "data" entry of the response data
+func (r testResponse) data() map[string]interface{} {
+	data, _ := r.Body.Data["data"].(map[string]interface{})
+	return data
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	gob.Register(time.Time{})
+
+	cfg := config.Defaults(config.EnvironmentTest)
+	repos := repositories.NewMemoryRepositories()
+	engine := gin.New()
+	engine.Use(sessions.Sessions("auth-session", cookie.NewStore([]byte(cfg.Auth.SessionSecret))))
+	cards := gateway.NewFake()
+	router.SetupRouter(engine, &cfg, repos, jobs.NewImports(repos, 1), cards)
+	return &testServer{t: t, engine: engine, repos: repos, cards: cards}
+}
+
+func (s *testServer) client() *testClient {
+	return &testClient{server: s}
+}
+
+// do calls the API, headers are given as name, value pairs
+func (c *testClient) do(method, path string, body interface{}, headers ...string) testResponse {
+	c.server.t.Helper()
+
+	var raw []byte
+	if body != nil {
+		var err error
+		if raw, err = json.Marshal(body); err != nil {
+			c.server.t.Fatal(err)
+		}
+	}
+	request := httptest.NewRequest(method, "/v1/api"+path, bytes.NewReader(raw))
+	request.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+	for _, cookie := range c.cookies {
+		request.AddCookie(cookie)
+	}
+	if c.token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	recorder := httptest.NewRecorder()
+	c.server.engine.ServeHTTP(recorder, request)
+	if cookies := recorder.Result().Cookies(); len(cookies) > 0 {
+		c.cookies = cookies
+	}
+
+	var response testResponse
+	response.Code = recorder.Code
+	response.Header = recorder.Header()
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response.Body); err != nil {
+		c.server.t.Fatalf("%s %s: decoding %q: %v", method, path, recorder.Body.String(), err)
+	}
+	return response
+}
+
+// expectStatus fails the test unless the response has the status
+func (c *testClient) expectStatus(response testResponse, status int, what string) {
+	c.server.t.Helper()
+	if response.Code != status {
+		c.server.t.Fatalf("%s: got %d %q, want %d", what, response.Code, response.Body.Message, status)
+	}
+}
+
+// signUp creates a user and logs them in
+func (s *testServer) signUp(email string) *testClient {
+	s.t.Helper()
+	c := s.client()
+	response := c.do(http.MethodPost, "/user", map[string]string{"first_name": "Ana", "last_name": "Horvat", "email": email, "password": "Password123", "location": "Zagreb"})
+	c.expectStatus(response, http.StatusCreated, "sign up")
+	c.login(email, "Password123")
+	return c
+}
+
+func (c *testClient) login(email, password string) testResponse {
+	c.server.t.Helper()
+	response := c.do(http.MethodPost, "/auth/login", map[string]string{"email": email, "password": password})
+	if response.Code == http.StatusOK {
+		c.token, _ = response.data()["token"].(string)
+	}
+	return response
+}
+
+// signUpOwner creates a user that owns a new organisation
+func (s *testServer) signUpOwner(email string) *testClient {
+	s.t.Helper()
+	c := s.signUp(email)
+	response := c.do(http.MethodPost, "/organisation", map[string]interface{}{"name": "Shop " + email, "description": "Repairs", "phone": "1", "country_code": 191, "address": "Ilica 1", "zip_code": "10000", "currency_code": 978})
+	c.expectStatus(response, http.StatusOK, "create organisation")
+	return c
+}
+
+// addMember invites the user of the member client with the role and has them accept
+func (c *testClient) addMember(member *testClient, email, role string) {
+	c.server.t.Helper()
+	c.expectStatus(c.do(http.MethodPost, "/organisation/member", map[string]string{"email": email, "role": role}), http.StatusOK, "invite "+role)
+	member.expectStatus(member.do(http.MethodPost, "/user/current/invitations/"+c.organisationID()+"/accept", nil), http.StatusOK, "accept invitation")
+}
+
+// id returns the ID of the user the client is logged in as
+func (c *testClient) id() string {
+	c.server.t.Helper()
+	response := c.do(http.MethodGet, "/user/current", nil)
+	c.expectStatus(response, http.StatusOK, "current user")
+	return response.data()["_id"].(string)
+}
+
+// organisationID returns the ID of the organisation of the user the client is logged in as
+func (c *testClient) organisationID() string {
+	c.server.t.Helper()
+	response := c.do(http.MethodGet, "/user/current", nil)
+	c.expectStatus(response, http.StatusOK, "current user")
+	return response.data()["organisation_id"].(string)
+}
@@ -0,0 +1,45 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// defaultLocations returns the names of the default stock locations of the organisation
+func (c *testClient) defaultLocations() []string {
+	c.server.t.Helper()
+	response := c.do(http.MethodGet, "/stock/locations", nil)
+	c.expectStatus(response, http.StatusOK, "get stock locations")
+	names := []string{}
+	for _, location := range response.Body.Data["data"].([]interface{}) {
+		location := location.(map[string]interface{})
+		if location["default"] == true {
+			names = append(names, location["name"].(string))
+		}
+	}
+	return names
+}
+
+func TestOneDefaultStockLocation(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUpOwner("owner@example.com")
+	if names := c.defaultLocations(); len(names) != 1 || names[0] != "Main" {
+		t.Fatalf("default locations are %v, want Main", names)
+	}
+
+	response := c.do(http.MethodPost, "/stock/location", m{"name": "Van", "default": true})
+	c.expectStatus(response, http.StatusOK, "create a default location")
+	van := response.data()["_id"].(string)
+	if names := c.defaultLocations(); len(names) != 1 || names[0] != "Van" {
+		t.Fatalf("default locations are %v after creating a default, want Van", names)
+	}
+
+	response = c.do(http.MethodPost, "/stock/location", m{"name": "Shelf"})
+	c.expectStatus(response, http.StatusOK, "create a location")
+	shelf := response.data()["_id"].(string)
+	c.expectStatus(c.do(http.MethodPut, "/stock/location/"+van, m{"name": "Van"}), http.StatusConflict, "leave the organisation without a default")
+	c.expectStatus(c.do(http.MethodPut, "/stock/location/"+shelf, m{"name": "Shelf", "default": true}), http.StatusOK, "make another location the default")
+	if names := c.defaultLocations(); len(names) != 1 || names[0] != "Shelf" {
+		t.Fatalf("default locations are %v after updating a default, want Shelf", names)
+	}
+}
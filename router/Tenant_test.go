@@ -0,0 +1,87 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+// TestOtherTenantsDoNotExist has the owner of one organisation reach for the item, user and organisation of another
+// one by ID. Every attempt answers 404 and leaves the other organisation untouched.
+func TestOtherTenantsDoNotExist(t *testing.T) {
+	server := newTestServer(t)
+	a := server.signUpOwner("a@example.com")
+	b := server.signUpOwner("b@example.com")
+
+	response := b.do(http.MethodPost, "/item", map[string]interface{}{"name": "Brake pad", "description": "Front", "price": "30"})
+	b.expectStatus(response, http.StatusOK, "create item of B")
+	itemID := response.data()["_id"].(string)
+	userID := b.id()
+	organisationID := b.organisationID()
+
+	organisation := map[string]interface{}{"name": "Taken", "description": "d", "phone": "1", "country_code": 191, "address": "a", "zip_code": "1", "currency_code": 978}
+	attempts := []struct {
+		what   string
+		method string
+		path   string
+		body   interface{}
+	}{
+		{"get item", http.MethodGet, "/item/" + itemID, nil},
+		{"update item", http.MethodPut, "/item/" + itemID, map[string]interface{}{"name": "Taken", "description": "d", "price": "1"}},
+		{"patch item", http.MethodPatch, "/item/" + itemID, map[string]interface{}{"name": "Taken"}},
+		{"delete item", http.MethodDelete, "/item/" + itemID, nil},
+		{"get user", http.MethodGet, "/user/" + userID, nil},
+		{"update user", http.MethodPut, "/user/" + userID, map[string]string{"first_name": "Taken", "last_name": "Over", "location": "Nowhere"}},
+		{"assign role", http.MethodPut, "/user/" + userID + "/role", map[string]string{"role": "cashier"}},
+		{"delete user", http.MethodDelete, "/user/" + userID, nil},
+		{"update organisation", http.MethodPut, "/organisation/" + organisationID, organisation},
+		{"delete organisation", http.MethodDelete, "/organisation/" + organisationID, nil},
+	}
+	for _, attempt := range attempts {
+		response := a.do(attempt.method, attempt.path, attempt.body, "If-Match", "*")
+		a.expectStatus(response, http.StatusNotFound, attempt.what+" of another organisation")
+	}
+
+	//The organisation of the caller is the only one they get
+	response = a.do(http.MethodGet, "/organisation", nil)
+	a.expectStatus(response, http.StatusOK, "get own organisation")
+	if response.data()["_id"] == organisationID {
+		t.Fatal("GET /organisation returned another organisation")
+	}
+
+	response = b.do(http.MethodGet, "/item/"+itemID, nil)
+	b.expectStatus(response, http.StatusOK, "get own item after the attempts")
+	if response.data()["name"] != "Brake pad" {
+		t.Fatalf("item of B was changed to %v", response.data()["name"])
+	}
+	response = b.do(http.MethodGet, "/user/current", nil)
+	if response.data()["first_name"] != "Ana" || response.data()["role"] == "cashier" {
+		t.Fatalf("user of B was changed to %v", response.data())
+	}
+	response = b.do(http.MethodGet, "/organisation", nil)
+	b.expectStatus(response, http.StatusOK, "get own organisation after the attempts")
+	if response.data()["name"] == "Taken" {
+		t.Fatal("organisation of B was renamed")
+	}
+}
+
+// TestListingsStayWithinTenant checks that listings and searches only ever return documents of the organisation of
+// the caller
+func TestListingsStayWithinTenant(t *testing.T) {
+	server := newTestServer(t)
+	a := server.signUpOwner("a@example.com")
+	b := server.signUpOwner("b@example.com")
+	b.expectStatus(b.do(http.MethodPost, "/item", map[string]interface{}{"name": "Brake pad", "description": "Front", "price": "30", "sku": "BP-1"}), http.StatusOK, "create item of B")
+
+	for _, path := range []string{"/items", "/items/search?q=brake"} {
+		response := a.do(http.MethodGet, path, nil)
+		a.expectStatus(response, http.StatusOK, "list "+path)
+		if items, _ := response.Body.Data["data"].([]interface{}); len(items) > 0 {
+			t.Fatalf("%s returned items of another organisation: %v", path, items)
+		}
+	}
+	b.expectStatus(b.do(http.MethodGet, "/items/lookup?sku=BP-1", nil), http.StatusOK, "look up own SKU")
+	a.expectStatus(a.do(http.MethodGet, "/items/lookup?sku=BP-1", nil), http.StatusNotFound, "look up SKU of another organisation")
+
+	//The same SKU is free in every other organisation
+	a.expectStatus(a.do(http.MethodPost, "/item", map[string]interface{}{"name": "Brake pad", "description": "Front", "price": "30", "sku": "BP-1"}), http.StatusOK, "create item with the SKU of another organisation")
+}
@@ -0,0 +1,65 @@
+package router_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSignUpRejectsDuplicateEmailAndWeakPassword(t *testing.T) {
+	server := newTestServer(t)
+	server.signUp("ana@example.com")
+	c := server.client()
+
+	response := c.do(http.MethodPost, "/user", map[string]string{"first_name": "Ana", "last_name": "Horvat", "email": "ANA@example.com", "password": "Password123", "location": "Zagreb"})
+	c.expectStatus(response, http.StatusBadRequest, "duplicate email")
+	response = c.do(http.MethodPost, "/user", map[string]string{"first_name": "Ivo", "last_name": "Horvat", "email": "ivo@example.com", "password": "short", "location": "Zagreb"})
+	c.expectStatus(response, http.StatusBadRequest, "weak password")
+}
+
+func TestUserUpdatesOwnAccount(t *testing.T) {
+	server := newTestServer(t)
+	c := server.signUp("ana@example.com")
+	id := c.id()
+
+	response := c.do(http.MethodPut, "/user/"+id, map[string]string{"first_name": "Ana", "last_name": "Kovač", "email": "ana.kovac@example.com", "password": "NewPassword123", "location": "Split"})
+	c.expectStatus(response, http.StatusOK, "update own account")
+	if _, ok := response.data()["password"]; ok {
+		t.Fatal("user response exposes the password")
+	}
+
+	c.expectStatus(c.login("ana.kovac@example.com", "NewPassword123"), http.StatusOK, "login with new email and password")
+}
+
+func TestManagerCannotTakeOverOwner(t *testing.T) {
+	server := newTestServer(t)
+	owner := server.signUpOwner("owner@example.com")
+	manager := server.signUp("manager@example.com")
+	owner.addMember(manager, "manager@example.com", "manager")
+	ownerID, managerID := owner.id(), manager.id()
+
+	response := manager.do(http.MethodPut, "/user/"+ownerID, map[string]string{"first_name": "Ana", "last_name": "Horvat", "email": "manager@example.com", "password": "Takeover123", "location": "Zagreb"})
+	manager.expectStatus(response, http.StatusForbidden, "manager changes the login of the owner")
+	manager.expectStatus(manager.do(http.MethodDelete, "/user/"+ownerID, nil), http.StatusForbidden, "manager deletes the owner")
+	manager.expectStatus(manager.do(http.MethodPut, "/user/"+ownerID+"/role", map[string]string{"role": "cashier"}), http.StatusForbidden, "manager demotes the owner")
+
+	//The owner may rename members but not change how they log in
+	response = owner.do(http.MethodPut, "/user/"+managerID, map[string]string{"first_name": "Iva", "last_name": "Horvat", "email": "manager@example.com", "password": "Takeover123", "location": "Zagreb"})
+	owner.expectStatus(response, http.StatusForbidden, "owner sets the password of a member")
+	response = owner.do(http.MethodPut, "/user/"+managerID, map[string]string{"first_name": "Iva", "last_name": "Horvat", "location": "Zagreb"})
+	owner.expectStatus(response, http.StatusOK, "owner renames a member")
+	response = owner.do(http.MethodPut, "/user/"+managerID, map[string]string{"first_name": "", "last_name": "Horvat", "location": "Zagreb"})
+	owner.expectStatus(response, http.StatusBadRequest, "owner blanks the name of a member")
+	response = manager.do(http.MethodPut, "/user/"+managerID, map[string]string{"first_name": "Iva", "last_name": "", "email": "manager@example.com", "password": "Password123", "location": "Zagreb"})
+	manager.expectStatus(response, http.StatusBadRequest, "member blanks their own name")
+	owner.expectStatus(owner.do(http.MethodDelete, "/user/"+managerID, nil), http.StatusOK, "owner deletes a member")
+}
+
+func TestTechnicianCannotManageUsers(t *testing.T) {
+	server := newTestServer(t)
+	owner := server.signUpOwner("owner@example.com")
+	technician := server.signUp("tech@example.com")
+	owner.addMember(technician, "tech@example.com", "technician")
+
+	technician.expectStatus(technician.do(http.MethodGet, "/user/"+owner.id(), nil), http.StatusForbidden, "technician reads another user")
+	technician.expectStatus(technician.do(http.MethodGet, "/user/"+technician.id(), nil), http.StatusOK, "technician reads themselves")
+}
@@ -4,51 +4,174 @@ import (
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"poosible-backend/config"
 	"poosible-backend/controllers"
+	"poosible-backend/gateway"
+	"poosible-backend/jobs"
 	"poosible-backend/middleware"
+	"poosible-backend/models"
+	"poosible-backend/repositories"
 )
 
 // SetupRouter is a function to set up all routes
-var SetupRouter = func(router *gin.Engine) {
+var SetupRouter = func(router *gin.Engine, cfg *config.Config, repos *repositories.Repositories, imports *jobs.Imports, cards gateway.PaymentGateway) {
 	v1api := router.Group("/v1/api")
 	{
 
 		//PUBLIC ROUTER
 
 		//Auth Routes
-		v1api.POST("auth/login", controllers.Login())
-		v1api.POST("auth/logout", controllers.Logout())
+		v1api.POST("auth/login", controllers.Login(cfg.Auth, repos.Users, repos.RefreshTokens))
+		v1api.POST("auth/refresh", controllers.Refresh(cfg.Auth, repos.Users, repos.RefreshTokens))
+		v1api.POST("auth/logout", controllers.Logout(repos.RefreshTokens))
 
 		//User Routes
-		v1api.POST("/user", controllers.CreateUser())
+		v1api.POST("/user", controllers.CreateUser(repos.Users))
 
 		//Helper Routes
 		v1api.GET("/helper/countries", controllers.Countries())
 		v1api.GET("/helper/currencies", controllers.Currencies())
 
-		// Apply the AuthMiddleware to the protected group
-		protectedGroup := v1api.Group("", middleware.AuthMiddleware())
+		//Estimate Routes, the token of the link stands in for a login
+		v1api.GET("/public/estimate/:token", controllers.GetPublicEstimate(repos.Estimates, repos.Organisations))
+		v1api.POST("/public/estimate/:token/reply", controllers.ReplyToEstimate(repos.Estimates, repos.Organisations))
+
+		// Apply the AuthMiddleware and resolve the tenant for the protected group
+		protectedGroup := v1api.Group("", middleware.AuthMiddleware(cfg.Auth), middleware.TenantMiddleware(repos.Users, repos.Roles))
 		{
 			// PRIVATE ROUTER
 
 			//User Routes
 			protectedGroup.GET("/user/current", controllers.GetCurrentUser())
-			protectedGroup.GET("/user/:userId", controllers.GetUser())
-			protectedGroup.PUT("/user/:userId", controllers.UpdateUser())
-			protectedGroup.DELETE("/user/:userId", controllers.DeleteUser())
+			protectedGroup.GET("/user/current/invitations", controllers.GetInvitations())
+			protectedGroup.POST("/user/current/invitations/:organisationId/accept", controllers.AcceptInvitation(repos.Users, repos.Roles))
+			protectedGroup.DELETE("/user/current/invitations/:organisationId", controllers.DeclineInvitation(repos.Users))
+			protectedGroup.GET("/user/:userId", middleware.RequireSelfOrPermission("userId", models.PermissionUsersRead), controllers.GetUser(repos.Users))
+			protectedGroup.PUT("/user/:userId", middleware.RequireSelfOrPermission("userId", models.PermissionUsersWrite), controllers.UpdateUser(repos.Users, repos.Roles))
+			protectedGroup.PUT("/user/:userId/role", middleware.RequirePermission(models.PermissionUsersWrite), controllers.AssignUserRole(repos.Users, repos.Roles))
+			protectedGroup.DELETE("/user/:userId", middleware.RequirePermission(models.PermissionUsersDelete), controllers.DeleteUser(repos.Users, repos.Roles))
 
 			//Organization Routes
-			protectedGroup.POST("/organisation", controllers.CreateOrganisation())
-			protectedGroup.GET("/organisation", controllers.GetOrganisation())
-			protectedGroup.PUT("/organisation/:organisationId", controllers.UpdateOrganisation())
-			protectedGroup.DELETE("/organisation/:organisationId", controllers.DeleteOrganisation())
+			protectedGroup.POST("/organisation", controllers.CreateOrganisation(repos.Organisations, repos.Users))
+			protectedGroup.GET("/organisation", middleware.RequirePermission(models.PermissionOrganisationRead), controllers.GetOrganisation(repos.Organisations))
+			protectedGroup.POST("/organisation/member", middleware.RequirePermission(models.PermissionUsersWrite), controllers.AddOrganisationMember(repos.Organisations, repos.Users, repos.Roles))
+			protectedGroup.PUT("/organisation/:organisationId", middleware.RequirePermission(models.PermissionOrganisationWrite), controllers.UpdateOrganisation(repos.Organisations, repos.Items, repos.Invoices, repos.CashSessions))
+			protectedGroup.DELETE("/organisation/:organisationId", middleware.RequirePermission(models.PermissionOrganisationDelete), controllers.DeleteOrganisation(repos.Organisations))
+
+			//Role Routes
+			protectedGroup.GET("/roles", middleware.RequirePermission(models.PermissionRolesRead), controllers.GetRoles(repos.Roles))
+			protectedGroup.POST("/role", middleware.RequirePermission(models.PermissionRolesWrite), controllers.CreateRole(repos.Roles))
+			protectedGroup.PUT("/role/:roleId", middleware.RequirePermission(models.PermissionRolesWrite), controllers.UpdateRole(repos.Roles))
+			protectedGroup.DELETE("/role/:roleId", middleware.RequirePermission(models.PermissionRolesDelete), controllers.DeleteRole(repos.Roles, repos.Users))
 
 			//Item Routes
-			protectedGroup.POST("/item", controllers.CreateItem())
-			protectedGroup.GET("/item/:itemId", controllers.GetItem())
-			protectedGroup.GET("/items", controllers.GetItems())
-			protectedGroup.PUT("/item/:itemId", controllers.UpdateItem())
-			protectedGroup.DELETE("/item/:itemId", controllers.DeleteItem())
+			protectedGroup.POST("/item", middleware.RequirePermission(models.PermissionItemsWrite), controllers.CreateItem(repos.Items, repos.Organisations, repos.TaxRates, repos.Suppliers, repos.Categories))
+			protectedGroup.GET("/item/:itemId", middleware.RequirePermission(models.PermissionItemsRead), controllers.GetItem(repos.Items))
+			protectedGroup.GET("/items", middleware.RequirePermission(models.PermissionItemsRead), controllers.GetItems(repos.Items, repos.Organisations, repos.Categories))
+			protectedGroup.GET("/items/lookup", middleware.RequirePermission(models.PermissionItemsRead), controllers.LookupItem(repos.Items))
+			protectedGroup.GET("/items/search", middleware.RequirePermission(models.PermissionItemsRead), controllers.SearchItems(repos.Items))
+			protectedGroup.GET("/items/export", middleware.RequirePermission(models.PermissionItemsRead), controllers.ExportItems(repos.Items, repos.Organisations, repos.Categories))
+			protectedGroup.POST("/items/import", middleware.RequirePermission(models.PermissionItemsWrite), controllers.ImportItems(imports, repos.Items, repos.Organisations, repos.TaxRates, repos.Suppliers, repos.Categories))
+			protectedGroup.GET("/items/import/:importId", middleware.RequirePermission(models.PermissionItemsRead), controllers.GetItemImport(repos.ItemImports))
+			protectedGroup.PUT("/item/:itemId", middleware.RequirePermission(models.PermissionItemsWrite), controllers.UpdateItem(repos.Items, repos.TaxRates, repos.Suppliers, repos.Categories))
+			protectedGroup.PATCH("/item/:itemId", middleware.RequirePermission(models.PermissionItemsWrite), controllers.PatchItem(repos.Items, repos.TaxRates, repos.Suppliers, repos.Categories))
+			protectedGroup.DELETE("/item/:itemId", middleware.RequirePermission(models.PermissionItemsDelete), controllers.DeleteItem(repos.Items))
+			protectedGroup.GET("/item/:itemId/stock", middleware.RequirePermission(models.PermissionStockRead), controllers.GetItemStock(repos.Stock, repos.Items))
+
+			//Category Routes
+			protectedGroup.POST("/category", middleware.RequirePermission(models.PermissionItemsWrite), controllers.CreateCategory(repos.Categories))
+			protectedGroup.GET("/category/:categoryId", middleware.RequirePermission(models.PermissionItemsRead), controllers.GetCategory(repos.Categories))
+			protectedGroup.GET("/categories", middleware.RequirePermission(models.PermissionItemsRead), controllers.GetCategories(repos.Categories))
+			protectedGroup.PUT("/category/:categoryId", middleware.RequirePermission(models.PermissionItemsWrite), controllers.UpdateCategory(repos.Categories))
+			protectedGroup.POST("/category/:categoryId/move", middleware.RequirePermission(models.PermissionItemsWrite), controllers.MoveCategory(repos.Categories))
+			protectedGroup.DELETE("/category/:categoryId", middleware.RequirePermission(models.PermissionItemsDelete), controllers.DeleteCategory(repos.Categories, repos.Items))
+
+			//Customer Routes
+			protectedGroup.POST("/customer", middleware.RequirePermission(models.PermissionCustomersWrite), controllers.CreateCustomer(repos.Customers))
+			protectedGroup.GET("/customer/:customerId", middleware.RequirePermission(models.PermissionCustomersRead), controllers.GetCustomer(repos.Customers))
+			protectedGroup.GET("/customers", middleware.RequirePermission(models.PermissionCustomersRead), controllers.GetCustomers(repos.Customers))
+			protectedGroup.PUT("/customer/:customerId", middleware.RequirePermission(models.PermissionCustomersWrite), controllers.UpdateCustomer(repos.Customers))
+			protectedGroup.DELETE("/customer/:customerId", middleware.RequirePermission(models.PermissionCustomersDelete), controllers.DeleteCustomer(repos.Customers, repos.Vehicles, repos.WorkOrders, repos.Estimates, repos.Invoices, repos.Payments))
+			protectedGroup.POST("/customer/:customerId/merge", middleware.RequirePermission(models.PermissionCustomersWrite, models.PermissionCustomersDelete), controllers.MergeCustomers(repos.Customers, repos.Vehicles, repos.WorkOrders, repos.Estimates, repos.Invoices, repos.Payments))
+
+			//Vehicle Routes
+			protectedGroup.POST("/vehicle", middleware.RequirePermission(models.PermissionVehiclesWrite), controllers.CreateVehicle(repos.Vehicles, repos.Customers))
+			protectedGroup.GET("/vehicle/:vehicleId", middleware.RequirePermission(models.PermissionVehiclesRead), controllers.GetVehicle(repos.Vehicles))
+			protectedGroup.GET("/vehicles", middleware.RequirePermission(models.PermissionVehiclesRead), controllers.GetVehicles(repos.Vehicles))
+			protectedGroup.PUT("/vehicle/:vehicleId", middleware.RequirePermission(models.PermissionVehiclesWrite), controllers.UpdateVehicle(repos.Vehicles, repos.Customers))
+			protectedGroup.DELETE("/vehicle/:vehicleId", middleware.RequirePermission(models.PermissionVehiclesDelete), controllers.DeleteVehicle(repos.Vehicles))
+			protectedGroup.POST("/vehicle/:vehicleId/odometer", middleware.RequirePermission(models.PermissionVehiclesWrite), controllers.AddOdometerReading(repos.Vehicles))
+			protectedGroup.GET("/vin/:vin", middleware.RequirePermission(models.PermissionVehiclesRead), controllers.DecodeVIN())
+
+			//Work Order Routes
+			protectedGroup.POST("/work-order", middleware.RequirePermission(models.PermissionWorkOrdersWrite), controllers.CreateWorkOrder(repos.WorkOrders, repos.Organisations, repos.Customers, repos.Vehicles, repos.Items, repos.Users))
+			protectedGroup.GET("/work-order/:workOrderId", middleware.RequirePermission(models.PermissionWorkOrdersRead), controllers.GetWorkOrder(repos.WorkOrders))
+			protectedGroup.GET("/work-orders", middleware.RequirePermission(models.PermissionWorkOrdersRead), controllers.GetWorkOrders(repos.WorkOrders))
+			protectedGroup.PUT("/work-order/:workOrderId", middleware.RequirePermission(models.PermissionWorkOrdersWrite), controllers.UpdateWorkOrder(repos.WorkOrders, repos.Organisations, repos.Customers, repos.Vehicles, repos.Items, repos.Users))
+			protectedGroup.POST("/work-order/:workOrderId/status", middleware.RequirePermission(models.PermissionWorkOrdersWrite), controllers.TransitionWorkOrder(repos.WorkOrders, repos.Organisations, repos.Items, repos.Stock, repos.StockLocations))
+			protectedGroup.DELETE("/work-order/:workOrderId", middleware.RequirePermission(models.PermissionWorkOrdersDelete), controllers.DeleteWorkOrder(repos.WorkOrders))
+			protectedGroup.POST("/work-order/:workOrderId/invoice", middleware.RequirePermission(models.PermissionInvoicesWrite), controllers.IssueWorkOrderInvoice(repos.Invoices, repos.Organisations, repos.Customers, repos.WorkOrders, repos.Items, repos.TaxRates))
+
+			//Estimate Routes
+			protectedGroup.POST("/estimate", middleware.RequirePermission(models.PermissionEstimatesWrite), controllers.CreateEstimate(repos.Estimates, repos.Organisations, repos.Customers, repos.Vehicles, repos.Items, repos.Users))
+			protectedGroup.GET("/estimate/:estimateId", middleware.RequirePermission(models.PermissionEstimatesRead), controllers.GetEstimate(repos.Estimates))
+			protectedGroup.GET("/estimates", middleware.RequirePermission(models.PermissionEstimatesRead), controllers.GetEstimates(repos.Estimates))
+			protectedGroup.PUT("/estimate/:estimateId", middleware.RequirePermission(models.PermissionEstimatesWrite), controllers.UpdateEstimate(repos.Estimates, repos.Organisations, repos.Customers, repos.Vehicles, repos.Items, repos.Users))
+			protectedGroup.POST("/estimate/:estimateId/share", middleware.RequirePermission(models.PermissionEstimatesWrite), controllers.ShareEstimate(repos.Estimates))
+			protectedGroup.POST("/estimate/:estimateId/work-order", middleware.RequirePermission(models.PermissionEstimatesWrite, models.PermissionWorkOrdersWrite), controllers.ConvertEstimate(repos.Estimates, repos.WorkOrders))
+
+			//Tax Rate Routes
+			protectedGroup.POST("/tax-rate", middleware.RequirePermission(models.PermissionTaxRatesWrite), controllers.CreateTaxRate(repos.TaxRates))
+			protectedGroup.GET("/tax-rate/:taxRateId", middleware.RequirePermission(models.PermissionTaxRatesRead), controllers.GetTaxRate(repos.TaxRates))
+			protectedGroup.GET("/tax-rates", middleware.RequirePermission(models.PermissionTaxRatesRead), controllers.GetTaxRates(repos.TaxRates))
+			protectedGroup.PUT("/tax-rate/:taxRateId", middleware.RequirePermission(models.PermissionTaxRatesWrite), controllers.UpdateTaxRate(repos.TaxRates))
+			protectedGroup.DELETE("/tax-rate/:taxRateId", middleware.RequirePermission(models.PermissionTaxRatesDelete), controllers.DeleteTaxRate(repos.TaxRates, repos.Items))
+
+			//Stock Routes
+			protectedGroup.POST("/stock/location", middleware.RequirePermission(models.PermissionStockWrite), controllers.CreateStockLocation(repos.StockLocations))
+			protectedGroup.GET("/stock/locations", middleware.RequirePermission(models.PermissionStockRead), controllers.GetStockLocations(repos.StockLocations))
+			protectedGroup.PUT("/stock/location/:locationId", middleware.RequirePermission(models.PermissionStockWrite), controllers.UpdateStockLocation(repos.StockLocations))
+			protectedGroup.DELETE("/stock/location/:locationId", middleware.RequirePermission(models.PermissionStockWrite), controllers.DeleteStockLocation(repos.StockLocations, repos.Stock))
+			protectedGroup.POST("/stock/movement", middleware.RequirePermission(models.PermissionStockWrite), controllers.RecordStockMovement(repos.Stock, repos.StockLocations, repos.Items, repos.Organisations))
+			protectedGroup.POST("/stock/transfer", middleware.RequirePermission(models.PermissionStockWrite), controllers.TransferStock(repos.Stock, repos.StockLocations, repos.Items, repos.Organisations))
+			protectedGroup.GET("/stock/movements", middleware.RequirePermission(models.PermissionStockRead), controllers.GetStockMovements(repos.Stock))
+
+			//Supplier Routes
+			protectedGroup.POST("/supplier", middleware.RequirePermission(models.PermissionSuppliersWrite), controllers.CreateSupplier(repos.Suppliers))
+			protectedGroup.GET("/supplier/:supplierId", middleware.RequirePermission(models.PermissionSuppliersRead), controllers.GetSupplier(repos.Suppliers))
+			protectedGroup.GET("/suppliers", middleware.RequirePermission(models.PermissionSuppliersRead), controllers.GetSuppliers(repos.Suppliers))
+			protectedGroup.PUT("/supplier/:supplierId", middleware.RequirePermission(models.PermissionSuppliersWrite), controllers.UpdateSupplier(repos.Suppliers))
+			protectedGroup.DELETE("/supplier/:supplierId", middleware.RequirePermission(models.PermissionSuppliersDelete), controllers.DeleteSupplier(repos.Suppliers, repos.Items, repos.PurchaseOrders))
+
+			//Purchase Order Routes
+			protectedGroup.POST("/purchase-order", middleware.RequirePermission(models.PermissionPurchaseOrdersWrite), controllers.CreatePurchaseOrder(repos.PurchaseOrders, repos.Organisations, repos.Suppliers, repos.Items, repos.Stock))
+			protectedGroup.GET("/purchase-order/:purchaseOrderId", middleware.RequirePermission(models.PermissionPurchaseOrdersRead), controllers.GetPurchaseOrder(repos.PurchaseOrders))
+			protectedGroup.GET("/purchase-orders", middleware.RequirePermission(models.PermissionPurchaseOrdersRead), controllers.GetPurchaseOrders(repos.PurchaseOrders))
+			protectedGroup.PUT("/purchase-order/:purchaseOrderId", middleware.RequirePermission(models.PermissionPurchaseOrdersWrite), controllers.UpdatePurchaseOrder(repos.PurchaseOrders, repos.Organisations, repos.Suppliers, repos.Items, repos.Stock))
+			protectedGroup.POST("/purchase-order/:purchaseOrderId/status", middleware.RequirePermission(models.PermissionPurchaseOrdersWrite), controllers.TransitionPurchaseOrder(repos.PurchaseOrders, repos.Suppliers))
+			protectedGroup.POST("/purchase-order/:purchaseOrderId/receipt", middleware.RequirePermission(models.PermissionPurchaseOrdersWrite, models.PermissionStockWrite), controllers.ReceivePurchaseOrder(repos.PurchaseOrders, repos.Stock, repos.StockLocations))
+			protectedGroup.GET("/reorder-suggestions", middleware.RequirePermission(models.PermissionPurchaseOrdersRead), controllers.GetReorderSuggestions(repos.ReorderSuggestions, repos.Suppliers))
+			protectedGroup.POST("/reorder-suggestions/purchase-orders", middleware.RequirePermission(models.PermissionPurchaseOrdersWrite), controllers.OrderReorderSuggestions(repos.ReorderSuggestions, repos.Suppliers, repos.PurchaseOrders, repos.Organisations))
+
+			//Invoice Routes
+			protectedGroup.POST("/invoice", middleware.RequirePermission(models.PermissionInvoicesWrite), controllers.IssueInvoice(repos.Invoices, repos.Organisations, repos.Customers, repos.Items, repos.TaxRates, repos.Stock, repos.StockLocations))
+			protectedGroup.GET("/invoice/:invoiceId", middleware.RequirePermission(models.PermissionInvoicesRead), controllers.GetInvoice(repos.Invoices))
+			protectedGroup.GET("/invoices", middleware.RequirePermission(models.PermissionInvoicesRead), controllers.GetInvoices(repos.Invoices))
+			protectedGroup.POST("/invoice/:invoiceId/credit-note", middleware.RequirePermission(models.PermissionInvoicesWrite), controllers.IssueCreditNote(repos.Invoices, repos.Items, repos.Stock, repos.StockLocations))
+			protectedGroup.POST("/invoice/:invoiceId/payment", middleware.RequirePermission(models.PermissionPaymentsWrite), controllers.TakePayment(repos.Payments, repos.Invoices, repos.CashSessions, cards))
+			protectedGroup.GET("/invoice/:invoiceId/payments", middleware.RequirePermission(models.PermissionPaymentsRead), controllers.GetInvoicePayments(repos.Payments, repos.Invoices))
+			protectedGroup.GET("/payment/:paymentId", middleware.RequirePermission(models.PermissionPaymentsRead), controllers.GetPayment(repos.Payments))
+			protectedGroup.GET("/payments", middleware.RequirePermission(models.PermissionPaymentsRead), controllers.GetPayments(repos.Payments))
+			protectedGroup.POST("/invoice/:invoiceId/refund", middleware.RequirePermission(models.PermissionPaymentsWrite), controllers.RefundCreditNote(repos.Payments, repos.Invoices, repos.CashSessions, cards))
+			protectedGroup.GET("/customer/:customerId/store-credit", middleware.RequirePermission(models.PermissionPaymentsRead), controllers.GetStoreCredit(repos.Payments, repos.Customers, repos.Organisations))
+			protectedGroup.POST("/cash-session", middleware.RequirePermission(models.PermissionCashSessionsWrite), controllers.OpenCashSession(repos.CashSessions, repos.Organisations))
+			protectedGroup.GET("/cash-session/current", middleware.RequirePermission(models.PermissionCashSessionsWrite), controllers.GetCurrentCashSession(repos.CashSessions))
+			protectedGroup.POST("/cash-session/:sessionId/movement", middleware.RequirePermission(models.PermissionCashSessionsWrite), controllers.AddCashMovement(repos.CashSessions))
+			protectedGroup.POST("/cash-session/:sessionId/close", middleware.RequirePermission(models.PermissionCashSessionsWrite), controllers.CloseCashSession(repos.CashSessions, repos.Payments, repos.Invoices))
+			protectedGroup.GET("/cash-session/:sessionId", middleware.RequirePermission(models.PermissionCashSessionsRead), controllers.GetCashSession(repos.CashSessions))
+			protectedGroup.GET("/cash-session/:sessionId/x-report", middleware.RequirePermission(models.PermissionCashSessionsRead), controllers.GetCashSessionXReport(repos.CashSessions, repos.Payments, repos.Invoices))
+			protectedGroup.GET("/cash-session/:sessionId/z-report", middleware.RequirePermission(models.PermissionCashSessionsRead), controllers.GetCashSessionZReport(repos.CashSessions))
+			protectedGroup.GET("/cash-sessions", middleware.RequirePermission(models.PermissionCashSessionsRead), controllers.GetCashSessions(repos.CashSessions))
 		}
 
 	}
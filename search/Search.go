@@ -0,0 +1,216 @@
+// Package search ranks documents against what counter staff type, which is often cut short ("frnt"), misspelt
+// ("brkae") or a part number typed without its dashes. Documents are split into weighted fields of words, and every
+// word of the query takes the best match it finds among them.
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// PrefixLength is how many leading characters of a query word a term must share to be a candidate. Typos are
+// tolerated after them, which is where they are usually made.
+const PrefixLength = 2
+
+// How well a query word matches a term, from an exact match down to a typo
+const (
+	exactScore        = 1.0
+	prefixScore       = 0.8
+	abbreviationScore = 0.6
+	typoScore         = 0.5
+)
+
+// Field is a text of a document and how much a match in it counts, e.g. a name counts more than a description
+type Field struct {
+	Text   string
+	Weight float64
+	// Code marks part numbers and SKUs, which also match when typed without their spaces, dashes and dots
+	Code bool
+}
+
+// Result is how well a document matches a query
+type Result struct {
+	// Matched is the number of query words that matched the document
+	Matched int
+	Score   float64
+}
+
+// Better reports whether the result ranks before the other, documents matching more of the query come first
+func (result Result) Better(other Result) bool {
+	if result.Matched != other.Matched {
+		return result.Matched > other.Matched
+	}
+	return result.Score > other.Score
+}
+
+// Words splits the text into lower case words of letters and digits
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// compact returns the letters and digits of a code as one lower case word
+func compact(text string) string {
+	return strings.Join(Words(text), "")
+}
+
+func (field Field) words() []string {
+	words := Words(field.Text)
+	if field.Code && len(words) > 1 {
+		words = append(words, compact(field.Text))
+	}
+	return words
+}
+
+// Terms returns the distinct words of the fields, which are stored with a document to find its candidates
+func Terms(fields ...Field) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	for _, field := range fields {
+		for _, word := range field.words() {
+			if !seen[word] {
+				seen[word] = true
+				terms = append(terms, word)
+			}
+		}
+	}
+	sort.Strings(terms)
+	return terms
+}
+
+// Prefixes returns the distinct leading characters of the query words that a candidate must have a term starting with
+func Prefixes(query []string) []string {
+	seen := map[string]bool{}
+	prefixes := []string{}
+	for _, word := range query {
+		prefix := string(leading([]rune(word), PrefixLength))
+		if !seen[prefix] {
+			seen[prefix] = true
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// Candidate reports whether any of the terms starts with one of the prefixes
+func Candidate(terms []string, prefixes []string) bool {
+	for _, term := range terms {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(term, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Score matches every query word against the words of the fields
+func Score(query []string, fields []Field) Result {
+	var result Result
+	for _, word := range query {
+		best := 0.0
+		for _, field := range fields {
+			for _, term := range field.words() {
+				if score := Match(word, term) * field.Weight; score > best {
+					best = score
+				}
+			}
+		}
+		if best > 0 {
+			result.Matched++
+			result.Score += best
+		}
+	}
+	return result
+}
+
+// Match scores how well a query word matches a term, 0 if it does not. The word may be the start of the term, an
+// abbreviation of it that keeps its first letter ("brk" for "brake") or the term, or its start, with a typo.
+func Match(word string, term string) float64 {
+	switch {
+	case word == term:
+		return exactScore
+	case strings.HasPrefix(term, word):
+		return prefixScore
+	}
+
+	w, t := []rune(word), []rune(term)
+	if len(w) >= 3 && len(w) < len(t) && w[0] == t[0] && subsequence(w, t) {
+		return abbreviationScore
+	}
+
+	allowed := typos(len(w))
+	if allowed == 0 {
+		return 0
+	}
+	if distance(w, t) <= allowed || (len(t) > len(w) && distance(w, leading(t, len(w))) <= allowed) {
+		return typoScore
+	}
+	return 0
+}
+
+// typos is how many typos a word of the length may have, short words have too many neighbours to allow any
+func typos(length int) int {
+	switch {
+	case length >= 8:
+		return 2
+	case length >= 4:
+		return 1
+	}
+	return 0
+}
+
+func leading(runes []rune, n int) []rune {
+	if len(runes) < n {
+		return runes
+	}
+	return runes[:n]
+}
+
+// subsequence reports whether the characters of a appear in b in the same order
+func subsequence(a []rune, b []rune) bool {
+	i := 0
+	for _, r := range b {
+		if i < len(a) && a[i] == r {
+			i++
+		}
+	}
+	return i == len(a)
+}
+
+// distance is the number of inserted, deleted, substituted and swapped neighbouring characters that turn a into b
+func distance(a []rune, b []rune) int {
+	rows := make([][]int, len(a)+1)
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+		rows[i][0] = i
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			rows[i][j] = min(rows[i-1][j]+1, rows[i][j-1]+1, rows[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				rows[i][j] = min(rows[i][j], rows[i-2][j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)][len(b)]
+}
+
+func min(values ...int) int {
+	smallest := values[0]
+	for _, value := range values[1:] {
+		if value < smallest {
+			smallest = value
+		}
+	}
+	return smallest
+}
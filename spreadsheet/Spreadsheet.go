@@ -0,0 +1,289 @@
+// Package spreadsheet reads the rows of the CSV and XLSX files shops export their part lists to. Only cell values are
+// read, XLSX formatting, formulas and every sheet but the first are ignored.
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type Format string
+
+const (
+	CSV  Format = "csv"
+	XLSX Format = "xlsx"
+)
+
+// Limits of the files that are read. Rows and columns are those of Excel, which no sheet it saved goes beyond. Cells
+// counts the empty cells before the values of a row and the empty rows before a row too, since they are kept.
+const (
+	MaxRows    = 1 << 20
+	MaxColumns = 1 << 14
+	MaxCells   = 1 << 22
+	// maxPartSize is the largest a part of an XLSX workbook may be once it is unpacked
+	maxPartSize = 256 << 20
+)
+
+var (
+	ErrFormat   = errors.New("file must be a CSV or XLSX file")
+	ErrEmpty    = errors.New("file has no rows")
+	ErrXLSX     = errors.New("file is not a valid XLSX workbook")
+	ErrTooLarge = fmt.Errorf("file has more than %d rows, %d columns or %d cells", MaxRows, MaxColumns, MaxCells)
+)
+
+// FormatOf returns the format of a file from its name
+func FormatOf(name string) (Format, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return CSV, nil
+	case ".xlsx":
+		return XLSX, nil
+	}
+	return "", ErrFormat
+}
+
+// Read returns the rows of the file, each as many cells long as the row has values up to its last one
+func Read(format Format, content []byte) ([][]string, error) {
+	var rows [][]string
+	var err error
+	switch format {
+	case CSV:
+		rows, err = readCSV(content)
+	case XLSX:
+		rows, err = readXLSX(content)
+	default:
+		return nil, ErrFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrEmpty
+	}
+	return rows, nil
+}
+
+// readCSV reads comma, semicolon or tab separated values, whichever the first line uses the most. Spreadsheet programs
+// set to a locale with a decimal comma save CSV files with semicolons.
+func readCSV(content []byte) ([][]string, error) {
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+	firstLine, _, _ := bytes.Cut(content, []byte("\n"))
+	delimiter, most := ',', 0
+	for _, candidate := range []rune{',', ';', '\t'} {
+		if count := bytes.Count(firstLine, []byte(string(candidate))); count > most {
+			delimiter, most = candidate, count
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	rows := [][]string{}
+	cells := 0
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		cells += len(row)
+		if len(rows) == MaxRows || len(row) > MaxColumns || cells > MaxCells {
+			return nil, ErrTooLarge
+		}
+		rows = append(rows, row)
+	}
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		RelationshipID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+// xlsxText is a string of the shared strings table or an inline string, rich text is split into runs
+type xlsxText struct {
+	Text string `xml:"t"`
+	Runs []struct {
+		Text string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (text xlsxText) String() string {
+	value := text.Text
+	for _, run := range text.Runs {
+		value += run.Text
+	}
+	return value
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Number int `xml:"r,attr"`
+		Cells  []struct {
+			Reference string    `xml:"r,attr"`
+			Type      string    `xml:"t,attr"`
+			Value     string    `xml:"v"`
+			Inline    *xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX reads the first sheet of the workbook
+func readXLSX(content []byte) ([][]string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, ErrXLSX
+	}
+
+	var workbook xlsxWorkbook
+	if err := readXML(archive, "xl/workbook.xml", &workbook); err != nil || len(workbook.Sheets) == 0 {
+		return nil, ErrXLSX
+	}
+	var relationships xlsxRelationships
+	if err := readXML(archive, "xl/_rels/workbook.xml.rels", &relationships); err != nil {
+		return nil, ErrXLSX
+	}
+	sheetPath := ""
+	for _, relationship := range relationships.Relationships {
+		if relationship.ID == workbook.Sheets[0].RelationshipID {
+			sheetPath = relationship.Target
+		}
+	}
+	if sheetPath == "" {
+		return nil, ErrXLSX
+	}
+	//Targets are relative to the xl folder unless they are absolute
+	if strings.HasPrefix(sheetPath, "/") {
+		sheetPath = strings.TrimPrefix(sheetPath, "/")
+	} else {
+		sheetPath = path.Join("xl", sheetPath)
+	}
+
+	var sharedStrings xlsxSharedStrings
+	if err := readXML(archive, "xl/sharedStrings.xml", &sharedStrings); err != nil && err != errMissingPart {
+		return nil, xlsxError(err)
+	}
+	var sheet xlsxSheet
+	if err := readXML(archive, sheetPath, &sheet); err != nil {
+		return nil, xlsxError(err)
+	}
+
+	rows := [][]string{}
+	cells := 0
+	for _, sheetRow := range sheet.Rows {
+		//Row numbers and cell references come from the file, they are checked before anything is padded up to them
+		if sheetRow.Number > MaxRows || len(rows) == MaxRows {
+			return nil, ErrTooLarge
+		}
+		//Empty rows are left out of the sheet too, they are kept so rows are reported by the number they have there
+		for sheetRow.Number > 0 && len(rows) < sheetRow.Number-1 {
+			rows = append(rows, []string{})
+		}
+		row := []string{}
+		for _, cell := range sheetRow.Cells {
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				index, err := strconv.Atoi(cell.Value)
+				if err != nil || index < 0 || index >= len(sharedStrings.Items) {
+					return nil, ErrXLSX
+				}
+				value = sharedStrings.Items[index].String()
+			case "inlineStr":
+				if cell.Inline != nil {
+					value = cell.Inline.String()
+				}
+			case "b":
+				value = strconv.FormatBool(cell.Value == "1")
+			}
+
+			//Empty cells are left out of the sheet, so the reference says which column a cell is in
+			column := len(row)
+			if cell.Reference != "" {
+				column = columnIndex(cell.Reference)
+			}
+			if column >= MaxColumns || len(row) >= MaxColumns {
+				return nil, ErrTooLarge
+			}
+			if column > len(row) {
+				cells += column - len(row)
+			}
+			if cells++; cells > MaxCells {
+				return nil, ErrTooLarge
+			}
+			for len(row) < column {
+				row = append(row, "")
+			}
+			row = append(row, value)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+var errMissingPart = errors.New("part is missing from the workbook")
+
+// xlsxError returns the error reading a part of the workbook is reported with
+func xlsxError(err error) error {
+	if err == ErrTooLarge {
+		return err
+	}
+	return ErrXLSX
+}
+
+func readXML(archive *zip.Reader, name string, value interface{}) error {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		//The size in the archive is what the file claims, the reader stops at the limit whatever it unpacks to
+		if file.UncompressedSize64 > maxPartSize {
+			return ErrTooLarge
+		}
+		return xml.NewDecoder(io.LimitReader(reader, maxPartSize)).Decode(value)
+	}
+	return errMissingPart
+}
+
+// columnIndex returns the zero based column of a cell reference such as "AB12". Columns past MaxColumns are returned
+// as MaxColumns, however many letters the reference has.
+func columnIndex(reference string) int {
+	column := 0
+	for _, r := range reference {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		column = column*26 + int(r-'A') + 1
+		if column > MaxColumns {
+			return MaxColumns
+		}
+	}
+	return column - 1
+}
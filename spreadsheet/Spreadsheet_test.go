@@ -0,0 +1,67 @@
+package spreadsheet_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"poosible-backend/spreadsheet"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// workbook returns an XLSX file whose first sheet has the rows, given as the XML of its sheetData
+func workbook(t *testing.T, rows string) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml":            `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Items" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		"xl/worksheets/sheet1.xml":   `<worksheet><sheetData>` + rows + `</sheetData></worksheet>`,
+	}
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range parts {
+		part, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	content := workbook(t, `<row r="1"><c r="A1" t="inlineStr"><is><t>sku</t></is></c><c r="C1" t="inlineStr"><is><t>price</t></is></c></row><row r="3"><c r="B3"><v>12.5</v></c></row>`)
+	rows, err := spreadsheet.Read(spreadsheet.XLSX, content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"sku", "", "price"}, {}, {"", "12.5"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("read %q, want %q", rows, want)
+	}
+}
+
+func TestReadRefusesOversizedSheets(t *testing.T) {
+	//Rows holding only a value in the last column are padded to every column
+	wideRow := `<row><c r="XFD1"><v>1</v></c></row>`
+	tests := []struct {
+		name    string
+		format  spreadsheet.Format
+		content []byte
+	}{
+		{"row number past the last row", spreadsheet.XLSX, workbook(t, `<row r="2000000000"><c r="A2000000000"><v>1</v></c></row>`)},
+		{"column past the last column", spreadsheet.XLSX, workbook(t, `<row r="1"><c r="ZZZZZZZ1"><v>1</v></c></row>`)},
+		{"too many cells", spreadsheet.XLSX, workbook(t, strings.Repeat(wideRow, spreadsheet.MaxCells/spreadsheet.MaxColumns+1))},
+		{"CSV row wider than the last column", spreadsheet.CSV, []byte(strings.Repeat("a,", spreadsheet.MaxColumns) + "a\n")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := spreadsheet.Read(test.format, test.content); err != spreadsheet.ErrTooLarge {
+				t.Fatalf("got %v, want %v", err, spreadsheet.ErrTooLarge)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Number is a decimal number such as a price, written to XLSX files as a number cell so it can be summed
+type Number string
+
+// Writer writes rows to a file one by one, so a file of any length is never held in memory
+type Writer interface {
+	// Write writes a row of strings, Numbers and bools
+	Write(row []interface{}) error
+	// Close finishes the file, an XLSX file cannot be opened before it is closed
+	Close() error
+}
+
+// NewWriter starts a file of the format on w
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case CSV:
+		return &csvWriter{writer: csv.NewWriter(w)}, nil
+	case XLSX:
+		writer, err := newXLSXWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return writer, nil
+	}
+	return nil, ErrFormat
+}
+
+// ContentType returns the media type of files of the format
+func ContentType(format Format) string {
+	if format == XLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+type csvWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvWriter) Write(row []interface{}) error {
+	record := make([]string, len(row))
+	for i, cell := range row {
+		switch value := cell.(type) {
+		case string:
+			record[i] = csvText(value)
+		case Number:
+			record[i] = string(value)
+		case bool:
+			record[i] = strconv.FormatBool(value)
+		default:
+			record[i] = fmt.Sprint(value)
+		}
+	}
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// csvText keeps spreadsheet programs from running text that looks like a formula when they open the file
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// The parts of a workbook with a single sheet, which is streamed after them
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxPackageRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookPart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRelationships = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxWriter writes text as inline strings, so no shared strings table has to be built before the sheet is written
+type xlsxWriter struct {
+	archive *zip.Writer
+	sheet   io.Writer
+	rows    int
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+	for _, part := range []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxPackageRelationships},
+		{"xl/workbook.xml", xlsxWorkbookPart},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRelationships},
+	} {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xlsxSheetStart); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{archive: archive, sheet: sheet}, nil
+}
+
+func (w *xlsxWriter) Write(row []interface{}) error {
+	w.rows++
+	var builder strings.Builder
+	fmt.Fprintf(&builder, `<row r="%d">`, w.rows)
+	for column, cell := range row {
+		reference := columnName(column) + strconv.Itoa(w.rows)
+		switch value := cell.(type) {
+		case Number:
+			fmt.Fprintf(&builder, `<c r="%s"><v>%s</v></c>`, reference, xmlText(string(value)))
+		case bool:
+			flag := 0
+			if value {
+				flag = 1
+			}
+			fmt.Fprintf(&builder, `<c r="%s" t="b"><v>%d</v></c>`, reference, flag)
+		default:
+			text := fmt.Sprint(value)
+			if text == "" {
+				continue
+			}
+			fmt.Fprintf(&builder, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, reference, xmlText(text))
+		}
+	}
+	builder.WriteString("</row>")
+	_, err := io.WriteString(w.sheet, builder.String())
+	return err
+}
+
+func (w *xlsxWriter) Close() error {
+	if _, err := io.WriteString(w.sheet, xlsxSheetEnd); err != nil {
+		return err
+	}
+	return w.archive.Close()
+}
+
+func xmlText(value string) string {
+	var builder strings.Builder
+	xml.EscapeText(&builder, []byte(value))
+	return builder.String()
+}
+
+// columnName returns the letters of the zero based column, the inverse of columnIndex
+func columnName(column int) string {
+	name := ""
+	for column >= 0 {
+		name = string(rune('A'+column%26)) + name
+		column = column/26 - 1
+	}
+	return name
+}
@@ -0,0 +1,165 @@
+// Package tax works out the tax of priced lines per tax rate, for prices that either include or exclude the tax
+package tax
+
+import (
+	"math/big"
+	"poosible-backend/money"
+)
+
+// Rate is what a line is taxed at. Lines with the same key are taxed together.
+type Rate struct {
+	Key     string  `json:"tax_rate_id,omitempty" bson:"tax_rate_id,omitempty"`
+	Name    string  `json:"name" bson:"name"`
+	Percent float64 `json:"percent" bson:"percent"`
+}
+
+// Line is an amount to be taxed, which is the net amount if prices exclude tax and the gross amount if they include it
+type Line struct {
+	Amount money.Money
+	Rate   Rate
+}
+
+// LineTax is the split of a line amount into net amount and tax
+type LineTax struct {
+	Net   money.Money `json:"net" bson:"net"`
+	Tax   money.Money `json:"tax" bson:"tax"`
+	Gross money.Money `json:"gross" bson:"gross"`
+}
+
+// RateTotal sums up the lines taxed at one rate
+type RateTotal struct {
+	Rate  `bson:",inline"`
+	Net   money.Money `json:"net" bson:"net"`
+	Tax   money.Money `json:"tax" bson:"tax"`
+	Gross money.Money `json:"gross" bson:"gross"`
+}
+
+// Breakdown is the result of a calculation, with the lines in the order they were given and the rates in the order
+// they first appeared
+type Breakdown struct {
+	Lines []LineTax
+	Rates []RateTotal
+	Net   money.Money
+	Tax   money.Money
+	Gross money.Money
+}
+
+// Calculate works out the tax of the lines. The tax of each rate is rounded half to even once on the sum of its lines
+// and then allocated to the lines in proportion to their amounts, so line taxes always add up to the rate totals and
+// the totals carry no accumulated rounding error. Rates with lines of opposite signs, such as a refunded part next to
+// a sold one, are rounded per line instead.
+func Calculate(currency string, lines []Line, inclusive bool) (*Breakdown, error) {
+	breakdown := &Breakdown{
+		Lines: make([]LineTax, len(lines)),
+		Rates: []RateTotal{},
+		Net:   money.Zero(currency),
+		Tax:   money.Zero(currency),
+		Gross: money.Zero(currency),
+	}
+
+	//Group the lines by rate, keeping the order the rates first appear in
+	var rates []Rate
+	groups := map[Rate][]int{}
+	for i, line := range lines {
+		if line.Amount.Currency != currency {
+			return nil, money.ErrCurrencyMismatch
+		}
+		if _, ok := groups[line.Rate]; !ok {
+			rates = append(rates, line.Rate)
+		}
+		groups[line.Rate] = append(groups[line.Rate], i)
+	}
+
+	for _, rate := range rates {
+		indexes := groups[rate]
+		taxes, err := rateTaxes(currency, lines, indexes, inclusive)
+		if err != nil {
+			return nil, err
+		}
+
+		total := RateTotal{Rate: rate, Net: money.Zero(currency), Tax: money.Zero(currency), Gross: money.Zero(currency)}
+		for n, i := range indexes {
+			lineTax := LineTax{Tax: taxes[n]}
+			if inclusive {
+				lineTax.Gross = lines[i].Amount
+				lineTax.Net, err = lineTax.Gross.Sub(lineTax.Tax)
+			} else {
+				lineTax.Net = lines[i].Amount
+				lineTax.Gross, err = lineTax.Net.Add(lineTax.Tax)
+			}
+			if err != nil {
+				return nil, err
+			}
+			breakdown.Lines[i] = lineTax
+
+			if err := addLineTax(&total.Net, &total.Tax, &total.Gross, lineTax); err != nil {
+				return nil, err
+			}
+			if err := addLineTax(&breakdown.Net, &breakdown.Tax, &breakdown.Gross, lineTax); err != nil {
+				return nil, err
+			}
+		}
+		breakdown.Rates = append(breakdown.Rates, total)
+	}
+	return breakdown, nil
+}
+
+// rateTaxes returns the tax of each of the lines taxed at one rate
+func rateTaxes(currency string, lines []Line, indexes []int, inclusive bool) ([]money.Money, error) {
+	rate := lines[indexes[0]].Rate
+	factor := money.Percent(rate.Percent)
+	if inclusive {
+		//The tax share of a gross amount is p / (100 + p)
+		percent := money.Ratio(rate.Percent)
+		factor = new(big.Rat).Quo(percent, new(big.Rat).Add(percent, big.NewRat(100, 1)))
+	}
+
+	base := money.Zero(currency)
+	positive, negative := false, false
+	ratios := make([]int64, len(indexes))
+	for n, i := range indexes {
+		amount := lines[i].Amount
+		var err error
+		if base, err = base.Add(amount); err != nil {
+			return nil, err
+		}
+		positive = positive || amount.Amount > 0
+		negative = negative || amount.Amount < 0
+		ratios[n] = amount.Amount
+		if ratios[n] < 0 {
+			ratios[n] = -ratios[n]
+		}
+	}
+
+	if positive && negative {
+		taxes := make([]money.Money, len(indexes))
+		for n, i := range indexes {
+			var err error
+			if taxes[n], err = lines[i].Amount.Mul(factor); err != nil {
+				return nil, err
+			}
+		}
+		return taxes, nil
+	}
+
+	tax, err := base.Mul(factor)
+	if err != nil {
+		return nil, err
+	}
+	if base.Amount == 0 {
+		return tax.Split(len(indexes))
+	}
+	return tax.Allocate(ratios...)
+}
+
+func addLineTax(net, tax, gross *money.Money, lineTax LineTax) error {
+	var err error
+	if *net, err = net.Add(lineTax.Net); err != nil {
+		return err
+	}
+	if *tax, err = tax.Add(lineTax.Tax); err != nil {
+		return err
+	}
+	*gross, err = gross.Add(lineTax.Gross)
+	return err
+}
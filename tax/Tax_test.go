@@ -0,0 +1,147 @@
+package tax_test
+
+import (
+	"errors"
+	"poosible-backend/money"
+	"poosible-backend/tax"
+	"reflect"
+	"testing"
+)
+
+var (
+	standard = tax.Rate{Key: "standard", Name: "VAT", Percent: 25}
+	reduced  = tax.Rate{Key: "reduced", Name: "Reduced VAT", Percent: 13}
+	swiss    = tax.Rate{Key: "swiss", Name: "MWST", Percent: 7.7}
+	none     = tax.Rate{Key: "none", Name: "Exempt", Percent: 0}
+)
+
+type line struct {
+	amount int64
+	rate   tax.Rate
+}
+
+type rateTotal struct {
+	key             string
+	net, tax, gross int64
+}
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		name      string
+		inclusive bool
+		lines     []line
+		taxes     []int64
+		rates     []rateTotal
+	}{
+		{"exclusive", false,
+			[]line{{1000, standard}},
+			[]int64{250},
+			[]rateTotal{{"standard", 1000, 250, 1250}}},
+		{"inclusive", true,
+			[]line{{1250, standard}},
+			[]int64{250},
+			[]rateTotal{{"standard", 1000, 250, 1250}}},
+		{"inclusive rounds the tax share of the gross amount", true,
+			[]line{{100, tax.Rate{Key: "vat", Name: "VAT", Percent: 19}}},
+			[]int64{16},
+			[]rateTotal{{"vat", 84, 16, 100}}},
+		{"fractional percentages are exact", false,
+			[]line{{1000, swiss}},
+			[]int64{77},
+			[]rateTotal{{"swiss", 1000, 77, 1077}}},
+		{"inclusive fractional percentages are exact", true,
+			[]line{{1077, swiss}},
+			[]int64{77},
+			[]rateTotal{{"swiss", 1000, 77, 1077}}},
+		{"exempt", false,
+			[]line{{1000, none}},
+			[]int64{0},
+			[]rateTotal{{"none", 1000, 0, 1000}}},
+		{"ties round down to even", false,
+			[]line{{50, standard}},
+			[]int64{12},
+			[]rateTotal{{"standard", 50, 12, 62}}},
+		{"ties round up to even", false,
+			[]line{{70, standard}},
+			[]int64{18},
+			[]rateTotal{{"standard", 70, 18, 88}}},
+		//Rounding each line would give 83 three times, 249 in all
+		{"rate rounded once on the sum of its lines", false,
+			[]line{{333, standard}, {333, standard}, {333, standard}},
+			[]int64{84, 83, 83},
+			[]rateTotal{{"standard", 999, 250, 1249}}},
+		{"inclusive rate rounded once on the sum of its lines", true,
+			[]line{{333, standard}, {333, standard}, {333, standard}},
+			[]int64{67, 67, 66},
+			[]rateTotal{{"standard", 799, 200, 999}}},
+		{"tax allocated in proportion to the lines", false,
+			[]line{{1000, standard}, {201, standard}},
+			[]int64{250, 50},
+			[]rateTotal{{"standard", 1201, 300, 1501}}},
+		{"tie of the rate split between lines", false,
+			[]line{{25, standard}, {25, standard}},
+			[]int64{6, 6},
+			[]rateTotal{{"standard", 50, 12, 62}}},
+		{"rates in the order they first appear", false,
+			[]line{{1000, standard}, {500, reduced}, {200, standard}},
+			[]int64{250, 65, 50},
+			[]rateTotal{{"standard", 1200, 300, 1500}, {"reduced", 500, 65, 565}}},
+		{"credited lines", false,
+			[]line{{-333, standard}, {-333, standard}, {-333, standard}},
+			[]int64{-84, -83, -83},
+			[]rateTotal{{"standard", -999, -250, -1249}}},
+		//Rounding the sum of 20 would give 5
+		{"lines of opposite signs rounded per line", false,
+			[]line{{70, standard}, {-50, standard}},
+			[]int64{18, -12},
+			[]rateTotal{{"standard", 20, 6, 26}}},
+		{"lines of nothing", false,
+			[]line{{0, standard}, {0, standard}},
+			[]int64{0, 0},
+			[]rateTotal{{"standard", 0, 0, 0}}},
+	}
+	for _, test := range tests {
+		lines := make([]tax.Line, len(test.lines))
+		for i, line := range test.lines {
+			lines[i] = tax.Line{Amount: money.New(line.amount, "EUR"), Rate: line.rate}
+		}
+		breakdown, err := tax.Calculate("EUR", lines, test.inclusive)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		taxes := make([]int64, len(breakdown.Lines))
+		for i, lineTax := range breakdown.Lines {
+			taxes[i] = lineTax.Tax.Amount
+			if lineTax.Net.Amount+lineTax.Tax.Amount != lineTax.Gross.Amount {
+				t.Errorf("%s: line %d nets %v and taxes %v to %v", test.name, i, lineTax.Net, lineTax.Tax, lineTax.Gross)
+			}
+			if amount := test.lines[i].amount; (test.inclusive && lineTax.Gross.Amount != amount) || (!test.inclusive && lineTax.Net.Amount != amount) {
+				t.Errorf("%s: line %d of %d was split into %v and %v", test.name, i, amount, lineTax.Net, lineTax.Tax)
+			}
+		}
+		if !reflect.DeepEqual(taxes, test.taxes) {
+			t.Errorf("%s: line taxes are %v, want %v", test.name, taxes, test.taxes)
+		}
+
+		rates := make([]rateTotal, len(breakdown.Rates))
+		var net, tax, gross int64
+		for i, rate := range breakdown.Rates {
+			rates[i] = rateTotal{rate.Key, rate.Net.Amount, rate.Tax.Amount, rate.Gross.Amount}
+			net, tax, gross = net+rate.Net.Amount, tax+rate.Tax.Amount, gross+rate.Gross.Amount
+		}
+		if !reflect.DeepEqual(rates, test.rates) {
+			t.Errorf("%s: rate totals are %v, want %v", test.name, rates, test.rates)
+		}
+		if breakdown.Net.Amount != net || breakdown.Tax.Amount != tax || breakdown.Gross.Amount != gross {
+			t.Errorf("%s: totals %v, %v and %v are not the sum of the rates", test.name, breakdown.Net, breakdown.Tax, breakdown.Gross)
+		}
+	}
+}
+
+func TestCalculateRefusesOtherCurrencies(t *testing.T) {
+	lines := []tax.Line{{Amount: money.New(1000, "EUR"), Rate: standard}, {Amount: money.New(1000, "USD"), Rate: standard}}
+	if _, err := tax.Calculate("EUR", lines, false); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Fatalf("calculating lines in two currencies answered %v", err)
+	}
+}
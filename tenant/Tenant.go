@@ -0,0 +1,46 @@
+package tenant
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"poosible-backend/models"
+)
+
+const contextKey = "tenant"
+
+var ErrNoOrganisation = errors.New("user does not belong to an organisation")
+
+// Tenant is the caller of a request together with the organisation all of their queries are scoped to
+type Tenant struct {
+	User           *models.User
+	OrganisationID *primitive.ObjectID
+	Permissions    []models.Permission
+}
+
+// Set stores the tenant resolved for the request
+func Set(c *gin.Context, t *Tenant) {
+	c.Set(contextKey, t)
+}
+
+// Get returns the tenant resolved for the request, or nil outside of the protected routes
+func Get(c *gin.Context) *Tenant {
+	t, exists := c.Get(contextKey)
+	if !exists {
+		return nil
+	}
+	return t.(*Tenant)
+}
+
+// Owns reports whether the organisation with the given ID is the organisation of the tenant
+func (t *Tenant) Owns(organisationID primitive.ObjectID) bool {
+	return t.OrganisationID != nil && *t.OrganisationID == organisationID
+}
+
+// Organisation returns the ID every repository call of the tenant has to be scoped to
+func (t *Tenant) Organisation() (primitive.ObjectID, error) {
+	if t.OrganisationID == nil {
+		return primitive.NilObjectID, ErrNoOrganisation
+	}
+	return *t.OrganisationID, nil
+}
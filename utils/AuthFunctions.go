@@ -1,49 +1,30 @@
 package utils
 
 import (
-	"fmt"
-	"github.com/dgrijalva/jwt-go"
-	"github.com/gin-gonic/gin"
-	"net/http"
-	"poosible-backend/responses"
-	"strings"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 )
 
-func GetClaims(c *gin.Context) (jwt.MapClaims, error) {
-	tokenString := c.GetHeader("Authorization")
-	if tokenString == "" {
-		c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": "Invalid token"}})
-		return nil, nil
-	}
-
-	//remove the Bearer prefix from the token
-	tokenString = strings.Replace(tokenString, "Bearer ", "", 1)
-
-	// Parse and validate the token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		// Make sure that the signing method is HMAC
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-
-		// Return the secret key
-		return []byte("secret_key"), nil
-	})
-	if err != nil {
-		c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": err.Error()}})
-		return nil, err
-	}
-
-	if !token.Valid {
-		c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": "Invalid token"}})
-		return nil, err
-	}
+// HashRefreshToken returns the hex encoded SHA-256 digest under which a refresh token is stored
+func HashRefreshToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		c.JSON(http.StatusBadRequest, responses.UserResponse{Status: http.StatusBadRequest, Message: "Invalid token", Data: map[string]interface{}{"data": "Invalid token claims"}})
-		return nil, err
+// NewLinkToken returns an unguessable token for a public link together with the digest it is stored under
+func NewLinkToken() (string, string, error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", "", err
 	}
+	token := base64.RawURLEncoding.EncodeToString(data)
+	return token, HashLinkToken(token), nil
+}
 
-	return claims, nil
+// HashLinkToken returns the hex encoded SHA-256 digest under which the token of a public link is stored
+func HashLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidETag = errors.New("invalid entity tag")
+
+// ETag returns the entity tag of a document at the given version
+func ETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ParseIfMatch returns the version an If-Match header refers to. wildcard is true for "*".
+func ParseIfMatch(header string) (version int, wildcard bool, err error) {
+	header = strings.TrimSpace(header)
+	if header == "*" {
+		return 0, true, nil
+	}
+
+	//Weak tags are accepted as the version is the only thing compared
+	header = strings.TrimPrefix(header, "W/")
+	unquoted, err := strconv.Unquote(header)
+	if err != nil {
+		return 0, false, ErrInvalidETag
+	}
+	version, err = strconv.Atoi(unquoted)
+	if err != nil || version < 0 {
+		return 0, false, ErrInvalidETag
+	}
+	return version, false, nil
+}
@@ -0,0 +1,167 @@
+// Package vin decodes 17 character vehicle identification numbers (ISO 3779) offline, using tables embedded in the
+// binary.
+package vin
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLength     = errors.New("a VIN has 17 characters")
+	ErrCharacter  = errors.New("a VIN contains only digits and the letters A to Z except I, O and Q")
+	ErrCheckDigit = errors.New("VIN check digit does not match")
+)
+
+//go:embed wmi.csv
+var wmiTable string
+
+//go:embed regions.csv
+var regionTable string
+
+var manufacturers = readManufacturers()
+var regions = readRegions()
+
+// transliteration is the value of each character in the check digit calculation
+var transliteration = map[rune]int{
+	'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+	'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+	'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+	'0': 0, '1': 1, '2': 2, '3': 3, '4': 4, '5': 5, '6': 6, '7': 7, '8': 8, '9': 9,
+}
+
+// weights is the weight of each position in the check digit calculation, the check digit itself weighs nothing
+var weights = [17]int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+// yearCodes are the model year codes of position 10, starting at 1980 and repeating every 30 years
+const yearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+
+const northAmerica = "North America"
+
+type Decoded struct {
+	VIN          string `json:"vin" example:"1HGCM82633A004352"`
+	WMI          string `json:"wmi" example:"1HG"`
+	Manufacturer string `json:"manufacturer" example:"Honda"`
+	Region       string `json:"region" example:"North America"`
+	ModelYear    int    `json:"model_year,omitempty" example:"2003"`
+	// CheckDigitValid is false for VINs from regions that do not use the check digit and whose ninth character does not happen to match
+	CheckDigitValid bool `json:"check_digit_valid" example:"true"`
+}
+
+// Normalize upper-cases the VIN and removes the spaces and dashes people type into it
+func Normalize(vin string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(vin)))
+}
+
+// CheckDigit returns the check digit the ninth character of the VIN should hold
+func CheckDigit(vin string) (byte, error) {
+	if len(vin) != 17 {
+		return 0, ErrLength
+	}
+
+	sum := 0
+	for i, r := range vin {
+		value, ok := transliteration[r]
+		if !ok {
+			return 0, ErrCharacter
+		}
+		sum += value * weights[i]
+	}
+
+	remainder := sum % 11
+	if remainder == 10 {
+		return 'X', nil
+	}
+	return byte('0' + remainder), nil
+}
+
+// Decode validates the VIN and derives its manufacturer, region and model year. The check digit is mandatory for
+// North American VINs, other regions do not require it, so a mismatch is only reported in the result.
+func Decode(vin string) (*Decoded, error) {
+	vin = Normalize(vin)
+	checkDigit, err := CheckDigit(vin)
+	if err != nil {
+		return nil, err
+	}
+
+	decoded := &Decoded{
+		VIN:             vin,
+		WMI:             vin[:3],
+		Manufacturer:    manufacturers[vin[:3]],
+		Region:          region(vin[0]),
+		CheckDigitValid: vin[8] == checkDigit,
+	}
+	if decoded.Region == northAmerica && !decoded.CheckDigitValid {
+		return nil, ErrCheckDigit
+	}
+	decoded.ModelYear = modelYear(vin, decoded.Region == northAmerica, time.Now().Year())
+	return decoded, nil
+}
+
+// modelYear reads the model year from position 10. The code repeats every 30 years: in North America the seventh
+// character is a letter from 2010 on, elsewhere the most recent year that is not after next year is assumed.
+func modelYear(vin string, northAmerican bool, currentYear int) int {
+	index := strings.IndexByte(yearCodes, vin[9])
+	if index < 0 {
+		return 0
+	}
+	year := 1980 + index
+
+	if northAmerican {
+		if vin[6] >= 'A' && vin[6] <= 'Z' {
+			year += 30
+		}
+		return year
+	}
+	for year+30 <= currentYear+1 {
+		year += 30
+	}
+	return year
+}
+
+func region(first byte) string {
+	for _, r := range regions {
+		if first >= r.from && first <= r.to {
+			return r.name
+		}
+	}
+	return ""
+}
+
+type regionRange struct {
+	from, to byte
+	name     string
+}
+
+func readManufacturers() map[string]string {
+	result := map[string]string{}
+	for _, record := range readTable(wmiTable) {
+		result[record[0]] = record[1]
+	}
+	return result
+}
+
+func readRegions() []regionRange {
+	var result []regionRange
+	for _, record := range readTable(regionTable) {
+		result = append(result, regionRange{from: record[0][0], to: record[1][0], name: record[2]})
+	}
+	return result
+}
+
+// readTable parses an embedded CSV table without its header row. The tables are part of the source, so a broken one is a programming error.
+func readTable(table string) [][]string {
+	records, err := csv.NewReader(strings.NewReader(table)).ReadAll()
+	if err != nil {
+		panic("vin: invalid embedded table: " + err.Error())
+	}
+	return records[1:]
+}